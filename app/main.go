@@ -1,77 +1,71 @@
+//go:build appengine
+// +build appengine
+
 package main
 
 import (
+	"context"
 	"net/http"
+	netmail "net/mail"
 
-	"github.com/gorilla/mux"
+	"github.com/house-emoji/bhap"
 	"github.com/house-emoji/bhap/email"
-	"github.com/house-emoji/bhap/pages"
+	"github.com/house-emoji/bhap/gaestore"
+	"github.com/house-emoji/bhap/log"
 	"google.golang.org/appengine"
+	aelog "google.golang.org/appengine/log"
+	"google.golang.org/appengine/mail"
 )
 
 func main() {
-	r := mux.NewRouter()
+	bhap.SetContextFunc(appengine.NewContext)
+	log.SetLogger(appEngineLogger{})
+	email.Mailer = appEngineSender{}
+	bhap.SetSigner(gaestore.NewSigner())
 
-	r.HandleFunc("/", pages.ServeListPage)
-	r.HandleFunc("/bhap", pages.ServeListPage)
+	if err := loadSettings(defaultSettingsFile); err != nil {
+		panic(err)
+	}
 
-	r.HandleFunc("/bhap/{id}", pages.ServeBHAPPage).
-		Methods("GET")
-	r.HandleFunc("/draft/{draftID}", pages.ServeBHAPPage).
-		Methods("GET")
+	http.Handle("/", newRouter(gaestore.New()))
 
-	r.HandleFunc("/draft/{draftID}/edit", pages.ServeEditPage).
-		Methods("GET")
-	r.HandleFunc("/bhap/{id}/edit", pages.ServeEditPage).
-		Methods("GET")
-	r.HandleFunc("/draft/{draftID}/edit",
-		pages.SetUpBHAPOperator(pages.HandleEdit)).
-		Methods("POST")
-	r.HandleFunc("/bhap/{id}/edit",
-		pages.SetUpBHAPOperator(pages.HandleEdit)).
-		Methods("POST")
+	appengine.Main()
+}
 
-	r.HandleFunc("/bhap/{id}/ready-for-discussion",
-		pages.SetUpBHAPOperator(pages.HandleReadyForDiscussion)).
-		Methods("POST")
-	r.HandleFunc("/bhap/{id}/delete-vote",
-		pages.SetUpBHAPOperator(pages.HandleDeleteVote)).
-		Methods("GET")
-	r.HandleFunc("/bhap/{id}/vote-accept",
-		pages.SetUpBHAPOperator(pages.HandleVoteAccept)).
-		Methods("POST")
-	r.HandleFunc("/bhap/{id}/vote-reject",
-		pages.SetUpBHAPOperator(pages.HandleVoteReject)).
-		Methods("POST")
-	r.HandleFunc("/bhap/{id}/withdraw",
-		pages.SetUpBHAPOperator(pages.HandleWithdraw)).
-		Methods("POST")
+// appEngineLogger writes to App Engine's request logs.
+type appEngineLogger struct{}
 
-	r.Handle("/propose", pages.RequireLogin(pages.ServeNewBHAPPage)).
-		Methods("GET")
-	r.HandleFunc("/propose", pages.HandleNewBHAPForm).
-		Methods("POST")
+func (appEngineLogger) Debugf(ctx context.Context, format string, args ...interface{}) {
+	aelog.Debugf(ctx, format, args...)
+}
 
-	r.HandleFunc("/login", pages.ServeLoginPage).
-		Methods("GET")
-	r.HandleFunc("/login", pages.HandleLoginForm).
-		Methods("POST")
-	r.Handle("/logout", pages.RequireLogin(pages.HandleLogoutForm)).
-		Methods("GET")
+func (appEngineLogger) Infof(ctx context.Context, format string, args ...interface{}) {
+	aelog.Infof(ctx, format, args...)
+}
 
-	r.HandleFunc("/invite", pages.ServeInvitePage).
-		Methods("GET")
-	r.HandleFunc("/invite", pages.HandleInvitationForm).
-		Methods("POST")
+func (appEngineLogger) Warningf(ctx context.Context, format string, args ...interface{}) {
+	aelog.Warningf(ctx, format, args...)
+}
 
-	r.HandleFunc("/new-user/{uid}", pages.ServeNewUserPage).
-		Methods("GET")
-	r.HandleFunc("/new-user/{uid}", pages.HandleNewUserForm).
-		Methods("POST")
+func (appEngineLogger) Errorf(ctx context.Context, format string, args ...interface{}) {
+	aelog.Errorf(ctx, format, args...)
+}
 
-	r.HandleFunc("/tasks/send-invitations", email.SendInvitations)
+// appEngineSender sends email through App Engine's mail service.
+type appEngineSender struct{}
 
-	http.Handle("/", r)
+func (appEngineSender) Send(ctx context.Context, msg *email.Message) error {
+	m := &mail.Message{
+		Sender:  msg.Sender,
+		To:      msg.To,
+		Subject: msg.Subject,
+		Body:    msg.Body,
+	}
+	if msg.UnsubscribeURL != "" {
+		m.Headers = netmail.Header{
+			"List-Unsubscribe": {"<" + msg.UnsubscribeURL + ">"},
+		}
+	}
 
-	appengine.Main()
+	return mail.Send(ctx, m)
 }
@@ -0,0 +1,208 @@
+//go:build !appengine
+// +build !appengine
+
+// When built without App Engine, the site runs as a standalone HTTP server.
+// It must be started from this directory so that templates and static files
+// can be found.
+//
+// App Engine's cron service isn't available, so the tasks in cron.yaml should
+// be run by requesting their URLs, for example with curl in a crontab. With
+// -admin-token set, give the token as the password, like
+// "curl -u admin:TOKEN"; otherwise, request them from the same machine.
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+	"github.com/house-emoji/bhap"
+	"github.com/house-emoji/bhap/email"
+	"github.com/house-emoji/bhap/memstore"
+	"github.com/house-emoji/bhap/sqlstore"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var (
+	addr = flag.String("addr", ":8080",
+		"address to listen on")
+	dbDriver = flag.String("db", "",
+		`database driver to use, either "sqlite3" or "postgres". If empty, `+
+			`data is kept in memory and lost on exit`)
+	dsn = flag.String("dsn", "bhap.db",
+		"data source name to pass to the database driver")
+	sessionKey = flag.String("session-key", "",
+		"secret key for signing session cookies. If empty, a random key is "+
+			"used and everyone is logged out on restart")
+	signingKey = flag.String("signing-key", "",
+		"secret key for signing links in emails, like unsubscribe links. It "+
+			"should differ from the session key. If empty, a random key is "+
+			"used and links in emails already sent stop working on restart")
+	smtpAddr = flag.String("smtp-addr", "localhost:25",
+		"address of the SMTP server to send mail through")
+	smtpUser = flag.String("smtp-user", "",
+		"username to authenticate with the SMTP server. If empty, no "+
+			"authentication is done")
+	smtpPassword = flag.String("smtp-password", "",
+		"password to authenticate with the SMTP server")
+	settingsFile = flag.String("settings", defaultSettingsFile,
+		"JSON file to read settings from. If it doesn't exist, the defaults "+
+			"are used")
+	adminToken = flag.String("admin-token", "",
+		"secret that must be given as the password, with any username, to "+
+			"run tasks and invite members. If empty, those are only served "+
+			"to requests from this machine, which includes every request "+
+			"passed on by a reverse proxy running here")
+	shutdownTimeout = flag.Duration("shutdown-timeout", 10*time.Second,
+		"how long to wait for requests to finish when shutting down")
+)
+
+// adminPaths are the paths that app.yaml restricts to admins. Without App
+// Engine's login service, they are only served to requests that give the
+// admin token, or from this machine if there is none.
+var adminPaths = []string{"/tasks/", "/invite"}
+
+func main() {
+	flag.Parse()
+
+	key := []byte(*sessionKey)
+	if len(key) == 0 {
+		log.Printf("no session key provided, generating one")
+		key = securecookie.GenerateRandomKey(32)
+	}
+
+	if *signingKey == "" {
+		log.Printf("WARNING: no signing key provided, so links in emails " +
+			"will stop working when the server restarts. Set -signing-key " +
+			"to a long random secret to keep them working")
+	} else {
+		if *signingKey == *sessionKey {
+			log.Printf("WARNING: the signing key is the same as the session " +
+				"key. Anyone who learns one can forge the other")
+		}
+		bhap.SetSigner(bhap.HMACSigner{Key: []byte(*signingKey)})
+	}
+
+	if *adminToken == "" {
+		log.Printf("WARNING: no admin token provided, so tasks and " +
+			"invitations are served to any request from this machine. " +
+			"Behind a reverse proxy on this machine, that is everyone. Set " +
+			"-admin-token to a long random secret to require it instead")
+	}
+
+	if err := loadSettings(*settingsFile); err != nil {
+		log.Fatalf("could not load settings: %v", err)
+	}
+
+	var store bhap.Store
+	if *dbDriver == "" {
+		log.Printf("no database provided, keeping data in memory")
+		store = memstore.New()
+		bhap.SetSessionStore(sessions.NewCookieStore(key))
+	} else {
+		sqlStore, err := sqlstore.Open(*dbDriver, *dsn)
+		if err != nil {
+			log.Fatalf("could not open database: %v", err)
+		}
+		defer sqlStore.Close()
+		store = sqlStore
+		bhap.SetSessionStore(sqlStore.SessionStore(key))
+	}
+
+	var auth smtp.Auth
+	if *smtpUser != "" {
+		host, _, err := net.SplitHostPort(*smtpAddr)
+		if err != nil {
+			log.Fatalf("invalid SMTP address: %v", err)
+		}
+		auth = smtp.PlainAuth("", *smtpUser, *smtpPassword, host)
+	}
+	email.Mailer = email.SMTPSender{Addr: *smtpAddr, Auth: auth}
+
+	r := newRouter(store)
+	r.PathPrefix("/static/").
+		Handler(http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
+
+	server := &http.Server{
+		Addr:    *addr,
+		Handler: restrictAdminPaths(r, *adminToken),
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Printf("listening on %v", *addr)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	select {
+	case err := <-serverErr:
+		log.Fatalf("server failed: %v", err)
+	case sig := <-stop:
+		log.Printf("received %v, shutting down", sig)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("could not shut down cleanly: %v", err)
+	}
+}
+
+// restrictAdminPaths is middleware that forbids requests to admin paths
+// unless they give the token as their basic auth password. If the token is
+// empty, requests from this machine are let through instead. Browsers ask
+// admins for the password, so the invitation page still works in one.
+func restrictAdminPaths(next http.Handler, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, prefix := range adminPaths {
+			if !strings.HasPrefix(r.URL.Path, prefix) {
+				continue
+			}
+
+			if token == "" && !isLocal(r) {
+				http.Error(w, "Only available to admins", http.StatusForbidden)
+				return
+			}
+			if token != "" && !hasToken(r, token) {
+				w.Header().Set("WWW-Authenticate", `Basic realm="BHAP admin"`)
+				http.Error(w, "Only available to admins", http.StatusUnauthorized)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// hasToken returns true if the request gives the token as its basic auth
+// password.
+func hasToken(r *http.Request, token string) bool {
+	_, password, ok := r.BasicAuth()
+	return ok && subtle.ConstantTimeCompare([]byte(password), []byte(token)) == 1
+}
+
+// isLocal returns true if the request was made from this machine.
+func isLocal(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,49 @@
+//go:build !appengine
+// +build !appengine
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRestrictAdminPaths(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		name       string
+		token      string
+		path       string
+		remoteAddr string
+		password   string
+		want       int
+	}{
+		{"not an admin path", "", "/bhaps", "203.0.113.1:1234", "", http.StatusOK},
+		{"local, no token set", "", "/tasks/send-digests", "127.0.0.1:1234", "", http.StatusOK},
+		{"remote, no token set", "", "/tasks/send-digests", "203.0.113.1:1234", "", http.StatusForbidden},
+		{"remote invite, no token set", "", "/invite", "203.0.113.1:1234", "", http.StatusForbidden},
+		// Behind a reverse proxy, every request looks local
+		{"local without token", "secret", "/tasks/send-digests", "127.0.0.1:1234", "", http.StatusUnauthorized},
+		{"wrong token", "secret", "/invite", "127.0.0.1:1234", "guess", http.StatusUnauthorized},
+		{"remote with token", "secret", "/tasks/send-digests", "203.0.113.1:1234", "secret", http.StatusOK},
+		{"not an admin path, token set", "secret", "/bhaps", "203.0.113.1:1234", "", http.StatusOK},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", test.path, nil)
+		r.RemoteAddr = test.remoteAddr
+		if test.password != "" {
+			r.SetBasicAuth("admin", test.password)
+		}
+		w := httptest.NewRecorder()
+		restrictAdminPaths(ok, test.token).ServeHTTP(w, r)
+
+		if w.Code != test.want {
+			t.Errorf("%v: got status %v, want %v", test.name, w.Code, test.want)
+		}
+		if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%v: browsers aren't asked for the token", test.name)
+		}
+	}
+}
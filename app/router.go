@@ -0,0 +1,191 @@
+package main
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/house-emoji/bhap"
+	"github.com/house-emoji/bhap/email"
+	"github.com/house-emoji/bhap/pages"
+)
+
+// newRouter creates a router that serves the whole site, loading and saving
+// data with the given store.
+func newRouter(store bhap.Store) *mux.Router {
+	pages.Store = store
+	email.Store = store
+	bhap.SetStatusNotifier(email.QueueStatusNotices)
+
+	r := mux.NewRouter()
+
+	r.HandleFunc("/", pages.ServeListPage)
+	r.HandleFunc("/bhap", pages.ServeListPage)
+
+	r.HandleFunc("/bhap/{id}", pages.ServeBHAPPage).
+		Methods("GET")
+	r.HandleFunc("/draft/{draftID}", pages.ServeBHAPPage).
+		Methods("GET")
+	r.Handle("/bhap/{id}/audit", pages.RequireLogin(pages.ServeAuditPage)).
+		Methods("GET")
+	r.HandleFunc("/bhap/{id}/history", pages.ServeHistoryPage).
+		Methods("GET")
+	r.HandleFunc("/draft/{draftID}/history", pages.ServeHistoryPage).
+		Methods("GET")
+
+	r.HandleFunc("/bhap/{id}/comments",
+		pages.SetUpBHAPOperator(pages.HandleNewComment)).
+		Methods("POST")
+	r.HandleFunc("/draft/{draftID}/comments",
+		pages.SetUpBHAPOperator(pages.HandleNewComment)).
+		Methods("POST")
+	r.HandleFunc("/bhap/{id}/comments/{commentID}/reply",
+		pages.SetUpBHAPOperator(pages.HandleNewComment)).
+		Methods("POST")
+	r.HandleFunc("/draft/{draftID}/comments/{commentID}/reply",
+		pages.SetUpBHAPOperator(pages.HandleNewComment)).
+		Methods("POST")
+	r.HandleFunc("/bhap/{id}/comments/{commentID}/edit",
+		pages.SetUpBHAPOperator(pages.HandleEditComment)).
+		Methods("POST")
+	r.HandleFunc("/draft/{draftID}/comments/{commentID}/edit",
+		pages.SetUpBHAPOperator(pages.HandleEditComment)).
+		Methods("POST")
+	r.HandleFunc("/bhap/{id}/comments/{commentID}/delete",
+		pages.SetUpBHAPOperator(pages.HandleDeleteComment)).
+		Methods("POST")
+	r.HandleFunc("/draft/{draftID}/comments/{commentID}/delete",
+		pages.SetUpBHAPOperator(pages.HandleDeleteComment)).
+		Methods("POST")
+
+	r.HandleFunc("/bhap/{id}/coauthors/invite",
+		pages.SetUpBHAPOperator(pages.HandleInviteCoAuthor)).
+		Methods("POST")
+	r.HandleFunc("/draft/{draftID}/coauthors/invite",
+		pages.SetUpBHAPOperator(pages.HandleInviteCoAuthor)).
+		Methods("POST")
+	r.HandleFunc("/bhap/{id}/coauthors/accept",
+		pages.SetUpBHAPOperator(pages.HandleAcceptCoAuthorship)).
+		Methods("POST")
+	r.HandleFunc("/draft/{draftID}/coauthors/accept",
+		pages.SetUpBHAPOperator(pages.HandleAcceptCoAuthorship)).
+		Methods("POST")
+	r.HandleFunc("/bhap/{id}/coauthors/decline",
+		pages.SetUpBHAPOperator(pages.HandleDeclineCoAuthorship)).
+		Methods("POST")
+	r.HandleFunc("/draft/{draftID}/coauthors/decline",
+		pages.SetUpBHAPOperator(pages.HandleDeclineCoAuthorship)).
+		Methods("POST")
+	r.HandleFunc("/bhap/{id}/coauthors/remove",
+		pages.SetUpBHAPOperator(pages.HandleRemoveCoAuthor)).
+		Methods("POST")
+	r.HandleFunc("/draft/{draftID}/coauthors/remove",
+		pages.SetUpBHAPOperator(pages.HandleRemoveCoAuthor)).
+		Methods("POST")
+
+	r.HandleFunc("/draft/{draftID}/edit", pages.ServeEditPage).
+		Methods("GET")
+	r.HandleFunc("/bhap/{id}/edit", pages.ServeEditPage).
+		Methods("GET")
+	r.HandleFunc("/draft/{draftID}/edit",
+		pages.SetUpBHAPOperator(pages.HandleEdit)).
+		Methods("POST")
+	r.HandleFunc("/bhap/{id}/edit",
+		pages.SetUpBHAPOperator(pages.HandleEdit)).
+		Methods("POST")
+
+	r.HandleFunc("/draft/{draftID}/ready-for-discussion",
+		pages.SetUpBHAPOperator(pages.HandleReadyForDiscussion)).
+		Methods("POST")
+	r.HandleFunc("/bhap/{id}/delete-vote",
+		pages.SetUpBHAPOperator(pages.HandleDeleteVote)).
+		Methods("GET")
+	r.Handle("/bhap/{id}/vote-accept",
+		pages.RequireLogin(pages.SetUpBHAPOperator(pages.ServeConfirmVoteAccept))).
+		Methods("GET")
+	r.Handle("/bhap/{id}/vote-reject",
+		pages.RequireLogin(pages.SetUpBHAPOperator(pages.ServeConfirmVoteReject))).
+		Methods("GET")
+	r.HandleFunc("/bhap/{id}/vote-accept",
+		pages.SetUpBHAPOperator(pages.HandleVoteAccept)).
+		Methods("POST")
+	r.HandleFunc("/bhap/{id}/vote-reject",
+		pages.SetUpBHAPOperator(pages.HandleVoteReject)).
+		Methods("POST")
+	r.HandleFunc("/bhap/{id}/vote-abstain",
+		pages.SetUpBHAPOperator(pages.HandleVoteAbstain)).
+		Methods("POST")
+	r.HandleFunc("/bhap/{id}/withdraw",
+		pages.SetUpBHAPOperator(pages.HandleWithdraw)).
+		Methods("POST")
+	r.HandleFunc("/bhap/{id}/defer",
+		pages.SetUpBHAPOperator(pages.HandleDefer)).
+		Methods("POST")
+	r.HandleFunc("/bhap/{id}/resume",
+		pages.SetUpBHAPOperator(pages.HandleResume)).
+		Methods("POST")
+	r.HandleFunc("/bhap/{id}/retally",
+		pages.SetUpBHAPOperator(pages.HandleRetally)).
+		Methods("POST")
+	r.HandleFunc("/bhap/{id}/reopen",
+		pages.SetUpBHAPOperator(pages.HandleReopen)).
+		Methods("POST")
+
+	r.Handle("/ballot/new", pages.RequireLogin(pages.ServeNewBallotPage)).
+		Methods("GET")
+	r.HandleFunc("/ballot/new", pages.HandleNewBallotForm).
+		Methods("POST")
+	r.HandleFunc("/ballot/{uid}", pages.ServeBallotPage).
+		Methods("GET")
+	r.HandleFunc("/ballot/{uid}/rank", pages.HandleRankForm).
+		Methods("POST")
+	r.HandleFunc("/ballot/{uid}/results", pages.ServeBallotResultsPage).
+		Methods("GET")
+
+	r.Handle("/delegations", pages.RequireLogin(pages.ServeDelegationsPage)).
+		Methods("GET")
+	r.HandleFunc("/delegations", pages.HandleDelegationForm).
+		Methods("POST")
+	r.HandleFunc("/delegations/{uid}/revoke", pages.HandleRevokeDelegation).
+		Methods("POST")
+
+	r.Handle("/settings", pages.RequireLogin(pages.ServeSettingsPage)).
+		Methods("GET")
+	r.HandleFunc("/settings", pages.HandleSettingsForm).
+		Methods("POST")
+	// Mail clients that unsubscribe in one click send the POST themselves
+	r.HandleFunc("/unsubscribe/{category}", pages.ServeUnsubscribePage).
+		Methods("GET")
+	r.HandleFunc("/unsubscribe/{category}", pages.HandleUnsubscribe).
+		Methods("POST")
+	r.HandleFunc("/resubscribe/{category}", pages.HandleResubscribe).
+		Methods("POST")
+
+	r.Handle("/propose", pages.RequireLogin(pages.ServeNewBHAPPage)).
+		Methods("GET")
+	r.HandleFunc("/propose", pages.HandleNewBHAPForm).
+		Methods("POST")
+
+	r.HandleFunc("/login", pages.ServeLoginPage).
+		Methods("GET")
+	r.HandleFunc("/login", pages.HandleLoginForm).
+		Methods("POST")
+	r.Handle("/logout", pages.RequireLogin(pages.HandleLogoutForm)).
+		Methods("GET")
+
+	r.HandleFunc("/invite", pages.ServeInvitePage).
+		Methods("GET")
+	r.HandleFunc("/invite", pages.HandleInvitationForm).
+		Methods("POST")
+
+	r.HandleFunc("/new-user/{uid}", pages.ServeNewUserPage).
+		Methods("GET")
+	r.HandleFunc("/new-user/{uid}", pages.HandleNewUserForm).
+		Methods("POST")
+
+	r.HandleFunc("/tasks/send-invitations", email.SendInvitations)
+	r.HandleFunc("/tasks/send-emails", email.SendQueuedEmails)
+	r.HandleFunc("/tasks/resume-deferred", pages.HandleResumeDeferred)
+	r.HandleFunc("/tasks/close-expired-votes", pages.HandleCloseExpiredVotes)
+	r.HandleFunc("/tasks/send-vote-reminders", pages.HandleSendVoteReminders)
+	r.HandleFunc("/tasks/send-digests", pages.HandleSendDigests)
+
+	return r
+}
@@ -0,0 +1,1052 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+	"github.com/house-emoji/bhap"
+	"github.com/house-emoji/bhap/memstore"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testPassword = "secret"
+
+// newTestSite serves the whole site from a store seeded with the given
+// members.
+func newTestSite(t *testing.T, emails ...string) (*httptest.Server, *memstore.Store) {
+	ctx := context.Background()
+	store := memstore.New()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, email := range emails {
+		_, err := store.NewUser(ctx, bhap.User{
+			FirstName:    email,
+			LastName:     "Tester",
+			Email:        email,
+			PasswordHash: hash,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	bhap.SetSessionStore(sessions.NewCookieStore(securecookie.GenerateRandomKey(32)))
+
+	return httptest.NewServer(newRouter(store)), store
+}
+
+// logIn returns a client logged in as the member with the given email. It
+// doesn't follow redirects, so that they can be checked.
+func logIn(t *testing.T, srv *httptest.Server, email string) *http.Client {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{
+		Jar: jar,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp := post(t, client, srv.URL+"/login", url.Values{
+		"email":    {email},
+		"password": {testPassword},
+	})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("logging in as %v: got status %v", email, resp.StatusCode)
+	}
+
+	return client
+}
+
+func post(t *testing.T, client *http.Client, url string, form url.Values) *http.Response {
+	resp, err := client.PostForm(url, form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func get(t *testing.T, client *http.Client, url string) *http.Response {
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+// getPage fetches a page and returns its status and body.
+func getPage(t *testing.T, client *http.Client, url string) (int, string) {
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+// memberKey returns the key of the member with the given email.
+func memberKey(t *testing.T, store *memstore.Store, email string) bhap.Key {
+	_, key, err := store.UserByEmail(context.Background(), email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key == "" {
+		t.Fatalf("no member with email %v", email)
+	}
+	return key
+}
+
+func TestRouterLogin(t *testing.T) {
+	srv, _ := newTestSite(t, "alice@example.com")
+	defer srv.Close()
+
+	client := &http.Client{}
+	for _, form := range []url.Values{
+		{"email": {"alice@example.com"}, "password": {"wrong"}},
+		{"email": {"nobody@example.com"}, "password": {testPassword}},
+	} {
+		resp := post(t, client, srv.URL+"/login", form)
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("logging in as %v: got status %v, want %v",
+				form.Get("email"), resp.StatusCode, http.StatusUnauthorized)
+		}
+	}
+
+	alice := logIn(t, srv, "alice@example.com")
+	if resp := get(t, alice, srv.URL+"/"); resp.StatusCode != http.StatusOK {
+		t.Errorf("viewing BHAPs: got status %v", resp.StatusCode)
+	}
+	if resp := get(t, alice, srv.URL+"/logout"); resp.StatusCode != http.StatusSeeOther {
+		t.Errorf("logging out: got status %v", resp.StatusCode)
+	}
+}
+
+func TestRouterGrantsListedAdmins(t *testing.T) {
+	srv, store := newTestSite(t, "alice@example.com", "bob@example.com")
+	defer srv.Close()
+
+	defer bhap.SetSettings(bhap.CurrentSettings())
+	settings := bhap.DefaultSettings()
+	settings.Admins = []string{"Bob@example.com"}
+	bhap.SetSettings(settings)
+
+	logIn(t, srv, "alice@example.com")
+	logIn(t, srv, "bob@example.com")
+
+	ctx := context.Background()
+	for email, want := range map[string]bool{
+		"alice@example.com": false,
+		"bob@example.com":   true,
+	} {
+		u, _, err := store.UserByEmail(ctx, email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.Admin != want {
+			t.Errorf("%v: got admin %v after logging in, want %v", email, u.Admin, want)
+		}
+	}
+}
+
+func TestRouterLifecycle(t *testing.T) {
+	srv, store := newTestSite(t, "alice@example.com", "bob@example.com",
+		"carol@example.com")
+	defer srv.Close()
+
+	alice := logIn(t, srv, "alice@example.com")
+	bob := logIn(t, srv, "bob@example.com")
+	carol := logIn(t, srv, "carol@example.com")
+
+	resp := post(t, alice, srv.URL+"/propose", url.Values{
+		"title":            {"Quiet hours"},
+		"shortDescription": {"No noise after ten"},
+		"content":          {"Be quiet after ten.\n\nThanks."},
+	})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("proposing: got status %v", resp.StatusCode)
+	}
+	draftURL := resp.Header.Get("Location")
+
+	if resp := get(t, bob, srv.URL+draftURL); resp.StatusCode != http.StatusOK {
+		t.Fatalf("viewing draft: got status %v", resp.StatusCode)
+	}
+
+	resp = post(t, bob, srv.URL+draftURL+"/ready-for-discussion", nil)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("non-author readying draft: got status %v, want %v",
+			resp.StatusCode, http.StatusForbidden)
+	}
+
+	resp = post(t, alice, srv.URL+draftURL+"/ready-for-discussion", nil)
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("readying draft: got status %v", resp.StatusCode)
+	}
+	bhapURL := resp.Header.Get("Location")
+	if bhapURL != "/bhap/100" {
+		t.Errorf("got BHAP at %v, want /bhap/100", bhapURL)
+	}
+
+	resp = post(t, bob, srv.URL+bhapURL+"/comments", url.Values{
+		"content": {"Does this include weekends?"},
+	})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("commenting: got status %v", resp.StatusCode)
+	}
+
+	for name, client := range map[string]*http.Client{"bob": bob, "carol": carol} {
+		resp := post(t, client, srv.URL+bhapURL+"/vote-accept", nil)
+		if resp.StatusCode != http.StatusSeeOther {
+			t.Fatalf("%v voting: got status %v", name, resp.StatusCode)
+		}
+	}
+
+	accepted, _, err := store.ByID(context.Background(), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accepted.Status != bhap.AcceptedStatus {
+		t.Errorf("got status %v after everyone voted to accept, want %v",
+			accepted.Status, bhap.AcceptedStatus)
+	}
+
+	if resp := get(t, carol, srv.URL+bhapURL); resp.StatusCode != http.StatusOK {
+		t.Errorf("viewing accepted BHAP: got status %v", resp.StatusCode)
+	}
+}
+
+func TestRouterUnknownBHAP(t *testing.T) {
+	srv, _ := newTestSite(t, "alice@example.com")
+	defer srv.Close()
+
+	alice := logIn(t, srv, "alice@example.com")
+
+	for _, path := range []string{"/bhap/404", "/draft/404"} {
+		resp := get(t, alice, srv.URL+path)
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("GET %v: got status %v, want %v",
+				path, resp.StatusCode, http.StatusNotFound)
+		}
+	}
+}
+
+func TestRouterRendersWholeDocument(t *testing.T) {
+	srv, _ := newTestSite(t, "alice@example.com")
+	defer srv.Close()
+
+	alice := logIn(t, srv, "alice@example.com")
+
+	content := "See [the lease][lease].\n\n" +
+		"```\nquiet\n\nhours\n```\n\n" +
+		"- First\n\n- Second\n\n    More about the second\n\n" +
+		"[lease]: https://example.com/lease\n"
+	resp := post(t, alice, srv.URL+"/propose", url.Values{
+		"title":            {"Quiet hours"},
+		"shortDescription": {"No noise after ten"},
+		"content":          {content},
+	})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("proposing: got status %v", resp.StatusCode)
+	}
+
+	_, html := getPage(t, alice, srv.URL+resp.Header.Get("Location"))
+
+	for _, want := range []string{
+		`<a href="https://example.com/lease">the lease</a>`,
+		"<p>Second</p>\n\n<p>More about the second</p>",
+		"<code>quiet\n\nhours\n</code>",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("page doesn't contain %q", want)
+		}
+	}
+	if n := strings.Count(html, "<ul>"); n != 1 {
+		t.Errorf("got %v lists, want the loose list to stay whole", n)
+	}
+	if n := strings.Count(html, `class="content-paragraph"`); n != 3 {
+		t.Errorf("got %v blocks to comment on, want 3", n)
+	}
+}
+
+func TestRouterHistory(t *testing.T) {
+	srv, _ := newTestSite(t, "alice@example.com", "bob@example.com")
+	defer srv.Close()
+
+	alice := logIn(t, srv, "alice@example.com")
+	bob := logIn(t, srv, "bob@example.com")
+
+	resp := post(t, alice, srv.URL+"/propose", url.Values{
+		"title":            {"Quiet hours"},
+		"shortDescription": {"No noise after ten"},
+		"content":          {"Be quiet after ten.\n\nThanks."},
+	})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("proposing: got status %v", resp.StatusCode)
+	}
+	draftURL := resp.Header.Get("Location")
+
+	for _, edit := range []url.Values{
+		{"content": {"Be quiet after nine.\n\nThanks."}, "summary": {"Earlier"}},
+		{"content": {"Be quiet after nine.\n\nThanks.\n\nReally."}},
+	} {
+		edit.Set("title", "Quiet hours")
+		edit.Set("shortDescription", "No noise after ten")
+		resp := post(t, bob, srv.URL+draftURL+"/edit", edit)
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("non-author editing: got status %v, want %v",
+				resp.StatusCode, http.StatusForbidden)
+		}
+		resp = post(t, alice, srv.URL+draftURL+"/edit", edit)
+		if resp.StatusCode != http.StatusSeeOther {
+			t.Fatalf("editing: got status %v", resp.StatusCode)
+		}
+	}
+
+	tests := []struct {
+		query  string
+		status int
+		want   []string
+	}{
+		// The latest revision is compared against the one before it
+		{"", http.StatusOK, []string{
+			"Revision #2 → Revision #3",
+			`<tr class="added">
+        <td class="line-number"></td>
+        <td class="left"></td>
+        <td class="line-number">4</td>
+        <td class="right"></td>`,
+			`<td class="right">Really.</td>`,
+		}},
+		{"?from=1&to=2", http.StatusOK, []string{
+			"Revision #1 → Revision #2",
+			`<tr class="changed">
+        <td class="line-number">1</td>
+        <td class="left">Be quiet after ten.</td>
+        <td class="line-number">1</td>
+        <td class="right">Be quiet after nine.</td>`,
+			`<tr class="same">
+        <td class="line-number">3</td>
+        <td class="left">Thanks.</td>`,
+			"<td>Earlier</td>",
+		}},
+		{"?from=2&to=2", http.StatusOK, []string{"These revisions are the same."}},
+		{"?from=0", http.StatusBadRequest, nil},
+		{"?to=4", http.StatusBadRequest, nil},
+		{"?from=latest", http.StatusBadRequest, nil},
+	}
+	for _, test := range tests {
+		status, html := getPage(t, bob, srv.URL+draftURL+"/history"+test.query)
+		if status != test.status {
+			t.Errorf("history%v: got status %v, want %v", test.query, status, test.status)
+			continue
+		}
+		for _, want := range test.want {
+			if !strings.Contains(html, want) {
+				t.Errorf("history%v doesn't contain %q", test.query, want)
+			}
+		}
+	}
+}
+
+func TestRouterInlineComments(t *testing.T) {
+	srv, store := newTestSite(t, "alice@example.com", "bob@example.com")
+	defer srv.Close()
+
+	alice := logIn(t, srv, "alice@example.com")
+	bob := logIn(t, srv, "bob@example.com")
+
+	// Two blocks: a paragraph of two lines, then a loose list followed by a
+	// link reference definition, which belong together
+	content := "Be quiet after ten.\nNo music.\n\n" +
+		"- First\n\n- Second\n\n" +
+		"[lease]: https://example.com/lease"
+	resp := post(t, alice, srv.URL+"/propose", url.Values{
+		"title":            {"Quiet hours"},
+		"shortDescription": {"No noise after ten"},
+		"content":          {content},
+	})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("proposing: got status %v", resp.StatusCode)
+	}
+	draftURL := resp.Header.Get("Location")
+
+	for _, form := range []url.Values{
+		{"revision": {"0"}, "paragraph": {"0"}},
+		{"revision": {"1"}, "paragraph": {"2"}},
+		{"revision": {"1"}, "paragraph": {"-1"}},
+		{"revision": {"1"}, "paragraph": {"first"}},
+		{"revision": {"1"}, "paragraph": {"0"}, "line": {"2"}},
+		{"revision": {"1"}, "paragraph": {"1"}, "line": {"-1"}},
+	} {
+		form.Set("content", "Why?")
+		resp := post(t, bob, srv.URL+draftURL+"/comments", form)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("commenting on revision %v, paragraph %v, line %q: got status %v, want %v",
+				form.Get("revision"), form.Get("paragraph"), form.Get("line"),
+				resp.StatusCode, http.StatusBadRequest)
+		}
+	}
+
+	for _, form := range []url.Values{
+		{"content": {"Any music?"}, "revision": {"1"}, "paragraph": {"0"}, "line": {"1"}},
+		{"content": {"Why a list?"}, "revision": {"1"}, "paragraph": {"1"}},
+	} {
+		resp := post(t, bob, srv.URL+draftURL+"/comments", form)
+		if resp.StatusCode != http.StatusSeeOther {
+			t.Fatalf("commenting %q: got status %v", form.Get("content"), resp.StatusCode)
+		}
+	}
+
+	ctx := context.Background()
+	_, key, err := store.ByDraftID(ctx, strings.TrimPrefix(draftURL, "/draft/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	comments, _, err := store.CommentsForBHAP(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []bhap.Comment{
+		{Content: "Any music?", Anchor: "No music.", AnchorParagraph: 0, AnchorRevision: 1},
+		{Content: "Why a list?", Anchor: "- First\n\n- Second\n\n[lease]: https://example.com/lease",
+			AnchorParagraph: 1, AnchorRevision: 1},
+	}
+	if len(comments) != len(want) {
+		t.Fatalf("got %v comments, want %v", len(comments), len(want))
+	}
+	for i, c := range comments {
+		if c.Content != want[i].Content || c.Anchor != want[i].Anchor ||
+			c.AnchorParagraph != want[i].AnchorParagraph ||
+			c.AnchorRevision != want[i].AnchorRevision {
+			t.Errorf("got comment %q on %q in paragraph %v of revision %v, want %q on %q in paragraph %v of revision %v",
+				c.Content, c.Anchor, c.AnchorParagraph, c.AnchorRevision,
+				want[i].Content, want[i].Anchor, want[i].AnchorParagraph,
+				want[i].AnchorRevision)
+		}
+	}
+
+	// Comments left from a page loaded before an edit are turned away
+	resp = post(t, alice, srv.URL+draftURL+"/edit", url.Values{
+		"title":            {"Quiet hours"},
+		"shortDescription": {"No noise after ten"},
+		"content":          {"Be quiet after nine.\nNo music."},
+	})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("editing: got status %v", resp.StatusCode)
+	}
+	resp = post(t, bob, srv.URL+draftURL+"/comments", url.Values{
+		"content":   {"Any music?"},
+		"revision":  {"1"},
+		"paragraph": {"0"},
+	})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("commenting on an old revision: got status %v, want %v",
+			resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestRouterUnsubscribe(t *testing.T) {
+	srv, store := newTestSite(t, "alice@example.com")
+	defer srv.Close()
+
+	ctx := context.Background()
+	aliceKey := memberKey(t, store, "alice@example.com")
+	token, err := bhap.UnsubscribeToken(ctx, aliceKey, bhap.ReminderNotification)
+	if err != nil {
+		t.Fatal(err)
+	}
+	link := url.Values{"user": {string(aliceKey)}, "token": {token}}
+	wantsReminders := func() bool {
+		u, err := store.UserByKey(ctx, aliceKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u.Wants(bhap.ReminderNotification)
+	}
+
+	client := &http.Client{}
+
+	// Following the link only asks to confirm
+	status, html := getPage(t, client, srv.URL+"/unsubscribe/reminders?"+link.Encode())
+	if status != http.StatusOK {
+		t.Fatalf("following unsubscribe link: got status %v", status)
+	}
+	if !strings.Contains(html, `<form action="/unsubscribe/reminders" method="POST">`) {
+		t.Errorf("unsubscribe link doesn't lead to a form to confirm")
+	}
+	if !wantsReminders() {
+		t.Errorf("following unsubscribe link unsubscribed without confirming")
+	}
+
+	for _, test := range []struct {
+		path   string
+		form   url.Values
+		status int
+	}{
+		{"/unsubscribe/digest", link, http.StatusForbidden},
+		{"/unsubscribe/everything", link, http.StatusNotFound},
+		{"/unsubscribe/reminders", url.Values{"user": {"User/2"}, "token": {token}}, http.StatusForbidden},
+		{"/unsubscribe/reminders", url.Values{"user": {string(aliceKey)}, "token": {"x" + token}}, http.StatusForbidden},
+		{"/unsubscribe/reminders", url.Values{"user": {string(aliceKey)}}, http.StatusForbidden},
+	} {
+		if status, _ := getPage(t, client, srv.URL+test.path+"?"+test.form.Encode()); status != test.status {
+			t.Errorf("GET %v with %v: got status %v, want %v",
+				test.path, test.form, status, test.status)
+		}
+		if resp := post(t, client, srv.URL+test.path, test.form); resp.StatusCode != test.status {
+			t.Errorf("POST %v with %v: got status %v, want %v",
+				test.path, test.form, resp.StatusCode, test.status)
+		}
+	}
+	if !wantsReminders() {
+		t.Errorf("unsubscribed by an invalid link")
+	}
+
+	if resp := post(t, client, srv.URL+"/unsubscribe/reminders", link); resp.StatusCode != http.StatusOK {
+		t.Fatalf("unsubscribing: got status %v", resp.StatusCode)
+	}
+	if wantsReminders() {
+		t.Errorf("still subscribed after confirming")
+	}
+
+	if resp := post(t, client, srv.URL+"/resubscribe/reminders", link); resp.StatusCode != http.StatusOK {
+		t.Fatalf("resubscribing: got status %v", resp.StatusCode)
+	}
+	if !wantsReminders() {
+		t.Errorf("still unsubscribed after undoing it")
+	}
+
+	// One-click unsubscribing posts to the link itself, as in RFC 8058
+	resp, err := client.Post(srv.URL+"/unsubscribe/reminders?"+link.Encode(),
+		"application/x-www-form-urlencoded",
+		strings.NewReader("List-Unsubscribe=One-Click"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unsubscribing in one click: got status %v", resp.StatusCode)
+	}
+	if wantsReminders() {
+		t.Errorf("still subscribed after unsubscribing in one click")
+	}
+}
+
+func TestRouterRevokeDelegation(t *testing.T) {
+	srv, store := newTestSite(t, "alice@example.com", "bob@example.com",
+		"carol@example.com")
+	defer srv.Close()
+
+	now := time.Now()
+	_, err := store.NewDelegation(context.Background(), bhap.Delegation{
+		UID:         "alice-to-bob",
+		From:        memberKey(t, store, "alice@example.com"),
+		To:          memberKey(t, store, "bob@example.com"),
+		Start:       now,
+		End:         now.AddDate(0, 0, 7),
+		CreatedDate: now,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	revokeURL := srv.URL + "/delegations/alice-to-bob/revoke"
+
+	// Neither the delegate nor anyone else may revoke it
+	for name, client := range map[string]*http.Client{
+		"logged out": {},
+		"delegate":   logIn(t, srv, "bob@example.com"),
+		"other":      logIn(t, srv, "carol@example.com"),
+	} {
+		if resp := post(t, client, revokeURL, nil); resp.StatusCode != http.StatusForbidden {
+			t.Errorf("%v revoking: got status %v, want %v",
+				name, resp.StatusCode, http.StatusForbidden)
+		}
+	}
+
+	alice := logIn(t, srv, "alice@example.com")
+	resp := post(t, alice, srv.URL+"/delegations/nobody/revoke", nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("revoking unknown delegation: got status %v, want %v",
+			resp.StatusCode, http.StatusNotFound)
+	}
+	if resp := post(t, alice, revokeURL, nil); resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("delegator revoking: got status %v", resp.StatusCode)
+	}
+
+	_, key, err := store.DelegationByUID(context.Background(), "alice-to-bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != "" {
+		t.Errorf("delegation is still there after the delegator revoked it")
+	}
+}
+
+func TestRouterCommentAuthorsOnly(t *testing.T) {
+	srv, store := newTestSite(t, "alice@example.com", "bob@example.com",
+		"carol@example.com")
+	defer srv.Close()
+
+	alice := logIn(t, srv, "alice@example.com")
+	bob := logIn(t, srv, "bob@example.com")
+	carol := logIn(t, srv, "carol@example.com")
+
+	var draftURLs []string
+	for _, title := range []string{"Quiet hours", "Dish duty"} {
+		resp := post(t, alice, srv.URL+"/propose", url.Values{
+			"title":            {title},
+			"shortDescription": {"Short"},
+			"content":          {"Content"},
+		})
+		if resp.StatusCode != http.StatusSeeOther {
+			t.Fatalf("proposing: got status %v", resp.StatusCode)
+		}
+		draftURLs = append(draftURLs, resp.Header.Get("Location"))
+	}
+	draftURL := draftURLs[0]
+
+	resp := post(t, bob, srv.URL+draftURL+"/comments", url.Values{
+		"content": {"Does this include weekends?"},
+	})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("commenting: got status %v", resp.StatusCode)
+	}
+	location := resp.Header.Get("Location")
+	uid := location[strings.Index(location, "#comment-")+len("#comment-"):]
+	commentURL := srv.URL + draftURL + "/comments/" + uid
+
+	// Not even the BHAP's author may change someone else's comment
+	for name, client := range map[string]*http.Client{"alice": alice, "carol": carol} {
+		resp := post(t, client, commentURL+"/edit", url.Values{"content": {"Changed"}})
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("%v editing bob's comment: got status %v, want %v",
+				name, resp.StatusCode, http.StatusForbidden)
+		}
+		resp = post(t, client, commentURL+"/delete", nil)
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("%v deleting bob's comment: got status %v, want %v",
+				name, resp.StatusCode, http.StatusForbidden)
+		}
+	}
+
+	// Nor is the comment found through another BHAP
+	otherURL := srv.URL + draftURLs[1] + "/comments/" + uid
+	if resp := post(t, bob, otherURL+"/delete", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("deleting comment through another BHAP: got status %v, want %v",
+			resp.StatusCode, http.StatusNotFound)
+	}
+
+	c, _, err := store.CommentByUID(context.Background(), uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Content != "Does this include weekends?" || c.Deleted {
+		t.Errorf("comment changed by someone other than its author: %+v", c)
+	}
+
+	resp = post(t, bob, commentURL+"/edit", url.Values{"content": {"Weekends too?"}})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("author editing: got status %v", resp.StatusCode)
+	}
+	if c, _, err := store.CommentByUID(context.Background(), uid); err != nil {
+		t.Fatal(err)
+	} else if c.Content != "Weekends too?" {
+		t.Errorf("got content %q after the author edited it", c.Content)
+	}
+
+	if resp := post(t, bob, commentURL+"/delete", nil); resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("author deleting: got status %v", resp.StatusCode)
+	}
+	if _, key, err := store.CommentByUID(context.Background(), uid); err != nil {
+		t.Fatal(err)
+	} else if key != "" {
+		t.Errorf("comment is still there after the author deleted it")
+	}
+}
+
+func TestRouterCoAuthorsChosenByAuthor(t *testing.T) {
+	srv, store := newTestSite(t, "alice@example.com", "bob@example.com",
+		"carol@example.com", "dave@example.com")
+	defer srv.Close()
+
+	alice := logIn(t, srv, "alice@example.com")
+	bob := logIn(t, srv, "bob@example.com")
+	dave := logIn(t, srv, "dave@example.com")
+
+	resp := post(t, alice, srv.URL+"/propose", url.Values{
+		"title":            {"Quiet hours"},
+		"shortDescription": {"No noise after ten"},
+		"content":          {"Be quiet after ten."},
+	})
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("proposing: got status %v", resp.StatusCode)
+	}
+	draftURL := srv.URL + resp.Header.Get("Location")
+
+	steps := []struct {
+		name   string
+		client *http.Client
+		action string
+		email  string
+		status int
+	}{
+		{"non-author inviting", bob, "invite", "carol@example.com", http.StatusForbidden},
+		{"author inviting", alice, "invite", "bob@example.com", http.StatusSeeOther},
+		{"accepting", bob, "accept", "", http.StatusSeeOther},
+		// Co-authors don't get to choose who else joins
+		{"co-author inviting", bob, "invite", "carol@example.com", http.StatusForbidden},
+		{"author inviting again", alice, "invite", "carol@example.com", http.StatusSeeOther},
+		{"co-author taking back invitation", bob, "remove", "carol@example.com", http.StatusForbidden},
+		{"non-author removing co-author", dave, "remove", "bob@example.com", http.StatusForbidden},
+		{"co-author leaving", bob, "remove", "bob@example.com", http.StatusSeeOther},
+	}
+	for _, step := range steps {
+		var form url.Values
+		if step.email != "" {
+			form = url.Values{"email": {step.email}}
+		}
+		resp := post(t, step.client, draftURL+"/coauthors/"+step.action, form)
+		if resp.StatusCode != step.status {
+			t.Fatalf("%v: got status %v, want %v", step.name, resp.StatusCode, step.status)
+		}
+	}
+
+	b, _, err := store.ByDraftID(context.Background(), strings.TrimPrefix(draftURL, srv.URL+"/draft/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	carol := memberKey(t, store, "carol@example.com")
+	if len(b.CoAuthors) != 0 || len(b.InvitedCoAuthors) != 1 || b.InvitedCoAuthors[0] != carol {
+		t.Errorf("got co-authors %v and invitations %v, want no co-authors and carol invited",
+			b.CoAuthors, b.InvitedCoAuthors)
+	}
+}
+
+func TestRouterBallotsByAdminsOnly(t *testing.T) {
+	srv, store := newTestSite(t, "alice@example.com", "bob@example.com")
+	defer srv.Close()
+
+	ctx := context.Background()
+	aliceKey := memberKey(t, store, "alice@example.com")
+	alice, err := store.UserByKey(ctx, aliceKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice.Admin = true
+	if err := store.PutUser(ctx, aliceKey, alice); err != nil {
+		t.Fatal(err)
+	}
+
+	var optionKeys []bhap.Key
+	for _, id := range []int{100, 101} {
+		key, err := store.NewBHAP(ctx, bhap.BHAP{
+			ID:             id,
+			Title:          "Quiet hours",
+			Author:         aliceKey,
+			Status:         bhap.DiscussionStatus,
+			Type:           bhap.HouseRuleBHAPType,
+			CreatedDate:    time.Now(),
+			LastModified:   time.Now(),
+			VotingDeadline: time.Now().AddDate(0, 0, 7),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		optionKeys = append(optionKeys, key)
+	}
+	form := url.Values{"title": {"Quiet hours"}, "option": {"100", "101"}}
+
+	bob := logIn(t, srv, "bob@example.com")
+	if resp := get(t, bob, srv.URL+"/ballot/new"); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("non-admin viewing ballot form: got status %v, want %v",
+			resp.StatusCode, http.StatusForbidden)
+	}
+	for name, client := range map[string]*http.Client{
+		"logged out": {},
+		"non-admin":  bob,
+	} {
+		if resp := post(t, client, srv.URL+"/ballot/new", form); resp.StatusCode != http.StatusForbidden {
+			t.Errorf("%v creating ballot: got status %v, want %v",
+				name, resp.StatusCode, http.StatusForbidden)
+		}
+	}
+	if ballots, _, err := store.OpenBallots(ctx); err != nil {
+		t.Fatal(err)
+	} else if len(ballots) != 0 {
+		t.Fatalf("got %v ballots created by non-admins", len(ballots))
+	}
+
+	admin := logIn(t, srv, "alice@example.com")
+	if resp := get(t, admin, srv.URL+"/ballot/new"); resp.StatusCode != http.StatusOK {
+		t.Errorf("admin viewing ballot form: got status %v", resp.StatusCode)
+	}
+	if resp := post(t, admin, srv.URL+"/ballot/new", form); resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("admin creating ballot: got status %v", resp.StatusCode)
+	}
+
+	_, ballotKeys, err := store.OpenBallots(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ballotKeys) != 1 {
+		t.Fatalf("got %v ballots, want 1", len(ballotKeys))
+	}
+	for _, key := range optionKeys {
+		b, err := store.ByKey(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.Ballot != ballotKeys[0] {
+			t.Errorf("BHAP %v isn't on the new ballot", b.ID)
+		}
+	}
+}
+
+// queuedEmails returns the subjects of the unsent emails queued for each
+// recipient.
+func queuedEmails(t *testing.T, store *memstore.Store) map[string][]string {
+	emails, _, err := store.UnsentEmails(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	subjects := make(map[string][]string)
+	for _, e := range emails {
+		subjects[e.To] = append(subjects[e.To], e.Subject)
+	}
+	return subjects
+}
+
+func TestRouterDigestsSentOnce(t *testing.T) {
+	srv, store := newTestSite(t, "alice@example.com", "bob@example.com")
+	defer srv.Close()
+
+	ctx := context.Background()
+	bobKey := memberKey(t, store, "bob@example.com")
+	bob, err := store.UserByKey(ctx, bobKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob.WeeklyDigest = true
+	if err := store.PutUser(ctx, bobKey, bob); err != nil {
+		t.Fatal(err)
+	}
+
+	// The last digest went out a few days ago, so only what happened since
+	// is new
+	lastDigest := time.Now().AddDate(0, 0, -3)
+	if err := store.RecordDigest(ctx, lastDigest); err != nil {
+		t.Fatal(err)
+	}
+	// Every digest lists the BHAPs in discussion, so there is always
+	// something to send
+	for _, b := range []bhap.BHAP{
+		{Title: "Already sent", Status: bhap.DraftStatus, CreatedDate: lastDigest.Add(-time.Hour)},
+		{Title: "Since sent", Status: bhap.DraftStatus, CreatedDate: lastDigest.Add(time.Hour)},
+		{ID: 100, Title: "Ongoing", Status: bhap.DiscussionStatus, CreatedDate: lastDigest.AddDate(0, 0, -7)},
+	} {
+		b.DraftID = b.Title
+		b.Author = memberKey(t, store, "alice@example.com")
+		b.Type = bhap.HouseRuleBHAPType
+		b.LastModified = b.CreatedDate
+		_, err := store.NewBHAP(ctx, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	client := &http.Client{}
+	for run := 1; run <= 2; run++ {
+		if resp := get(t, client, srv.URL+"/tasks/send-digests"); resp.StatusCode != http.StatusOK {
+			t.Fatalf("sending digests, run %v: got status %v", run, resp.StatusCode)
+		}
+	}
+
+	emails, _, err := store.UnsentEmails(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(emails) != 1 || emails[0].To != "bob@example.com" {
+		t.Fatalf("got %v emails, want one digest to bob: %v", len(emails), queuedEmails(t, store))
+	}
+	if body := emails[0].Body; !strings.Contains(body, "Since sent") || strings.Contains(body, "Already sent") {
+		t.Errorf("digest doesn't cover just what happened since the last one:\n%v", body)
+	}
+
+	last, err := store.LastDigestDate(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !last.After(lastDigest) {
+		t.Errorf("digest wasn't recorded as sent")
+	}
+}
+
+func TestRouterVoteReminders(t *testing.T) {
+	defer bhap.SetSettings(bhap.CurrentSettings())
+	bhap.SetSettings(bhap.DefaultSettings())
+
+	srv, store := newTestSite(t, "alice@example.com", "bob@example.com", "carol@example.com")
+	defer srv.Close()
+
+	ctx := context.Background()
+	alice := memberKey(t, store, "alice@example.com")
+	bob := memberKey(t, store, "bob@example.com")
+	carol := memberKey(t, store, "carol@example.com")
+
+	// Voting started three days ago, so the first reminder is due
+	key, err := store.NewBHAP(ctx, bhap.BHAP{
+		ID:             100,
+		Title:          "Quiet hours",
+		Author:         alice,
+		Status:         bhap.DiscussionStatus,
+		Type:           bhap.HouseRuleBHAPType,
+		Voters:         []bhap.Key{alice, bob, carol},
+		VotersDate:     time.Now(),
+		VotingDeadline: time.Now().AddDate(0, 0, 11),
+		Revision:       1,
+		CreatedDate:    time.Now(),
+		LastModified:   time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetVoteForBHAP(ctx, key, alice, bhap.AcceptedStatus, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	const subject = "Reminder: vote on BHAP 0100: Quiet hours"
+	client := &http.Client{}
+	sendReminders := func(want map[string][]string, wantSent int) {
+		t.Helper()
+		if resp := get(t, client, srv.URL+"/tasks/send-vote-reminders"); resp.StatusCode != http.StatusOK {
+			t.Fatalf("sending reminders: got status %v", resp.StatusCode)
+		}
+		if got := queuedEmails(t, store); !reflect.DeepEqual(got, want) {
+			t.Errorf("got emails %v, want %v", got, want)
+		}
+
+		b, err := store.ByKey(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.RemindersSent != wantSent {
+			t.Errorf("got %v reminders sent, want %v", b.RemindersSent, wantSent)
+		}
+	}
+
+	// Only the members who haven't voted are reminded
+	once := map[string][]string{
+		"bob@example.com":   {subject},
+		"carol@example.com": {subject},
+	}
+	sendReminders(once, 1)
+	// Running the task again doesn't repeat the reminder
+	sendReminders(once, 1)
+
+	// Both of the next reminders have been missed, so only one more goes out
+	b, err := store.ByKey(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.VotingDeadline = time.Now().AddDate(0, 0, 4)
+	if err := store.PutBHAP(ctx, key, b); err != nil {
+		t.Fatal(err)
+	}
+	sendReminders(map[string][]string{
+		"bob@example.com":   {subject, subject},
+		"carol@example.com": {subject, subject},
+	}, 3)
+}
+
+func TestRouterStatusNotices(t *testing.T) {
+	srv, store := newTestSite(t, "alice@example.com", "bob@example.com", "carol@example.com")
+	defer srv.Close()
+
+	ctx := context.Background()
+	alice := memberKey(t, store, "alice@example.com")
+	carolKey := memberKey(t, store, "carol@example.com")
+	carol, err := store.UserByKey(ctx, carolKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	carol.SetWants(bhap.DecisionNotification, false)
+	if err := store.PutUser(ctx, carolKey, carol); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = store.NewBHAP(ctx, bhap.BHAP{
+		ID:             100,
+		Title:          "Quiet hours",
+		Author:         alice,
+		Status:         bhap.DiscussionStatus,
+		Type:           bhap.HouseRuleBHAPType,
+		VotingDeadline: time.Now().AddDate(0, 0, 7),
+		Revision:       1,
+		CreatedDate:    time.Now(),
+		LastModified:   time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	discussion := "BHAP 0100 is open for discussion: Quiet hours"
+	withdrawn := "BHAP 0100 has been withdrawn: Quiet hours"
+	client := logIn(t, srv, "alice@example.com")
+	steps := []struct {
+		name string
+		path string
+		form url.Values
+		want map[string][]string
+	}{
+		{
+			// Members aren't told about BHAPs being put on hold
+			"defer", "/bhap/100/defer", url.Values{"reason": {"Waiting on the landlord"}},
+			map[string][]string{},
+		},
+		{
+			"resume", "/bhap/100/resume", nil,
+			map[string][]string{
+				"alice@example.com": {discussion},
+				"bob@example.com":   {discussion},
+				"carol@example.com": {discussion},
+			},
+		},
+		{
+			// Carol chose not to hear about decisions
+			"withdraw", "/bhap/100/withdraw", nil,
+			map[string][]string{
+				"alice@example.com": {discussion, withdrawn},
+				"bob@example.com":   {discussion, withdrawn},
+				"carol@example.com": {discussion},
+			},
+		},
+	}
+	for _, step := range steps {
+		if resp := post(t, client, srv.URL+step.path, step.form); resp.StatusCode != http.StatusSeeOther {
+			t.Fatalf("%v: got status %v", step.name, resp.StatusCode)
+		}
+		if got := queuedEmails(t, store); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%v: got emails %v, want %v", step.name, got, step.want)
+		}
+	}
+}
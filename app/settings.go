@@ -0,0 +1,24 @@
+package main
+
+import (
+	"os"
+
+	"github.com/house-emoji/bhap"
+)
+
+// defaultSettingsFile is where settings are read from unless told otherwise.
+const defaultSettingsFile = "settings.json"
+
+// loadSettings puts the settings in the given file into effect. If the file
+// doesn't exist, the defaults are kept.
+func loadSettings(filename string) error {
+	s, err := bhap.LoadSettingsFile(filename)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	bhap.SetSettings(s)
+	return nil
+}
@@ -0,0 +1,235 @@
+package bhap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// VoteAction is something that happened to a user's vote on a BHAP.
+type VoteAction string
+
+const (
+	// VoteCast is a vote being cast where there was none before.
+	VoteCast VoteAction = "Cast"
+	// VoteChanged is an existing vote being given a different value.
+	VoteChanged VoteAction = "Changed"
+	// VoteRetracted is a user taking back their vote.
+	VoteRetracted VoteAction = "Retracted"
+	// VoteCleared is a vote being thrown out along with every other vote on
+	// the BHAP, like when the BHAP is deferred.
+	VoteCleared VoteAction = "Cleared"
+	// VoteConfirmed is a vote being cast again with the same value on a
+	// newer revision of the BHAP.
+	VoteConfirmed VoteAction = "Confirmed"
+)
+
+// VoteEvent records a change to a user's vote on a BHAP. Vote events are
+// never changed or deleted once recorded, so they make up an audit trail
+// that outlives the votes themselves.
+type VoteEvent struct {
+	OnBHAP Key
+	ByUser Key
+	Action VoteAction
+	// Value is the value of the vote after the event. For retracted and
+	// cleared votes, it is the value the vote had.
+	Value Status
+	// Rank is where the user placed the BHAP, for ranked votes
+	Rank int
+	// Previous is the value the vote had before it was changed
+	Previous Status
+	// Proxy is the key of the delegate who cast the vote on the user's
+	// behalf, if any
+	Proxy Key
+	// Revision is the number of the BHAP's revision the vote was cast on
+	Revision int
+	Date     time.Time
+}
+
+var (
+	// ErrNoVote is returned when retracting a vote that was never cast.
+	ErrNoVote = errors.New("no vote has been cast")
+	// ErrVoteFinal is returned when changing or retracting a vote on a
+	// secret ballot. Since the choice isn't linked to the member, it can't
+	// be taken back out of the count.
+	ErrVoteFinal = errors.New("votes on a secret ballot are final")
+)
+
+// CastVote sets the user's vote on a BHAP to the given value, recording it in
+// the audit trail. On a secret ballot, the audit trail only records that the
+// user voted.
+func CastVote(ctx context.Context, s Store, bhapKey Key, b BHAP, userKey Key, value Status) error {
+	existing, existingKey, err := s.GetVoteForBHAP(ctx, bhapKey, userKey)
+	if err != nil {
+		return fmt.Errorf("getting existing vote: %v", err)
+	}
+
+	if b.SecretBallot {
+		return castSecretVote(ctx, s, bhapKey, userKey, existingKey, value, b.Revision)
+	}
+
+	if err := s.SetVoteForBHAP(ctx, bhapKey, userKey, value, b.Revision); err != nil {
+		return fmt.Errorf("setting vote: %v", err)
+	}
+
+	err = recordChange(ctx, s, existing, existingKey, Vote{
+		OnBHAP:   bhapKey,
+		ByUser:   userKey,
+		Value:    value,
+		Revision: b.Revision,
+	})
+	if err != nil {
+		return err
+	}
+
+	return syncDelegates(ctx, s, bhapKey, userKey)
+}
+
+// castSecretVote records that the user voted on a BHAP with a secret ballot
+// and counts their choice separately. Proxy votes aren't cast on secret
+// ballots, since they would reveal the delegate's choice.
+func castSecretVote(ctx context.Context, s Store, bhapKey, userKey, existingKey Key, value Status, revision int) error {
+	if existingKey != "" {
+		return ErrVoteFinal
+	}
+
+	// The store checks again, in case another vote was cast in the meantime
+	if err := s.CastSecretVote(ctx, bhapKey, userKey, value, revision); err == ErrVoteFinal {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("casting secret vote: %v", err)
+	}
+
+	return recordEvent(ctx, s, VoteEvent{
+		OnBHAP:   bhapKey,
+		ByUser:   userKey,
+		Action:   VoteCast,
+		Value:    SecretVote,
+		Revision: revision,
+		Date:     time.Now(),
+	})
+}
+
+// RankVote sets where the user ranks a BHAP on a ballot, recording it in the
+// audit trail.
+func RankVote(ctx context.Context, s Store, bhapKey, userKey Key, rank int) error {
+	existing, existingKey, err := s.GetVoteForBHAP(ctx, bhapKey, userKey)
+	if err != nil {
+		return fmt.Errorf("getting existing vote: %v", err)
+	}
+
+	if err := s.SetRankForBHAP(ctx, bhapKey, userKey, rank); err != nil {
+		return fmt.Errorf("setting rank: %v", err)
+	}
+
+	return recordChange(ctx, s, existing, existingKey,
+		Vote{OnBHAP: bhapKey, ByUser: userKey, Value: RankedVote, Rank: rank})
+}
+
+// recordChange records a vote being cast, changed or confirmed on a newer
+// revision. Nothing is recorded if the vote is the same as before.
+func recordChange(ctx context.Context, s Store, existing Vote, existingKey Key, updated Vote) error {
+	event := VoteEvent{
+		OnBHAP:   updated.OnBHAP,
+		ByUser:   updated.ByUser,
+		Action:   VoteCast,
+		Value:    updated.Value,
+		Rank:     updated.Rank,
+		Proxy:    updated.Proxy,
+		Revision: updated.Revision,
+		Date:     time.Now(),
+	}
+	if existingKey != "" {
+		if existing == updated {
+			return nil
+		}
+
+		sameChoice := existing
+		sameChoice.Revision = updated.Revision
+		if sameChoice == updated {
+			event.Action = VoteConfirmed
+		} else {
+			event.Action = VoteChanged
+			event.Previous = existing.Value
+		}
+	}
+
+	return recordEvent(ctx, s, event)
+}
+
+// recordEvent adds an event to the audit trail.
+func recordEvent(ctx context.Context, s Store, event VoteEvent) error {
+	if err := s.NewVoteEvent(ctx, event); err != nil {
+		return fmt.Errorf("recording vote event: %v", err)
+	}
+	return nil
+}
+
+// RetractVote deletes the user's vote on a BHAP, recording it in the audit
+// trail. If the user has not voted, ErrNoVote is returned. Votes on a secret
+// ballot can't be retracted, so ErrVoteFinal is returned for them.
+func RetractVote(ctx context.Context, s Store, bhapKey, userKey Key) error {
+	vote, voteKey, err := s.GetVoteForBHAP(ctx, bhapKey, userKey)
+	if err != nil {
+		return fmt.Errorf("getting vote: %v", err)
+	}
+	if voteKey == "" {
+		return ErrNoVote
+	}
+	if vote.Value == SecretVote {
+		return ErrVoteFinal
+	}
+
+	if err := s.DeleteVote(ctx, voteKey); err != nil {
+		return fmt.Errorf("deleting vote: %v", err)
+	}
+
+	err = recordEvent(ctx, s, VoteEvent{
+		OnBHAP:   bhapKey,
+		ByUser:   userKey,
+		Action:   VoteRetracted,
+		Value:    vote.Value,
+		Rank:     vote.Rank,
+		Proxy:    vote.Proxy,
+		Revision: vote.Revision,
+		Date:     time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return syncDelegates(ctx, s, bhapKey, userKey)
+}
+
+// ClearVotes deletes every vote on a BHAP, recording each one in the audit
+// trail.
+func ClearVotes(ctx context.Context, s Store, bhapKey Key) error {
+	votes, err := s.AllVotesForBHAP(ctx, bhapKey)
+	if err != nil {
+		return fmt.Errorf("getting votes: %v", err)
+	}
+
+	if err := s.DeleteVotesForBHAP(ctx, bhapKey); err != nil {
+		return fmt.Errorf("deleting votes: %v", err)
+	}
+
+	now := time.Now()
+	for _, vote := range votes {
+		err := recordEvent(ctx, s, VoteEvent{
+			OnBHAP:   bhapKey,
+			ByUser:   vote.ByUser,
+			Action:   VoteCleared,
+			Value:    vote.Value,
+			Rank:     vote.Rank,
+			Proxy:    vote.Proxy,
+			Revision: vote.Revision,
+			Date:     now,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,210 @@
+package bhap_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/house-emoji/bhap"
+	"github.com/house-emoji/bhap/memstore"
+)
+
+// newTestUser saves a member with the given email and returns their key.
+func newTestUser(t *testing.T, s bhap.Store, email string) bhap.Key {
+	t.Helper()
+
+	key, err := s.NewUser(context.Background(), bhap.User{
+		FirstName: email,
+		LastName:  "Tester",
+		Email:     email,
+	})
+	if err != nil {
+		t.Fatalf("saving user: %v", err)
+	}
+	return key
+}
+
+// newTestBHAP saves the BHAP, filling in what it leaves out with a House
+// Rule in discussion, and returns its key.
+func newTestBHAP(t *testing.T, s bhap.Store, b bhap.BHAP) bhap.Key {
+	t.Helper()
+
+	if b.Title == "" {
+		b.Title = "Quiet hours"
+	}
+	if b.Type == "" {
+		b.Type = bhap.HouseRuleBHAPType
+	}
+	if b.Status == "" {
+		b.Status = bhap.DiscussionStatus
+	}
+	if b.CreatedDate.IsZero() {
+		b.CreatedDate = time.Now()
+		b.LastModified = b.CreatedDate
+	}
+
+	key, err := s.NewBHAP(context.Background(), b)
+	if err != nil {
+		t.Fatalf("saving BHAP: %v", err)
+	}
+	return key
+}
+
+func TestCastVoteRecordsChanges(t *testing.T) {
+	ctx := context.Background()
+	s := memstore.New()
+	author := newTestUser(t, s, "author@example.com")
+	voter := newTestUser(t, s, "voter@example.com")
+	b := bhap.BHAP{ID: 100, Author: author, Revision: 1}
+	bhapKey := newTestBHAP(t, s, b)
+
+	steps := []struct {
+		name     string
+		value    bhap.Status
+		revision int
+		// wantAction is empty if nothing should be recorded
+		wantAction   bhap.VoteAction
+		wantPrevious bhap.Status
+	}{
+		{"first vote", bhap.AcceptedStatus, 1, bhap.VoteCast, ""},
+		{"same vote", bhap.AcceptedStatus, 1, "", ""},
+		{"different vote", bhap.RejectedStatus, 1, bhap.VoteChanged, bhap.AcceptedStatus},
+		{"same vote on a new revision", bhap.RejectedStatus, 2, bhap.VoteConfirmed, ""},
+		{"same vote on the same revision", bhap.RejectedStatus, 2, "", ""},
+		{"different vote on a new revision", bhap.AbstainVote, 3, bhap.VoteChanged, bhap.RejectedStatus},
+	}
+
+	var recorded int
+	for _, step := range steps {
+		b.Revision = step.revision
+		if err := bhap.CastVote(ctx, s, bhapKey, b, voter, step.value); err != nil {
+			t.Fatalf("%v: casting vote: %v", step.name, err)
+		}
+
+		events, err := s.VoteEventsForBHAP(ctx, bhapKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if step.wantAction == "" {
+			if len(events) != recorded {
+				t.Errorf("%v: got new events %+v, want none", step.name, events[recorded:])
+			}
+			continue
+		}
+
+		if len(events) != recorded+1 {
+			t.Fatalf("%v: got %v new events, want 1", step.name, len(events)-recorded)
+		}
+		recorded++
+
+		e := events[len(events)-1]
+		if e.Action != step.wantAction || e.Value != step.value ||
+			e.Previous != step.wantPrevious || e.Revision != step.revision ||
+			e.ByUser != voter {
+			t.Errorf("%v: got event %+v, want %v to %v from %q on revision %v",
+				step.name, e, step.wantAction, step.value, step.wantPrevious,
+				step.revision)
+		}
+	}
+}
+
+func TestRetractVote(t *testing.T) {
+	ctx := context.Background()
+	s := memstore.New()
+	author := newTestUser(t, s, "author@example.com")
+	voter := newTestUser(t, s, "voter@example.com")
+	open := bhap.BHAP{ID: 100, Author: author, Revision: 2}
+	openKey := newTestBHAP(t, s, open)
+	secret := bhap.BHAP{ID: 101, Author: author, Revision: 1, SecretBallot: true}
+	secretKey := newTestBHAP(t, s, secret)
+
+	if err := bhap.RetractVote(ctx, s, openKey, voter); err != bhap.ErrNoVote {
+		t.Errorf("retracting a vote never cast: got error %v, want %v", err, bhap.ErrNoVote)
+	}
+
+	if err := bhap.CastVote(ctx, s, secretKey, secret, voter, bhap.AcceptedStatus); err != nil {
+		t.Fatalf("casting secret vote: %v", err)
+	}
+	if err := bhap.RetractVote(ctx, s, secretKey, voter); err != bhap.ErrVoteFinal {
+		t.Errorf("retracting a secret vote: got error %v, want %v", err, bhap.ErrVoteFinal)
+	}
+
+	if err := bhap.CastVote(ctx, s, openKey, open, voter, bhap.RejectedStatus); err != nil {
+		t.Fatalf("casting vote: %v", err)
+	}
+	if err := bhap.RetractVote(ctx, s, openKey, voter); err != nil {
+		t.Fatalf("retracting vote: %v", err)
+	}
+
+	if _, key, err := s.GetVoteForBHAP(ctx, openKey, voter); err != nil {
+		t.Fatal(err)
+	} else if key != "" {
+		t.Errorf("vote is still there after retracting it")
+	}
+
+	events, err := s.VoteEventsForBHAP(ctx, openKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got events %+v, want the vote being cast and retracted", events)
+	}
+	e := events[1]
+	if e.Action != bhap.VoteRetracted || e.Value != bhap.RejectedStatus ||
+		e.Revision != 2 || e.ByUser != voter {
+		t.Errorf("got event %+v, want the rejection on revision 2 retracted", e)
+	}
+}
+
+func TestClearVotes(t *testing.T) {
+	ctx := context.Background()
+	s := memstore.New()
+	author := newTestUser(t, s, "author@example.com")
+	alice := newTestUser(t, s, "alice@example.com")
+	bob := newTestUser(t, s, "bob@example.com")
+	b := bhap.BHAP{ID: 100, Author: author, Revision: 1}
+	bhapKey := newTestBHAP(t, s, b)
+	otherKey := newTestBHAP(t, s, bhap.BHAP{ID: 101, Author: author})
+
+	want := map[bhap.Key]bhap.Status{
+		alice: bhap.AcceptedStatus,
+		bob:   bhap.AbstainVote,
+	}
+	for user, value := range want {
+		if err := bhap.CastVote(ctx, s, bhapKey, b, user, value); err != nil {
+			t.Fatalf("casting vote: %v", err)
+		}
+	}
+	if err := s.SetVoteForBHAP(ctx, otherKey, alice, bhap.RejectedStatus, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bhap.ClearVotes(ctx, s, bhapKey); err != nil {
+		t.Fatalf("clearing votes: %v", err)
+	}
+
+	if votes, err := s.AllVotesForBHAP(ctx, bhapKey); err != nil {
+		t.Fatal(err)
+	} else if len(votes) != 0 {
+		t.Errorf("got votes %+v after clearing them, want none", votes)
+	}
+	if votes, err := s.AllVotesForBHAP(ctx, otherKey); err != nil {
+		t.Fatal(err)
+	} else if len(votes) != 1 {
+		t.Errorf("got %v votes on another BHAP, want 1 to be left", len(votes))
+	}
+
+	events, err := s.VoteEventsForBHAP(ctx, bhapKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cleared := make(map[bhap.Key]bhap.Status)
+	for _, e := range events {
+		if e.Action == bhap.VoteCleared {
+			cleared[e.ByUser] = e.Value
+		}
+	}
+	if len(cleared) != len(want) || cleared[alice] != want[alice] || cleared[bob] != want[bob] {
+		t.Errorf("got cleared votes %v, want %v", cleared, want)
+	}
+}
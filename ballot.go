@@ -0,0 +1,366 @@
+package bhap
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/house-emoji/bhap/log"
+)
+
+// RankedVote is the value of a vote that ranks a BHAP on a ballot. The
+// vote's Rank holds where the user placed it.
+const RankedVote Status = "Ranked"
+
+// Ballot groups rival BHAPs in discussion so that members can rank them
+// against each other. Ballots are decided by instant-runoff voting. The
+// winner is accepted and the other options are rejected.
+type Ballot struct {
+	// UID identifies the ballot in URLs
+	UID   string
+	Title string
+	// Options holds the keys of the BHAPs on the ballot, in the order they
+	// are listed
+	Options     []Key
+	CreatedBy   Key
+	CreatedDate time.Time
+	// Deadline is when ranking closes
+	Deadline time.Time
+	// Closed is true once the ballot has been decided
+	Closed bool
+	// Counted holds the options that were still in discussion when the
+	// ballot closed, and so took part in the count
+	Counted []Key
+	// Winner is the key of the option that was accepted, if any
+	Winner Key
+	// Quorum is the share of eligible members who had to rank options for
+	// the result to stand, as it was when the ballot closed
+	Quorum float64
+}
+
+// Round is one round of an instant-runoff count.
+type Round struct {
+	// Counts holds the number of rankings counting toward each option that
+	// is still in the running.
+	Counts map[Key]int
+	// Exhausted is the number of rankings with no options left in the
+	// running.
+	Exhausted int
+	// Eliminated holds the options knocked out at the end of the round.
+	Eliminated []Key
+}
+
+// InstantRunoff counts rankings of the given options, each of which lists
+// options in order of preference. In each round, every ranking counts toward
+// its most preferred option still in the running. An option with more than
+// half of those rankings wins. Otherwise, the options with the fewest are
+// eliminated and the count repeats. If every option left is tied, there is
+// no winner and the returned key is empty.
+func InstantRunoff(options []Key, rankings [][]Key) (Key, []Round) {
+	running := make(map[Key]bool)
+	for _, option := range options {
+		running[option] = true
+	}
+
+	var rounds []Round
+	for len(running) > 0 {
+		round := Round{Counts: make(map[Key]int)}
+		for option := range running {
+			round.Counts[option] = 0
+		}
+
+		for _, ranking := range rankings {
+			counted := false
+			for _, option := range ranking {
+				if running[option] {
+					round.Counts[option]++
+					counted = true
+					break
+				}
+			}
+			if !counted {
+				round.Exhausted++
+			}
+		}
+
+		active := len(rankings) - round.Exhausted
+		fewest := -1
+		for _, option := range options {
+			if !running[option] {
+				continue
+			}
+
+			count := round.Counts[option]
+			if active > 0 && count*2 > active {
+				rounds = append(rounds, round)
+				return option, rounds
+			}
+			if fewest == -1 || count < fewest {
+				fewest = count
+			}
+		}
+
+		for _, option := range options {
+			if running[option] && round.Counts[option] == fewest {
+				round.Eliminated = append(round.Eliminated, option)
+			}
+		}
+		rounds = append(rounds, round)
+
+		if len(round.Eliminated) == len(running) {
+			// Everyone left is tied
+			return "", rounds
+		}
+		for _, option := range round.Eliminated {
+			delete(running, option)
+		}
+	}
+
+	return "", rounds
+}
+
+// BallotResult is the outcome of counting a ballot.
+type BallotResult struct {
+	// Winner is the key of the winning option, or empty if there is none.
+	Winner Key
+	Rounds []Round
+	// Voters is the number of members who ranked at least one option.
+	Voters int
+	// Eligible is the number of members who may rank options.
+	Eligible int
+	// Quorum is the share of eligible members who must rank options for
+	// the result to stand.
+	Quorum float64
+	// QuorumMet is true if enough members ranked options for the result
+	// to stand.
+	QuorumMet bool
+}
+
+// CountBallot gathers the rankings cast on a ballot and runs an
+// instant-runoff count. While the ballot is open, the options still in
+// discussion are counted. Once closed, the options that were counted at the
+// time are. Every member may rank the options, including their authors, as
+// long as they were a member when each option entered discussion. The
+// strictest quorum of the counted options applies, or the one recorded when
+// the ballot closed.
+func CountBallot(ctx context.Context, s Store, ballot Ballot) (BallotResult, error) {
+	var options []Key
+	if ballot.Closed {
+		options = ballot.Counted
+	}
+	loaded := make(map[Key]BHAP)
+	for _, key := range ballot.Options {
+		b, err := s.ByKey(ctx, key)
+		if err != nil {
+			return BallotResult{}, fmt.Errorf("loading option: %v", err)
+		}
+		loaded[key] = b
+
+		if !ballot.Closed && b.Status == DiscussionStatus {
+			options = append(options, key)
+		}
+	}
+
+	var quorum float64
+	optionBHAPs := make([]BHAP, len(options))
+	for i, option := range options {
+		optionBHAPs[i] = loaded[option]
+		if rule := RuleFor(optionBHAPs[i].Type); rule.Quorum > quorum {
+			quorum = rule.Quorum
+		}
+	}
+	// Ballots closed before the quorum was recorded go by the current rules
+	if ballot.Closed && ballot.Quorum > 0 {
+		quorum = ballot.Quorum
+	}
+	members, recorded := ballotMembers(optionBHAPs)
+
+	type rankedOption struct {
+		option Key
+		rank   int
+	}
+	byUser := make(map[Key][]rankedOption)
+	for _, option := range options {
+		votes, err := s.AllVotesForBHAP(ctx, option)
+		if err != nil {
+			return BallotResult{}, fmt.Errorf("getting rankings: %v", err)
+		}
+
+		for _, vote := range votes {
+			if vote.Value == RankedVote && (!recorded || members[vote.ByUser]) {
+				byUser[vote.ByUser] = append(byUser[vote.ByUser],
+					rankedOption{option, vote.Rank})
+			}
+		}
+	}
+
+	// Go through users in a fixed order so that counts are repeatable
+	users := make([]string, 0, len(byUser))
+	for user := range byUser {
+		users = append(users, string(user))
+	}
+	sort.Strings(users)
+
+	rankings := make([][]Key, len(users))
+	for i, user := range users {
+		ranked := byUser[Key(user)]
+		sort.SliceStable(ranked, func(i, j int) bool {
+			return ranked[i].rank < ranked[j].rank
+		})
+		for _, r := range ranked {
+			rankings[i] = append(rankings[i], r.option)
+		}
+	}
+
+	eligible := len(members)
+	if !recorded {
+		userCnt, err := s.UserCount(ctx)
+		if err != nil {
+			return BallotResult{}, fmt.Errorf("counting users: %v", err)
+		}
+		eligible = userCnt
+	}
+
+	result := BallotResult{
+		Voters:   len(rankings),
+		Eligible: eligible,
+		Quorum:   quorum,
+	}
+	result.QuorumMet = result.Voters > 0 &&
+		float64(result.Voters) >= quorum*float64(result.Eligible)
+	result.Winner, result.Rounds = InstantRunoff(options, rankings)
+
+	return result, nil
+}
+
+// ballotMembers returns the members who may rank the given options: those who
+// were members when every one of them entered discussion, authors included.
+// If any option entered discussion before voters were recorded, or there are
+// no options, false is returned.
+func ballotMembers(options []BHAP) (map[Key]bool, bool) {
+	var members map[Key]bool
+	for _, b := range options {
+		if b.VotersDate.IsZero() {
+			return nil, false
+		}
+
+		// Authors are left out of the voters, but they were members too
+		these := map[Key]bool{b.Author: true}
+		for _, key := range b.CoAuthors {
+			these[key] = true
+		}
+		for _, key := range b.Voters {
+			these[key] = true
+		}
+
+		if members == nil {
+			members = these
+			continue
+		}
+		for key := range members {
+			if !these[key] {
+				delete(members, key)
+			}
+		}
+	}
+
+	return members, members != nil
+}
+
+// CheckBallot closes a ballot once every member has ranked its options.
+func CheckBallot(ctx context.Context, s Store, ballotKey Key, ballot Ballot) error {
+	if ballot.Closed {
+		return nil
+	}
+
+	result, err := CountBallot(ctx, s, ballot)
+	if err != nil {
+		return err
+	}
+
+	if result.Voters != result.Eligible {
+		return nil
+	}
+
+	return CloseBallot(ctx, s, ballotKey, ballot)
+}
+
+// CloseBallot decides a ballot. If the quorum is met, the winner of the
+// instant-runoff count is accepted. Every other option still in discussion is
+// rejected. Options that left discussion early are taken off the ballot.
+func CloseBallot(ctx context.Context, s Store, ballotKey Key, ballot Ballot) error {
+	result, err := CountBallot(ctx, s, ballot)
+	if err != nil {
+		return err
+	}
+
+	if !result.QuorumMet {
+		log.Infof(ctx, "ballot %v did not reach quorum with %v of %v voters",
+			ballot.UID, result.Voters, result.Eligible)
+		result.Winner = ""
+	}
+
+	ballot.Closed = true
+	ballot.Winner = result.Winner
+	ballot.Quorum = result.Quorum
+	ballot.Counted = nil
+	if len(result.Rounds) > 0 {
+		for key := range result.Rounds[0].Counts {
+			ballot.Counted = append(ballot.Counted, key)
+		}
+	}
+	// Keep the counted options in ballot order
+	ballot.Counted = inOrder(ballot.Options, ballot.Counted)
+
+	if err := s.PutBallot(ctx, ballotKey, ballot); err != nil {
+		return fmt.Errorf("saving ballot: %v", err)
+	}
+
+	counted := make(map[Key]bool)
+	for _, key := range ballot.Counted {
+		counted[key] = true
+	}
+
+	for _, key := range ballot.Options {
+		b, err := s.ByKey(ctx, key)
+		if err != nil {
+			return fmt.Errorf("loading option: %v", err)
+		}
+
+		if !counted[key] {
+			// The option can be voted on by itself from now on
+			b.Ballot = ""
+			if err := s.PutBHAP(ctx, key, b); err != nil {
+				return fmt.Errorf("taking BHAP %v off the ballot: %v", b.ID, err)
+			}
+			continue
+		}
+
+		decision := RejectedStatus
+		if key == result.Winner {
+			decision = AcceptedStatus
+		}
+		if _, err := ChangeStatus(ctx, s, key, b, decision, SystemActor); err != nil {
+			return fmt.Errorf("finalizing BHAP %v: %v", b.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// inOrder returns the keys in subset, in the order they appear in all.
+func inOrder(all, subset []Key) []Key {
+	wanted := make(map[Key]bool)
+	for _, key := range subset {
+		wanted[key] = true
+	}
+
+	var ordered []Key
+	for _, key := range all {
+		if wanted[key] {
+			ordered = append(ordered, key)
+		}
+	}
+	return ordered
+}
@@ -0,0 +1,169 @@
+package bhap
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestInstantRunoff(t *testing.T) {
+	options := []Key{"a", "b", "c"}
+
+	tests := []struct {
+		name     string
+		options  []Key
+		rankings [][]Key
+		// wantEliminated holds the options knocked out in each round
+		wantEliminated [][]Key
+		wantWinner     Key
+		// wantExhausted is the number of exhausted rankings in the last
+		// round
+		wantExhausted int
+	}{
+		{
+			name:           "majority in the first round",
+			options:        options,
+			rankings:       [][]Key{{"b"}, {"b", "a"}, {"a"}},
+			wantEliminated: [][]Key{nil},
+			wantWinner:     "b",
+		},
+		{
+			name:           "preferences move to the next option",
+			options:        options,
+			rankings:       [][]Key{{"a"}, {"a"}, {"b"}, {"b"}, {"c", "a"}},
+			wantEliminated: [][]Key{{"c"}, nil},
+			wantWinner:     "a",
+		},
+		{
+			name:           "options tied for fewest are eliminated together",
+			options:        options,
+			rankings:       [][]Key{{"a"}, {"a"}, {"b", "a"}, {"c", "a"}},
+			wantEliminated: [][]Key{{"b", "c"}, nil},
+			wantWinner:     "a",
+		},
+		{
+			name:           "exhausted rankings don't count toward a majority",
+			options:        options,
+			rankings:       [][]Key{{"a"}, {"a"}, {"a"}, {"b"}, {"b"}, {"c"}},
+			wantEliminated: [][]Key{{"c"}, nil},
+			wantWinner:     "a",
+			wantExhausted:  1,
+		},
+		{
+			name:           "exhausted rankings can leave a tie",
+			options:        options,
+			rankings:       [][]Key{{"a"}, {"a"}, {"b"}, {"c"}, {"c"}},
+			wantEliminated: [][]Key{{"b"}, {"a", "c"}},
+			wantExhausted:  1,
+		},
+		{
+			name:           "everyone tied",
+			options:        options,
+			rankings:       [][]Key{{"a"}, {"b"}, {"c"}},
+			wantEliminated: [][]Key{{"a", "b", "c"}},
+		},
+		{
+			name:           "no rankings",
+			options:        options,
+			wantEliminated: [][]Key{{"a", "b", "c"}},
+		},
+		{
+			name:           "every ranking exhausted",
+			options:        options,
+			rankings:       [][]Key{{"d"}, {}},
+			wantEliminated: [][]Key{{"a", "b", "c"}},
+			wantExhausted:  2,
+		},
+		{
+			name:     "no options",
+			rankings: [][]Key{{"a"}},
+		},
+	}
+
+	for _, test := range tests {
+		winner, rounds := InstantRunoff(test.options, test.rankings)
+		if winner != test.wantWinner {
+			t.Errorf("%v: got winner %q, want %q", test.name, winner, test.wantWinner)
+		}
+
+		var eliminated [][]Key
+		for _, round := range rounds {
+			eliminated = append(eliminated, round.Eliminated)
+		}
+		if !reflect.DeepEqual(eliminated, test.wantEliminated) {
+			t.Errorf("%v: got eliminations %v, want %v",
+				test.name, eliminated, test.wantEliminated)
+		}
+
+		if len(rounds) == 0 {
+			continue
+		}
+		if exhausted := rounds[len(rounds)-1].Exhausted; exhausted != test.wantExhausted {
+			t.Errorf("%v: got %v exhausted rankings, want %v",
+				test.name, exhausted, test.wantExhausted)
+		}
+	}
+}
+
+func TestBallotMembers(t *testing.T) {
+	entered := time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		options      []BHAP
+		wantMembers  []Key
+		wantRecorded bool
+	}{
+		{
+			name: "one option",
+			options: []BHAP{
+				{Author: "a", Voters: []Key{"b", "c"}, VotersDate: entered},
+			},
+			wantMembers:  []Key{"a", "b", "c"},
+			wantRecorded: true,
+		},
+		{
+			name: "members of every option",
+			options: []BHAP{
+				{Author: "a", Voters: []Key{"b", "c"}, VotersDate: entered},
+				{Author: "b", CoAuthors: []Key{"d"}, Voters: []Key{"a"},
+					VotersDate: entered},
+			},
+			wantMembers:  []Key{"a", "b"},
+			wantRecorded: true,
+		},
+		{
+			name: "option from before voters were recorded",
+			options: []BHAP{
+				{Author: "a", Voters: []Key{"b"}, VotersDate: entered},
+				{Author: "b"},
+			},
+		},
+		{
+			name: "no options",
+		},
+	}
+
+	for _, test := range tests {
+		members, recorded := ballotMembers(test.options)
+		if recorded != test.wantRecorded {
+			t.Errorf("%v: got recorded %v, want %v",
+				test.name, recorded, test.wantRecorded)
+		}
+
+		want := make(map[Key]bool)
+		for _, key := range test.wantMembers {
+			want[key] = true
+		}
+		if len(members) != len(want) {
+			t.Errorf("%v: got members %v, want %v", test.name, members, test.wantMembers)
+			continue
+		}
+		for key := range members {
+			if !want[key] {
+				t.Errorf("%v: got members %v, want %v", test.name, members, test.wantMembers)
+				break
+			}
+		}
+	}
+}
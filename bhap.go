@@ -1,12 +1,9 @@
 package bhap
 
 import (
-	"context"
+	"errors"
 	"fmt"
-	"sort"
 	"time"
-
-	"google.golang.org/appengine/datastore"
 )
 
 // Status describes the current status of a BHAP.
@@ -43,10 +40,46 @@ const (
 	HouseRuleBHAPType BHAPType = "House Rule"
 )
 
-const BHAPEntityName = "BHAP"
+const (
+	// FirstMetaID is the ID of the first Meta BHAP.
+	FirstMetaID = 0
+	// FirstHouseRuleID is the ID of the first House Rule BHAP. Meta BHAPs
+	// must have IDs below this one.
+	FirstHouseRuleID = 100
+)
+
+// ErrIDsExhausted is returned when there are no IDs left to give to BHAPs of
+// a certain type. This happens once there have been 100 Meta BHAPs, since any
+// more would collide with House Rule IDs.
+var ErrIDsExhausted = errors.New("no BHAP IDs are left for this type")
+
+// FirstID returns the ID that the first BHAP of the given type receives.
+func FirstID(typ BHAPType) (int, error) {
+	switch typ {
+	case MetaBHAPType:
+		return FirstMetaID, nil
+	case HouseRuleBHAPType:
+		return FirstHouseRuleID, nil
+	default:
+		return 0, fmt.Errorf("unknown BHAP type %v", typ)
+	}
+}
+
+// InIDRange returns true if the ID falls within the numbering convention for
+// the given type.
+func InIDRange(typ BHAPType, id int) bool {
+	switch typ {
+	case MetaBHAPType:
+		return id >= FirstMetaID && id < FirstHouseRuleID
+	case HouseRuleBHAPType:
+		return id >= FirstHouseRuleID
+	default:
+		return false
+	}
+}
 
-// BHAP contains info on a BHAP proposal. It is meant to be persisted in
-// Datastore.
+// BHAP contains info on a BHAP proposal. It is meant to be persisted in a
+// Store.
 type BHAP struct {
 	// DraftID is the ID to refer to this BHAP by before it leaves the draft
 	// stage and is assigned a normal ID
@@ -56,124 +89,83 @@ type BHAP struct {
 	Title            string
 	ShortDescription string
 	LastModified     time.Time
-	Author           *datastore.Key
+	Author           Key
 	Status           Status
 	CreatedDate      time.Time
 	Type             BHAPType
 	// Stored in Markdown
 	Content string `datastore:"Content,noindex"`
+	// DeferReason explains why a deferred BHAP was put on hold
+	DeferReason string `datastore:"DeferReason,noindex"`
+	// ResumeDate is when a deferred BHAP goes back to discussion. It is zero
+	// if the BHAP was deferred indefinitely
+	ResumeDate time.Time
+	// Replaces holds the keys of accepted BHAPs that this BHAP supersedes.
+	// They are replaced once this BHAP is accepted
+	Replaces []Key
+	// ReplacedBy is the key of the BHAP that superseded this one, if any
+	ReplacedBy Key
+	// VotingPeriodDays is how many days the BHAP stays in discussion before
+	// its vote closes. If zero, the period from the settings is used
+	VotingPeriodDays int
+	// VotingDeadline is when the vote on this BHAP closes. It is set each
+	// time the BHAP enters discussion
+	VotingDeadline time.Time
+	// Ballot is the key of the ballot this BHAP is an option on, if any.
+	// BHAPs on a ballot are ranked against each other instead of being
+	// voted on by themselves
+	Ballot Key
+	// SecretBallot is set when the author wants votes on the BHAP kept
+	// secret. Only who voted is recorded for each member. Their choices are
+	// counted without being linked to them
+	SecretBallot bool
+	// Voters holds the keys of the members eligible to vote on the BHAP.
+	// They are recorded when it enters discussion, so that members joining
+	// or leaving don't change a vote already underway
+	Voters []Key
+	// VotersDate is when Voters was recorded. It is zero for BHAPs that
+	// entered discussion before voters were recorded
+	VotersDate time.Time
+	// Revision is the number of the BHAP's latest revision. It is zero for
+	// BHAPs that haven't been saved since revisions were recorded
+	Revision int
+	// SubstantiveRevision is the number of the latest revision that changed
+	// what is being voted on. Votes cast on earlier revisions are outdated
+	SubstantiveRevision int
+	// CoAuthors holds the keys of members who accepted the author's
+	// invitation to co-author the BHAP. They can edit and advance it like
+	// the author can, and like the author, they can't vote on it
+	CoAuthors []Key
+	// InvitedCoAuthors holds the keys of members who have been invited to
+	// co-author the BHAP but haven't yet accepted
+	InvitedCoAuthors []Key
+	// RemindersSent is how many of the reminders on the schedule in the
+	// settings have gone out to members who haven't voted on the BHAP since
+	// it last entered discussion
+	RemindersSent int
+	// DecidedDate is when the BHAP was last accepted, rejected or
+	// withdrawn. It is zero for BHAPs that haven't been, or were before
+	// decisions were dated
+	DecidedDate time.Time
 }
 
-// ByDraftID gets a BHAP by the given draft ID. If none exists, the key will
-// equal nil.
-func ByDraftID(ctx context.Context, draftID string) (BHAP, *datastore.Key, error) {
-	var results []BHAP
-	query := datastore.NewQuery(BHAPEntityName).
-		Filter("DraftID =", draftID).
-		Limit(1)
-	keys, err := query.GetAll(ctx, &results)
-	if err != nil {
-		return BHAP{}, nil, fmt.Errorf("by draft ID %v: %v", draftID, err)
+// IsAuthor returns true if the user is the author or a co-author of the BHAP.
+func (b BHAP) IsAuthor(userKey Key) bool {
+	if userKey == "" {
+		return false
 	}
-
-	if len(results) == 0 {
-		return BHAP{}, nil, nil
+	if userKey == b.Author {
+		return true
 	}
-
-	return results[0], keys[0], nil
+	return containsKey(b.CoAuthors, userKey)
 }
 
-// ByID gets a BHAP by the given ID. If none exists, the key will equal nil.
-func ByID(ctx context.Context, id int) (BHAP, *datastore.Key, error) {
-	var results []BHAP
-	query := datastore.NewQuery(BHAPEntityName).
-		Filter("ID =", id).
-		Limit(1)
-	keys, err := query.GetAll(ctx, &results)
-	if err != nil {
-		return BHAP{}, nil, fmt.Errorf("by ID %v: %v", id, err)
-	}
-
-	if len(results) == 0 {
-		return BHAP{}, nil, nil
-	}
-
-	return results[0], keys[0], nil
-}
-
-// NextID returns the next unused ID for a new BHAP, using the numbering
-// convention that fits for the given type.
-func NextID(ctx context.Context, typ BHAPType) (int, error) {
-	// TODO(velovix): Nasty race condition here. Some kind of database lock
-	// should fix this
-	query := datastore.NewQuery(BHAPEntityName).
-		Order("-ID")
-	var indexStart int
-
-	if typ == MetaBHAPType {
-		query = query.Filter("ID <", 100)
-		indexStart = 0
-	} else if typ == HouseRuleBHAPType {
-		query = query.Filter("ID >=", 100)
-		indexStart = 100
+// VotingPeriod returns how long the BHAP stays in discussion before its vote
+// closes.
+func (b BHAP) VotingPeriod() time.Duration {
+	days := b.VotingPeriodDays
+	if days <= 0 {
+		days = CurrentSettings().VotingPeriodDays
 	}
-
-	var results []BHAP
-	if _, err := query.GetAll(ctx, &results); err != nil {
-		return 0, err
-	}
-
-	if len(results) == 0 {
-		return indexStart, nil
-	} else {
-		return results[0].ID + 1, nil
-	}
-}
-
-// GetAll returns all recorded BHAPs.
-func GetAll(ctx context.Context) ([]BHAP, error) {
-	var results []BHAP
-	_, err := datastore.NewQuery(BHAPEntityName).
-		Order("ID").
-		GetAll(ctx, &results)
-	if err != nil {
-		return nil, err
-	}
-
-	return results, nil
-}
-
-// ByStatus returns all BHAPs with the given status(es).
-func ByStatus(ctx context.Context, statuses ...Status) ([]BHAP, error) {
-	allResults := make([][]BHAP, len(statuses))
-
-	// Get BHAPs for every status
-	for i, status := range statuses {
-		_, err := datastore.NewQuery(BHAPEntityName).
-			Order("ID").
-			Filter("Status =", status).
-			GetAll(ctx, &allResults[i])
-		if err != nil {
-			return nil, fmt.Errorf("finding %v BHAPs: %v", status, err)
-		}
-	}
-
-	// Combine the BHAP collections into a single set
-	resultSet := make(map[BHAP]bool)
-	for _, section := range allResults {
-		for _, result := range section {
-			resultSet[result] = true
-		}
-	}
-
-	// Sort the results
-	sorted := make([]BHAP, 0)
-	for result, _ := range resultSet {
-		sorted = append(sorted, result)
-	}
-	sort.Slice(sorted, func(i, j int) bool {
-		return sorted[i].ID < sorted[j].ID
-	})
-
-	return sorted, nil
+	return time.Duration(days) * 24 * time.Hour
 }
@@ -0,0 +1,217 @@
+package bhap_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/house-emoji/bhap"
+	"github.com/house-emoji/bhap/memstore"
+)
+
+func TestCloseBallot(t *testing.T) {
+	tests := []struct {
+		name string
+		// rankings holds each member's options in order of preference, by
+		// ID
+		rankings   map[string][]int
+		wantWinner int
+	}{
+		{
+			name: "winner",
+			rankings: map[string][]int{
+				"alice": {100, 101},
+				"bob":   {100},
+				"carol": {101, 100},
+			},
+			wantWinner: 100,
+		},
+		{
+			name: "winner after a runoff",
+			rankings: map[string][]int{
+				"author": {100, 101},
+				"alice":  {101},
+				"bob":    {101},
+				"carol":  {102},
+			},
+			wantWinner: 101,
+		},
+		{
+			name: "no quorum",
+			rankings: map[string][]int{
+				"alice": {100, 101},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		ctx := context.Background()
+		s := memstore.New()
+
+		author := newTestUser(t, s, "author")
+		members := map[string]bhap.Key{"author": author}
+		for _, name := range []string{"alice", "bob", "carol"} {
+			members[name] = newTestUser(t, s, name)
+		}
+
+		ballot := bhap.Ballot{
+			UID:         "ballot",
+			Title:       "Quiet hours",
+			CreatedBy:   author,
+			CreatedDate: time.Now(),
+			Deadline:    time.Now(),
+		}
+		ballotKey, err := s.NewBallot(ctx, ballot)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The last option left discussion before the ballot closed
+		keys := make(map[int]bhap.Key)
+		for id := 100; id <= 103; id++ {
+			status := bhap.DiscussionStatus
+			if id == 103 {
+				status = bhap.WithdrawnStatus
+			}
+			keys[id] = newTestBHAP(t, s, bhap.BHAP{
+				ID:     id,
+				Author: author,
+				Status: status,
+				Ballot: ballotKey,
+			})
+			ballot.Options = append(ballot.Options, keys[id])
+		}
+		if err := s.PutBallot(ctx, ballotKey, ballot); err != nil {
+			t.Fatal(err)
+		}
+
+		for name, ranking := range test.rankings {
+			for i, id := range ranking {
+				if err := bhap.RankVote(ctx, s, keys[id], members[name], i+1); err != nil {
+					t.Fatalf("%v: ranking: %v", test.name, err)
+				}
+			}
+		}
+
+		if err := bhap.CloseBallot(ctx, s, ballotKey, ballot); err != nil {
+			t.Fatalf("%v: closing ballot: %v", test.name, err)
+		}
+
+		closed, err := s.BallotByKey(ctx, ballotKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !closed.Closed || closed.Winner != keys[test.wantWinner] {
+			t.Errorf("%v: got ballot closed %v with winner %q, want closed with %q",
+				test.name, closed.Closed, closed.Winner, keys[test.wantWinner])
+		}
+		if len(closed.Counted) != 3 {
+			t.Errorf("%v: got %v options counted, want the 3 in discussion",
+				test.name, len(closed.Counted))
+		}
+
+		for id, key := range keys {
+			b, err := s.ByKey(ctx, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := bhap.RejectedStatus
+			if id == test.wantWinner {
+				want = bhap.AcceptedStatus
+			} else if id == 103 {
+				want = bhap.WithdrawnStatus
+				if b.Ballot != "" {
+					t.Errorf("%v: withdrawn option is still on the ballot", test.name)
+				}
+			}
+			if b.Status != want {
+				t.Errorf("%v: got BHAP %v %v, want %v", test.name, id, b.Status, want)
+			}
+		}
+	}
+}
+
+func TestCountBallotQuorum(t *testing.T) {
+	defer bhap.SetSettings(bhap.CurrentSettings())
+	settings := bhap.DefaultSettings()
+	settings.VotingRules[bhap.MetaBHAPType] = bhap.VotingRule{
+		Threshold: bhap.TwoThirds,
+		Quorum:    1,
+	}
+	bhap.SetSettings(settings)
+
+	ctx := context.Background()
+	s := memstore.New()
+	author := newTestUser(t, s, "author")
+	alice := newTestUser(t, s, "alice")
+	newTestUser(t, s, "bob")
+	newTestUser(t, s, "carol")
+
+	ballot := bhap.Ballot{
+		UID:         "ballot",
+		Title:       "Quiet hours",
+		CreatedBy:   author,
+		CreatedDate: time.Now(),
+		Deadline:    time.Now(),
+	}
+	ballotKey, err := s.NewBallot(ctx, ballot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The Meta BHAP left discussion, so its stricter quorum doesn't apply
+	for _, b := range []bhap.BHAP{
+		{ID: 100, Status: bhap.DiscussionStatus},
+		{ID: 101, Status: bhap.DiscussionStatus},
+		{ID: 102, Status: bhap.WithdrawnStatus, Type: bhap.MetaBHAPType},
+	} {
+		b.Author = author
+		b.Ballot = ballotKey
+		ballot.Options = append(ballot.Options, newTestBHAP(t, s, b))
+	}
+	if err := s.PutBallot(ctx, ballotKey, ballot); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, voter := range []bhap.Key{author, alice} {
+		if err := bhap.RankVote(ctx, s, ballot.Options[i], voter, 1); err != nil {
+			t.Fatalf("ranking: %v", err)
+		}
+	}
+
+	result, err := bhap.CountBallot(ctx, s, ballot)
+	if err != nil {
+		t.Fatalf("counting open ballot: %v", err)
+	}
+	if result.Quorum != 0.5 || !result.QuorumMet {
+		t.Errorf("open ballot: got quorum %v met %v, want 0.5 met",
+			result.Quorum, result.QuorumMet)
+	}
+
+	if err := bhap.CloseBallot(ctx, s, ballotKey, ballot); err != nil {
+		t.Fatalf("closing ballot: %v", err)
+	}
+	closed, err := s.BallotByKey(ctx, ballotKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if closed.Quorum != 0.5 {
+		t.Errorf("got quorum %v recorded at close, want 0.5", closed.Quorum)
+	}
+
+	// The result stands by the quorum in force when the ballot closed
+	settings.VotingRules[bhap.HouseRuleBHAPType] = bhap.VotingRule{
+		Threshold: bhap.SimpleMajority,
+		Quorum:    1,
+	}
+	bhap.SetSettings(settings)
+	result, err = bhap.CountBallot(ctx, s, closed)
+	if err != nil {
+		t.Fatalf("counting closed ballot: %v", err)
+	}
+	if result.Quorum != 0.5 || !result.QuorumMet {
+		t.Errorf("closed ballot: got quorum %v met %v, want 0.5 met",
+			result.Quorum, result.QuorumMet)
+	}
+}
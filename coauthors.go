@@ -0,0 +1,115 @@
+package bhap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrAlreadyInvited is returned when inviting a member to co-author a
+	// BHAP they already author or have already been invited to.
+	ErrAlreadyInvited = errors.New("the member is already an author or has been invited")
+	// ErrNotInvited is returned when accepting or declining an invitation to
+	// co-author a BHAP that the member was never sent.
+	ErrNotInvited = errors.New("the member has not been invited to co-author the BHAP")
+	// ErrNotCoAuthor is returned when removing a member who is neither a
+	// co-author of the BHAP nor invited to be one.
+	ErrNotCoAuthor = errors.New("the member is not a co-author of the BHAP")
+)
+
+// InviteCoAuthor invites a member to co-author a BHAP. They become a
+// co-author once they accept.
+func InviteCoAuthor(ctx context.Context, s Store, key Key, b BHAP, userKey Key) (BHAP, error) {
+	if b.IsAuthor(userKey) || containsKey(b.InvitedCoAuthors, userKey) {
+		return BHAP{}, ErrAlreadyInvited
+	}
+
+	b.InvitedCoAuthors = append(b.InvitedCoAuthors, userKey)
+	if err := s.PutBHAP(ctx, key, b); err != nil {
+		return BHAP{}, fmt.Errorf("saving BHAP: %v", err)
+	}
+
+	return b, nil
+}
+
+// AcceptCoAuthorship makes an invited member a co-author of a BHAP. Authors
+// can't vote, so the member stops being one of its voters and any vote they
+// cast is thrown out. With one less voter, this may decide the vote.
+func AcceptCoAuthorship(ctx context.Context, s Store, key Key, b BHAP, userKey Key) (BHAP, error) {
+	if !containsKey(b.InvitedCoAuthors, userKey) {
+		return BHAP{}, ErrNotInvited
+	}
+
+	b.InvitedCoAuthors = removeKey(b.InvitedCoAuthors, userKey)
+	b.CoAuthors = append(b.CoAuthors, userKey)
+	b.Voters = removeKey(b.Voters, userKey)
+
+	if err := throwOutIneligibleVotes(ctx, s, key, b, time.Now()); err != nil {
+		return BHAP{}, err
+	}
+	if err := s.PutBHAP(ctx, key, b); err != nil {
+		return BHAP{}, fmt.Errorf("saving BHAP: %v", err)
+	}
+
+	if b.Status == DiscussionStatus {
+		if err := CheckVotes(ctx, s, key, b); err != nil {
+			return BHAP{}, fmt.Errorf("checking votes: %v", err)
+		}
+	}
+
+	return b, nil
+}
+
+// DeclineCoAuthorship turns down a member's invitation to co-author a BHAP.
+func DeclineCoAuthorship(ctx context.Context, s Store, key Key, b BHAP, userKey Key) (BHAP, error) {
+	if !containsKey(b.InvitedCoAuthors, userKey) {
+		return BHAP{}, ErrNotInvited
+	}
+
+	b.InvitedCoAuthors = removeKey(b.InvitedCoAuthors, userKey)
+	if err := s.PutBHAP(ctx, key, b); err != nil {
+		return BHAP{}, fmt.Errorf("saving BHAP: %v", err)
+	}
+
+	return b, nil
+}
+
+// RemoveCoAuthor removes a co-author from a BHAP, or takes back their
+// invitation if they haven't accepted it yet. A co-author removed while the
+// BHAP is in discussion doesn't become a voter until an admin re-tallies it.
+func RemoveCoAuthor(ctx context.Context, s Store, key Key, b BHAP, userKey Key) (BHAP, error) {
+	if !containsKey(b.CoAuthors, userKey) && !containsKey(b.InvitedCoAuthors, userKey) {
+		return BHAP{}, ErrNotCoAuthor
+	}
+
+	b.CoAuthors = removeKey(b.CoAuthors, userKey)
+	b.InvitedCoAuthors = removeKey(b.InvitedCoAuthors, userKey)
+	if err := s.PutBHAP(ctx, key, b); err != nil {
+		return BHAP{}, fmt.Errorf("saving BHAP: %v", err)
+	}
+
+	return b, nil
+}
+
+// containsKey returns true if the key is in the list.
+func containsKey(keys []Key, key Key) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// removeKey returns the list without the given key.
+func removeKey(keys []Key, key Key) []Key {
+	var kept []Key
+	for _, k := range keys {
+		if k != key {
+			kept = append(kept, k)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,165 @@
+package bhap
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Comment is a member's comment on a BHAP. Comments can reply to other
+// comments, forming threads.
+type Comment struct {
+	OnBHAP Key
+	// Parent is the key of the comment this one replies to. It is empty for
+	// comments that start a thread
+	Parent Key
+	Author Key
+	// UID identifies the comment in URLs
+	UID string
+	// Stored in Markdown
+	Content string `datastore:"Content,noindex"`
+	Date    time.Time
+	// Edited is when the comment was last edited. It is zero if it never
+	// was
+	Edited time.Time
+	// Deleted is set when a comment with replies is deleted. Its content is
+	// thrown out, but it's kept so that the replies stay in their thread
+	Deleted bool
+
+	// Anchor is the text of the paragraph or line that an inline comment is
+	// attached to. It is empty for comments on the BHAP as a whole, and for
+	// replies, which stay with the comment they reply to
+	Anchor string `datastore:"Anchor,noindex"`
+	// AnchorParagraph is the number of the paragraph the anchor was in,
+	// starting from 0. It decides between paragraphs that match equally
+	AnchorParagraph int `datastore:"AnchorParagraph,noindex"`
+	// AnchorRevision is the revision of the BHAP the comment was attached to
+	AnchorRevision int `datastore:"AnchorRevision,noindex"`
+}
+
+// Paragraphs splits Markdown content at blank lines, except inside fenced
+// code blocks. Blank lines don't always end a Markdown block, so the pieces
+// are joined back together where needed before comments are attached.
+func Paragraphs(content string) []string {
+	content = strings.Replace(content, "\r\n", "\n", -1)
+
+	var paragraphs []string
+	var current []string
+	fenced := false
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fenced = !fenced
+		}
+
+		if trimmed == "" && !fenced {
+			if len(current) > 0 {
+				paragraphs = append(paragraphs, strings.Join(current, "\n"))
+				current = nil
+			}
+			continue
+		}
+		current = append(current, line)
+	}
+	if len(current) > 0 {
+		paragraphs = append(paragraphs, strings.Join(current, "\n"))
+	}
+
+	return paragraphs
+}
+
+// FindAnchor returns the number of the paragraph that an inline comment's
+// anchor is found in, either as the whole paragraph or as one of its lines.
+// Changes to spacing are ignored. If the anchor is no longer found, the
+// comment is outdated and false is returned.
+func (c Comment) FindAnchor(paragraphs []string) (int, bool) {
+	matches := func(i int) bool {
+		if sameWords(paragraphs[i], c.Anchor) {
+			return true
+		}
+		for _, line := range strings.Split(paragraphs[i], "\n") {
+			if sameWords(line, c.Anchor) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if c.AnchorParagraph < len(paragraphs) && matches(c.AnchorParagraph) {
+		return c.AnchorParagraph, true
+	}
+	for i := range paragraphs {
+		if matches(i) {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+// DeleteComment deletes a comment. If the comment has replies, its content
+// is thrown out instead, so that the replies stay in their thread.
+func DeleteComment(ctx context.Context, s Store, key Key, c Comment) error {
+	comments, _, err := s.CommentsForBHAP(ctx, c.OnBHAP)
+	if err != nil {
+		return fmt.Errorf("getting comments: %v", err)
+	}
+
+	for _, other := range comments {
+		if other.Parent == key {
+			c.Content = ""
+			c.Deleted = true
+			if err := s.PutComment(ctx, key, c); err != nil {
+				return fmt.Errorf("saving comment: %v", err)
+			}
+			return nil
+		}
+	}
+
+	if err := s.DeleteComment(ctx, key); err != nil {
+		return fmt.Errorf("deleting comment: %v", err)
+	}
+
+	return pruneDeletedParents(ctx, s, c.OnBHAP, c.Parent)
+}
+
+// pruneDeletedParents cleans up after a reply is deleted. A deleted comment
+// is only kept to hold its replies in their thread, so once it has none left,
+// it's deleted too, and so on up the thread.
+func pruneDeletedParents(ctx context.Context, s Store, bhapKey, parentKey Key) error {
+	for parentKey != "" {
+		comments, keys, err := s.CommentsForBHAP(ctx, bhapKey)
+		if err != nil {
+			return fmt.Errorf("getting comments: %v", err)
+		}
+
+		var parent Comment
+		found := false
+		for i, other := range comments {
+			if keys[i] == parentKey {
+				parent = other
+				found = true
+			}
+			if other.Parent == parentKey {
+				return nil
+			}
+		}
+		if !found || !parent.Deleted {
+			return nil
+		}
+
+		if err := s.DeleteComment(ctx, parentKey); err != nil {
+			return fmt.Errorf("deleting comment: %v", err)
+		}
+		parentKey = parent.Parent
+	}
+
+	return nil
+}
+
+// AnchorsWhole returns true if an inline comment is attached to the whole of
+// the given paragraph, rather than just one of its lines.
+func (c Comment) AnchorsWhole(paragraph string) bool {
+	return sameWords(paragraph, c.Anchor)
+}
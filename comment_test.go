@@ -0,0 +1,79 @@
+package bhap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParagraphs(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"empty", "", nil},
+		{"one line", "Be quiet.", []string{"Be quiet."}},
+		{"lines", "Be quiet.\nThanks.", []string{"Be quiet.\nThanks."}},
+		{"blank line", "Be quiet.\n\nThanks.", []string{"Be quiet.", "Thanks."}},
+		{"blank lines", "\n\nBe quiet.\n\n\n\nThanks.\n\n", []string{"Be quiet.", "Thanks."}},
+		{"spaces on a blank line", "Be quiet.\n  \t\nThanks.", []string{"Be quiet.", "Thanks."}},
+		{"Windows line endings", "Be quiet.\r\n\r\nThanks.\r\n", []string{"Be quiet.", "Thanks."}},
+		{
+			"fenced code",
+			"Like so:\n\n```\nquiet\n\nhours\n```\n\nThanks.",
+			[]string{"Like so:", "```\nquiet\n\nhours\n```", "Thanks."},
+		},
+		{
+			"tilde fence",
+			"~~~\nquiet\n\nhours\n~~~\nThanks.",
+			[]string{"~~~\nquiet\n\nhours\n~~~\nThanks."},
+		},
+		{
+			"unclosed fence",
+			"```\nquiet\n\nhours",
+			[]string{"```\nquiet\n\nhours"},
+		},
+	}
+
+	for _, test := range tests {
+		if got := Paragraphs(test.content); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestCommentFindAnchor(t *testing.T) {
+	paragraphs := []string{
+		"Be quiet after ten.",
+		"Except on:\nFridays\nSaturdays",
+		"Thanks.",
+		"Thanks.",
+	}
+
+	tests := []struct {
+		name      string
+		anchor    string
+		paragraph int
+		want      int
+		wantFound bool
+	}{
+		{"whole paragraph", "Be quiet after ten.", 0, 0, true},
+		{"line", "Saturdays", 1, 1, true},
+		{"moved", "Saturdays", 0, 1, true},
+		{"spacing changed", "  Be quiet   after\nten. ", 0, 0, true},
+		{"repeated, at its paragraph", "Thanks.", 3, 3, true},
+		{"repeated, moved", "Thanks.", 0, 2, true},
+		{"past the end", "Except on:\nFridays\nSaturdays", 9, 1, true},
+		{"part of a line", "Fridays and Saturdays", 1, 0, false},
+		{"gone", "Be loud.", 0, 0, false},
+	}
+
+	for _, test := range tests {
+		c := Comment{Anchor: test.anchor, AnchorParagraph: test.paragraph}
+		got, found := c.FindAnchor(paragraphs)
+		if got != test.want || found != test.wantFound {
+			t.Errorf("%v: got %v, %v, want %v, %v",
+				test.name, got, found, test.want, test.wantFound)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package bhap
+
+import (
+	"context"
+	"net/http"
+)
+
+var contextFunc = func(r *http.Request) context.Context {
+	return r.Context()
+}
+
+// RequestContext returns the context that a request should be handled with.
+func RequestContext(r *http.Request) context.Context {
+	return contextFunc(r)
+}
+
+// SetContextFunc replaces how request contexts are created. By default, the
+// request's own context is used, but App Engine needs contexts of its own to
+// use its services.
+func SetContextFunc(f func(r *http.Request) context.Context) {
+	contextFunc = f
+}
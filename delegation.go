@@ -0,0 +1,229 @@
+package bhap
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Delegation lets a member's vote be cast on their behalf by another member,
+// like while they are away. While a delegation applies, the delegate's vote
+// on a BHAP is copied to the member as a proxy vote, unless the member has
+// voted themselves.
+type Delegation struct {
+	// UID identifies the delegation in URLs
+	UID string
+	// From is the key of the member whose vote is delegated
+	From Key
+	// To is the key of the member who votes on their behalf
+	To Key
+	// BHAP limits the delegation to a single BHAP. If empty, the delegation
+	// applies to every vote from Start until End.
+	BHAP  Key
+	Start time.Time
+	// End is when the delegation stops applying. It is exclusive.
+	End         time.Time
+	CreatedDate time.Time
+}
+
+// Covers returns true if the delegation applies to votes on the given BHAP
+// at the given time.
+func (d Delegation) Covers(bhapKey Key, now time.Time) bool {
+	if d.BHAP != "" {
+		return d.BHAP == bhapKey
+	}
+	return !now.Before(d.Start) && now.Before(d.End)
+}
+
+// Overlaps returns true if both delegations could apply to the same vote
+// for the same member. A delegation for a single BHAP only overlaps another
+// for the same BHAP, since it takes precedence over date ranges.
+func (d Delegation) Overlaps(other Delegation) bool {
+	if d.From != other.From {
+		return false
+	}
+	if d.BHAP != "" || other.BHAP != "" {
+		return d.BHAP == other.BHAP
+	}
+	return d.Start.Before(other.End) && other.Start.Before(d.End)
+}
+
+// DelegateFor returns the key of the member who votes on behalf of the user
+// on the given BHAP at the given time, or an empty key if nobody does. A
+// delegation for the BHAP itself takes precedence over a date range.
+func DelegateFor(ctx context.Context, s Store, userKey, bhapKey Key, now time.Time) (Key, error) {
+	delegations, _, err := s.DelegationsFrom(ctx, userKey)
+	if err != nil {
+		return "", fmt.Errorf("getting delegations: %v", err)
+	}
+
+	var delegate Key
+	for _, d := range delegations {
+		if !d.Covers(bhapKey, now) {
+			continue
+		}
+		if d.BHAP != "" {
+			return d.To, nil
+		}
+		delegate = d.To
+	}
+
+	return delegate, nil
+}
+
+// Delegate saves a new delegation and casts proxy votes on the BHAPs it
+// already applies to.
+func Delegate(ctx context.Context, s Store, d Delegation) (Key, error) {
+	key, err := s.NewDelegation(ctx, d)
+	if err != nil {
+		return "", fmt.Errorf("saving delegation: %v", err)
+	}
+
+	if err := syncDelegation(ctx, s, d); err != nil {
+		return "", err
+	}
+
+	return key, nil
+}
+
+// RevokeDelegation deletes a delegation and takes back the proxy votes cast
+// under it on BHAPs that are still being voted on.
+func RevokeDelegation(ctx context.Context, s Store, key Key, d Delegation) error {
+	if err := s.DeleteDelegation(ctx, key); err != nil {
+		return fmt.Errorf("deleting delegation: %v", err)
+	}
+
+	return syncDelegation(ctx, s, d)
+}
+
+// syncDelegation brings the proxy votes of the delegating member up to date
+// on every BHAP open for voting that the delegation applies to.
+func syncDelegation(ctx context.Context, s Store, d Delegation) error {
+	discussion, err := s.ByStatus(ctx, DiscussionStatus)
+	if err != nil {
+		return fmt.Errorf("getting discussion BHAPs: %v", err)
+	}
+
+	now := time.Now()
+	for _, b := range discussion {
+		if b.Ballot != "" || VotingClosed(b, now) {
+			continue
+		}
+
+		_, key, err := s.ByID(ctx, b.ID)
+		if err != nil {
+			return fmt.Errorf("loading BHAP %v: %v", b.ID, err)
+		}
+		if !d.Covers(key, now) {
+			continue
+		}
+
+		if err := syncProxyVote(ctx, s, key, b, d.From, now); err != nil {
+			return err
+		}
+		if err := CheckVotes(ctx, s, key, b); err != nil {
+			return fmt.Errorf("checking votes on BHAP %v: %v", b.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// syncDelegates brings proxy votes up to date on a BHAP after a member has
+// changed their vote on it, for everyone who delegated to that member.
+func syncDelegates(ctx context.Context, s Store, bhapKey, userKey Key) error {
+	delegations, _, err := s.DelegationsTo(ctx, userKey)
+	if err != nil {
+		return fmt.Errorf("getting delegations: %v", err)
+	}
+
+	now := time.Now()
+	var b BHAP
+	loaded := false
+	for _, d := range delegations {
+		if !d.Covers(bhapKey, now) {
+			continue
+		}
+
+		if !loaded {
+			if b, err = s.ByKey(ctx, bhapKey); err != nil {
+				return fmt.Errorf("loading BHAP: %v", err)
+			}
+			loaded = true
+		}
+		if err := syncProxyVote(ctx, s, bhapKey, b, d.From, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// syncProxyVote makes the user's vote on a BHAP match that of their current
+// delegate. Votes the user cast themselves are left alone. If the user has
+// no delegate, or the delegate hasn't voted, any proxy vote is taken back.
+// Delegations don't apply to secret ballots.
+func syncProxyVote(ctx context.Context, s Store, bhapKey Key, b BHAP, userKey Key, now time.Time) error {
+	if !b.CanVote(userKey) || b.SecretBallot {
+		return nil
+	}
+
+	current, currentKey, err := s.GetVoteForBHAP(ctx, bhapKey, userKey)
+	if err != nil {
+		return fmt.Errorf("getting vote: %v", err)
+	}
+	if currentKey != "" && current.Proxy == "" {
+		return nil
+	}
+
+	delegate, err := DelegateFor(ctx, s, userKey, bhapKey, now)
+	if err != nil {
+		return err
+	}
+
+	var want Status
+	var wantRevision int
+	if delegate != "" {
+		delegateVote, delegateVoteKey, err := s.GetVoteForBHAP(ctx, bhapKey, delegate)
+		if err != nil {
+			return fmt.Errorf("getting delegate's vote: %v", err)
+		}
+		// Proxies are only ever cast from the delegate's own vote, so
+		// that delegations don't chain
+		if delegateVoteKey != "" && delegateVote.Proxy == "" &&
+			delegateVote.Value != RankedVote {
+			want = delegateVote.Value
+			wantRevision = delegateVote.Revision
+		}
+	}
+
+	if want == "" {
+		if currentKey == "" {
+			return nil
+		}
+		if err := s.DeleteVote(ctx, currentKey); err != nil {
+			return fmt.Errorf("deleting proxy vote: %v", err)
+		}
+		return recordEvent(ctx, s, VoteEvent{
+			OnBHAP:   bhapKey,
+			ByUser:   userKey,
+			Action:   VoteRetracted,
+			Value:    current.Value,
+			Proxy:    current.Proxy,
+			Revision: current.Revision,
+			Date:     now,
+		})
+	}
+
+	if err := s.SetProxyVoteForBHAP(ctx, bhapKey, userKey, delegate, want, wantRevision); err != nil {
+		return fmt.Errorf("setting proxy vote: %v", err)
+	}
+
+	return recordChange(ctx, s, current, currentKey, Vote{
+		OnBHAP:   bhapKey,
+		ByUser:   userKey,
+		Value:    want,
+		Proxy:    delegate,
+		Revision: wantRevision,
+	})
+}
@@ -0,0 +1,100 @@
+package bhap
+
+import (
+	"testing"
+	"time"
+)
+
+// date returns midnight UTC on the given day of March 2018.
+func date(day int) time.Time {
+	return time.Date(2018, 3, day, 0, 0, 0, 0, time.UTC)
+}
+
+// away returns a delegation from one member to another from the start of the
+// first day to the end of the last, like the delegation form makes.
+func away(from Key, firstDay, lastDay int) Delegation {
+	return Delegation{
+		From:  from,
+		To:    "proxy",
+		Start: date(firstDay),
+		End:   date(lastDay).Add(24 * time.Hour),
+	}
+}
+
+func TestDelegationCovers(t *testing.T) {
+	tests := []struct {
+		name       string
+		delegation Delegation
+		bhapKey    Key
+		now        time.Time
+		want       bool
+	}{
+		{"before the first day", away("a", 10, 12), "b1", date(10).Add(-time.Nanosecond), false},
+		{"start of the first day", away("a", 10, 12), "b1", date(10), true},
+		{"middle", away("a", 10, 12), "b1", date(11).Add(15 * time.Hour), true},
+		{"end of the last day", away("a", 10, 12), "b1", date(13).Add(-time.Nanosecond), true},
+		{"day after the last", away("a", 10, 12), "b1", date(13), false},
+		{"one day", away("a", 10, 10), "b1", date(10).Add(23 * time.Hour), true},
+		{"single BHAP", Delegation{From: "a", BHAP: "b1"}, "b1", date(1), true},
+		{"other BHAP", Delegation{From: "a", BHAP: "b1"}, "b2", date(1), false},
+		{
+			name: "single BHAP ignores dates",
+			delegation: Delegation{
+				From:  "a",
+				BHAP:  "b1",
+				Start: date(10),
+				End:   date(11),
+			},
+			bhapKey: "b1",
+			now:     date(20),
+			want:    true,
+		},
+	}
+
+	for _, test := range tests {
+		if got := test.delegation.Covers(test.bhapKey, test.now); got != test.want {
+			t.Errorf("%v: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDelegationOverlaps(t *testing.T) {
+	tests := []struct {
+		name string
+		a    Delegation
+		b    Delegation
+		want bool
+	}{
+		{"same days", away("a", 10, 12), away("a", 10, 12), true},
+		{"inside", away("a", 10, 20), away("a", 12, 14), true},
+		{"sharing the last day", away("a", 10, 12), away("a", 12, 14), true},
+		{"back to back", away("a", 10, 12), away("a", 13, 14), false},
+		{"apart", away("a", 10, 12), away("a", 20, 22), false},
+		{"other member", away("a", 10, 12), away("b", 10, 12), false},
+		{
+			name: "same BHAP",
+			a:    Delegation{From: "a", BHAP: "b1"},
+			b:    Delegation{From: "a", BHAP: "b1"},
+			want: true,
+		},
+		{
+			name: "other BHAP",
+			a:    Delegation{From: "a", BHAP: "b1"},
+			b:    Delegation{From: "a", BHAP: "b2"},
+		},
+		{
+			name: "BHAP and date range",
+			a:    Delegation{From: "a", BHAP: "b1"},
+			b:    away("a", 10, 12),
+		},
+	}
+
+	for _, test := range tests {
+		if got := test.a.Overlaps(test.b); got != test.want {
+			t.Errorf("%v: got %v, want %v", test.name, got, test.want)
+		}
+		if got := test.b.Overlaps(test.a); got != test.want {
+			t.Errorf("%v, reversed: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package bhap_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/house-emoji/bhap"
+	"github.com/house-emoji/bhap/memstore"
+)
+
+func TestDeleteComment(t *testing.T) {
+	ctx := context.Background()
+	s := memstore.New()
+	author := newTestUser(t, s, "author@example.com")
+	alice := newTestUser(t, s, "alice@example.com")
+	bhapKey := newTestBHAP(t, s, bhap.BHAP{ID: 100, Author: author})
+
+	comments := make(map[string]bhap.Comment)
+	keys := make(map[string]bhap.Key)
+	add := func(name, parent string) {
+		c := bhap.Comment{
+			OnBHAP:  bhapKey,
+			Parent:  keys[parent],
+			Author:  alice,
+			UID:     name,
+			Content: "Comment " + name,
+		}
+		key, err := s.NewComment(ctx, c)
+		if err != nil {
+			t.Fatalf("saving comment %v: %v", name, err)
+		}
+		comments[name] = c
+		keys[name] = key
+	}
+	// One thread three deep, another with a single reply, and a comment on
+	// its own
+	add("root", "")
+	add("reply", "root")
+	add("nested", "reply")
+	add("other", "")
+	add("other reply", "other")
+	add("alone", "")
+
+	// remaining checks which comments are left, and which of them are only
+	// kept to hold their replies
+	remaining := func(step string, want map[string]bool) {
+		t.Helper()
+		got, _, err := s.CommentsForBHAP(ctx, bhapKey)
+		if err != nil {
+			t.Fatalf("getting comments: %v", err)
+		}
+		if len(got) != len(want) {
+			t.Errorf("%v: got %v comments, want %v", step, len(got), len(want))
+		}
+		for _, c := range got {
+			deleted, ok := want[c.UID]
+			if !ok {
+				t.Errorf("%v: comment %v is still there", step, c.UID)
+				continue
+			}
+			if c.Deleted != deleted {
+				t.Errorf("%v: comment %v marked deleted %v, want %v",
+					step, c.UID, c.Deleted, deleted)
+			}
+			if c.Deleted && c.Content != "" {
+				t.Errorf("%v: deleted comment %v kept its content %q",
+					step, c.UID, c.Content)
+			}
+		}
+	}
+	deleteComment := func(name string) {
+		t.Helper()
+		if err := bhap.DeleteComment(ctx, s, keys[name], comments[name]); err != nil {
+			t.Fatalf("deleting comment %v: %v", name, err)
+		}
+	}
+
+	deleteComment("alone")
+	deleteComment("root")
+	deleteComment("reply")
+	remaining("deleting comments with replies", map[string]bool{
+		"root":        true,
+		"reply":       true,
+		"nested":      false,
+		"other":       false,
+		"other reply": false,
+	})
+
+	// Once the last reply goes, so do the deleted comments above it
+	deleteComment("nested")
+	remaining("deleting the last reply in a thread", map[string]bool{
+		"other":       false,
+		"other reply": false,
+	})
+
+	// Comments that weren't deleted stay when their replies go
+	deleteComment("other reply")
+	remaining("deleting a reply", map[string]bool{
+		"other": false,
+	})
+}
@@ -0,0 +1,80 @@
+package bhap
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Digest summarizes what happened to BHAPs over a period, for members who
+// would rather hear about it all at once.
+type Digest struct {
+	// Since is when the period started
+	Since time.Time
+	// NewDrafts are the drafts proposed during the period
+	NewDrafts []BHAP
+	// Discussion holds every BHAP currently in discussion, along with how
+	// its vote is going
+	Discussion []DigestEntry
+	// Decided are the BHAPs accepted, rejected or withdrawn during the
+	// period
+	Decided []BHAP
+}
+
+// DigestEntry is a BHAP in discussion as listed in a digest.
+type DigestEntry struct {
+	BHAP BHAP
+	// Tally is the vote so far. It is empty for BHAPs on a ballot, which are
+	// ranked instead
+	Tally Tally
+}
+
+// Empty returns true if nothing happened over the period of the digest.
+func (d Digest) Empty() bool {
+	return len(d.NewDrafts) == 0 && len(d.Discussion) == 0 && len(d.Decided) == 0
+}
+
+// CollectDigest gathers what has happened to BHAPs since the given time.
+func CollectDigest(ctx context.Context, s Store, since time.Time) (Digest, error) {
+	d := Digest{Since: since}
+
+	drafts, err := s.ByStatus(ctx, DraftStatus)
+	if err != nil {
+		return Digest{}, fmt.Errorf("getting drafts: %v", err)
+	}
+	for _, b := range drafts {
+		if !b.CreatedDate.Before(since) {
+			d.NewDrafts = append(d.NewDrafts, b)
+		}
+	}
+
+	discussion, err := s.ByStatus(ctx, DiscussionStatus)
+	if err != nil {
+		return Digest{}, fmt.Errorf("getting discussion BHAPs: %v", err)
+	}
+	for _, b := range discussion {
+		entry := DigestEntry{BHAP: b}
+		if b.Ballot == "" {
+			_, key, err := s.ByID(ctx, b.ID)
+			if err != nil {
+				return Digest{}, fmt.Errorf("loading BHAP %v: %v", b.ID, err)
+			}
+			if entry.Tally, err = CountVotes(ctx, s, key, b); err != nil {
+				return Digest{}, err
+			}
+		}
+		d.Discussion = append(d.Discussion, entry)
+	}
+
+	decided, err := s.ByStatus(ctx, AcceptedStatus, RejectedStatus, WithdrawnStatus)
+	if err != nil {
+		return Digest{}, fmt.Errorf("getting decided BHAPs: %v", err)
+	}
+	for _, b := range decided {
+		if !b.DecidedDate.Before(since) {
+			d.Decided = append(d.Decided, b)
+		}
+	}
+
+	return d, nil
+}
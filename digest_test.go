@@ -0,0 +1,108 @@
+package bhap_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/house-emoji/bhap"
+	"github.com/house-emoji/bhap/memstore"
+)
+
+func TestCollectDigest(t *testing.T) {
+	ctx := context.Background()
+	s := memstore.New()
+	author := newTestUser(t, s, "author@example.com")
+	voter := newTestUser(t, s, "voter@example.com")
+
+	since := time.Date(2018, 1, 8, 9, 0, 0, 0, time.UTC)
+	before := since.Add(-time.Hour)
+	after := since.Add(time.Hour)
+
+	for _, b := range []bhap.BHAP{
+		{Title: "Old draft", Status: bhap.DraftStatus, CreatedDate: before},
+		{Title: "New draft", Status: bhap.DraftStatus, CreatedDate: after},
+		{Title: "Draft at the start", Status: bhap.DraftStatus, CreatedDate: since},
+		{ID: 100, Title: "Old accepted", Status: bhap.AcceptedStatus, DecidedDate: before},
+		{ID: 101, Title: "New accepted", Status: bhap.AcceptedStatus, DecidedDate: after},
+		{ID: 102, Title: "New rejected", Status: bhap.RejectedStatus, DecidedDate: after},
+		{ID: 103, Title: "New withdrawn", Status: bhap.WithdrawnStatus, DecidedDate: after},
+		{ID: 104, Title: "Deferred", Status: bhap.DeferredStatus, CreatedDate: after},
+		{ID: 105, Title: "On a ballot", Ballot: "Ballot/1", CreatedDate: after},
+	} {
+		b.Author = author
+		if b.CreatedDate.IsZero() {
+			b.CreatedDate = before
+		}
+		b.LastModified = b.CreatedDate
+		newTestBHAP(t, s, b)
+	}
+
+	// BHAPs in discussion are listed no matter how long they've been there
+	key := newTestBHAP(t, s, bhap.BHAP{
+		ID:          106,
+		Title:       "Old discussion",
+		Author:      author,
+		Voters:      []bhap.Key{voter},
+		VotersDate:  before,
+		CreatedDate: before,
+	})
+	if err := s.SetVoteForBHAP(ctx, key, voter, bhap.AcceptedStatus, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := bhap.CollectDigest(ctx, s, since)
+	if err != nil {
+		t.Fatalf("collecting digest: %v", err)
+	}
+
+	titles := func(bhaps []bhap.BHAP) []string {
+		var titles []string
+		for _, b := range bhaps {
+			titles = append(titles, b.Title)
+		}
+		return titles
+	}
+	if got, want := titles(d.NewDrafts), []string{"New draft", "Draft at the start"}; !sameStrings(got, want) {
+		t.Errorf("got new drafts %q, want %q", got, want)
+	}
+	if got, want := titles(d.Decided), []string{"New accepted", "New rejected", "New withdrawn"}; !sameStrings(got, want) {
+		t.Errorf("got decided BHAPs %q, want %q", got, want)
+	}
+
+	if len(d.Discussion) != 2 {
+		t.Fatalf("got %v BHAPs in discussion, want 2", len(d.Discussion))
+	}
+	for _, entry := range d.Discussion {
+		switch entry.BHAP.Title {
+		case "Old discussion":
+			if entry.Tally.Accepted != 1 || entry.Tally.Eligible != 1 {
+				t.Errorf("got tally %+v, want the one vote counted", entry.Tally)
+			}
+		case "On a ballot":
+			if entry.Tally != (bhap.Tally{}) {
+				t.Errorf("got tally %+v for a BHAP on a ballot, want none", entry.Tally)
+			}
+		default:
+			t.Errorf("BHAP %q listed as in discussion", entry.BHAP.Title)
+		}
+	}
+}
+
+// sameStrings returns true if both hold the same strings, in any order.
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	counts := make(map[string]int)
+	for _, s := range a {
+		counts[s]++
+	}
+	for _, s := range b {
+		counts[s]--
+		if counts[s] < 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,256 @@
+package bhap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// VoterChange records an admin bringing the eligible voters of a BHAP up to
+// date with the current membership, like after someone joins or leaves the
+// house. Voter changes are never changed or deleted once recorded.
+type VoterChange struct {
+	OnBHAP Key
+	// ByUser is the key of the admin who made the change
+	ByUser Key
+	// ReopenedFrom is the status the BHAP had been decided as, if it was put
+	// back into discussion. It is empty if the BHAP was still in discussion
+	ReopenedFrom Status
+	// Added holds the keys of members who became eligible to vote
+	Added []Key
+	// Removed holds the keys of members who are no longer eligible to vote
+	Removed []Key
+	Date    time.Time
+}
+
+var (
+	// ErrVotersUnchanged is returned when re-tallying or reopening a BHAP
+	// whose eligible voters already match the membership.
+	ErrVotersUnchanged = errors.New("the eligible voters match the membership")
+	// ErrCannotReopen is returned when reopening a BHAP whose decision has
+	// already had effects that can't be undone.
+	ErrCannotReopen = errors.New("the BHAP cannot be reopened")
+)
+
+// CanVote returns true if the user is eligible to vote on the BHAP. BHAPs
+// that entered discussion before voters were recorded may be voted on by
+// everyone but the author and co-authors.
+func (b BHAP) CanVote(userKey Key) bool {
+	if b.VotersDate.IsZero() {
+		return userKey != "" && !b.IsAuthor(userKey)
+	}
+
+	for _, voter := range b.Voters {
+		if voter == userKey {
+			return true
+		}
+	}
+	return false
+}
+
+// VoterChanges compares the eligible voters of a BHAP to the current
+// membership, returning the members who have joined and left since. Nothing
+// is returned for BHAPs that entered discussion before voters were recorded.
+func VoterChanges(ctx context.Context, s Store, b BHAP) (added, removed []Key, err error) {
+	if b.VotersDate.IsZero() {
+		return nil, nil, nil
+	}
+
+	current, err := currentVoters(ctx, s, b)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	was := make(map[Key]bool)
+	for _, voter := range b.Voters {
+		was[voter] = true
+	}
+	is := make(map[Key]bool)
+	for _, voter := range current {
+		is[voter] = true
+		if !was[voter] {
+			added = append(added, voter)
+		}
+	}
+	for _, voter := range b.Voters {
+		if !is[voter] {
+			removed = append(removed, voter)
+		}
+	}
+
+	return added, removed, nil
+}
+
+// Retally updates the eligible voters of a BHAP in discussion to match the
+// current membership, records the change and checks whether the vote is now
+// decided. If nothing has changed, ErrVotersUnchanged is returned.
+func Retally(ctx context.Context, s Store, key Key, b BHAP, adminKey Key) (BHAP, error) {
+	if b.Status != DiscussionStatus {
+		return BHAP{}, fmt.Errorf("BHAP %v is %v, not in discussion", b.ID, b.Status)
+	}
+
+	added, removed, err := VoterChanges(ctx, s, b)
+	if err != nil {
+		return BHAP{}, err
+	}
+	if len(added) == 0 && len(removed) == 0 {
+		return BHAP{}, ErrVotersUnchanged
+	}
+
+	if err := recordVoters(ctx, s, key, &b); err != nil {
+		return BHAP{}, err
+	}
+	if err := s.PutBHAP(ctx, key, b); err != nil {
+		return BHAP{}, fmt.Errorf("saving BHAP: %v", err)
+	}
+
+	err = s.NewVoterChange(ctx, VoterChange{
+		OnBHAP:  key,
+		ByUser:  adminKey,
+		Added:   added,
+		Removed: removed,
+		Date:    b.VotersDate,
+	})
+	if err != nil {
+		return BHAP{}, fmt.Errorf("recording voter change: %v", err)
+	}
+
+	if err := CheckVotes(ctx, s, key, b); err != nil {
+		return BHAP{}, fmt.Errorf("checking votes: %v", err)
+	}
+
+	return b, nil
+}
+
+// Reopen puts a decided BHAP back into discussion because membership has
+// changed since it was voted on. Votes from members who are still eligible
+// are kept, and voting stays open for another full voting period. If nothing
+// has changed, ErrVotersUnchanged is returned.
+func Reopen(ctx context.Context, s Store, key Key, b BHAP, actor Actor) (BHAP, error) {
+	added, removed, err := VoterChanges(ctx, s, b)
+	if err != nil {
+		return BHAP{}, err
+	}
+	if len(added) == 0 && len(removed) == 0 {
+		return BHAP{}, ErrVotersUnchanged
+	}
+
+	from := b.Status
+	updated, err := ChangeStatus(ctx, s, key, b, DiscussionStatus, actor)
+	if err != nil {
+		return BHAP{}, err
+	}
+
+	err = s.NewVoterChange(ctx, VoterChange{
+		OnBHAP:       key,
+		ByUser:       actor.UserKey,
+		ReopenedFrom: from,
+		Added:        added,
+		Removed:      removed,
+		Date:         updated.VotersDate,
+	})
+	if err != nil {
+		return BHAP{}, fmt.Errorf("recording voter change: %v", err)
+	}
+
+	if err := CheckVotes(ctx, s, key, updated); err != nil {
+		return BHAP{}, fmt.Errorf("checking votes: %v", err)
+	}
+
+	return updated, nil
+}
+
+// checkReopenable makes sure a decided BHAP can go back into discussion.
+// BHAPs decided on a ballot are left alone, as are accepted BHAPs that have
+// already replaced others.
+func checkReopenable(ctx context.Context, s Store, key Key, b *BHAP) error {
+	if b.Ballot != "" || (b.Status == AcceptedStatus && len(b.Replaces) > 0) {
+		return ErrCannotReopen
+	}
+	return nil
+}
+
+// currentVoters returns the keys of every member who may vote on the BHAP
+// as of now, which is everyone but the author and co-authors.
+func currentVoters(ctx context.Context, s Store, b BHAP) ([]Key, error) {
+	_, keys, err := s.AllUsers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting users: %v", err)
+	}
+
+	voters := make([]Key, 0, len(keys))
+	for _, key := range keys {
+		if !b.IsAuthor(key) {
+			voters = append(voters, key)
+		}
+	}
+
+	return voters, nil
+}
+
+// recordVoters sets the eligible voters of a BHAP to the current membership
+// and throws out votes from members who are no longer eligible.
+func recordVoters(ctx context.Context, s Store, key Key, b *BHAP) error {
+	voters, err := currentVoters(ctx, s, *b)
+	if err != nil {
+		return err
+	}
+	b.Voters = voters
+	b.VotersDate = time.Now()
+
+	return throwOutIneligibleVotes(ctx, s, key, *b, b.VotersDate)
+}
+
+// throwOutIneligibleVotes throws out the votes on a BHAP from members who
+// aren't eligible to vote on it, recording that they were cleared as of the
+// given date. Choices on a secret ballot can't be taken back out of the
+// count, so if any member who isn't eligible voted on one, every vote on the
+// BHAP is thrown out.
+func throwOutIneligibleVotes(ctx context.Context, s Store, key Key, b BHAP, date time.Time) error {
+	// Everyone ranks the options on a ballot, so ranked votes are left be
+	if b.Ballot != "" {
+		return nil
+	}
+
+	votes, err := s.AllVotesForBHAP(ctx, key)
+	if err != nil {
+		return fmt.Errorf("getting votes: %v", err)
+	}
+
+	var ineligible []Vote
+	for _, vote := range votes {
+		if b.CanVote(vote.ByUser) {
+			continue
+		}
+		if vote.Value == SecretVote {
+			return ClearVotes(ctx, s, key)
+		}
+		ineligible = append(ineligible, vote)
+	}
+
+	for _, vote := range ineligible {
+		_, voteKey, err := s.GetVoteForBHAP(ctx, key, vote.ByUser)
+		if err != nil {
+			return fmt.Errorf("getting vote: %v", err)
+		}
+		if err := s.DeleteVote(ctx, voteKey); err != nil {
+			return fmt.Errorf("deleting vote: %v", err)
+		}
+
+		err = recordEvent(ctx, s, VoteEvent{
+			OnBHAP:   key,
+			ByUser:   vote.ByUser,
+			Action:   VoteCleared,
+			Value:    vote.Value,
+			Proxy:    vote.Proxy,
+			Revision: vote.Revision,
+			Date:     date,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
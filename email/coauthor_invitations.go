@@ -0,0 +1,35 @@
+package email
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/house-emoji/bhap"
+)
+
+var coAuthorInvitationTemplate = compileTempl("mail_templates/coauthor_invitation.txt")
+
+// QueueCoAuthorInvitation queues an email inviting a member to co-author a
+// BHAP.
+func QueueCoAuthorInvitation(ctx context.Context, b bhap.BHAP, inviteeKey bhap.Key) error {
+	author, err := Store.UserByKey(ctx, b.Author)
+	if err != nil {
+		return fmt.Errorf("loading author: %v", err)
+	}
+	invitee, err := Store.UserByKey(ctx, inviteeKey)
+	if err != nil {
+		return fmt.Errorf("loading invitee: %v", err)
+	}
+
+	filler := coAuthorInvitationFiller{
+		FirstName:  invitee.FirstName,
+		AuthorName: author.FirstName + " " + author.LastName,
+		Title:      b.Title,
+		BHAPURL:    bhapURL(b),
+	}
+	subject := fmt.Sprintf("%v invited you to co-author %v",
+		filler.AuthorName, b.Title)
+
+	return queueNotification(ctx, inviteeKey, invitee, bhap.CommentNotification,
+		subject, coAuthorInvitationTemplate, filler)
+}
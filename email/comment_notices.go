@@ -0,0 +1,48 @@
+package email
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/house-emoji/bhap"
+)
+
+var commentNoticeTemplate = compileTempl("mail_templates/comment_notice.txt")
+
+// QueueCommentNotices queues an email to the author and co-authors of a BHAP
+// about a new comment on it, other than whoever wrote the comment.
+func QueueCommentNotices(ctx context.Context, b bhap.BHAP, c bhap.Comment) error {
+	commenter, err := Store.UserByKey(ctx, c.Author)
+	if err != nil {
+		return fmt.Errorf("loading commenter: %v", err)
+	}
+
+	filler := commentNoticeFiller{
+		CommenterName: commenter.FirstName + " " + commenter.LastName,
+		Title:         b.Title,
+		Content:       c.Content,
+		CommentURL:    bhapURL(b) + "#comment-" + c.UID,
+	}
+	subject := fmt.Sprintf("%v commented on %v", filler.CommenterName, b.Title)
+
+	authors := append([]bhap.Key{b.Author}, b.CoAuthors...)
+	for _, authorKey := range authors {
+		if authorKey == c.Author {
+			continue
+		}
+
+		author, err := Store.UserByKey(ctx, authorKey)
+		if err != nil {
+			return fmt.Errorf("loading author: %v", err)
+		}
+
+		filler.FirstName = author.FirstName
+		err = queueNotification(ctx, authorKey, author, bhap.CommentNotification,
+			subject, commentNoticeTemplate, filler)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package email
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/house-emoji/bhap"
+)
+
+var digestTemplate = compileTempl("mail_templates/digest.txt")
+
+// QueueDigests queues the digest to every member who chose to get it.
+func QueueDigests(ctx context.Context, d bhap.Digest) error {
+	filler := digestFiller{
+		Since:     d.Since,
+		NewDrafts: digestBHAPsOf(d.NewDrafts),
+		Decided:   digestBHAPsOf(d.Decided),
+	}
+	for _, entry := range d.Discussion {
+		b := digestBHAPOf(entry.BHAP)
+		b.OnBallot = entry.BHAP.Ballot != ""
+		b.Tally = entry.Tally
+		filler.Discussion = append(filler.Discussion, b)
+	}
+
+	subject := fmt.Sprintf("BHAP digest for the week of %v",
+		d.Since.UTC().Format("January 2, 2006"))
+
+	users, keys, err := Store.AllUsers(ctx)
+	if err != nil {
+		return fmt.Errorf("getting users: %v", err)
+	}
+
+	for i, user := range users {
+		filler.FirstName = user.FirstName
+		err := queueNotification(ctx, keys[i], user, bhap.DigestNotification,
+			subject, digestTemplate, filler)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// digestBHAPsOf returns the BHAPs as they are listed in the digest.
+func digestBHAPsOf(bhaps []bhap.BHAP) []digestBHAP {
+	results := make([]digestBHAP, len(bhaps))
+	for i, b := range bhaps {
+		results[i] = digestBHAPOf(b)
+	}
+	return results
+}
+
+// digestBHAPOf returns the BHAP as it is listed in the digest.
+func digestBHAPOf(b bhap.BHAP) digestBHAP {
+	return digestBHAP{
+		ID:               b.ID,
+		Title:            b.Title,
+		ShortDescription: b.ShortDescription,
+		Status:           b.Status,
+		URL:              bhapURL(b),
+		VotingDeadline:   b.VotingDeadline,
+	}
+}
@@ -5,22 +5,22 @@ import (
 	"net/http"
 
 	"github.com/house-emoji/bhap"
-	"google.golang.org/appengine"
-	"google.golang.org/appengine/datastore"
-	"google.golang.org/appengine/log"
-	"google.golang.org/appengine/mail"
+	"github.com/house-emoji/bhap/log"
 )
 
 var invitationTemplate = compileTempl("mail_templates/invitation.txt")
 
 const InvitationSubject = "You have been invited to join the BHAP Consortium"
 
+// siteURL is where links in emails point to.
+const siteURL = "https://bhap.club"
+
 // SendInvitations sends any unsent invitation emails to potential users. It is
 // called periodically as a cron job.
 func SendInvitations(w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
+	ctx := bhap.RequestContext(r)
 
-	unsents, keys, err := bhap.UnsentInvitations(ctx)
+	unsents, keys, err := Store.UnsentInvitations(ctx)
 	if err != nil {
 		http.Error(w, "Could not get unsent invitations", 500)
 		log.Errorf(ctx, "could not get unsent invitations: %v", err)
@@ -34,7 +34,7 @@ func SendInvitations(w http.ResponseWriter, r *http.Request) {
 	for i, unsent := range unsents {
 		var buf bytes.Buffer
 		filler := invitationFiller{
-			CreateAccountURL: "https://bhap.club/new-user/" + unsent.UID,
+			CreateAccountURL: siteURL + "/new-user/" + unsent.UID,
 		}
 		if err := invitationTemplate.Execute(&buf, filler); err != nil {
 			log.Errorf(ctx, "failed to execute email invitation template: %v", err)
@@ -42,14 +42,14 @@ func SendInvitations(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 
-		message := mail.Message{
+		message := Message{
 			Sender:  "BHAP Invitations <invitations@the-bhaps.appspotmail.com>",
 			To:      []string{unsent.Email},
 			Subject: InvitationSubject,
 			Body:    buf.String(),
 		}
 
-		if err := mail.Send(ctx, &message); err != nil {
+		if err := Mailer.Send(ctx, &message); err != nil {
 			log.Errorf(ctx, "failed to send mail to %v: %v",
 				unsent.Email, err)
 			failCount++
@@ -57,7 +57,7 @@ func SendInvitations(w http.ResponseWriter, r *http.Request) {
 		}
 
 		unsent.EmailSent = true
-		if _, err := datastore.Put(ctx, keys[i], &unsent); err != nil {
+		if err := Store.PutInvitation(ctx, keys[i], unsent); err != nil {
 			log.Errorf(ctx, "failed to save invitation: %v", err)
 			failCount++
 			continue
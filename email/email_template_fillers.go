@@ -1,6 +1,108 @@
 package email
 
+import (
+	"time"
+
+	"github.com/house-emoji/bhap"
+)
+
 // invitationFiller fills the email template used to invite new users to BHAP.
 type invitationFiller struct {
 	CreateAccountURL string
 }
+
+// revisionNoticeFiller fills the email template used to tell members that a
+// BHAP they voted on has changed.
+type revisionNoticeFiller struct {
+	FirstName  string
+	EditorName string
+	ID         int
+	Title      string
+	// Reset is true if the member's vote was thrown out, rather than kept
+	// and marked as cast on an older revision
+	Reset      bool
+	BHAPURL    string
+	HistoryURL string
+}
+
+// coAuthorInvitationFiller fills the email template used to invite a member
+// to co-author a BHAP.
+type coAuthorInvitationFiller struct {
+	FirstName  string
+	AuthorName string
+	Title      string
+	BHAPURL    string
+}
+
+// statusNoticeFiller fills the email template used to tell members that a
+// BHAP has changed status.
+type statusNoticeFiller struct {
+	FirstName        string
+	ID               int
+	Title            string
+	ShortDescription string
+	Status           bhap.Status
+	// From is the status the BHAP had before
+	From bhap.Status
+	// VotingDeadline is when voting closes, for BHAPs entering discussion
+	VotingDeadline time.Time
+	BHAPURL        string
+}
+
+// voteReminderFiller fills the email template used to remind members to
+// vote on a BHAP in discussion.
+type voteReminderFiller struct {
+	FirstName        string
+	ID               int
+	Title            string
+	ShortDescription string
+	VotingDeadline   time.Time
+	BHAPURL          string
+	// AcceptURL and RejectURL lead to pages for voting either way, once the
+	// member has logged in
+	AcceptURL string
+	RejectURL string
+}
+
+// digestFiller fills the email template for the weekly digest.
+type digestFiller struct {
+	FirstName  string
+	Since      time.Time
+	NewDrafts  []digestBHAP
+	Discussion []digestBHAP
+	Decided    []digestBHAP
+}
+
+// digestBHAP is a BHAP as listed in the weekly digest.
+type digestBHAP struct {
+	ID               int
+	Title            string
+	ShortDescription string
+	Status           bhap.Status
+	URL              string
+	// OnBallot is set for BHAPs in discussion that are ranked on a ballot,
+	// which have no vote progress of their own
+	OnBallot       bool
+	Tally          bhap.Tally
+	VotingDeadline time.Time
+}
+
+// unsubscribeFooterFiller fills the template for the end of each email about
+// BHAPs, which tells members how to stop getting that kind of email.
+type unsubscribeFooterFiller struct {
+	// Description describes the kind of email
+	Description    string
+	UnsubscribeURL string
+	SettingsURL    string
+}
+
+// commentNoticeFiller fills the email template used to tell the authors of a
+// BHAP that someone commented on it.
+type commentNoticeFiller struct {
+	FirstName     string
+	CommenterName string
+	Title         string
+	// Content is the comment as written, in Markdown
+	Content    string
+	CommentURL string
+}
@@ -0,0 +1,115 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"text/template"
+	"time"
+
+	"github.com/house-emoji/bhap"
+	"github.com/house-emoji/bhap/log"
+)
+
+// notificationSender is who emails about BHAPs are sent from.
+const notificationSender = "BHAP Notifications <notifications@the-bhaps.appspotmail.com>"
+
+// bhapURL returns the full URL of a BHAP's page.
+func bhapURL(b bhap.BHAP) string {
+	if b.Status == bhap.DraftStatus {
+		return fmt.Sprintf("%v/draft/%v", siteURL, b.DraftID)
+	}
+	return fmt.Sprintf("%v/bhap/%v", siteURL, b.ID)
+}
+
+var unsubscribeFooterTemplate = compileTempl("mail_templates/unsubscribe_footer.txt")
+
+// queueNotification fills in an email template and adds the email to the
+// outbox, to be sent to a member about something they choose whether to hear
+// about. Nothing is queued if they've chosen not to. The email ends with a
+// link that unsubscribes them from that kind of email without logging in.
+func queueNotification(ctx context.Context, userKey bhap.Key, user bhap.User, n bhap.Notification, subject string, templ *template.Template, filler interface{}) error {
+	if !user.Wants(n) {
+		return nil
+	}
+
+	token, err := bhap.UnsubscribeToken(ctx, userKey, n)
+	if err != nil {
+		return fmt.Errorf("signing unsubscribe link: %v", err)
+	}
+	footer := unsubscribeFooterFiller{
+		Description: n.Description(),
+		UnsubscribeURL: fmt.Sprintf("%v/unsubscribe/%v?user=%v&token=%v",
+			siteURL, n, url.QueryEscape(string(userKey)), token),
+		SettingsURL: siteURL + "/settings",
+	}
+
+	var buf bytes.Buffer
+	if err := templ.Execute(&buf, filler); err != nil {
+		return fmt.Errorf("executing email template: %v", err)
+	}
+	if err := unsubscribeFooterTemplate.Execute(&buf, footer); err != nil {
+		return fmt.Errorf("executing email footer template: %v", err)
+	}
+
+	err = Store.QueueEmail(ctx, bhap.OutgoingEmail{
+		To:             user.Email,
+		Subject:        subject,
+		Body:           buf.String(),
+		Queued:         time.Now(),
+		UnsubscribeURL: footer.UnsubscribeURL,
+	})
+	if err != nil {
+		return fmt.Errorf("queueing email to %v: %v", user.Email, err)
+	}
+
+	return nil
+}
+
+// SendQueuedEmails sends every email waiting in the outbox. It is called
+// periodically as a cron job.
+func SendQueuedEmails(w http.ResponseWriter, r *http.Request) {
+	ctx := bhap.RequestContext(r)
+
+	unsents, keys, err := Store.UnsentEmails(ctx)
+	if err != nil {
+		http.Error(w, "Could not get queued emails", 500)
+		log.Errorf(ctx, "could not get queued emails: %v", err)
+		return
+	}
+
+	log.Infof(ctx, "about to send %v queued emails", len(unsents))
+
+	failCount := 0
+
+	for i, unsent := range unsents {
+		message := Message{
+			Sender:         notificationSender,
+			To:             []string{unsent.To},
+			Subject:        unsent.Subject,
+			Body:           unsent.Body,
+			UnsubscribeURL: unsent.UnsubscribeURL,
+		}
+
+		if err := Mailer.Send(ctx, &message); err != nil {
+			log.Errorf(ctx, "failed to send mail to %v: %v", unsent.To, err)
+			failCount++
+			continue
+		}
+
+		unsent.Sent = true
+		if err := Store.PutEmail(ctx, keys[i], unsent); err != nil {
+			log.Errorf(ctx, "failed to save sent email: %v", err)
+			failCount++
+			continue
+		}
+	}
+
+	if failCount > 0 {
+		http.Error(w, "Failures while sending emails", 500)
+		log.Infof(ctx, "failed the task because %v emails failed to send", failCount)
+		return
+	}
+}
@@ -0,0 +1,58 @@
+package email
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/house-emoji/bhap"
+)
+
+var revisionNoticeTemplate = compileTempl("mail_templates/revision_notice.txt")
+
+// QueueRevisionNotices queues an email to each member whose vote was outdated
+// by an edit to a BHAP, explaining what happened to their vote. The editor is
+// the author or co-author who made the edit.
+func QueueRevisionNotices(ctx context.Context, b bhap.BHAP, editorKey bhap.Key, votes []bhap.Vote) error {
+	if len(votes) == 0 {
+		return nil
+	}
+
+	editor, err := Store.UserByKey(ctx, editorKey)
+	if err != nil {
+		return fmt.Errorf("loading editor: %v", err)
+	}
+
+	subject := fmt.Sprintf("BHAP %04d has changed since you voted", b.ID)
+	pageURL := bhapURL(b)
+
+	for _, vote := range votes {
+		voter, err := Store.UserByKey(ctx, vote.ByUser)
+		if err != nil {
+			return fmt.Errorf("loading voter: %v", err)
+		}
+
+		// Votes from before revisions were recorded were cast on the first
+		// revision that was
+		from := vote.Revision
+		if from == 0 {
+			from = 1
+		}
+
+		filler := revisionNoticeFiller{
+			FirstName:  voter.FirstName,
+			EditorName: editor.FirstName + " " + editor.LastName,
+			ID:         b.ID,
+			Title:      b.Title,
+			Reset:      bhap.CurrentSettings().ResetVotesOnEdit,
+			BHAPURL:    pageURL,
+			HistoryURL: fmt.Sprintf("%v/history?from=%v&to=%v", pageURL, from, b.Revision),
+		}
+		err = queueNotification(ctx, vote.ByUser, voter, bhap.ReminderNotification,
+			subject, revisionNoticeTemplate, filler)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
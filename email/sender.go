@@ -0,0 +1,60 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net/mail"
+	"net/smtp"
+	"strings"
+)
+
+// Message is an email to be sent.
+type Message struct {
+	Sender  string
+	To      []string
+	Subject string
+	Body    string
+	// UnsubscribeURL is offered by mail clients as a way to unsubscribe
+	// from emails like this one. It may be empty
+	UnsubscribeURL string
+}
+
+// Sender sends emails.
+type Sender interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// Mailer is what email tasks send messages with. It must be set before any
+// handlers are served.
+var Mailer Sender
+
+// SMTPSender sends emails through an SMTP server.
+type SMTPSender struct {
+	// Addr is the address of the server, including the port.
+	Addr string
+	// Auth authenticates with the server. It may be nil.
+	Auth smtp.Auth
+}
+
+// Send sends the message through the SMTP server.
+func (s SMTPSender) Send(ctx context.Context, msg *Message) error {
+	from, err := mail.ParseAddress(msg.Sender)
+	if err != nil {
+		return fmt.Errorf("parsing sender address: %v", err)
+	}
+
+	var data bytes.Buffer
+	fmt.Fprintf(&data, "From: %v\r\n", from)
+	fmt.Fprintf(&data, "To: %v\r\n", strings.Join(msg.To, ", "))
+	fmt.Fprintf(&data, "Subject: %v\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&data, "Content-Type: text/plain; charset=utf-8\r\n")
+	if msg.UnsubscribeURL != "" {
+		fmt.Fprintf(&data, "List-Unsubscribe: <%v>\r\n", msg.UnsubscribeURL)
+		fmt.Fprintf(&data, "List-Unsubscribe-Post: List-Unsubscribe=One-Click\r\n")
+	}
+	fmt.Fprintf(&data, "\r\n%v", msg.Body)
+
+	return smtp.SendMail(s.Addr, s.Auth, from.Address, msg.To, data.Bytes())
+}
@@ -0,0 +1,64 @@
+package email
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/house-emoji/bhap"
+)
+
+var statusNoticeTemplate = compileTempl("mail_templates/status_notice.txt")
+
+// statusNoticeSubjects holds the subject of the email sent to members when a
+// BHAP moves to each status. Members aren't told about the other statuses.
+var statusNoticeSubjects = map[bhap.Status]string{
+	bhap.DiscussionStatus: "BHAP %04d is open for discussion: %v",
+	bhap.AcceptedStatus:   "BHAP %04d has been accepted: %v",
+	bhap.RejectedStatus:   "BHAP %04d has been rejected: %v",
+	bhap.WithdrawnStatus:  "BHAP %04d has been withdrawn: %v",
+}
+
+// statusNoticeKinds holds the kind of email sent to members when a BHAP
+// moves to each status.
+var statusNoticeKinds = map[bhap.Status]bhap.Notification{
+	bhap.DiscussionStatus: bhap.DiscussionNotification,
+	bhap.AcceptedStatus:   bhap.DecisionNotification,
+	bhap.RejectedStatus:   bhap.DecisionNotification,
+	bhap.WithdrawnStatus:  bhap.DecisionNotification,
+}
+
+// QueueStatusNotices queues an email to every member about a BHAP that has
+// entered discussion or been decided or withdrawn. Other status changes are
+// ignored, as are members who chose not to hear about the change.
+func QueueStatusNotices(ctx context.Context, b bhap.BHAP, from bhap.Status) error {
+	subject, ok := statusNoticeSubjects[b.Status]
+	if !ok {
+		return nil
+	}
+	subject = fmt.Sprintf(subject, b.ID, b.Title)
+
+	users, keys, err := Store.AllUsers(ctx)
+	if err != nil {
+		return fmt.Errorf("getting users: %v", err)
+	}
+
+	for i, user := range users {
+		filler := statusNoticeFiller{
+			FirstName:        user.FirstName,
+			ID:               b.ID,
+			Title:            b.Title,
+			ShortDescription: b.ShortDescription,
+			Status:           b.Status,
+			From:             from,
+			VotingDeadline:   b.VotingDeadline,
+			BHAPURL:          bhapURL(b),
+		}
+		err := queueNotification(ctx, keys[i], user, statusNoticeKinds[b.Status],
+			subject, statusNoticeTemplate, filler)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
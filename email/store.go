@@ -0,0 +1,7 @@
+package email
+
+import "github.com/house-emoji/bhap"
+
+// Store is where email tasks load and save BHAP data. It must be set before
+// any handlers are served.
+var Store bhap.Store
@@ -1,6 +1,6 @@
 package email
 
-import "html/template"
+import "text/template"
 
 // compileTempl wraps the common template compiling pattern. Panics in case of
 // error.
@@ -0,0 +1,42 @@
+package email
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/house-emoji/bhap"
+)
+
+var voteReminderTemplate = compileTempl("mail_templates/vote_reminder.txt")
+
+// QueueVoteReminders queues an email to each of the given members reminding
+// them to vote on a BHAP in discussion.
+func QueueVoteReminders(ctx context.Context, b bhap.BHAP, userKeys []bhap.Key) error {
+	subject := fmt.Sprintf("Reminder: vote on BHAP %04d: %v", b.ID, b.Title)
+	url := bhapURL(b)
+
+	for _, userKey := range userKeys {
+		user, err := Store.UserByKey(ctx, userKey)
+		if err != nil {
+			return fmt.Errorf("getting user: %v", err)
+		}
+
+		filler := voteReminderFiller{
+			FirstName:        user.FirstName,
+			ID:               b.ID,
+			Title:            b.Title,
+			ShortDescription: b.ShortDescription,
+			VotingDeadline:   b.VotingDeadline,
+			BHAPURL:          url,
+			AcceptURL:        url + "/vote-accept",
+			RejectURL:        url + "/vote-reject",
+		}
+		err = queueNotification(ctx, userKey, user, bhap.ReminderNotification,
+			subject, voteReminderTemplate, filler)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
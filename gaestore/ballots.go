@@ -0,0 +1,98 @@
+package gaestore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/house-emoji/bhap"
+	"google.golang.org/appengine/datastore"
+)
+
+// ballotEntity is the Datastore representation of a ballot.
+type ballotEntity struct {
+	bhap.Ballot
+}
+
+func (e *ballotEntity) Load(props []datastore.Property) error {
+	return loadKeyed(&e.Ballot, props)
+}
+
+func (e *ballotEntity) Save() ([]datastore.Property, error) {
+	return saveKeyed(&e.Ballot)
+}
+
+// BallotByKey returns the ballot with the given key.
+func (s *Store) BallotByKey(ctx context.Context, key bhap.Key) (bhap.Ballot, error) {
+	dsKey, err := decodeKey(key)
+	if err != nil {
+		return bhap.Ballot{}, err
+	}
+
+	var result ballotEntity
+	if err := datastore.Get(ctx, dsKey, &result); err != nil {
+		return bhap.Ballot{}, fmt.Errorf("getting ballot: %v", err)
+	}
+
+	return result.Ballot, nil
+}
+
+// BallotByUID returns the ballot with the given UID.
+func (s *Store) BallotByUID(ctx context.Context, uid string) (bhap.Ballot, bhap.Key, error) {
+	var results []ballotEntity
+	keys, err := datastore.NewQuery(ballotEntityName).
+		Filter("UID =", uid).
+		Limit(1).
+		GetAll(ctx, &results)
+	if err != nil {
+		return bhap.Ballot{}, "", fmt.Errorf("by UID %v: %v", uid, err)
+	}
+
+	if len(results) == 0 {
+		return bhap.Ballot{}, "", nil
+	}
+
+	return results[0].Ballot, encodeKey(keys[0]), nil
+}
+
+// OpenBallots returns all ballots that have yet to be decided.
+func (s *Store) OpenBallots(ctx context.Context) ([]bhap.Ballot, []bhap.Key, error) {
+	var results []ballotEntity
+	keys, err := datastore.NewQuery(ballotEntityName).
+		Filter("Closed =", false).
+		GetAll(ctx, &results)
+	if err != nil {
+		return nil, nil, fmt.Errorf("finding open ballots: %v", err)
+	}
+
+	ballots := make([]bhap.Ballot, len(results))
+	for i, result := range results {
+		ballots[i] = result.Ballot
+	}
+
+	return ballots, encodeKeys(keys), nil
+}
+
+// NewBallot saves a new ballot and returns its key.
+func (s *Store) NewBallot(ctx context.Context, b bhap.Ballot) (bhap.Key, error) {
+	key := datastore.NewIncompleteKey(ctx, ballotEntityName, nil)
+	key, err := datastore.Put(ctx, key, &ballotEntity{b})
+	if err != nil {
+		return "", fmt.Errorf("saving new ballot: %v", err)
+	}
+
+	return encodeKey(key), nil
+}
+
+// PutBallot saves changes to an existing ballot.
+func (s *Store) PutBallot(ctx context.Context, key bhap.Key, b bhap.Ballot) error {
+	dsKey, err := decodeKey(key)
+	if err != nil {
+		return err
+	}
+
+	if _, err := datastore.Put(ctx, dsKey, &ballotEntity{b}); err != nil {
+		return fmt.Errorf("saving ballot: %v", err)
+	}
+
+	return nil
+}
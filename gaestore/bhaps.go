@@ -0,0 +1,228 @@
+package gaestore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/house-emoji/bhap"
+	"google.golang.org/appengine/datastore"
+)
+
+// bhapEntity is the Datastore representation of a BHAP.
+type bhapEntity struct {
+	bhap.BHAP
+}
+
+func (e *bhapEntity) Load(props []datastore.Property) error {
+	return loadKeyed(&e.BHAP, props)
+}
+
+func (e *bhapEntity) Save() ([]datastore.Property, error) {
+	return saveKeyed(&e.BHAP)
+}
+
+// toBHAPs unwraps a list of BHAP entities.
+func toBHAPs(entities []bhapEntity) []bhap.BHAP {
+	bhaps := make([]bhap.BHAP, len(entities))
+	for i, e := range entities {
+		bhaps[i] = e.BHAP
+	}
+	return bhaps
+}
+
+// ByKey gets the BHAP with the given key.
+func (s *Store) ByKey(ctx context.Context, key bhap.Key) (bhap.BHAP, error) {
+	dsKey, err := decodeKey(key)
+	if err != nil {
+		return bhap.BHAP{}, err
+	}
+
+	var result bhapEntity
+	if err := datastore.Get(ctx, dsKey, &result); err != nil {
+		return bhap.BHAP{}, fmt.Errorf("by key %v: %v", key, err)
+	}
+
+	return result.BHAP, nil
+}
+
+// ByDraftID gets a BHAP by the given draft ID. If none exists, the key will
+// be empty.
+func (s *Store) ByDraftID(ctx context.Context, draftID string) (bhap.BHAP, bhap.Key, error) {
+	var results []bhapEntity
+	query := datastore.NewQuery(bhapEntityName).
+		Filter("DraftID =", draftID).
+		Limit(1)
+	keys, err := query.GetAll(ctx, &results)
+	if err != nil {
+		return bhap.BHAP{}, "", fmt.Errorf("by draft ID %v: %v", draftID, err)
+	}
+
+	if len(results) == 0 {
+		return bhap.BHAP{}, "", nil
+	}
+
+	return results[0].BHAP, encodeKey(keys[0]), nil
+}
+
+// ByID gets a BHAP by the given ID. If none exists, the key will be empty.
+func (s *Store) ByID(ctx context.Context, id int) (bhap.BHAP, bhap.Key, error) {
+	var results []bhapEntity
+	query := datastore.NewQuery(bhapEntityName).
+		Filter("ID =", id).
+		Limit(1)
+	keys, err := query.GetAll(ctx, &results)
+	if err != nil {
+		return bhap.BHAP{}, "", fmt.Errorf("by ID %v: %v", id, err)
+	}
+
+	if len(results) == 0 {
+		return bhap.BHAP{}, "", nil
+	}
+
+	return results[0].BHAP, encodeKey(keys[0]), nil
+}
+
+// idCounter holds the next ID to give to a BHAP of a certain type. There is
+// one per type, keyed by the type's name.
+type idCounter struct {
+	Next int
+}
+
+// NextID reserves and returns the next unused ID for a new BHAP, using the
+// numbering convention that fits for the given type. IDs are handed out from
+// a counter entity in a transaction, so concurrent callers never receive the
+// same ID.
+func (s *Store) NextID(ctx context.Context, typ bhap.BHAPType) (int, error) {
+	// Counters for types that predate them start after the highest ID in
+	// use. Datastore transactions only allow ancestor queries, so this has
+	// to be found beforehand.
+	initial, err := s.highestID(ctx, typ)
+	if err != nil {
+		return 0, fmt.Errorf("finding highest %v ID: %v", typ, err)
+	}
+
+	counterKey := datastore.NewKey(ctx, idCounterEntityName, string(typ), 0, nil)
+	var id int
+
+	err = datastore.RunInTransaction(ctx, func(ctx context.Context) error {
+		var counter idCounter
+		err := datastore.Get(ctx, counterKey, &counter)
+		if err == datastore.ErrNoSuchEntity {
+			counter.Next = initial
+		} else if err != nil {
+			return err
+		}
+
+		if !bhap.InIDRange(typ, counter.Next) {
+			return bhap.ErrIDsExhausted
+		}
+
+		id = counter.Next
+		counter.Next++
+		_, err = datastore.Put(ctx, counterKey, &counter)
+		return err
+	}, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// highestID returns one more than the highest ID in use by BHAPs of the
+// given type, or the type's first ID if there are none.
+func (s *Store) highestID(ctx context.Context, typ bhap.BHAPType) (int, error) {
+	indexStart, err := bhap.FirstID(typ)
+	if err != nil {
+		return 0, err
+	}
+
+	query := datastore.NewQuery(bhapEntityName).
+		Filter("ID >=", indexStart).
+		Order("-ID").
+		Limit(1)
+	if typ == bhap.MetaBHAPType {
+		query = query.Filter("ID <", bhap.FirstHouseRuleID)
+	}
+
+	var results []bhapEntity
+	if _, err := query.GetAll(ctx, &results); err != nil {
+		return 0, err
+	}
+
+	if len(results) == 0 {
+		return indexStart, nil
+	} else {
+		return results[0].ID + 1, nil
+	}
+}
+
+// GetAll returns all recorded BHAPs.
+func (s *Store) GetAll(ctx context.Context) ([]bhap.BHAP, error) {
+	var results []bhapEntity
+	_, err := datastore.NewQuery(bhapEntityName).
+		Order("ID").
+		GetAll(ctx, &results)
+	if err != nil {
+		return nil, err
+	}
+
+	return toBHAPs(results), nil
+}
+
+// ByStatus returns all BHAPs with the given status(es).
+func (s *Store) ByStatus(ctx context.Context, statuses ...bhap.Status) ([]bhap.BHAP, error) {
+	// Get BHAPs for every status, combining them into a single set
+	resultSet := make(map[string]bhap.BHAP)
+	for _, status := range statuses {
+		var results []bhapEntity
+		keys, err := datastore.NewQuery(bhapEntityName).
+			Order("ID").
+			Filter("Status =", status).
+			GetAll(ctx, &results)
+		if err != nil {
+			return nil, fmt.Errorf("finding %v BHAPs: %v", status, err)
+		}
+
+		for i, result := range results {
+			resultSet[keys[i].Encode()] = result.BHAP
+		}
+	}
+
+	// Sort the results
+	sorted := make([]bhap.BHAP, 0)
+	for _, result := range resultSet {
+		sorted = append(sorted, result)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	return sorted, nil
+}
+
+// NewBHAP saves a new BHAP and returns its key.
+func (s *Store) NewBHAP(ctx context.Context, b bhap.BHAP) (bhap.Key, error) {
+	key := datastore.NewIncompleteKey(ctx, bhapEntityName, nil)
+	key, err := datastore.Put(ctx, key, &bhapEntity{b})
+	if err != nil {
+		return "", fmt.Errorf("saving new BHAP: %v", err)
+	}
+
+	return encodeKey(key), nil
+}
+
+// PutBHAP saves changes to an existing BHAP.
+func (s *Store) PutBHAP(ctx context.Context, key bhap.Key, b bhap.BHAP) error {
+	dsKey, err := decodeKey(key)
+	if err != nil {
+		return err
+	}
+
+	if _, err := datastore.Put(ctx, dsKey, &bhapEntity{b}); err != nil {
+		return fmt.Errorf("saving BHAP: %v", err)
+	}
+
+	return nil
+}
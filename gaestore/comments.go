@@ -0,0 +1,109 @@
+package gaestore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/house-emoji/bhap"
+	"google.golang.org/appengine/datastore"
+)
+
+// commentEntity is the Datastore representation of a comment. Comments are
+// stored as children of the BHAP they are on.
+type commentEntity struct {
+	bhap.Comment
+}
+
+func (e *commentEntity) Load(props []datastore.Property) error {
+	return loadKeyed(&e.Comment, props)
+}
+
+func (e *commentEntity) Save() ([]datastore.Property, error) {
+	return saveKeyed(&e.Comment)
+}
+
+// CommentsForBHAP returns every comment on a BHAP, oldest first.
+func (s *Store) CommentsForBHAP(ctx context.Context, bhapKey bhap.Key) ([]bhap.Comment, []bhap.Key, error) {
+	dsBHAPKey, err := decodeKey(bhapKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var results []commentEntity
+	keys, err := datastore.NewQuery(commentEntityName).
+		Ancestor(dsBHAPKey).
+		Order("Date").
+		GetAll(ctx, &results)
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting comments: %v", err)
+	}
+
+	comments := make([]bhap.Comment, len(results))
+	for i, result := range results {
+		comments[i] = result.Comment
+	}
+
+	return comments, encodeKeys(keys), nil
+}
+
+// CommentByUID returns the comment with the given UID.
+func (s *Store) CommentByUID(ctx context.Context, uid string) (bhap.Comment, bhap.Key, error) {
+	var results []commentEntity
+	keys, err := datastore.NewQuery(commentEntityName).
+		Filter("UID =", uid).
+		Limit(1).
+		GetAll(ctx, &results)
+	if err != nil {
+		return bhap.Comment{}, "", fmt.Errorf("by UID %v: %v", uid, err)
+	}
+
+	if len(results) == 0 {
+		return bhap.Comment{}, "", nil
+	}
+
+	return results[0].Comment, encodeKey(keys[0]), nil
+}
+
+// NewComment saves a new comment and returns its key.
+func (s *Store) NewComment(ctx context.Context, c bhap.Comment) (bhap.Key, error) {
+	dsBHAPKey, err := decodeKey(c.OnBHAP)
+	if err != nil {
+		return "", err
+	}
+
+	key := datastore.NewIncompleteKey(ctx, commentEntityName, dsBHAPKey)
+	key, err = datastore.Put(ctx, key, &commentEntity{c})
+	if err != nil {
+		return "", fmt.Errorf("saving new comment: %v", err)
+	}
+
+	return encodeKey(key), nil
+}
+
+// PutComment saves changes to an existing comment.
+func (s *Store) PutComment(ctx context.Context, key bhap.Key, c bhap.Comment) error {
+	dsKey, err := decodeKey(key)
+	if err != nil {
+		return err
+	}
+
+	if _, err := datastore.Put(ctx, dsKey, &commentEntity{c}); err != nil {
+		return fmt.Errorf("saving comment: %v", err)
+	}
+
+	return nil
+}
+
+// DeleteComment deletes a comment.
+func (s *Store) DeleteComment(ctx context.Context, key bhap.Key) error {
+	dsKey, err := decodeKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err := datastore.Delete(ctx, dsKey); err != nil {
+		return fmt.Errorf("deleting comment: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package gaestore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/house-emoji/bhap"
+	"google.golang.org/appengine/datastore"
+)
+
+// delegationEntity is the Datastore representation of a delegation.
+type delegationEntity struct {
+	bhap.Delegation
+}
+
+func (e *delegationEntity) Load(props []datastore.Property) error {
+	return loadKeyed(&e.Delegation, props)
+}
+
+func (e *delegationEntity) Save() ([]datastore.Property, error) {
+	return saveKeyed(&e.Delegation)
+}
+
+// DelegationsFrom returns every delegation made by the given user.
+func (s *Store) DelegationsFrom(ctx context.Context, userKey bhap.Key) ([]bhap.Delegation, []bhap.Key, error) {
+	return s.delegationsByUser(ctx, "From =", userKey)
+}
+
+// DelegationsTo returns every delegation made to the given user.
+func (s *Store) DelegationsTo(ctx context.Context, userKey bhap.Key) ([]bhap.Delegation, []bhap.Key, error) {
+	return s.delegationsByUser(ctx, "To =", userKey)
+}
+
+// delegationsByUser returns every delegation where the user key matches the
+// given filter.
+func (s *Store) delegationsByUser(ctx context.Context, filter string, userKey bhap.Key) ([]bhap.Delegation, []bhap.Key, error) {
+	dsUserKey, err := decodeKey(userKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var results []delegationEntity
+	keys, err := datastore.NewQuery(delegationEntityName).
+		Filter(filter, dsUserKey).
+		GetAll(ctx, &results)
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting delegations: %v", err)
+	}
+
+	delegations := make([]bhap.Delegation, len(results))
+	for i, result := range results {
+		delegations[i] = result.Delegation
+	}
+
+	return delegations, encodeKeys(keys), nil
+}
+
+// DelegationByUID returns the delegation with the given UID.
+func (s *Store) DelegationByUID(ctx context.Context, uid string) (bhap.Delegation, bhap.Key, error) {
+	var results []delegationEntity
+	keys, err := datastore.NewQuery(delegationEntityName).
+		Filter("UID =", uid).
+		Limit(1).
+		GetAll(ctx, &results)
+	if err != nil {
+		return bhap.Delegation{}, "", fmt.Errorf("by UID %v: %v", uid, err)
+	}
+
+	if len(results) == 0 {
+		return bhap.Delegation{}, "", nil
+	}
+
+	return results[0].Delegation, encodeKey(keys[0]), nil
+}
+
+// NewDelegation saves a new delegation and returns its key.
+func (s *Store) NewDelegation(ctx context.Context, d bhap.Delegation) (bhap.Key, error) {
+	key := datastore.NewIncompleteKey(ctx, delegationEntityName, nil)
+	key, err := datastore.Put(ctx, key, &delegationEntity{d})
+	if err != nil {
+		return "", fmt.Errorf("saving new delegation: %v", err)
+	}
+
+	return encodeKey(key), nil
+}
+
+// DeleteDelegation deletes a delegation.
+func (s *Store) DeleteDelegation(ctx context.Context, key bhap.Key) error {
+	dsKey, err := decodeKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err := datastore.Delete(ctx, dsKey); err != nil {
+		return fmt.Errorf("deleting delegation: %v", err)
+	}
+
+	return nil
+}
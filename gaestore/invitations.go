@@ -0,0 +1,80 @@
+package gaestore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/house-emoji/bhap"
+	"google.golang.org/appengine/datastore"
+)
+
+// UnsentInvitations returns all invitations that have yet to be emailed.
+func (s *Store) UnsentInvitations(ctx context.Context) ([]bhap.Invitation, []bhap.Key, error) {
+	var results []bhap.Invitation
+	query := datastore.NewQuery(invitationEntityName).
+		Filter("EmailSent =", false)
+
+	keys, err := query.GetAll(ctx, &results)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return results, encodeKeys(keys), nil
+}
+
+// InvitationByUID returns the invitation with the corresponding UID.
+func (s *Store) InvitationByUID(ctx context.Context, uid string) (bhap.Invitation, bhap.Key, error) {
+	var results []bhap.Invitation
+	query := datastore.NewQuery(invitationEntityName).
+		Filter("UID =", uid)
+
+	keys, err := query.GetAll(ctx, &results)
+	if err != nil {
+		return bhap.Invitation{}, "", err
+	}
+
+	if len(results) == 0 {
+		return bhap.Invitation{}, "", nil
+	}
+
+	return results[0], encodeKey(keys[0]), nil
+}
+
+// NewInvitation saves a new invitation and returns its key.
+func (s *Store) NewInvitation(ctx context.Context, inv bhap.Invitation) (bhap.Key, error) {
+	key := datastore.NewIncompleteKey(ctx, invitationEntityName, nil)
+	key, err := datastore.Put(ctx, key, &inv)
+	if err != nil {
+		return "", fmt.Errorf("saving new invitation: %v", err)
+	}
+
+	return encodeKey(key), nil
+}
+
+// PutInvitation saves changes to an existing invitation.
+func (s *Store) PutInvitation(ctx context.Context, key bhap.Key, inv bhap.Invitation) error {
+	dsKey, err := decodeKey(key)
+	if err != nil {
+		return err
+	}
+
+	if _, err := datastore.Put(ctx, dsKey, &inv); err != nil {
+		return fmt.Errorf("saving invitation: %v", err)
+	}
+
+	return nil
+}
+
+// DeleteInvitation deletes an invitation.
+func (s *Store) DeleteInvitation(ctx context.Context, key bhap.Key) error {
+	dsKey, err := decodeKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err := datastore.Delete(ctx, dsKey); err != nil {
+		return fmt.Errorf("deleting invitation: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package gaestore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/house-emoji/bhap"
+	"google.golang.org/appengine/datastore"
+)
+
+// UnsentEmails returns every queued email that has yet to be sent, oldest
+// first.
+func (s *Store) UnsentEmails(ctx context.Context) ([]bhap.OutgoingEmail, []bhap.Key, error) {
+	var results []bhap.OutgoingEmail
+	query := datastore.NewQuery(emailEntityName).
+		Filter("Sent =", false).
+		Order("Queued")
+
+	keys, err := query.GetAll(ctx, &results)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return results, encodeKeys(keys), nil
+}
+
+// QueueEmail adds an email to the outbox.
+func (s *Store) QueueEmail(ctx context.Context, e bhap.OutgoingEmail) error {
+	key := datastore.NewIncompleteKey(ctx, emailEntityName, nil)
+	if _, err := datastore.Put(ctx, key, &e); err != nil {
+		return fmt.Errorf("queueing email: %v", err)
+	}
+
+	return nil
+}
+
+// PutEmail saves changes to a queued email.
+func (s *Store) PutEmail(ctx context.Context, key bhap.Key, e bhap.OutgoingEmail) error {
+	dsKey, err := decodeKey(key)
+	if err != nil {
+		return err
+	}
+
+	if _, err := datastore.Put(ctx, dsKey, &e); err != nil {
+		return fmt.Errorf("saving email: %v", err)
+	}
+
+	return nil
+}
+
+// digest records a weekly digest being sent.
+type digest struct {
+	Sent time.Time
+}
+
+// LastDigestDate returns when the weekly digest was last sent, or the zero
+// time if it never was.
+func (s *Store) LastDigestDate(ctx context.Context) (time.Time, error) {
+	var results []digest
+	_, err := datastore.NewQuery(digestEntityName).
+		Order("-Sent").
+		Limit(1).
+		GetAll(ctx, &results)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("getting last digest: %v", err)
+	}
+	if len(results) == 0 {
+		return time.Time{}, nil
+	}
+
+	return results[0].Sent, nil
+}
+
+// RecordDigest records that the weekly digest was sent at the given time.
+func (s *Store) RecordDigest(ctx context.Context, date time.Time) error {
+	key := datastore.NewIncompleteKey(ctx, digestEntityName, nil)
+	if _, err := datastore.Put(ctx, key, &digest{Sent: date}); err != nil {
+		return fmt.Errorf("recording digest: %v", err)
+	}
+
+	return nil
+}
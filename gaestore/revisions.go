@@ -0,0 +1,112 @@
+package gaestore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/house-emoji/bhap"
+	"google.golang.org/appengine/datastore"
+)
+
+// revisionEntity is the Datastore representation of a revision. Revisions
+// are stored as children of the BHAP they are of.
+type revisionEntity struct {
+	bhap.Revision
+}
+
+func (e *revisionEntity) Load(props []datastore.Property) error {
+	return loadKeyed(&e.Revision, props)
+}
+
+func (e *revisionEntity) Save() ([]datastore.Property, error) {
+	return saveKeyed(&e.Revision)
+}
+
+// RevisionsForBHAP returns every revision of a BHAP, oldest first.
+func (s *Store) RevisionsForBHAP(ctx context.Context, bhapKey bhap.Key) ([]bhap.Revision, error) {
+	dsBHAPKey, err := decodeKey(bhapKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []revisionEntity
+	_, err = datastore.NewQuery(revisionEntityName).
+		Ancestor(dsBHAPKey).
+		Order("Number").
+		GetAll(ctx, &results)
+	if err != nil {
+		return nil, fmt.Errorf("getting revisions: %v", err)
+	}
+
+	revisions := make([]bhap.Revision, len(results))
+	for i, result := range results {
+		revisions[i] = result.Revision
+	}
+
+	return revisions, nil
+}
+
+// NewRevision records a revision.
+func (s *Store) NewRevision(ctx context.Context, r bhap.Revision) error {
+	dsBHAPKey, err := decodeKey(r.OnBHAP)
+	if err != nil {
+		return err
+	}
+
+	key := datastore.NewIncompleteKey(ctx, revisionEntityName, dsBHAPKey)
+	if _, err := datastore.Put(ctx, key, &revisionEntity{r}); err != nil {
+		return fmt.Errorf("saving revision: %v", err)
+	}
+
+	return nil
+}
+
+// SaveRevision saves changes to an existing BHAP and records the given
+// revisions of it, all in one transaction.
+func (s *Store) SaveRevision(ctx context.Context, key bhap.Key, b bhap.BHAP, substantive bool, revisions ...bhap.Revision) (bhap.BHAP, error) {
+	dsKey, err := decodeKey(key)
+	if err != nil {
+		return bhap.BHAP{}, err
+	}
+
+	saved := b
+	err = datastore.RunInTransaction(ctx, func(ctx context.Context) error {
+		var existing []revisionEntity
+		_, err := datastore.NewQuery(revisionEntityName).
+			Ancestor(dsKey).
+			Order("Number").
+			GetAll(ctx, &existing)
+		if err != nil {
+			return err
+		}
+
+		number := 0
+		if len(existing) > 0 {
+			number = existing[len(existing)-1].Number
+		}
+
+		for _, r := range revisions {
+			number++
+			r.Number = number
+			revisionKey := datastore.NewIncompleteKey(ctx, revisionEntityName, dsKey)
+			if _, err := datastore.Put(ctx, revisionKey, &revisionEntity{r}); err != nil {
+				return err
+			}
+		}
+
+		// The transaction may be retried, so each attempt starts over from
+		// the BHAP as given
+		saved = b
+		saved.Revision = number
+		if substantive {
+			saved.SubstantiveRevision = number
+		}
+		_, err = datastore.Put(ctx, dsKey, &bhapEntity{saved})
+		return err
+	}, nil)
+	if err != nil {
+		return bhap.BHAP{}, fmt.Errorf("saving revision: %v", err)
+	}
+
+	return saved, nil
+}
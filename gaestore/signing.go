@@ -0,0 +1,87 @@
+package gaestore
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"sync"
+
+	"github.com/house-emoji/bhap"
+	"google.golang.org/appengine/datastore"
+)
+
+// signingKey is the Datastore representation of the key that links in emails
+// are signed with.
+type signingKey struct {
+	Key []byte `datastore:",noindex"`
+}
+
+// Signer signs data with a secret key kept in Datastore. The key is made the
+// first time it's needed and never changes, so every instance makes and
+// accepts the same signatures, and links already sent keep working.
+type Signer struct {
+	mu  sync.Mutex
+	key []byte
+}
+
+var _ bhap.Signer = (*Signer)(nil)
+
+// NewSigner creates a new Datastore-backed signer.
+func NewSigner() *Signer {
+	return &Signer{}
+}
+
+// Sign returns a signature of the data.
+func (s *Signer) Sign(ctx context.Context, data []byte) ([]byte, error) {
+	key, err := s.signingKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return bhap.HMACSigner{Key: key}.Sign(ctx, data)
+}
+
+// Verify returns true if the signature is one the signer made of the data.
+func (s *Signer) Verify(ctx context.Context, data, signature []byte) (bool, error) {
+	key, err := s.signingKey(ctx)
+	if err != nil {
+		return false, err
+	}
+	return bhap.HMACSigner{Key: key}.Verify(ctx, data, signature)
+}
+
+// signingKey returns the key to sign with, making and saving one if there
+// isn't one yet. The key is loaded once per instance.
+func (s *Signer) signingKey(ctx context.Context) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.key != nil {
+		return s.key, nil
+	}
+
+	entityKey := datastore.NewKey(ctx, signingKeyEntityName, "links", 0, nil)
+	var stored signingKey
+
+	// Instances starting at once must agree on a single key
+	err := datastore.RunInTransaction(ctx, func(ctx context.Context) error {
+		err := datastore.Get(ctx, entityKey, &stored)
+		if err == nil {
+			return nil
+		} else if err != datastore.ErrNoSuchEntity {
+			return err
+		}
+
+		stored.Key = make([]byte, 32)
+		if _, err := rand.Read(stored.Key); err != nil {
+			return err
+		}
+		_, err = datastore.Put(ctx, entityKey, &stored)
+		return err
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("loading signing key: %v", err)
+	}
+
+	s.key = stored.Key
+	return s.key, nil
+}
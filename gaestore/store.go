@@ -0,0 +1,122 @@
+// Package gaestore implements bhap.Store on top of App Engine Datastore.
+package gaestore
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/house-emoji/bhap"
+	"google.golang.org/appengine/datastore"
+)
+
+const (
+	bhapEntityName         = "BHAP"
+	voteEntityName         = "Vote"
+	userEntityName         = "User"
+	invitationEntityName   = "Invitation"
+	idCounterEntityName    = "BHAPIDCounter"
+	ballotEntityName       = "Ballot"
+	voteEventEntityName    = "VoteEvent"
+	delegationEntityName   = "Delegation"
+	secretChoiceEntityName = "SecretChoice"
+	voterChangeEntityName  = "VoterChange"
+	revisionEntityName     = "Revision"
+	commentEntityName      = "Comment"
+	emailEntityName        = "OutgoingEmail"
+	signingKeyEntityName   = "SigningKey"
+	digestEntityName       = "Digest"
+)
+
+// Store is a bhap.Store backed by App Engine Datastore. Contexts passed to
+// its methods must be App Engine contexts.
+type Store struct{}
+
+var _ bhap.Store = (*Store)(nil)
+
+// New creates a new Datastore-backed store.
+func New() *Store {
+	return &Store{}
+}
+
+// encodeKey converts a Datastore key to a bhap.Key.
+func encodeKey(key *datastore.Key) bhap.Key {
+	if key == nil {
+		return ""
+	}
+	return bhap.Key(key.Encode())
+}
+
+// encodeKeys converts a list of Datastore keys to bhap.Keys.
+func encodeKeys(keys []*datastore.Key) []bhap.Key {
+	encoded := make([]bhap.Key, len(keys))
+	for i, key := range keys {
+		encoded[i] = encodeKey(key)
+	}
+	return encoded
+}
+
+// decodeKey converts a bhap.Key created by this store back into a Datastore
+// key.
+func decodeKey(key bhap.Key) (*datastore.Key, error) {
+	if key == "" {
+		return nil, nil
+	}
+
+	decoded, err := datastore.DecodeKey(string(key))
+	if err != nil {
+		return nil, fmt.Errorf("decoding key %v: %v", key, err)
+	}
+	return decoded, nil
+}
+
+var bhapKeyType = reflect.TypeOf(bhap.Key(""))
+
+// keyFields returns the names of all fields in the given struct type that
+// hold bhap.Keys.
+func keyFields(typ reflect.Type) map[string]bool {
+	fields := make(map[string]bool)
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Type == bhapKeyType ||
+			(field.Type.Kind() == reflect.Slice && field.Type.Elem() == bhapKeyType) {
+			fields[field.Name] = true
+		}
+	}
+	return fields
+}
+
+// loadKeyed loads properties into the struct pointed to by dst, converting
+// Datastore keys into bhap.Keys.
+func loadKeyed(dst interface{}, props []datastore.Property) error {
+	for i := range props {
+		if key, ok := props[i].Value.(*datastore.Key); ok {
+			props[i].Value = string(encodeKey(key))
+		}
+	}
+	return datastore.LoadStruct(dst, props)
+}
+
+// saveKeyed saves the struct pointed to by src as properties, storing its
+// bhap.Key fields as Datastore keys. This keeps references queryable and
+// compatible with entities saved before the Store abstraction existed.
+func saveKeyed(src interface{}) ([]datastore.Property, error) {
+	props, err := datastore.SaveStruct(src)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := keyFields(reflect.TypeOf(src).Elem())
+	for i := range props {
+		if !fields[props[i].Name] {
+			continue
+		}
+		encoded, _ := props[i].Value.(string)
+		key, err := decodeKey(bhap.Key(encoded))
+		if err != nil {
+			return nil, err
+		}
+		props[i].Value = key
+	}
+
+	return props, nil
+}
@@ -0,0 +1,94 @@
+package gaestore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/house-emoji/bhap"
+	"google.golang.org/appengine/datastore"
+)
+
+// UserByKey returns the user with the given key.
+func (s *Store) UserByKey(ctx context.Context, key bhap.Key) (bhap.User, error) {
+	dsKey, err := decodeKey(key)
+	if err != nil {
+		return bhap.User{}, err
+	}
+
+	var user bhap.User
+	if err := datastore.Get(ctx, dsKey, &user); err != nil {
+		return bhap.User{}, fmt.Errorf("getting user: %v", err)
+	}
+
+	return user, nil
+}
+
+// UserByEmail returns the user with the given email. If no user with that
+// email exists, the key will be empty.
+func (s *Store) UserByEmail(ctx context.Context, email string) (bhap.User, bhap.Key, error) {
+	var results []bhap.User
+	query := datastore.NewQuery(userEntityName).
+		Filter("Email =", email).
+		Limit(1)
+	keys, err := query.GetAll(ctx, &results)
+	if err != nil {
+		return bhap.User{}, "", err
+	}
+
+	if len(results) == 0 {
+		return bhap.User{}, "", nil
+	}
+
+	return results[0], encodeKey(keys[0]), nil
+}
+
+// UserCount returns the number of users.
+func (s *Store) UserCount(ctx context.Context) (int, error) {
+	count, err := datastore.NewQuery(userEntityName).Count(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("counting users: %v", err)
+	}
+
+	return count, nil
+}
+
+// AllUsers returns every user.
+func (s *Store) AllUsers(ctx context.Context) ([]bhap.User, []bhap.Key, error) {
+	var results []bhap.User
+	keys, err := datastore.NewQuery(userEntityName).GetAll(ctx, &results)
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting users: %v", err)
+	}
+
+	userKeys := make([]bhap.Key, len(keys))
+	for i, key := range keys {
+		userKeys[i] = encodeKey(key)
+	}
+
+	return results, userKeys, nil
+}
+
+// NewUser saves a new user and returns its key.
+func (s *Store) NewUser(ctx context.Context, u bhap.User) (bhap.Key, error) {
+	key := datastore.NewIncompleteKey(ctx, userEntityName, nil)
+	key, err := datastore.Put(ctx, key, &u)
+	if err != nil {
+		return "", fmt.Errorf("saving new user: %v", err)
+	}
+
+	return encodeKey(key), nil
+}
+
+// PutUser saves changes to an existing user.
+func (s *Store) PutUser(ctx context.Context, key bhap.Key, u bhap.User) error {
+	dsKey, err := decodeKey(key)
+	if err != nil {
+		return err
+	}
+
+	if _, err := datastore.Put(ctx, dsKey, &u); err != nil {
+		return fmt.Errorf("saving user: %v", err)
+	}
+
+	return nil
+}
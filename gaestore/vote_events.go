@@ -0,0 +1,63 @@
+package gaestore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/house-emoji/bhap"
+	"google.golang.org/appengine/datastore"
+)
+
+// voteEventEntity is the Datastore representation of a vote event. Vote
+// events are stored as children of the BHAP they are about.
+type voteEventEntity struct {
+	bhap.VoteEvent
+}
+
+func (e *voteEventEntity) Load(props []datastore.Property) error {
+	return loadKeyed(&e.VoteEvent, props)
+}
+
+func (e *voteEventEntity) Save() ([]datastore.Property, error) {
+	return saveKeyed(&e.VoteEvent)
+}
+
+// VoteEventsForBHAP returns every vote event recorded for a BHAP, oldest
+// first.
+func (s *Store) VoteEventsForBHAP(ctx context.Context, bhapKey bhap.Key) ([]bhap.VoteEvent, error) {
+	dsBHAPKey, err := decodeKey(bhapKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []voteEventEntity
+	_, err = datastore.NewQuery(voteEventEntityName).
+		Ancestor(dsBHAPKey).
+		Order("Date").
+		GetAll(ctx, &results)
+	if err != nil {
+		return nil, fmt.Errorf("getting vote events: %v", err)
+	}
+
+	events := make([]bhap.VoteEvent, len(results))
+	for i, result := range results {
+		events[i] = result.VoteEvent
+	}
+
+	return events, nil
+}
+
+// NewVoteEvent records a vote event.
+func (s *Store) NewVoteEvent(ctx context.Context, e bhap.VoteEvent) error {
+	dsBHAPKey, err := decodeKey(e.OnBHAP)
+	if err != nil {
+		return err
+	}
+
+	key := datastore.NewIncompleteKey(ctx, voteEventEntityName, dsBHAPKey)
+	if _, err := datastore.Put(ctx, key, &voteEventEntity{e}); err != nil {
+		return fmt.Errorf("saving vote event: %v", err)
+	}
+
+	return nil
+}
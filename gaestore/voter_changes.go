@@ -0,0 +1,63 @@
+package gaestore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/house-emoji/bhap"
+	"google.golang.org/appengine/datastore"
+)
+
+// voterChangeEntity is the Datastore representation of a voter change. Voter
+// changes are stored as children of the BHAP they are about.
+type voterChangeEntity struct {
+	bhap.VoterChange
+}
+
+func (e *voterChangeEntity) Load(props []datastore.Property) error {
+	return loadKeyed(&e.VoterChange, props)
+}
+
+func (e *voterChangeEntity) Save() ([]datastore.Property, error) {
+	return saveKeyed(&e.VoterChange)
+}
+
+// VoterChangesForBHAP returns every voter change recorded for a BHAP, oldest
+// first.
+func (s *Store) VoterChangesForBHAP(ctx context.Context, bhapKey bhap.Key) ([]bhap.VoterChange, error) {
+	dsBHAPKey, err := decodeKey(bhapKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []voterChangeEntity
+	_, err = datastore.NewQuery(voterChangeEntityName).
+		Ancestor(dsBHAPKey).
+		Order("Date").
+		GetAll(ctx, &results)
+	if err != nil {
+		return nil, fmt.Errorf("getting voter changes: %v", err)
+	}
+
+	changes := make([]bhap.VoterChange, len(results))
+	for i, result := range results {
+		changes[i] = result.VoterChange
+	}
+
+	return changes, nil
+}
+
+// NewVoterChange records a voter change.
+func (s *Store) NewVoterChange(ctx context.Context, c bhap.VoterChange) error {
+	dsBHAPKey, err := decodeKey(c.OnBHAP)
+	if err != nil {
+		return err
+	}
+
+	key := datastore.NewIncompleteKey(ctx, voterChangeEntityName, dsBHAPKey)
+	if _, err := datastore.Put(ctx, key, &voterChangeEntity{c}); err != nil {
+		return fmt.Errorf("saving voter change: %v", err)
+	}
+
+	return nil
+}
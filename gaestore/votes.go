@@ -0,0 +1,307 @@
+package gaestore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/house-emoji/bhap"
+	"google.golang.org/appengine/datastore"
+)
+
+// voteEntity is the Datastore representation of a vote. Votes are stored as
+// children of the BHAP they were cast on.
+type voteEntity struct {
+	bhap.Vote
+}
+
+func (e *voteEntity) Load(props []datastore.Property) error {
+	return loadKeyed(&e.Vote, props)
+}
+
+func (e *voteEntity) Save() ([]datastore.Property, error) {
+	return saveKeyed(&e.Vote)
+}
+
+// AllVotesForBHAP returns all the votes that have been cast for a given BHAP.
+func (s *Store) AllVotesForBHAP(ctx context.Context, bhapKey bhap.Key) ([]bhap.Vote, error) {
+	dsBHAPKey, err := decodeKey(bhapKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []voteEntity
+	_, err = datastore.NewQuery(voteEntityName).
+		Ancestor(dsBHAPKey).
+		GetAll(ctx, &results)
+	if err != nil {
+		return []bhap.Vote{}, fmt.Errorf("getting BHAP votes: %v", err)
+	}
+
+	votes := make([]bhap.Vote, len(results))
+	for i, result := range results {
+		votes[i] = result.Vote
+	}
+
+	return votes, nil
+}
+
+// GetVoteForBHAP returns the user's current vote on a BHAP.
+func (s *Store) GetVoteForBHAP(ctx context.Context, bhapKey, userKey bhap.Key) (bhap.Vote, bhap.Key, error) {
+	if userKey == "" {
+		return bhap.Vote{}, "", nil
+	}
+
+	existing, existingKey, err := s.userVote(ctx, bhapKey, userKey)
+	if err != nil {
+		return bhap.Vote{}, "", fmt.Errorf("getting user's vote: %v", err)
+	}
+
+	return existing.Vote, encodeKey(existingKey), nil
+}
+
+// SetVoteForBHAP sets the vote of the user for the given BHAP to a value cast
+// on the given revision, creating a new vote if necessary.
+func (s *Store) SetVoteForBHAP(ctx context.Context, bhapKey, userKey bhap.Key, value bhap.Status, revision int) error {
+	voteToSave, voteKey, err := s.userVote(ctx, bhapKey, userKey)
+	if err != nil {
+		return fmt.Errorf("looking for existing votes: %v", err)
+	}
+
+	if voteKey == nil {
+		// Make a new vote if one doesn't exist
+		dsBHAPKey, err := decodeKey(bhapKey)
+		if err != nil {
+			return err
+		}
+		voteKey = datastore.NewIncompleteKey(ctx, voteEntityName, dsBHAPKey)
+		voteToSave = voteEntity{bhap.Vote{
+			OnBHAP:   bhapKey,
+			ByUser:   userKey,
+			Value:    value,
+			Revision: revision}}
+	} else {
+		// Edit the existing vote
+		voteToSave.Value = value
+		voteToSave.Rank = 0
+		voteToSave.Proxy = ""
+		voteToSave.Revision = revision
+	}
+
+	if _, err := datastore.Put(ctx, voteKey, &voteToSave); err != nil {
+		return fmt.Errorf("creating vote: %v", err)
+	}
+
+	return nil
+}
+
+// SetProxyVoteForBHAP sets the vote of the user for the given BHAP to a value
+// cast on their behalf by a delegate on the given revision, creating a new
+// vote if necessary.
+func (s *Store) SetProxyVoteForBHAP(ctx context.Context, bhapKey, userKey, proxyKey bhap.Key, value bhap.Status, revision int) error {
+	_, voteKey, err := s.userVote(ctx, bhapKey, userKey)
+	if err != nil {
+		return fmt.Errorf("looking for existing votes: %v", err)
+	}
+
+	if voteKey == nil {
+		dsBHAPKey, err := decodeKey(bhapKey)
+		if err != nil {
+			return err
+		}
+		voteKey = datastore.NewIncompleteKey(ctx, voteEntityName, dsBHAPKey)
+	}
+
+	voteToSave := voteEntity{bhap.Vote{
+		OnBHAP:   bhapKey,
+		ByUser:   userKey,
+		Value:    value,
+		Proxy:    proxyKey,
+		Revision: revision}}
+	if _, err := datastore.Put(ctx, voteKey, &voteToSave); err != nil {
+		return fmt.Errorf("saving proxy vote: %v", err)
+	}
+
+	return nil
+}
+
+// SetRankForBHAP sets the user's ranked vote for a BHAP on a ballot, creating
+// a new vote if necessary.
+func (s *Store) SetRankForBHAP(ctx context.Context, bhapKey, userKey bhap.Key, rank int) error {
+	_, voteKey, err := s.userVote(ctx, bhapKey, userKey)
+	if err != nil {
+		return fmt.Errorf("looking for existing votes: %v", err)
+	}
+
+	if voteKey == nil {
+		dsBHAPKey, err := decodeKey(bhapKey)
+		if err != nil {
+			return err
+		}
+		voteKey = datastore.NewIncompleteKey(ctx, voteEntityName, dsBHAPKey)
+	}
+
+	voteToSave := voteEntity{bhap.Vote{
+		OnBHAP: bhapKey,
+		ByUser: userKey,
+		Value:  bhap.RankedVote,
+		Rank:   rank}}
+	if _, err := datastore.Put(ctx, voteKey, &voteToSave); err != nil {
+		return fmt.Errorf("saving ranked vote: %v", err)
+	}
+
+	return nil
+}
+
+// DeleteVote deletes a cast vote.
+func (s *Store) DeleteVote(ctx context.Context, voteKey bhap.Key) error {
+	dsVoteKey, err := decodeKey(voteKey)
+	if err != nil {
+		return err
+	}
+
+	if err := datastore.Delete(ctx, dsVoteKey); err != nil {
+		return fmt.Errorf("deleting vote: %v", err)
+	}
+
+	return nil
+}
+
+// DeleteVotesForBHAP deletes every vote cast on a BHAP, along with any
+// secret choices made on it.
+func (s *Store) DeleteVotesForBHAP(ctx context.Context, bhapKey bhap.Key) error {
+	dsBHAPKey, err := decodeKey(bhapKey)
+	if err != nil {
+		return err
+	}
+
+	keys, err := datastore.NewQuery(voteEntityName).
+		Ancestor(dsBHAPKey).
+		KeysOnly().
+		GetAll(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("finding BHAP votes: %v", err)
+	}
+
+	choiceKeys, err := datastore.NewQuery(secretChoiceEntityName).
+		Ancestor(dsBHAPKey).
+		KeysOnly().
+		GetAll(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("finding secret choices: %v", err)
+	}
+
+	if err := datastore.DeleteMulti(ctx, append(keys, choiceKeys...)); err != nil {
+		return fmt.Errorf("deleting BHAP votes: %v", err)
+	}
+
+	return nil
+}
+
+// secretChoice counts how many times a choice was made on a BHAP's secret
+// ballot. There is one per choice, stored as a child of the BHAP and keyed
+// by the choice's value.
+type secretChoice struct {
+	Count int
+}
+
+// CastSecretVote records that the user voted on a BHAP with a secret ballot
+// and counts their choice, as long as they haven't already voted. The vote
+// and the count are children of the BHAP, so both are checked and saved in
+// one transaction, and concurrent votes are never lost or doubled.
+func (s *Store) CastSecretVote(ctx context.Context, bhapKey, userKey bhap.Key, value bhap.Status, revision int) error {
+	dsBHAPKey, err := decodeKey(bhapKey)
+	if err != nil {
+		return err
+	}
+
+	choiceKey := datastore.NewKey(ctx, secretChoiceEntityName, string(value), 0, dsBHAPKey)
+
+	err = datastore.RunInTransaction(ctx, func(ctx context.Context) error {
+		_, existingKey, err := s.userVote(ctx, bhapKey, userKey)
+		if err != nil {
+			return err
+		}
+		if existingKey != nil {
+			return bhap.ErrVoteFinal
+		}
+
+		vote := voteEntity{bhap.Vote{
+			OnBHAP:   bhapKey,
+			ByUser:   userKey,
+			Value:    bhap.SecretVote,
+			Revision: revision}}
+		voteKey := datastore.NewIncompleteKey(ctx, voteEntityName, dsBHAPKey)
+		if _, err := datastore.Put(ctx, voteKey, &vote); err != nil {
+			return err
+		}
+
+		var choice secretChoice
+		err = datastore.Get(ctx, choiceKey, &choice)
+		if err != nil && err != datastore.ErrNoSuchEntity {
+			return err
+		}
+
+		choice.Count++
+		_, err = datastore.Put(ctx, choiceKey, &choice)
+		return err
+	}, nil)
+	if err == bhap.ErrVoteFinal {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("casting secret vote: %v", err)
+	}
+
+	return nil
+}
+
+// SecretChoices returns how many times each choice was made on a BHAP's
+// secret ballot.
+func (s *Store) SecretChoices(ctx context.Context, bhapKey bhap.Key) (map[bhap.Status]int, error) {
+	dsBHAPKey, err := decodeKey(bhapKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []secretChoice
+	keys, err := datastore.NewQuery(secretChoiceEntityName).
+		Ancestor(dsBHAPKey).
+		GetAll(ctx, &results)
+	if err != nil {
+		return nil, fmt.Errorf("getting secret choices: %v", err)
+	}
+
+	choices := make(map[bhap.Status]int)
+	for i, result := range results {
+		choices[bhap.Status(keys[i].StringID())] = result.Count
+	}
+
+	return choices, nil
+}
+
+// userVote finds the vote a user has cast on a BHAP. If no vote has been
+// cast, the returned key will be nil.
+func (s *Store) userVote(ctx context.Context, bhapKey, userKey bhap.Key) (voteEntity, *datastore.Key, error) {
+	dsBHAPKey, err := decodeKey(bhapKey)
+	if err != nil {
+		return voteEntity{}, nil, err
+	}
+	dsUserKey, err := decodeKey(userKey)
+	if err != nil {
+		return voteEntity{}, nil, err
+	}
+
+	var results []voteEntity
+	keys, err := datastore.NewQuery(voteEntityName).
+		Ancestor(dsBHAPKey).
+		Filter("ByUser =", dsUserKey).
+		GetAll(ctx, &results)
+	if err != nil {
+		return voteEntity{}, nil, err
+	}
+
+	if len(results) == 0 {
+		return voteEntity{}, nil, nil
+	}
+
+	return results[0], keys[0], nil
+}
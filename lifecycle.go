@@ -0,0 +1,325 @@
+package bhap
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/house-emoji/bhap/log"
+)
+
+// Role describes a capacity in which someone acts on a BHAP. Roles are bit
+// flags, so a set of them can be held at once.
+type Role int
+
+const (
+	// AuthorRole is held by the author and co-authors of the BHAP being
+	// acted on.
+	AuthorRole Role = 1 << iota
+	// AdminRole is held by house admins.
+	AdminRole
+	// SystemRole is held by the app itself, for changes that happen
+	// automatically, like when votes are tallied.
+	SystemRole
+)
+
+func (r Role) String() string {
+	var names []string
+	if r&AuthorRole != 0 {
+		names = append(names, "an author")
+	}
+	if r&AdminRole != 0 {
+		names = append(names, "an admin")
+	}
+	if r&SystemRole != 0 {
+		names = append(names, "the system")
+	}
+	return strings.Join(names, " or ")
+}
+
+// Actor is someone or something requesting that a BHAP change status.
+type Actor struct {
+	// UserKey is the key of the user making the change, or empty for the
+	// system.
+	UserKey Key
+	Roles   Role
+}
+
+// SystemActor acts on behalf of the app itself.
+var SystemActor = Actor{Roles: SystemRole}
+
+// UserActor returns an actor for the given user acting on the given BHAP.
+func UserActor(b BHAP, userKey Key, user User) Actor {
+	var roles Role
+	if b.IsAuthor(userKey) {
+		roles |= AuthorRole
+	}
+	if user.Admin {
+		roles |= AdminRole
+	}
+
+	return Actor{UserKey: userKey, Roles: roles}
+}
+
+// Effect is a side effect of a status change. Effects run before the BHAP is
+// saved and may modify it. If an effect fails, the status change is aborted.
+type Effect func(ctx context.Context, s Store, key Key, b *BHAP) error
+
+// Transition is an allowed change from one status to another.
+type Transition struct {
+	From Status
+	To   Status
+	// AllowedRoles are the roles that may make this transition. Holding
+	// any one of them is enough.
+	AllowedRoles Role
+	// Effects are run in order when the transition is made.
+	Effects []Effect
+}
+
+// transitions is the full set of allowed status changes. Any change that
+// isn't listed here is illegal.
+var transitions []Transition
+
+// The table is filled in at init time because some effects make status
+// changes of their own, which refer back to it.
+func init() {
+	transitions = []Transition{
+		{
+			From:         DraftStatus,
+			To:           DiscussionStatus,
+			AllowedRoles: AuthorRole,
+			Effects:      []Effect{assignID, startVoting},
+		},
+		{
+			From:         DraftStatus,
+			To:           AprilFoolsStatus,
+			AllowedRoles: AdminRole,
+		},
+		{
+			From:         DiscussionStatus,
+			To:           WithdrawnStatus,
+			AllowedRoles: AuthorRole,
+			Effects:      []Effect{dateDecision},
+		},
+		{
+			From:         DiscussionStatus,
+			To:           DeferredStatus,
+			AllowedRoles: AuthorRole | AdminRole,
+			Effects:      []Effect{clearVotesOnDefer},
+		},
+		{
+			From:         DiscussionStatus,
+			To:           AcceptedStatus,
+			AllowedRoles: SystemRole,
+			Effects:      []Effect{replaceSuperseded, dateDecision},
+		},
+		{
+			From:         DiscussionStatus,
+			To:           RejectedStatus,
+			AllowedRoles: SystemRole,
+			Effects:      []Effect{dateDecision},
+		},
+		{
+			From:         DiscussionStatus,
+			To:           AprilFoolsStatus,
+			AllowedRoles: AdminRole,
+		},
+		{
+			From:         DeferredStatus,
+			To:           DiscussionStatus,
+			AllowedRoles: AuthorRole | AdminRole | SystemRole,
+			Effects:      []Effect{clearDeferral, startVoting},
+		},
+		{
+			// Admins may put a BHAP on hold and bring it back, but
+			// withdrawing it is up to its authors, as it is from
+			// discussion
+			From:         DeferredStatus,
+			To:           WithdrawnStatus,
+			AllowedRoles: AuthorRole,
+			Effects:      []Effect{clearDeferral, dateDecision},
+		},
+		{
+			From:         AcceptedStatus,
+			To:           ReplacedStatus,
+			AllowedRoles: SystemRole,
+		},
+		{
+			From:         AcceptedStatus,
+			To:           DiscussionStatus,
+			AllowedRoles: AdminRole,
+			Effects:      []Effect{checkReopenable, startVoting},
+		},
+		{
+			From:         RejectedStatus,
+			To:           DiscussionStatus,
+			AllowedRoles: AdminRole,
+			Effects:      []Effect{checkReopenable, startVoting},
+		},
+	}
+}
+
+// IllegalTransitionError is returned when a BHAP is asked to make a status
+// change that is never allowed.
+type IllegalTransitionError struct {
+	From Status
+	To   Status
+}
+
+func (e *IllegalTransitionError) Error() string {
+	return fmt.Sprintf("a BHAP cannot go from %v to %v", e.From, e.To)
+}
+
+// ForbiddenTransitionError is returned when a status change is allowed, but
+// not for the actor that requested it.
+type ForbiddenTransitionError struct {
+	From         Status
+	To           Status
+	AllowedRoles Role
+}
+
+func (e *ForbiddenTransitionError) Error() string {
+	if e.AllowedRoles == SystemRole {
+		return fmt.Sprintf("a BHAP only goes from %v to %v automatically",
+			e.From, e.To)
+	}
+	return fmt.Sprintf("only %v may move a BHAP from %v to %v",
+		e.AllowedRoles, e.From, e.To)
+}
+
+// FindTransition returns the transition from one status to another if the
+// actor is allowed to make it. Otherwise, an *IllegalTransitionError or
+// *ForbiddenTransitionError is returned.
+func FindTransition(from, to Status, actor Actor) (Transition, error) {
+	for _, t := range transitions {
+		if t.From != from || t.To != to {
+			continue
+		}
+
+		if t.AllowedRoles&actor.Roles == 0 {
+			return Transition{}, &ForbiddenTransitionError{
+				From:         from,
+				To:           to,
+				AllowedRoles: t.AllowedRoles,
+			}
+		}
+		return t, nil
+	}
+
+	return Transition{}, &IllegalTransitionError{From: from, To: to}
+}
+
+// statusNotifier is told about every status change once it's saved.
+var statusNotifier func(ctx context.Context, b BHAP, from Status) error
+
+// SetStatusNotifier sets what is told about every status change once it's
+// saved, like to let members know. The BHAP is given with its new status,
+// along with the status it came from.
+func SetStatusNotifier(f func(ctx context.Context, b BHAP, from Status) error) {
+	statusNotifier = f
+}
+
+// ChangeStatus moves a BHAP to a new status on behalf of the actor, running
+// the transition's side effects and saving the result. The updated BHAP is
+// returned.
+func ChangeStatus(ctx context.Context, s Store, key Key, b BHAP, to Status, actor Actor) (BHAP, error) {
+	t, err := FindTransition(b.Status, to, actor)
+	if err != nil {
+		return BHAP{}, err
+	}
+
+	for _, effect := range t.Effects {
+		if err := effect(ctx, s, key, &b); err != nil {
+			return BHAP{}, err
+		}
+	}
+
+	b.Status = to
+	if err := s.PutBHAP(ctx, key, b); err != nil {
+		return BHAP{}, fmt.Errorf("saving BHAP: %v", err)
+	}
+
+	log.Infof(ctx, "moved BHAP %v from %v to %v", b.ID, t.From, t.To)
+
+	// The change is saved either way, so members not hearing about it isn't
+	// worth failing over
+	if statusNotifier != nil {
+		if err := statusNotifier(ctx, b, t.From); err != nil {
+			log.Errorf(ctx, "failed to send notice of BHAP %v moving to %v: %v",
+				b.ID, t.To, err)
+		}
+	}
+
+	return b, nil
+}
+
+// assignID gives a BHAP that is leaving the draft stage its permanent ID.
+func assignID(ctx context.Context, s Store, key Key, b *BHAP) error {
+	id, err := s.NextID(ctx, b.Type)
+	if err != nil {
+		return err
+	}
+
+	b.ID = id
+	return nil
+}
+
+// startVoting sets the deadline and eligible voters for the vote on a BHAP
+// that is entering discussion.
+func startVoting(ctx context.Context, s Store, key Key, b *BHAP) error {
+	b.VotingDeadline = time.Now().Add(b.VotingPeriod())
+	b.RemindersSent = 0
+	return recordVoters(ctx, s, key, b)
+}
+
+// clearVotesOnDefer deletes the votes on a BHAP that is being deferred, unless
+// the settings say to keep them.
+func clearVotesOnDefer(ctx context.Context, s Store, key Key, b *BHAP) error {
+	if CurrentSettings().KeepVotesOnDefer {
+		return nil
+	}
+
+	if err := ClearVotes(ctx, s, key); err != nil {
+		return fmt.Errorf("clearing votes: %v", err)
+	}
+	return nil
+}
+
+// clearDeferral removes the details of why and until when a BHAP was
+// deferred.
+func clearDeferral(ctx context.Context, s Store, key Key, b *BHAP) error {
+	b.DeferReason = ""
+	b.ResumeDate = time.Time{}
+	return nil
+}
+
+// dateDecision records when a BHAP was decided or withdrawn.
+func dateDecision(ctx context.Context, s Store, key Key, b *BHAP) error {
+	b.DecidedDate = time.Now()
+	return nil
+}
+
+// replaceSuperseded moves the BHAPs that a newly accepted BHAP supersedes
+// to Replaced, linking them back to the new BHAP.
+func replaceSuperseded(ctx context.Context, s Store, key Key, b *BHAP) error {
+	for _, oldKey := range b.Replaces {
+		old, err := s.ByKey(ctx, oldKey)
+		if err != nil {
+			return fmt.Errorf("loading superseded BHAP: %v", err)
+		}
+
+		if old.Status != AcceptedStatus {
+			log.Warningf(ctx, "BHAP %v is %v, so BHAP %v cannot replace it",
+				old.ID, old.Status, b.ID)
+			continue
+		}
+
+		old.ReplacedBy = key
+		if _, err := ChangeStatus(ctx, s, oldKey, old, ReplacedStatus, SystemActor); err != nil {
+			return fmt.Errorf("replacing BHAP %v: %v", old.ID, err)
+		}
+	}
+
+	return nil
+}
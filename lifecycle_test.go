@@ -0,0 +1,84 @@
+package bhap
+
+import "testing"
+
+func TestFindTransition(t *testing.T) {
+	const (
+		allowed = iota
+		forbidden
+		illegal
+	)
+
+	tests := []struct {
+		from  Status
+		to    Status
+		roles Role
+		want  int
+	}{
+		{DraftStatus, DiscussionStatus, AuthorRole, allowed},
+		{DraftStatus, DiscussionStatus, AuthorRole | AdminRole, allowed},
+		{DraftStatus, DiscussionStatus, AdminRole, forbidden},
+		{DraftStatus, DiscussionStatus, 0, forbidden},
+		{DraftStatus, AprilFoolsStatus, AdminRole, allowed},
+		{DraftStatus, AprilFoolsStatus, AuthorRole, forbidden},
+
+		{DiscussionStatus, WithdrawnStatus, AuthorRole, allowed},
+		{DiscussionStatus, WithdrawnStatus, AdminRole, forbidden},
+		{DiscussionStatus, DeferredStatus, AuthorRole, allowed},
+		{DiscussionStatus, DeferredStatus, AdminRole, allowed},
+		{DiscussionStatus, DeferredStatus, SystemRole, forbidden},
+		{DiscussionStatus, AcceptedStatus, SystemRole, allowed},
+		{DiscussionStatus, AcceptedStatus, AuthorRole | AdminRole, forbidden},
+		{DiscussionStatus, RejectedStatus, SystemRole, allowed},
+		{DiscussionStatus, RejectedStatus, AdminRole, forbidden},
+
+		{DeferredStatus, DiscussionStatus, AuthorRole, allowed},
+		{DeferredStatus, DiscussionStatus, AdminRole, allowed},
+		{DeferredStatus, DiscussionStatus, SystemRole, allowed},
+		{DeferredStatus, DiscussionStatus, 0, forbidden},
+		{DeferredStatus, WithdrawnStatus, AuthorRole, allowed},
+		{DeferredStatus, WithdrawnStatus, AdminRole, forbidden},
+
+		{AcceptedStatus, ReplacedStatus, SystemRole, allowed},
+		{AcceptedStatus, ReplacedStatus, AdminRole, forbidden},
+		{AcceptedStatus, DiscussionStatus, AdminRole, allowed},
+		{AcceptedStatus, DiscussionStatus, AuthorRole, forbidden},
+		{RejectedStatus, DiscussionStatus, AdminRole, allowed},
+
+		{DraftStatus, AcceptedStatus, SystemRole, illegal},
+		{DiscussionStatus, DraftStatus, AuthorRole | AdminRole | SystemRole, illegal},
+		{DiscussionStatus, DiscussionStatus, SystemRole, illegal},
+		{AcceptedStatus, RejectedStatus, SystemRole, illegal},
+		{WithdrawnStatus, DiscussionStatus, AuthorRole | AdminRole, illegal},
+		{ReplacedStatus, AcceptedStatus, SystemRole, illegal},
+		{AprilFoolsStatus, DraftStatus, AdminRole, illegal},
+		{DeferredStatus, AcceptedStatus, SystemRole, illegal},
+	}
+
+	for _, test := range tests {
+		transition, err := FindTransition(test.from, test.to, Actor{Roles: test.roles})
+
+		var got int
+		switch err.(type) {
+		case nil:
+			got = allowed
+			if transition.From != test.from || transition.To != test.to {
+				t.Errorf("%v to %v as %v: got transition from %v to %v",
+					test.from, test.to, test.roles, transition.From, transition.To)
+			}
+		case *ForbiddenTransitionError:
+			got = forbidden
+		case *IllegalTransitionError:
+			got = illegal
+		default:
+			t.Errorf("%v to %v as %v: got unexpected error %v",
+				test.from, test.to, test.roles, err)
+			continue
+		}
+
+		if got != test.want {
+			t.Errorf("%v to %v as %v: got error %v", test.from, test.to,
+				test.roles, err)
+		}
+	}
+}
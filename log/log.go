@@ -0,0 +1,65 @@
+// Package log provides leveled logging with a pluggable backend. Messages go
+// to the standard library's logger unless another Logger is set, such as one
+// that writes to App Engine's logs.
+package log
+
+import (
+	"context"
+	stdlog "log"
+)
+
+// Logger writes log messages at different levels of severity.
+type Logger interface {
+	Debugf(ctx context.Context, format string, args ...interface{})
+	Infof(ctx context.Context, format string, args ...interface{})
+	Warningf(ctx context.Context, format string, args ...interface{})
+	Errorf(ctx context.Context, format string, args ...interface{})
+}
+
+var logger Logger = StdLogger{}
+
+// SetLogger replaces the logger that all messages are written to. It should
+// be called before any handlers are served.
+func SetLogger(l Logger) {
+	logger = l
+}
+
+// Debugf logs a debug message.
+func Debugf(ctx context.Context, format string, args ...interface{}) {
+	logger.Debugf(ctx, format, args...)
+}
+
+// Infof logs an informational message.
+func Infof(ctx context.Context, format string, args ...interface{}) {
+	logger.Infof(ctx, format, args...)
+}
+
+// Warningf logs a warning message.
+func Warningf(ctx context.Context, format string, args ...interface{}) {
+	logger.Warningf(ctx, format, args...)
+}
+
+// Errorf logs an error message.
+func Errorf(ctx context.Context, format string, args ...interface{}) {
+	logger.Errorf(ctx, format, args...)
+}
+
+// StdLogger is a Logger that writes to the standard library's logger, with
+// each message prefixed by its level.
+type StdLogger struct{}
+
+func (StdLogger) Debugf(ctx context.Context, format string, args ...interface{}) {
+	stdlog.Printf("DEBUG: "+format, args...)
+}
+
+func (StdLogger) Infof(ctx context.Context, format string, args ...interface{}) {
+	stdlog.Printf("INFO: "+format, args...)
+}
+
+func (StdLogger) Warningf(ctx context.Context, format string, args ...interface{}) {
+	stdlog.Printf("WARNING: "+format, args...)
+}
+
+func (StdLogger) Errorf(ctx context.Context, format string, args ...interface{}) {
+	stdlog.Printf("ERROR: "+format, args...)
+}
@@ -0,0 +1,103 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/house-emoji/bhap"
+)
+
+const ballotKind = "Ballot"
+
+// BallotByKey returns the ballot with the given key.
+func (s *Store) BallotByKey(ctx context.Context, key bhap.Key) (bhap.Ballot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.ballots[key]
+	if !ok {
+		return bhap.Ballot{}, fmt.Errorf("no ballot with key %v", key)
+	}
+
+	return b, nil
+}
+
+// BallotByUID returns the ballot with the given UID.
+func (s *Store) BallotByUID(ctx context.Context, uid string) (bhap.Ballot, bhap.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range s.ballotKeys() {
+		if s.ballots[key].UID == uid {
+			return s.ballots[key], key, nil
+		}
+	}
+
+	return bhap.Ballot{}, "", nil
+}
+
+// OpenBallots returns all ballots that have yet to be decided.
+func (s *Store) OpenBallots(ctx context.Context) ([]bhap.Ballot, []bhap.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var results []bhap.Ballot
+	var keys []bhap.Key
+	for _, key := range s.ballotKeys() {
+		if !s.ballots[key].Closed {
+			results = append(results, s.ballots[key])
+			keys = append(keys, key)
+		}
+	}
+
+	return results, keys, nil
+}
+
+// NewBallot saves a new ballot and returns its key.
+func (s *Store) NewBallot(ctx context.Context, b bhap.Ballot) (bhap.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := s.newKey(ballotKind, "")
+	s.ballots[key] = copyBallot(b)
+
+	return key, nil
+}
+
+// PutBallot saves changes to an existing ballot.
+func (s *Store) PutBallot(ctx context.Context, key bhap.Key, b bhap.Ballot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.ballots[key]; !ok {
+		return fmt.Errorf("no ballot with key %v", key)
+	}
+	s.ballots[key] = copyBallot(b)
+
+	return nil
+}
+
+// ballotKeys returns the keys of all ballots in creation order. The caller
+// must hold the lock.
+func (s *Store) ballotKeys() []bhap.Key {
+	keys := make([]bhap.Key, 0, len(s.ballots))
+	for _, key := range s.order {
+		if _, ok := s.ballots[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// copyBallot returns a copy of the ballot that shares no memory with the
+// original.
+func copyBallot(b bhap.Ballot) bhap.Ballot {
+	if b.Options != nil {
+		b.Options = append([]bhap.Key(nil), b.Options...)
+	}
+	if b.Counted != nil {
+		b.Counted = append([]bhap.Key(nil), b.Counted...)
+	}
+	return b
+}
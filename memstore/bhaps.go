@@ -0,0 +1,174 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/house-emoji/bhap"
+)
+
+const bhapKind = "BHAP"
+
+// ByKey gets the BHAP with the given key.
+func (s *Store) ByKey(ctx context.Context, key bhap.Key) (bhap.BHAP, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.bhaps[key]
+	if !ok {
+		return bhap.BHAP{}, fmt.Errorf("no BHAP with key %v", key)
+	}
+
+	return b, nil
+}
+
+// ByDraftID gets a BHAP by the given draft ID. If none exists, the key will
+// be empty.
+func (s *Store) ByDraftID(ctx context.Context, draftID string) (bhap.BHAP, bhap.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range s.bhapKeys() {
+		if s.bhaps[key].DraftID == draftID {
+			return s.bhaps[key], key, nil
+		}
+	}
+
+	return bhap.BHAP{}, "", nil
+}
+
+// ByID gets a BHAP by the given ID. If none exists, the key will be empty.
+func (s *Store) ByID(ctx context.Context, id int) (bhap.BHAP, bhap.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range s.bhapKeys() {
+		if s.bhaps[key].ID == id {
+			return s.bhaps[key], key, nil
+		}
+	}
+
+	return bhap.BHAP{}, "", nil
+}
+
+// NextID reserves and returns the next unused ID for a new BHAP, using the
+// numbering convention that fits for the given type.
+func (s *Store) NextID(ctx context.Context, typ bhap.BHAPType) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next, ok := s.idCounters[typ]
+	if !ok {
+		// Start after any IDs that are already in use
+		var err error
+		next, err = bhap.FirstID(typ)
+		if err != nil {
+			return 0, err
+		}
+		for _, b := range s.bhaps {
+			if bhap.InIDRange(typ, b.ID) && b.ID >= next {
+				next = b.ID + 1
+			}
+		}
+	}
+
+	if !bhap.InIDRange(typ, next) {
+		return 0, bhap.ErrIDsExhausted
+	}
+	s.idCounters[typ] = next + 1
+
+	return next, nil
+}
+
+// GetAll returns all recorded BHAPs.
+func (s *Store) GetAll(ctx context.Context) ([]bhap.BHAP, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	results := make([]bhap.BHAP, 0, len(s.bhaps))
+	for _, key := range s.bhapKeys() {
+		results = append(results, s.bhaps[key])
+	}
+	sortByID(results)
+
+	return results, nil
+}
+
+// ByStatus returns all BHAPs with the given status(es).
+func (s *Store) ByStatus(ctx context.Context, statuses ...bhap.Status) ([]bhap.BHAP, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wanted := make(map[bhap.Status]bool)
+	for _, status := range statuses {
+		wanted[status] = true
+	}
+
+	results := make([]bhap.BHAP, 0)
+	for _, key := range s.bhapKeys() {
+		if wanted[s.bhaps[key].Status] {
+			results = append(results, s.bhaps[key])
+		}
+	}
+	sortByID(results)
+
+	return results, nil
+}
+
+// NewBHAP saves a new BHAP and returns its key.
+func (s *Store) NewBHAP(ctx context.Context, b bhap.BHAP) (bhap.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := s.newKey(bhapKind, "")
+	s.bhaps[key] = copyBHAP(b)
+
+	return key, nil
+}
+
+// PutBHAP saves changes to an existing BHAP.
+func (s *Store) PutBHAP(ctx context.Context, key bhap.Key, b bhap.BHAP) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.bhaps[key]; !ok {
+		return fmt.Errorf("no BHAP with key %v", key)
+	}
+	s.bhaps[key] = copyBHAP(b)
+
+	return nil
+}
+
+// bhapKeys returns the keys of all BHAPs in creation order. The caller must
+// hold the lock.
+func (s *Store) bhapKeys() []bhap.Key {
+	keys := make([]bhap.Key, 0, len(s.bhaps))
+	for _, key := range s.order {
+		if _, ok := s.bhaps[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// sortByID sorts BHAPs by their ID, keeping creation order for BHAPs with
+// the same ID.
+func sortByID(bhaps []bhap.BHAP) {
+	sort.SliceStable(bhaps, func(i, j int) bool {
+		return bhaps[i].ID < bhaps[j].ID
+	})
+}
+
+// copyBHAP returns a copy of the BHAP that shares no memory with the
+// original, so that callers can't change stored BHAPs by accident.
+func copyBHAP(b bhap.BHAP) bhap.BHAP {
+	if b.Replaces != nil {
+		b.Replaces = append([]bhap.Key(nil), b.Replaces...)
+	}
+	if b.Voters != nil {
+		b.Voters = append([]bhap.Key(nil), b.Voters...)
+	}
+	return b
+}
@@ -0,0 +1,92 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/house-emoji/bhap"
+)
+
+const commentKind = "Comment"
+
+// CommentsForBHAP returns every comment on a BHAP, oldest first.
+func (s *Store) CommentsForBHAP(ctx context.Context, bhapKey bhap.Key) ([]bhap.Comment, []bhap.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	results := make([]bhap.Comment, 0)
+	var keys []bhap.Key
+	for _, key := range s.commentKeys() {
+		if s.comments[key].OnBHAP == bhapKey {
+			results = append(results, s.comments[key])
+			keys = append(keys, key)
+		}
+	}
+
+	return results, keys, nil
+}
+
+// CommentByUID returns the comment with the given UID.
+func (s *Store) CommentByUID(ctx context.Context, uid string) (bhap.Comment, bhap.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range s.commentKeys() {
+		if s.comments[key].UID == uid {
+			return s.comments[key], key, nil
+		}
+	}
+
+	return bhap.Comment{}, "", nil
+}
+
+// NewComment saves a new comment and returns its key. The key is nested
+// under the BHAP being commented on.
+func (s *Store) NewComment(ctx context.Context, c bhap.Comment) (bhap.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := s.newKey(commentKind, c.OnBHAP)
+	s.comments[key] = c
+
+	return key, nil
+}
+
+// PutComment saves changes to an existing comment.
+func (s *Store) PutComment(ctx context.Context, key bhap.Key, c bhap.Comment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.comments[key]; !ok {
+		return fmt.Errorf("no comment with key %v", key)
+	}
+	s.comments[key] = c
+
+	return nil
+}
+
+// DeleteComment deletes a comment.
+func (s *Store) DeleteComment(ctx context.Context, key bhap.Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.comments[key]; !ok {
+		return fmt.Errorf("no comment with key %v", key)
+	}
+	delete(s.comments, key)
+
+	return nil
+}
+
+// commentKeys returns the keys of all comments in creation order. The caller
+// must hold the lock.
+func (s *Store) commentKeys() []bhap.Key {
+	keys := make([]bhap.Key, 0, len(s.comments))
+	for _, key := range s.order {
+		if _, ok := s.comments[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
@@ -0,0 +1,95 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/house-emoji/bhap"
+)
+
+const delegationKind = "Delegation"
+
+// DelegationsFrom returns every delegation made by the given user.
+func (s *Store) DelegationsFrom(ctx context.Context, userKey bhap.Key) ([]bhap.Delegation, []bhap.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var results []bhap.Delegation
+	var keys []bhap.Key
+	for _, key := range s.delegationKeys() {
+		if s.delegations[key].From == userKey {
+			results = append(results, s.delegations[key])
+			keys = append(keys, key)
+		}
+	}
+
+	return results, keys, nil
+}
+
+// DelegationsTo returns every delegation made to the given user.
+func (s *Store) DelegationsTo(ctx context.Context, userKey bhap.Key) ([]bhap.Delegation, []bhap.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var results []bhap.Delegation
+	var keys []bhap.Key
+	for _, key := range s.delegationKeys() {
+		if s.delegations[key].To == userKey {
+			results = append(results, s.delegations[key])
+			keys = append(keys, key)
+		}
+	}
+
+	return results, keys, nil
+}
+
+// DelegationByUID returns the delegation with the given UID.
+func (s *Store) DelegationByUID(ctx context.Context, uid string) (bhap.Delegation, bhap.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range s.delegationKeys() {
+		if s.delegations[key].UID == uid {
+			return s.delegations[key], key, nil
+		}
+	}
+
+	return bhap.Delegation{}, "", nil
+}
+
+// NewDelegation saves a new delegation and returns its key.
+func (s *Store) NewDelegation(ctx context.Context, d bhap.Delegation) (bhap.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := s.newKey(delegationKind, "")
+	s.delegations[key] = d
+
+	return key, nil
+}
+
+// DeleteDelegation deletes a delegation.
+func (s *Store) DeleteDelegation(ctx context.Context, key bhap.Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.delegations[key]; !ok {
+		return fmt.Errorf("no delegation with key %v", key)
+	}
+	delete(s.delegations, key)
+
+	return nil
+}
+
+// delegationKeys returns the keys of all delegations in creation order. The
+// caller must hold the lock.
+func (s *Store) delegationKeys() []bhap.Key {
+	keys := make([]bhap.Key, 0, len(s.delegations))
+	for _, key := range s.order {
+		if _, ok := s.delegations[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
@@ -0,0 +1,91 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/house-emoji/bhap"
+)
+
+const invitationKind = "Invitation"
+
+// UnsentInvitations returns all invitations that have yet to be emailed.
+func (s *Store) UnsentInvitations(ctx context.Context) ([]bhap.Invitation, []bhap.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var results []bhap.Invitation
+	var keys []bhap.Key
+	for _, key := range s.invitationKeys() {
+		if !s.invitations[key].EmailSent {
+			results = append(results, s.invitations[key])
+			keys = append(keys, key)
+		}
+	}
+
+	return results, keys, nil
+}
+
+// InvitationByUID returns the invitation with the corresponding UID.
+func (s *Store) InvitationByUID(ctx context.Context, uid string) (bhap.Invitation, bhap.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range s.invitationKeys() {
+		if s.invitations[key].UID == uid {
+			return s.invitations[key], key, nil
+		}
+	}
+
+	return bhap.Invitation{}, "", nil
+}
+
+// NewInvitation saves a new invitation and returns its key.
+func (s *Store) NewInvitation(ctx context.Context, inv bhap.Invitation) (bhap.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := s.newKey(invitationKind, "")
+	s.invitations[key] = inv
+
+	return key, nil
+}
+
+// PutInvitation saves changes to an existing invitation.
+func (s *Store) PutInvitation(ctx context.Context, key bhap.Key, inv bhap.Invitation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.invitations[key]; !ok {
+		return fmt.Errorf("no invitation with key %v", key)
+	}
+	s.invitations[key] = inv
+
+	return nil
+}
+
+// DeleteInvitation deletes an invitation.
+func (s *Store) DeleteInvitation(ctx context.Context, key bhap.Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.invitations[key]; !ok {
+		return fmt.Errorf("no invitation with key %v", key)
+	}
+	delete(s.invitations, key)
+
+	return nil
+}
+
+// invitationKeys returns the keys of all invitations in creation order. The
+// caller must hold the lock.
+func (s *Store) invitationKeys() []bhap.Key {
+	keys := make([]bhap.Key, 0, len(s.invitations))
+	for _, key := range s.order {
+		if _, ok := s.invitations[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
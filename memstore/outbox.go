@@ -0,0 +1,87 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/house-emoji/bhap"
+)
+
+const outgoingEmailKind = "OutgoingEmail"
+
+// UnsentEmails returns every queued email that has yet to be sent, oldest
+// first.
+func (s *Store) UnsentEmails(ctx context.Context) ([]bhap.OutgoingEmail, []bhap.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var results []bhap.OutgoingEmail
+	var keys []bhap.Key
+	for _, key := range s.emailKeys() {
+		if !s.emails[key].Sent {
+			results = append(results, s.emails[key])
+			keys = append(keys, key)
+		}
+	}
+
+	return results, keys, nil
+}
+
+// QueueEmail adds an email to the outbox.
+func (s *Store) QueueEmail(ctx context.Context, e bhap.OutgoingEmail) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := s.newKey(outgoingEmailKind, "")
+	s.emails[key] = e
+
+	return nil
+}
+
+// PutEmail saves changes to a queued email.
+func (s *Store) PutEmail(ctx context.Context, key bhap.Key, e bhap.OutgoingEmail) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.emails[key]; !ok {
+		return fmt.Errorf("no email with key %v", key)
+	}
+	s.emails[key] = e
+
+	return nil
+}
+
+// emailKeys returns the keys of all queued emails in creation order. The
+// caller must hold the lock.
+func (s *Store) emailKeys() []bhap.Key {
+	keys := make([]bhap.Key, 0, len(s.emails))
+	for _, key := range s.order {
+		if _, ok := s.emails[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// LastDigestDate returns when the weekly digest was last sent, or the zero
+// time if it never was.
+func (s *Store) LastDigestDate(ctx context.Context) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.lastDigest, nil
+}
+
+// RecordDigest records that the weekly digest was sent at the given time.
+func (s *Store) RecordDigest(ctx context.Context, date time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if date.After(s.lastDigest) {
+		s.lastDigest = date
+	}
+
+	return nil
+}
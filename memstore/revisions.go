@@ -0,0 +1,65 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/house-emoji/bhap"
+)
+
+// RevisionsForBHAP returns every revision of a BHAP, oldest first.
+func (s *Store) RevisionsForBHAP(ctx context.Context, bhapKey bhap.Key) ([]bhap.Revision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	revisions := make([]bhap.Revision, 0)
+	for _, r := range s.revisions {
+		if r.OnBHAP == bhapKey {
+			revisions = append(revisions, r)
+		}
+	}
+
+	return revisions, nil
+}
+
+// NewRevision records a revision.
+func (s *Store) NewRevision(ctx context.Context, r bhap.Revision) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.revisions = append(s.revisions, r)
+
+	return nil
+}
+
+// SaveRevision saves changes to an existing BHAP and records the given
+// revisions of it, all in one transaction.
+func (s *Store) SaveRevision(ctx context.Context, key bhap.Key, b bhap.BHAP, substantive bool, revisions ...bhap.Revision) (bhap.BHAP, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.bhaps[key]; !ok {
+		return bhap.BHAP{}, fmt.Errorf("no BHAP with key %v", key)
+	}
+
+	number := 0
+	for _, r := range s.revisions {
+		if r.OnBHAP == key && r.Number > number {
+			number = r.Number
+		}
+	}
+
+	for _, r := range revisions {
+		number++
+		r.Number = number
+		s.revisions = append(s.revisions, r)
+	}
+
+	b.Revision = number
+	if substantive {
+		b.SubstantiveRevision = number
+	}
+	s.bhaps[key] = copyBHAP(b)
+
+	return b, nil
+}
@@ -0,0 +1,71 @@
+// Package memstore implements bhap.Store entirely in memory. It is meant for
+// local development and tests, where no App Engine services are available.
+package memstore
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/house-emoji/bhap"
+)
+
+// Store is a bhap.Store that keeps everything in memory. It is safe for
+// concurrent use. Nothing is persisted once the process exits.
+type Store struct {
+	mu sync.Mutex
+	// order holds every key ever created, in creation order. Queries walk
+	// it so that results come out in a stable order.
+	order []bhap.Key
+
+	bhaps       map[bhap.Key]bhap.BHAP
+	comments    map[bhap.Key]bhap.Comment
+	votes       map[bhap.Key]bhap.Vote
+	users       map[bhap.Key]bhap.User
+	invitations map[bhap.Key]bhap.Invitation
+	ballots     map[bhap.Key]bhap.Ballot
+	delegations map[bhap.Key]bhap.Delegation
+	emails      map[bhap.Key]bhap.OutgoingEmail
+	idCounters  map[bhap.BHAPType]int
+	// secretChoices counts the choices made on each BHAP's secret ballot
+	secretChoices map[bhap.Key]map[bhap.Status]int
+	// voteEvents holds every vote event in the order it was recorded
+	voteEvents []bhap.VoteEvent
+	// voterChanges holds every voter change in the order it was recorded
+	voterChanges []bhap.VoterChange
+	// revisions holds every revision in the order it was recorded
+	revisions []bhap.Revision
+	// lastDigest is when the weekly digest was last sent
+	lastDigest time.Time
+}
+
+var _ bhap.Store = (*Store)(nil)
+
+// New creates a new, empty in-memory store.
+func New() *Store {
+	return &Store{
+		bhaps:       make(map[bhap.Key]bhap.BHAP),
+		comments:    make(map[bhap.Key]bhap.Comment),
+		votes:       make(map[bhap.Key]bhap.Vote),
+		users:       make(map[bhap.Key]bhap.User),
+		invitations: make(map[bhap.Key]bhap.Invitation),
+		ballots:     make(map[bhap.Key]bhap.Ballot),
+		delegations: make(map[bhap.Key]bhap.Delegation),
+		emails:      make(map[bhap.Key]bhap.OutgoingEmail),
+		idCounters:  make(map[bhap.BHAPType]int),
+
+		secretChoices: make(map[bhap.Key]map[bhap.Status]int),
+	}
+}
+
+// newKey creates a unique key for a new entity of the given kind. If a parent
+// is provided, the key is nested under it. The caller must hold the lock.
+func (s *Store) newKey(kind string, parent bhap.Key) bhap.Key {
+	key := bhap.Key(fmt.Sprintf("%v/%v", kind, len(s.order)+1))
+	if parent != "" {
+		key = parent + "/" + key
+	}
+	s.order = append(s.order, key)
+
+	return key
+}
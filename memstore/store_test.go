@@ -0,0 +1,14 @@
+package memstore
+
+import (
+	"testing"
+
+	"github.com/house-emoji/bhap"
+	"github.com/house-emoji/bhap/storetest"
+)
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) bhap.Store {
+		return New()
+	})
+}
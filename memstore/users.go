@@ -0,0 +1,97 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/house-emoji/bhap"
+)
+
+const userKind = "User"
+
+// UserByKey returns the user with the given key.
+func (s *Store) UserByKey(ctx context.Context, key bhap.Key) (bhap.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[key]
+	if !ok {
+		return bhap.User{}, fmt.Errorf("no user with key %v", key)
+	}
+
+	return user, nil
+}
+
+// UserByEmail returns the user with the given email. If no user with that
+// email exists, the key will be empty.
+func (s *Store) UserByEmail(ctx context.Context, email string) (bhap.User, bhap.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range s.userKeys() {
+		if s.users[key].Email == email {
+			return s.users[key], key, nil
+		}
+	}
+
+	return bhap.User{}, "", nil
+}
+
+// UserCount returns the number of users.
+func (s *Store) UserCount(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.users), nil
+}
+
+// AllUsers returns every user.
+func (s *Store) AllUsers(ctx context.Context) ([]bhap.User, []bhap.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := s.userKeys()
+	users := make([]bhap.User, len(keys))
+	for i, key := range keys {
+		users[i] = s.users[key]
+	}
+
+	return users, keys, nil
+}
+
+// NewUser saves a new user and returns its key.
+func (s *Store) NewUser(ctx context.Context, u bhap.User) (bhap.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := s.newKey(userKind, "")
+	s.users[key] = u
+
+	return key, nil
+}
+
+// PutUser saves changes to an existing user.
+func (s *Store) PutUser(ctx context.Context, key bhap.Key, u bhap.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[key]; !ok {
+		return fmt.Errorf("no user with key %v", key)
+	}
+	s.users[key] = u
+
+	return nil
+}
+
+// userKeys returns the keys of all users in creation order. The caller must
+// hold the lock.
+func (s *Store) userKeys() []bhap.Key {
+	keys := make([]bhap.Key, 0, len(s.users))
+	for _, key := range s.order {
+		if _, ok := s.users[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
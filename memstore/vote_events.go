@@ -0,0 +1,33 @@
+package memstore
+
+import (
+	"context"
+
+	"github.com/house-emoji/bhap"
+)
+
+// VoteEventsForBHAP returns every vote event recorded for a BHAP, oldest
+// first.
+func (s *Store) VoteEventsForBHAP(ctx context.Context, bhapKey bhap.Key) ([]bhap.VoteEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events := make([]bhap.VoteEvent, 0)
+	for _, e := range s.voteEvents {
+		if e.OnBHAP == bhapKey {
+			events = append(events, e)
+		}
+	}
+
+	return events, nil
+}
+
+// NewVoteEvent records a vote event.
+func (s *Store) NewVoteEvent(ctx context.Context, e bhap.VoteEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.voteEvents = append(s.voteEvents, e)
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package memstore
+
+import (
+	"context"
+
+	"github.com/house-emoji/bhap"
+)
+
+// VoterChangesForBHAP returns every voter change recorded for a BHAP, oldest
+// first.
+func (s *Store) VoterChangesForBHAP(ctx context.Context, bhapKey bhap.Key) ([]bhap.VoterChange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changes := make([]bhap.VoterChange, 0)
+	for _, c := range s.voterChanges {
+		if c.OnBHAP == bhapKey {
+			changes = append(changes, copyVoterChange(c))
+		}
+	}
+
+	return changes, nil
+}
+
+// NewVoterChange records a voter change.
+func (s *Store) NewVoterChange(ctx context.Context, c bhap.VoterChange) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.voterChanges = append(s.voterChanges, copyVoterChange(c))
+
+	return nil
+}
+
+// copyVoterChange returns a copy of the voter change that shares no memory
+// with the original.
+func copyVoterChange(c bhap.VoterChange) bhap.VoterChange {
+	if c.Added != nil {
+		c.Added = append([]bhap.Key(nil), c.Added...)
+	}
+	if c.Removed != nil {
+		c.Removed = append([]bhap.Key(nil), c.Removed...)
+	}
+	return c
+}
@@ -0,0 +1,215 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/house-emoji/bhap"
+)
+
+const voteKind = "Vote"
+
+// AllVotesForBHAP returns all the votes that have been cast for a given BHAP.
+func (s *Store) AllVotesForBHAP(ctx context.Context, bhapKey bhap.Key) ([]bhap.Vote, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	votes := make([]bhap.Vote, 0)
+	for _, key := range s.voteKeys() {
+		if s.votes[key].OnBHAP == bhapKey {
+			votes = append(votes, s.votes[key])
+		}
+	}
+
+	return votes, nil
+}
+
+// GetVoteForBHAP returns the user's current vote on a BHAP.
+func (s *Store) GetVoteForBHAP(ctx context.Context, bhapKey, userKey bhap.Key) (bhap.Vote, bhap.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if userKey == "" {
+		return bhap.Vote{}, "", nil
+	}
+
+	key := s.userVoteKey(bhapKey, userKey)
+	return s.votes[key], key, nil
+}
+
+// SetVoteForBHAP sets the vote of the user for the given BHAP to a value cast
+// on the given revision, creating a new vote if necessary.
+func (s *Store) SetVoteForBHAP(ctx context.Context, bhapKey, userKey bhap.Key, value bhap.Status, revision int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.bhaps[bhapKey]; !ok {
+		return fmt.Errorf("no BHAP with key %v", bhapKey)
+	}
+
+	key := s.userVoteKey(bhapKey, userKey)
+	if key == "" {
+		// Make a new vote if one doesn't exist
+		key = s.newKey(voteKind, bhapKey)
+		s.votes[key] = bhap.Vote{
+			OnBHAP:   bhapKey,
+			ByUser:   userKey,
+			Value:    value,
+			Revision: revision}
+	} else {
+		// Edit the existing vote
+		vote := s.votes[key]
+		vote.Value = value
+		vote.Rank = 0
+		vote.Proxy = ""
+		vote.Revision = revision
+		s.votes[key] = vote
+	}
+
+	return nil
+}
+
+// SetProxyVoteForBHAP sets the vote of the user for the given BHAP to a value
+// cast on their behalf by a delegate on the given revision, creating a new
+// vote if necessary.
+func (s *Store) SetProxyVoteForBHAP(ctx context.Context, bhapKey, userKey, proxyKey bhap.Key, value bhap.Status, revision int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.bhaps[bhapKey]; !ok {
+		return fmt.Errorf("no BHAP with key %v", bhapKey)
+	}
+
+	key := s.userVoteKey(bhapKey, userKey)
+	if key == "" {
+		key = s.newKey(voteKind, bhapKey)
+	}
+	s.votes[key] = bhap.Vote{
+		OnBHAP:   bhapKey,
+		ByUser:   userKey,
+		Value:    value,
+		Proxy:    proxyKey,
+		Revision: revision}
+
+	return nil
+}
+
+// SetRankForBHAP sets the user's ranked vote for a BHAP on a ballot, creating
+// a new vote if necessary.
+func (s *Store) SetRankForBHAP(ctx context.Context, bhapKey, userKey bhap.Key, rank int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.bhaps[bhapKey]; !ok {
+		return fmt.Errorf("no BHAP with key %v", bhapKey)
+	}
+
+	key := s.userVoteKey(bhapKey, userKey)
+	if key == "" {
+		key = s.newKey(voteKind, bhapKey)
+	}
+	s.votes[key] = bhap.Vote{
+		OnBHAP: bhapKey,
+		ByUser: userKey,
+		Value:  bhap.RankedVote,
+		Rank:   rank}
+
+	return nil
+}
+
+// DeleteVote deletes a cast vote.
+func (s *Store) DeleteVote(ctx context.Context, voteKey bhap.Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.votes[voteKey]; !ok {
+		return fmt.Errorf("no vote with key %v", voteKey)
+	}
+	delete(s.votes, voteKey)
+
+	return nil
+}
+
+// DeleteVotesForBHAP deletes every vote cast on a BHAP, along with any
+// secret choices made on it.
+func (s *Store) DeleteVotesForBHAP(ctx context.Context, bhapKey bhap.Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range s.voteKeys() {
+		if s.votes[key].OnBHAP == bhapKey {
+			delete(s.votes, key)
+		}
+	}
+	delete(s.secretChoices, bhapKey)
+
+	return nil
+}
+
+// CastSecretVote records that the user voted on a BHAP with a secret ballot
+// and counts their choice, as long as they haven't already voted.
+func (s *Store) CastSecretVote(ctx context.Context, bhapKey, userKey bhap.Key, value bhap.Status, revision int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.bhaps[bhapKey]; !ok {
+		return fmt.Errorf("no BHAP with key %v", bhapKey)
+	}
+	if s.userVoteKey(bhapKey, userKey) != "" {
+		return bhap.ErrVoteFinal
+	}
+
+	key := s.newKey(voteKind, bhapKey)
+	s.votes[key] = bhap.Vote{
+		OnBHAP:   bhapKey,
+		ByUser:   userKey,
+		Value:    bhap.SecretVote,
+		Revision: revision}
+
+	if s.secretChoices[bhapKey] == nil {
+		s.secretChoices[bhapKey] = make(map[bhap.Status]int)
+	}
+	s.secretChoices[bhapKey][value]++
+
+	return nil
+}
+
+// SecretChoices returns how many times each choice was made on a BHAP's
+// secret ballot.
+func (s *Store) SecretChoices(ctx context.Context, bhapKey bhap.Key) (map[bhap.Status]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	choices := make(map[bhap.Status]int)
+	for value, count := range s.secretChoices[bhapKey] {
+		choices[value] = count
+	}
+
+	return choices, nil
+}
+
+// userVoteKey returns the key of the vote a user has cast on a BHAP, or an
+// empty key if none has been cast. The caller must hold the lock.
+func (s *Store) userVoteKey(bhapKey, userKey bhap.Key) bhap.Key {
+	for _, key := range s.voteKeys() {
+		vote := s.votes[key]
+		if vote.OnBHAP == bhapKey && vote.ByUser == userKey {
+			return key
+		}
+	}
+
+	return ""
+}
+
+// voteKeys returns the keys of all votes in creation order. The caller must
+// hold the lock.
+func (s *Store) voteKeys() []bhap.Key {
+	keys := make([]bhap.Key, 0, len(s.votes))
+	for _, key := range s.order {
+		if _, ok := s.votes[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
@@ -0,0 +1,118 @@
+package bhap
+
+import (
+	"context"
+	"encoding/base64"
+)
+
+// Notification is a kind of email that members choose whether to get.
+type Notification string
+
+const (
+	// DiscussionNotification is sent when a BHAP enters discussion.
+	DiscussionNotification Notification = "discussion"
+	// DecisionNotification is sent when a BHAP is decided or withdrawn.
+	DecisionNotification Notification = "decisions"
+	// CommentNotification is sent to the authors of a BHAP when someone
+	// comments on it, and to members invited to co-author one.
+	CommentNotification Notification = "comments"
+	// ReminderNotification is sent to remind members to vote, or to look
+	// again at a BHAP that changed after they voted on it.
+	ReminderNotification Notification = "reminders"
+	// DigestNotification is the weekly digest.
+	DigestNotification Notification = "digest"
+)
+
+// Notifications lists every kind of email that members choose whether to
+// get, in the order they are shown in.
+var Notifications = []Notification{
+	DiscussionNotification,
+	DecisionNotification,
+	CommentNotification,
+	ReminderNotification,
+	DigestNotification,
+}
+
+// notificationDescriptions describe each kind of email to members.
+var notificationDescriptions = map[Notification]string{
+	DiscussionNotification: "emails when a BHAP enters discussion",
+	DecisionNotification:   "emails when a BHAP is decided or withdrawn",
+	CommentNotification:    "emails about comments on your BHAPs and invitations to co-author",
+	ReminderNotification:   "reminders to vote",
+	DigestNotification:     "the weekly digest",
+}
+
+// Description describes the kind of email to members.
+func (n Notification) Description() string {
+	return notificationDescriptions[n]
+}
+
+// Valid returns true if the notification is one of the known kinds.
+func (n Notification) Valid() bool {
+	_, ok := notificationDescriptions[n]
+	return ok
+}
+
+// Wants returns true if the user has chosen to get the kind of email. The
+// weekly digest is off unless chosen, while the rest are on unless turned
+// off.
+func (u User) Wants(n Notification) bool {
+	switch n {
+	case DiscussionNotification:
+		return !u.NoDiscussionEmails
+	case DecisionNotification:
+		return !u.NoDecisionEmails
+	case CommentNotification:
+		return !u.NoCommentEmails
+	case ReminderNotification:
+		return !u.NoReminderEmails
+	case DigestNotification:
+		return u.WeeklyDigest
+	default:
+		return false
+	}
+}
+
+// SetWants sets whether the user gets the kind of email.
+func (u *User) SetWants(n Notification, wants bool) {
+	switch n {
+	case DiscussionNotification:
+		u.NoDiscussionEmails = !wants
+	case DecisionNotification:
+		u.NoDecisionEmails = !wants
+	case CommentNotification:
+		u.NoCommentEmails = !wants
+	case ReminderNotification:
+		u.NoReminderEmails = !wants
+	case DigestNotification:
+		u.WeeklyDigest = wants
+	}
+}
+
+// UnsubscribeToken returns a token that lets whoever has it unsubscribe the
+// user from the kind of email without logging in. It is put in links in the
+// emails themselves.
+func UnsubscribeToken(ctx context.Context, userKey Key, n Notification) (string, error) {
+	signature, err := signer.Sign(ctx, unsubscribeData(userKey, n))
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// CheckUnsubscribeToken returns true if the token was made by
+// UnsubscribeToken for the user and kind of email.
+func CheckUnsubscribeToken(ctx context.Context, userKey Key, n Notification, token string) (bool, error) {
+	signature, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return false, nil
+	}
+	return signer.Verify(ctx, unsubscribeData(userKey, n), signature)
+}
+
+// unsubscribeData is what is signed in an unsubscribe token. The parts are
+// separated by a character that can't appear in either, so that no two
+// tokens sign the same data.
+func unsubscribeData(userKey Key, n Notification) []byte {
+	return []byte("unsubscribe\x00" + string(userKey) + "\x00" + string(n))
+}
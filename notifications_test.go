@@ -0,0 +1,50 @@
+package bhap
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCheckUnsubscribeToken(t *testing.T) {
+	ctx := context.Background()
+	token, err := UnsubscribeToken(ctx, "User/1", DigestNotification)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Flipping a character still decodes, but no longer matches
+	tampered := []byte(token)
+	if tampered[0] == 'A' {
+		tampered[0] = 'B'
+	} else {
+		tampered[0] = 'A'
+	}
+
+	tests := []struct {
+		name    string
+		userKey Key
+		n       Notification
+		token   string
+		want    bool
+	}{
+		{"valid", "User/1", DigestNotification, token, true},
+		{"wrong user", "User/2", DigestNotification, token, false},
+		{"wrong kind of email", "User/1", ReminderNotification, token, false},
+		{"user and kind run together", "User/1\x00digest", "", token, false},
+		{"tampered", "User/1", DigestNotification, string(tampered), false},
+		{"truncated", "User/1", DigestNotification, token[:len(token)-1], false},
+		{"not base64", "User/1", DigestNotification, token + "!", false},
+		{"empty", "User/1", DigestNotification, "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := CheckUnsubscribeToken(ctx, test.userKey, test.n, test.token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
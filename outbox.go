@@ -0,0 +1,17 @@
+package bhap
+
+import "time"
+
+// OutgoingEmail is an email waiting in the outbox. Emails are queued while
+// handling a request and sent later by a task, so that a slow mail server
+// never holds up the request.
+type OutgoingEmail struct {
+	To      string
+	Subject string
+	Body    string `datastore:"Body,noindex"`
+	Queued  time.Time
+	Sent    bool
+	// UnsubscribeURL unsubscribes the recipient from emails like this one.
+	// It is given to mail clients that offer to unsubscribe
+	UnsubscribeURL string `datastore:"UnsubscribeURL,noindex"`
+}
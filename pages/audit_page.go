@@ -0,0 +1,200 @@
+package pages
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/house-emoji/bhap"
+	"github.com/house-emoji/bhap/log"
+)
+
+var auditTemplate = compileTempl("views/audit.html")
+
+// auditEntry is a vote event as shown on the audit page.
+type auditEntry struct {
+	Date     time.Time
+	Voter    string
+	Action   bhap.VoteAction
+	Vote     string
+	Previous string
+	// Proxy is the name of the delegate who cast the vote, if any
+	Proxy string
+	// Revision is the number of the revision the vote was cast on, if known
+	Revision int
+}
+
+// voterChangeEntry is a voter change as shown on the audit page.
+type voterChangeEntry struct {
+	Date  time.Time
+	Admin string
+	// ReopenedFrom is the status the BHAP was reopened from, if any
+	ReopenedFrom bhap.Status
+	Added        []string
+	Removed      []string
+}
+
+// auditPageFiller fills the vote audit page template.
+type auditPageFiller struct {
+	LoggedIn     bool
+	FullName     string
+	BHAP         bhap.BHAP
+	Entries      []auditEntry
+	VoterChanges []voterChangeEntry
+}
+
+// ServeAuditPage serves a read-only page listing every vote cast, changed or
+// retracted on a BHAP, so that results can be checked after the fact.
+func ServeAuditPage(w http.ResponseWriter, r *http.Request) {
+	ctx := bhap.RequestContext(r)
+
+	loadedBHAP, bhapKey, err := bhapFromURLVars(ctx, mux.Vars(r))
+	if err != nil {
+		log.Errorf(ctx, "could not load BHAP: %v", err)
+		http.Error(w, "Failed to load BHAP", http.StatusInternalServerError)
+		return
+	}
+	if bhapKey == "" {
+		http.Error(w, "No BHAP with identifier", http.StatusNotFound)
+		log.Warningf(ctx, "unknown BHAP requested")
+		return
+	}
+
+	user, userKey, err := bhap.UserFromSession(ctx, Store, r)
+	if err != nil {
+		http.Error(w, "Could not read session", http.StatusInternalServerError)
+		log.Errorf(ctx, "getting session email: %v", err)
+		return
+	}
+
+	events, err := Store.VoteEventsForBHAP(ctx, bhapKey)
+	if err != nil {
+		http.Error(w, "Could not get vote history",
+			http.StatusInternalServerError)
+		log.Errorf(ctx, "getting vote events: %v", err)
+		return
+	}
+
+	names := make(map[bhap.Key]string)
+	nameOf := func(key bhap.Key) (string, error) {
+		if name, ok := names[key]; ok {
+			return name, nil
+		}
+		voter, err := Store.UserByKey(ctx, key)
+		if err != nil {
+			return "", err
+		}
+		names[key] = voter.FirstName + " " + voter.LastName
+		return names[key], nil
+	}
+
+	entries := make([]auditEntry, len(events))
+	for i, e := range events {
+		name, err := nameOf(e.ByUser)
+		if err != nil {
+			http.Error(w, "Failed to load user", http.StatusInternalServerError)
+			log.Errorf(ctx, "loading user: %v", err)
+			return
+		}
+
+		entries[i] = auditEntry{
+			Date:     e.Date,
+			Voter:    name,
+			Action:   e.Action,
+			Vote:     describeVote(e.Value, e.Rank),
+			Revision: e.Revision,
+		}
+		if e.Previous != "" {
+			entries[i].Previous = describeVote(e.Previous, 0)
+		}
+		if e.Proxy != "" {
+			if entries[i].Proxy, err = nameOf(e.Proxy); err != nil {
+				http.Error(w, "Failed to load user",
+					http.StatusInternalServerError)
+				log.Errorf(ctx, "loading proxy: %v", err)
+				return
+			}
+		}
+	}
+
+	changes, err := Store.VoterChangesForBHAP(ctx, bhapKey)
+	if err != nil {
+		http.Error(w, "Could not get voter changes",
+			http.StatusInternalServerError)
+		log.Errorf(ctx, "getting voter changes: %v", err)
+		return
+	}
+
+	namesOf := func(keys []bhap.Key) ([]string, error) {
+		var result []string
+		for _, key := range keys {
+			name, err := nameOf(key)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, name)
+		}
+		return result, nil
+	}
+
+	voterChanges := make([]voterChangeEntry, len(changes))
+	for i, c := range changes {
+		admin, err := nameOf(c.ByUser)
+		if err != nil {
+			http.Error(w, "Failed to load user", http.StatusInternalServerError)
+			log.Errorf(ctx, "loading admin: %v", err)
+			return
+		}
+		added, err := namesOf(c.Added)
+		if err != nil {
+			http.Error(w, "Failed to load user", http.StatusInternalServerError)
+			log.Errorf(ctx, "loading added members: %v", err)
+			return
+		}
+		removed, err := namesOf(c.Removed)
+		if err != nil {
+			http.Error(w, "Failed to load user", http.StatusInternalServerError)
+			log.Errorf(ctx, "loading removed members: %v", err)
+			return
+		}
+
+		voterChanges[i] = voterChangeEntry{
+			Date:         c.Date,
+			Admin:        admin,
+			ReopenedFrom: c.ReopenedFrom,
+			Added:        added,
+			Removed:      removed,
+		}
+	}
+
+	filler := auditPageFiller{
+		LoggedIn:     userKey != "",
+		FullName:     user.FirstName + " " + user.LastName,
+		BHAP:         loadedBHAP,
+		Entries:      entries,
+		VoterChanges: voterChanges,
+	}
+	showTemplate(ctx, w, auditTemplate, filler)
+}
+
+// describeVote returns a short description of a vote with the given value.
+func describeVote(value bhap.Status, rank int) string {
+	switch value {
+	case bhap.AcceptedStatus:
+		return "Accept"
+	case bhap.RejectedStatus:
+		return "Reject"
+	case bhap.AbstainVote:
+		return "Abstain"
+	case bhap.SecretVote:
+		return "Secret ballot"
+	case bhap.RankedVote:
+		if rank == 0 {
+			return "Ranked"
+		}
+		return fmt.Sprintf("Ranked #%v", rank)
+	default:
+		return string(value)
+	}
+}
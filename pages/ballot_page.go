@@ -0,0 +1,567 @@
+package pages
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/house-emoji/bhap"
+	"github.com/house-emoji/bhap/log"
+	"github.com/rs/xid"
+)
+
+var (
+	newBallotTemplate     = compileTempl("views/new-ballot.html")
+	ballotTemplate        = compileTempl("views/ballot.html")
+	ballotResultsTemplate = compileTempl("views/ballot-results.html")
+)
+
+// newBallotPageFiller fills the ballot creation page template.
+type newBallotPageFiller struct {
+	LoggedIn   bool
+	FullName   string
+	Candidates []bhap.BHAP
+}
+
+// ballotOption is a BHAP on a ballot as shown to a member.
+type ballotOption struct {
+	BHAP bhap.BHAP
+	// Rank is where the member ranked the option, or zero if they haven't
+	Rank int
+	// Running is true if the option is still in discussion
+	Running bool
+}
+
+// ballotPageFiller fills the ballot page template.
+type ballotPageFiller struct {
+	LoggedIn bool
+	FullName string
+	Ballot   bhap.Ballot
+	Options  []ballotOption
+	CanRank  bool
+	Ranked   bool
+	Winner   *bhap.BHAP
+
+	VoterCount int
+	UserCount  int
+}
+
+// resultRow is an option's standing in one round of a ballot count.
+type resultRow struct {
+	BHAP       bhap.BHAP
+	Count      int
+	Eliminated bool
+	Won        bool
+}
+
+// resultRound is one round of a ballot count as shown on the results page.
+type resultRound struct {
+	Number    int
+	Rows      []resultRow
+	Exhausted int
+}
+
+// ballotResultsPageFiller fills the ballot results page template.
+type ballotResultsPageFiller struct {
+	LoggedIn  bool
+	FullName  string
+	Ballot    bhap.Ballot
+	Rounds    []resultRound
+	Winner    *bhap.BHAP
+	QuorumMet bool
+
+	VoterCount int
+	UserCount  int
+}
+
+// ServeNewBallotPage serves a page for grouping BHAPs in discussion into a
+// ballot.
+func ServeNewBallotPage(w http.ResponseWriter, r *http.Request) {
+	ctx := bhap.RequestContext(r)
+
+	currUser, userKey, err := bhap.UserFromSession(ctx, Store, r)
+	if err != nil {
+		http.Error(w, "Could not read session", http.StatusInternalServerError)
+		log.Errorf(ctx, "could not get session email: %v", err)
+		return
+	}
+	if userKey == "" || !currUser.Admin {
+		http.Error(w, "Only admins may create ballots", http.StatusForbidden)
+		log.Warningf(ctx, "ballot page request from non-admin denied")
+		return
+	}
+
+	candidates, err := ballotCandidates(ctx)
+	if err != nil {
+		http.Error(w, "Could not get discussion BHAPs",
+			http.StatusInternalServerError)
+		log.Errorf(ctx, "getting discussion BHAPs: %v", err)
+		return
+	}
+
+	filler := newBallotPageFiller{
+		LoggedIn:   true,
+		FullName:   currUser.FirstName + " " + currUser.LastName,
+		Candidates: candidates,
+	}
+
+	showTemplate(ctx, w, newBallotTemplate, filler)
+}
+
+// HandleNewBallotForm creates a new ballot from the BHAPs chosen in a POST
+// form.
+func HandleNewBallotForm(w http.ResponseWriter, r *http.Request) {
+	ctx := bhap.RequestContext(r)
+
+	currUser, userKey, err := bhap.UserFromSession(ctx, Store, r)
+	if err != nil {
+		http.Error(w, "Could not read session", http.StatusInternalServerError)
+		log.Errorf(ctx, "could not get session email: %v", err)
+		return
+	}
+	if userKey == "" || !currUser.Admin {
+		http.Error(w, "Only admins may create ballots", http.StatusForbidden)
+		log.Warningf(ctx, "ballot creation request from non-admin denied")
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Could not parse form", http.StatusBadRequest)
+		log.Warningf(ctx, "parsing ballot form: %v", err)
+		return
+	}
+
+	title := strings.TrimSpace(r.Form.Get("title"))
+	if title == "" {
+		http.Error(w, "A ballot needs a title", http.StatusBadRequest)
+		log.Warningf(ctx, "ballot without a title denied")
+		return
+	}
+
+	options, optionKeys, err := parseBallotOptions(ctx, r.Form["option"])
+	if _, ok := err.(formError); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		log.Warningf(ctx, "invalid ballot options: %v", err)
+		return
+	} else if err != nil {
+		http.Error(w, "Could not load BHAP", http.StatusInternalServerError)
+		log.Errorf(ctx, "loading ballot options: %v", err)
+		return
+	}
+	if len(options) < 2 {
+		http.Error(w, "A ballot needs at least two BHAPs",
+			http.StatusBadRequest)
+		log.Warningf(ctx, "ballot with fewer than two options denied")
+		return
+	}
+
+	// Ranking stays open as long as the longest running option would have
+	now := time.Now()
+	var deadline time.Time
+	for _, b := range options {
+		if b.VotingDeadline.After(deadline) {
+			deadline = b.VotingDeadline
+		}
+	}
+	if deadline.IsZero() {
+		days := bhap.CurrentSettings().VotingPeriodDays
+		deadline = now.Add(time.Duration(days) * 24 * time.Hour)
+	}
+
+	ballot := bhap.Ballot{
+		UID:         xid.New().String(),
+		Title:       title,
+		Options:     optionKeys,
+		CreatedBy:   userKey,
+		CreatedDate: now,
+		Deadline:    deadline,
+	}
+
+	ballotKey, err := Store.NewBallot(ctx, ballot)
+	if err != nil {
+		http.Error(w, "Could not save ballot", http.StatusInternalServerError)
+		log.Errorf(ctx, "saving ballot: %v", err)
+		return
+	}
+
+	for i, b := range options {
+		// Accept and reject votes don't count on a ballot, so members
+		// start over by ranking the options
+		if err := bhap.ClearVotes(ctx, Store, optionKeys[i]); err != nil {
+			http.Error(w, "Could not clear votes", http.StatusInternalServerError)
+			log.Errorf(ctx, "clearing votes on BHAP %v: %v", b.ID, err)
+			return
+		}
+
+		b.Ballot = ballotKey
+		if err := Store.PutBHAP(ctx, optionKeys[i], b); err != nil {
+			http.Error(w, "Could not save BHAP", http.StatusInternalServerError)
+			log.Errorf(ctx, "putting BHAP %v on ballot: %v", b.ID, err)
+			return
+		}
+	}
+
+	log.Infof(ctx, "created ballot %v: %v", ballot.UID, title)
+
+	http.Redirect(w, r, fmt.Sprintf("/ballot/%v", ballot.UID), http.StatusSeeOther)
+}
+
+// ServeBallotPage serves a page where members rank the options on a ballot.
+func ServeBallotPage(w http.ResponseWriter, r *http.Request) {
+	ctx := bhap.RequestContext(r)
+
+	ballot, ballotKey, err := Store.BallotByUID(ctx, mux.Vars(r)["uid"])
+	if err != nil {
+		http.Error(w, "Could not load ballot", http.StatusInternalServerError)
+		log.Errorf(ctx, "loading ballot: %v", err)
+		return
+	}
+	if ballotKey == "" {
+		http.Error(w, "No ballot with that identifier", http.StatusNotFound)
+		log.Warningf(ctx, "unknown ballot requested")
+		return
+	}
+
+	user, userKey, err := bhap.UserFromSession(ctx, Store, r)
+	if err != nil {
+		http.Error(w, "Could not read session", http.StatusInternalServerError)
+		log.Errorf(ctx, "getting session email: %v", err)
+		return
+	}
+
+	options := make([]ballotOption, len(ballot.Options))
+	ranked := false
+	for i, key := range ballot.Options {
+		b, err := Store.ByKey(ctx, key)
+		if err != nil {
+			http.Error(w, "Could not load ballot options",
+				http.StatusInternalServerError)
+			log.Errorf(ctx, "loading ballot option: %v", err)
+			return
+		}
+		options[i] = ballotOption{
+			BHAP:    b,
+			Running: b.Status == bhap.DiscussionStatus && b.Ballot == ballotKey,
+		}
+
+		if userKey == "" {
+			continue
+		}
+		vote, voteKey, err := Store.GetVoteForBHAP(ctx, key, userKey)
+		if err != nil {
+			http.Error(w, "Could not read user's ranking",
+				http.StatusInternalServerError)
+			log.Errorf(ctx, "getting user's ranking: %v", err)
+			return
+		}
+		if voteKey != "" && vote.Value == bhap.RankedVote {
+			options[i].Rank = vote.Rank
+			ranked = true
+		}
+	}
+
+	result, err := bhap.CountBallot(ctx, Store, ballot)
+	if err != nil {
+		http.Error(w, "Could not count ballot", http.StatusInternalServerError)
+		log.Errorf(ctx, "counting ballot: %v", err)
+		return
+	}
+
+	var winner *bhap.BHAP
+	if ballot.Winner != "" {
+		b, err := Store.ByKey(ctx, ballot.Winner)
+		if err != nil {
+			http.Error(w, "Could not load winner", http.StatusInternalServerError)
+			log.Errorf(ctx, "loading ballot winner: %v", err)
+			return
+		}
+		winner = &b
+	}
+
+	var fullName string
+	if userKey != "" {
+		fullName = user.FirstName + " " + user.LastName
+	}
+
+	filler := ballotPageFiller{
+		LoggedIn: userKey != "",
+		FullName: fullName,
+		Ballot:   ballot,
+		Options:  options,
+		CanRank:  userKey != "" && !ballot.Closed && time.Now().Before(ballot.Deadline),
+		Ranked:   ranked,
+		Winner:   winner,
+
+		VoterCount: result.Voters,
+		UserCount:  result.Eligible,
+	}
+	showTemplate(ctx, w, ballotTemplate, filler)
+}
+
+// HandleRankForm records a member's ranking of the options on a ballot.
+// Options left blank are unranked.
+func HandleRankForm(w http.ResponseWriter, r *http.Request) {
+	ctx := bhap.RequestContext(r)
+
+	ballot, ballotKey, err := Store.BallotByUID(ctx, mux.Vars(r)["uid"])
+	if err != nil {
+		http.Error(w, "Could not load ballot", http.StatusInternalServerError)
+		log.Errorf(ctx, "loading ballot: %v", err)
+		return
+	}
+	if ballotKey == "" {
+		http.Error(w, "No ballot with that identifier", http.StatusNotFound)
+		log.Warningf(ctx, "ranking on unknown ballot denied")
+		return
+	}
+
+	_, userKey, err := bhap.UserFromSession(ctx, Store, r)
+	if err != nil {
+		http.Error(w, "Could not load user", http.StatusInternalServerError)
+		log.Errorf(ctx, "loading user: %v", err)
+		return
+	}
+	if userKey == "" {
+		http.Error(w, "You are not logged in", http.StatusForbidden)
+		log.Warningf(ctx, "request from user that is not logged in")
+		return
+	}
+
+	if ballot.Closed || !time.Now().Before(ballot.Deadline) {
+		http.Error(w, "Ranking on this ballot has closed", http.StatusBadRequest)
+		log.Warningf(ctx, "ranking after ballot closed denied")
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Could not parse form", http.StatusBadRequest)
+		log.Warningf(ctx, "parsing rank form: %v", err)
+		return
+	}
+
+	type rankedOption struct {
+		key  bhap.Key
+		rank int
+	}
+	var ranked []rankedOption
+	var unranked []bhap.Key
+	usedRanks := make(map[int]bool)
+	for _, key := range ballot.Options {
+		b, err := Store.ByKey(ctx, key)
+		if err != nil {
+			http.Error(w, "Could not load ballot options",
+				http.StatusInternalServerError)
+			log.Errorf(ctx, "loading ballot option: %v", err)
+			return
+		}
+		if b.Status != bhap.DiscussionStatus || b.Ballot != ballotKey {
+			continue
+		}
+
+		value := strings.TrimSpace(r.Form.Get(fmt.Sprintf("rank-%v", b.ID)))
+		if value == "" {
+			unranked = append(unranked, key)
+			continue
+		}
+
+		rank, err := strconv.Atoi(value)
+		if err != nil || rank < 1 {
+			http.Error(w, "Ranks must be positive whole numbers",
+				http.StatusBadRequest)
+			log.Warningf(ctx, "invalid rank %q", value)
+			return
+		}
+		if usedRanks[rank] {
+			http.Error(w, "Each option must be given a different rank",
+				http.StatusBadRequest)
+			log.Warningf(ctx, "duplicate rank %v", rank)
+			return
+		}
+		usedRanks[rank] = true
+		ranked = append(ranked, rankedOption{key, rank})
+	}
+
+	// Close any gaps so that ranks always run from 1 up
+	sort.Slice(ranked, func(i, j int) bool {
+		return ranked[i].rank < ranked[j].rank
+	})
+	for i, option := range ranked {
+		err := bhap.RankVote(ctx, Store, option.key, userKey, i+1)
+		if err != nil {
+			http.Error(w, "Could not save ranking", http.StatusInternalServerError)
+			log.Errorf(ctx, "saving ranking: %v", err)
+			return
+		}
+	}
+	for _, key := range unranked {
+		err := bhap.RetractVote(ctx, Store, key, userKey)
+		if err == bhap.ErrNoVote {
+			continue
+		} else if err != nil {
+			http.Error(w, "Could not save ranking", http.StatusInternalServerError)
+			log.Errorf(ctx, "deleting ranking: %v", err)
+			return
+		}
+	}
+
+	if err := bhap.CheckBallot(ctx, Store, ballotKey, ballot); err != nil {
+		log.Errorf(ctx, "checking ballot %v: %v", ballot.UID, err)
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/ballot/%v", ballot.UID), http.StatusSeeOther)
+}
+
+// ServeBallotResultsPage serves a page showing each round of the count of a
+// closed ballot.
+func ServeBallotResultsPage(w http.ResponseWriter, r *http.Request) {
+	ctx := bhap.RequestContext(r)
+
+	ballot, ballotKey, err := Store.BallotByUID(ctx, mux.Vars(r)["uid"])
+	if err != nil {
+		http.Error(w, "Could not load ballot", http.StatusInternalServerError)
+		log.Errorf(ctx, "loading ballot: %v", err)
+		return
+	}
+	if ballotKey == "" {
+		http.Error(w, "No ballot with that identifier", http.StatusNotFound)
+		log.Warningf(ctx, "unknown ballot requested")
+		return
+	}
+	if !ballot.Closed {
+		http.Error(w, "Results are shown once the ballot closes",
+			http.StatusNotFound)
+		log.Warningf(ctx, "results requested for open ballot")
+		return
+	}
+
+	user, userKey, err := bhap.UserFromSession(ctx, Store, r)
+	if err != nil {
+		http.Error(w, "Could not read session", http.StatusInternalServerError)
+		log.Errorf(ctx, "getting session email: %v", err)
+		return
+	}
+
+	result, err := bhap.CountBallot(ctx, Store, ballot)
+	if err != nil {
+		http.Error(w, "Could not count ballot", http.StatusInternalServerError)
+		log.Errorf(ctx, "counting ballot: %v", err)
+		return
+	}
+
+	counted, err := bhapsByKey(ctx, ballot.Counted)
+	if err != nil {
+		http.Error(w, "Could not load ballot options",
+			http.StatusInternalServerError)
+		log.Errorf(ctx, "loading ballot options: %v", err)
+		return
+	}
+
+	rounds := make([]resultRound, len(result.Rounds))
+	for i, round := range result.Rounds {
+		eliminated := make(map[bhap.Key]bool)
+		for _, key := range round.Eliminated {
+			eliminated[key] = true
+		}
+
+		rounds[i] = resultRound{Number: i + 1, Exhausted: round.Exhausted}
+		for j, key := range ballot.Counted {
+			count, ok := round.Counts[key]
+			if !ok {
+				// Knocked out in an earlier round
+				continue
+			}
+			rounds[i].Rows = append(rounds[i].Rows, resultRow{
+				BHAP:       counted[j],
+				Count:      count,
+				Eliminated: eliminated[key],
+				Won:        key == ballot.Winner,
+			})
+		}
+	}
+
+	var winner *bhap.BHAP
+	for i, key := range ballot.Counted {
+		if key == ballot.Winner {
+			winner = &counted[i]
+		}
+	}
+
+	var fullName string
+	if userKey != "" {
+		fullName = user.FirstName + " " + user.LastName
+	}
+
+	filler := ballotResultsPageFiller{
+		LoggedIn:  userKey != "",
+		FullName:  fullName,
+		Ballot:    ballot,
+		Rounds:    rounds,
+		Winner:    winner,
+		QuorumMet: result.QuorumMet,
+
+		VoterCount: result.Voters,
+		UserCount:  result.Eligible,
+	}
+	showTemplate(ctx, w, ballotResultsTemplate, filler)
+}
+
+// parseBallotOptions loads the BHAPs with the given IDs, as chosen in the
+// "option" fields of the new ballot form. Only BHAPs that ballotCandidates
+// would offer may be chosen. Problems with the choices are reported as a
+// formError.
+func parseBallotOptions(ctx context.Context, ids []string) ([]bhap.BHAP, []bhap.Key, error) {
+	var options []bhap.BHAP
+	var optionKeys []bhap.Key
+	seen := make(map[bhap.Key]bool)
+	for _, idStr := range ids {
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			return nil, nil, formError(fmt.Sprintf("%q is not a BHAP ID", idStr))
+		}
+
+		b, key, err := Store.ByID(ctx, id)
+		if err != nil {
+			return nil, nil, fmt.Errorf("loading BHAP %v: %v", id, err)
+		}
+		if key == "" || b.Status != bhap.DiscussionStatus || b.Ballot != "" {
+			return nil, nil, formError(fmt.Sprintf(
+				"BHAP %v is not in discussion on its own", id))
+		}
+		if b.SecretBallot {
+			return nil, nil, formError(fmt.Sprintf(
+				"BHAP %v has a secret ballot, and ranked votes aren't secret", id))
+		}
+
+		if !seen[key] {
+			seen[key] = true
+			options = append(options, b)
+			optionKeys = append(optionKeys, key)
+		}
+	}
+
+	return options, optionKeys, nil
+}
+
+// ballotCandidates returns the BHAPs in discussion that could be put on a
+// new ballot. BHAPs with a secret ballot are left out, since ranked votes
+// aren't kept secret.
+func ballotCandidates(ctx context.Context) ([]bhap.BHAP, error) {
+	discussion, err := Store.ByStatus(ctx, bhap.DiscussionStatus)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []bhap.BHAP
+	for _, b := range discussion {
+		if b.Ballot == "" && !b.SecretBallot {
+			candidates = append(candidates, b)
+		}
+	}
+
+	return candidates, nil
+}
@@ -3,55 +3,38 @@ package pages
 import (
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/house-emoji/bhap"
-	"google.golang.org/appengine"
-	"google.golang.org/appengine/datastore"
-	"google.golang.org/appengine/log"
+	"github.com/house-emoji/bhap/log"
 )
 
+// dateInputFormat is the format of dates submitted by HTML date inputs.
+const dateInputFormat = "2006-01-02"
+
 // HandleReadyForDiscussion handles requests to make BHAPs as ready to be
 // discussed.
 func HandleReadyForDiscussion(op bhapOperator, w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
-
-	if !op.bhap.Author.Equal(op.userKey) {
-		http.Error(w, "Only authors may mark a BHAP as ready for discussion",
-			http.StatusForbidden)
-		log.Warningf(ctx, "request from non-author denied")
-		return
-	}
+	ctx := bhap.RequestContext(r)
 
 	if op.bhap.Status != bhap.DraftStatus {
-		http.Error(w, "Only drafts may be marked as ready for discussion",
+		http.Error(w, "Only draft BHAPs can be made ready for discussion",
 			http.StatusBadRequest)
-		log.Warningf(ctx, "non-draft BHAP denied")
+		log.Warningf(ctx, "ready request for non-draft BHAP denied")
 		return
 	}
 
-	newID, err := bhap.NextID(ctx, op.bhap.Type)
-	if err != nil {
-		http.Error(w, "Error while assigning new BHAP ID",
-			http.StatusInternalServerError)
-		log.Errorf(ctx, "getting next BHAP ID: %V", err)
-	}
-
-	op.bhap.ID = newID
-	op.bhap.Status = bhap.DiscussionStatus
-	if _, err := datastore.Put(ctx, op.bhapKey, &op.bhap); err != nil {
-		http.Error(w, "Could not update BHAP", http.StatusInternalServerError)
-		log.Warningf(ctx, "updating BHAP: %v", err)
-		return
+	if updated, ok := changeStatus(op, w, r, bhap.DiscussionStatus); ok {
+		http.Redirect(w, r, bhapURL(updated), http.StatusSeeOther)
 	}
-
-	http.Redirect(w, r, fmt.Sprintf("/bhap/%v", op.bhap.ID), http.StatusSeeOther)
 }
 
 // HandleDeleteVote handles requests to delete a submitted vote.
 func HandleDeleteVote(op bhapOperator, w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
+	ctx := bhap.RequestContext(r)
 
-	if op.bhap.Author.Equal(op.userKey) {
+	if op.bhap.IsAuthor(op.userKey) {
 		http.Error(w, "Authors may not vote on their own BHAP", http.StatusBadRequest)
 		log.Warningf(ctx, "request from author denied")
 		return
@@ -64,20 +47,30 @@ func HandleDeleteVote(op bhapOperator, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, voteKey, err := bhap.GetVoteForBHAP(ctx, op.bhapKey, op.userKey)
-	if err != nil {
-		http.Error(w, "Could not load vote", http.StatusInternalServerError)
-		log.Errorf(ctx, "getting vote: %v", err)
+	if bhap.VotingClosed(op.bhap, time.Now()) {
+		http.Error(w, "Voting on this BHAP has closed", http.StatusBadRequest)
+		log.Warningf(ctx, "vote delete request after deadline denied")
 		return
 	}
-	if voteKey == nil {
-		http.Error(w, "No vote has been cast", http.StatusNotFound)
-		log.Warningf(ctx, "vote delete request on non-existent vote denied")
+
+	if op.bhap.Ballot != "" {
+		http.Error(w, "This BHAP is on a ballot and must be ranked there",
+			http.StatusBadRequest)
+		log.Warningf(ctx, "vote delete request on ballot BHAP denied")
 		return
 	}
 
-	err = datastore.Delete(ctx, voteKey)
-	if err != nil {
+	err := bhap.RetractVote(ctx, Store, op.bhapKey, op.userKey)
+	if err == bhap.ErrNoVote {
+		http.Error(w, "No vote has been cast", http.StatusNotFound)
+		log.Warningf(ctx, "vote delete request on non-existent vote denied")
+		return
+	} else if err == bhap.ErrVoteFinal {
+		http.Error(w, "Votes on a secret ballot cannot be changed",
+			http.StatusBadRequest)
+		log.Warningf(ctx, "vote delete request on secret ballot denied")
+		return
+	} else if err != nil {
 		http.Error(w, "Could not delete vote", http.StatusInternalServerError)
 		log.Errorf(ctx, "deleting vote: %v", err)
 		return
@@ -88,14 +81,37 @@ func HandleDeleteVote(op bhapOperator, w http.ResponseWriter, r *http.Request) {
 
 // HandleVoteAccept handles requests to submit an accept vote on the BHAP.
 func HandleVoteAccept(op bhapOperator, w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
+	castVote(op, w, r, bhap.AcceptedStatus)
+}
+
+// HandleVoteReject handles requests to submit an reject vote on the BHAP.
+func HandleVoteReject(op bhapOperator, w http.ResponseWriter, r *http.Request) {
+	castVote(op, w, r, bhap.RejectedStatus)
+}
 
-	if op.bhap.Author.Equal(op.userKey) {
+// HandleVoteAbstain handles requests to abstain from voting on the BHAP.
+func HandleVoteAbstain(op bhapOperator, w http.ResponseWriter, r *http.Request) {
+	castVote(op, w, r, bhap.AbstainVote)
+}
+
+// castVote sets the user's vote on the BHAP and redirects back to the BHAP
+// page.
+func castVote(op bhapOperator, w http.ResponseWriter, r *http.Request, value bhap.Status) {
+	ctx := bhap.RequestContext(r)
+
+	if op.bhap.IsAuthor(op.userKey) {
 		http.Error(w, "Authors may not vote on their own BHAP", http.StatusBadRequest)
 		log.Warningf(ctx, "request from author denied")
 		return
 	}
 
+	if !op.bhap.CanVote(op.userKey) {
+		http.Error(w, "Only members when this BHAP entered discussion may vote on it",
+			http.StatusForbidden)
+		log.Warningf(ctx, "vote from ineligible user denied")
+		return
+	}
+
 	if op.bhap.Status != bhap.DiscussionStatus {
 		http.Error(w, "Only discussion BHAPs may be voted on",
 			http.StatusBadRequest)
@@ -103,71 +119,217 @@ func HandleVoteAccept(op bhapOperator, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := bhap.SetVoteForBHAP(ctx, op.bhapKey, op.userKey, bhap.AcceptedStatus)
-	if err != nil {
+	if bhap.VotingClosed(op.bhap, time.Now()) {
+		http.Error(w, "Voting on this BHAP has closed", http.StatusBadRequest)
+		log.Warningf(ctx, "vote after deadline denied")
+		return
+	}
+
+	if op.bhap.Ballot != "" {
+		http.Error(w, "This BHAP is on a ballot and must be ranked there",
+			http.StatusBadRequest)
+		log.Warningf(ctx, "vote on ballot BHAP denied")
+		return
+	}
+
+	err := bhap.CastVote(ctx, Store, op.bhapKey, op.bhap, op.userKey, value)
+	if err == bhap.ErrVoteFinal {
+		http.Error(w, "Votes on a secret ballot cannot be changed",
+			http.StatusBadRequest)
+		log.Warningf(ctx, "changed vote on secret ballot denied")
+		return
+	} else if err != nil {
 		log.Errorf(ctx, "could not create vote: %v", err)
 		http.Error(w, "Could not create vote", 500)
 		return
 	}
 
-	bhap.CheckVotes(ctx, op.bhapKey, op.bhap)
+	// The vote is saved either way, and the BHAP is decided again when its
+	// vote closes
+	if err := bhap.CheckVotes(ctx, Store, op.bhapKey, op.bhap); err != nil {
+		log.Errorf(ctx, "checking votes: %v", err)
+	}
 
 	http.Redirect(w, r, fmt.Sprintf("/bhap/%v", op.bhap.ID), http.StatusSeeOther)
 }
 
-// HandleVoteReject handles requests to submit an reject vote on the BHAP.
-func HandleVoteReject(op bhapOperator, w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
+// HandleWithdraw handles requests to withdraw a BHAP.
+func HandleWithdraw(op bhapOperator, w http.ResponseWriter, r *http.Request) {
+	if updated, ok := changeStatus(op, w, r, bhap.WithdrawnStatus); ok {
+		http.Redirect(w, r, bhapURL(updated), http.StatusSeeOther)
+	}
+}
 
-	if op.bhap.Author.Equal(op.userKey) {
-		http.Error(w, "Authors may not vote on their own BHAP", http.StatusBadRequest)
-		log.Warningf(ctx, "request from non-author denied")
+// HandleDefer handles requests to put a BHAP in discussion on hold.
+func HandleDefer(op bhapOperator, w http.ResponseWriter, r *http.Request) {
+	ctx := bhap.RequestContext(r)
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Could not parse form", http.StatusBadRequest)
+		log.Warningf(ctx, "parsing defer form: %v", err)
 		return
 	}
 
-	if op.bhap.Status != bhap.DiscussionStatus {
-		http.Error(w, "Only discussion BHAPs may be voted on",
-			http.StatusBadRequest)
-		log.Warningf(ctx, "vote on non-discussion BHAP denied")
+	reason := strings.TrimSpace(r.Form.Get("reason"))
+	if reason == "" {
+		http.Error(w, "A reason for deferring is required", http.StatusBadRequest)
+		log.Warningf(ctx, "defer request without a reason denied")
 		return
 	}
 
-	err := bhap.SetVoteForBHAP(ctx, op.bhapKey, op.userKey, bhap.RejectedStatus)
-	if err != nil {
-		log.Errorf(ctx, "could not create vote: %v", err)
-		http.Error(w, "Could not create vote", 500)
+	var resumeDate time.Time
+	if dateStr := r.Form.Get("resumeDate"); dateStr != "" {
+		var err error
+		resumeDate, err = time.Parse(dateInputFormat, dateStr)
+		if err != nil {
+			http.Error(w, "Invalid resume date", http.StatusBadRequest)
+			log.Warningf(ctx, "parsing resume date: %v", err)
+			return
+		}
+		if !resumeDate.After(time.Now()) {
+			http.Error(w, "The resume date must be in the future",
+				http.StatusBadRequest)
+			log.Warningf(ctx, "defer request with past resume date denied")
+			return
+		}
+	}
+
+	op.bhap.DeferReason = reason
+	op.bhap.ResumeDate = resumeDate
+	if updated, ok := changeStatus(op, w, r, bhap.DeferredStatus); ok {
+		http.Redirect(w, r, bhapURL(updated), http.StatusSeeOther)
+	}
+}
+
+// HandleResume handles requests to bring a deferred BHAP back into
+// discussion.
+func HandleResume(op bhapOperator, w http.ResponseWriter, r *http.Request) {
+	ctx := bhap.RequestContext(r)
+
+	if op.bhap.Status != bhap.DeferredStatus {
+		http.Error(w, "Only deferred BHAPs can be resumed", http.StatusBadRequest)
+		log.Warningf(ctx, "resume request for non-deferred BHAP denied")
 		return
 	}
 
-	bhap.CheckVotes(ctx, op.bhapKey, op.bhap)
+	updated, ok := changeStatus(op, w, r, bhap.DiscussionStatus)
+	if !ok {
+		return
+	}
 
-	http.Redirect(w, r, fmt.Sprintf("/bhap/%v", op.bhap.ID), http.StatusSeeOther)
+	// Votes kept from before the BHAP was deferred may already decide it
+	if err := bhap.CheckVotes(ctx, Store, op.bhapKey, updated); err != nil {
+		log.Errorf(ctx, "checking votes: %v", err)
+	}
+
+	http.Redirect(w, r, bhapURL(updated), http.StatusSeeOther)
 }
 
-// HandleWithdraw handles requests to withdraw a BHAP.
-func HandleWithdraw(op bhapOperator, w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
+// HandleRetally handles requests from admins to update who may vote on a BHAP
+// in discussion after membership has changed.
+func HandleRetally(op bhapOperator, w http.ResponseWriter, r *http.Request) {
+	ctx := bhap.RequestContext(r)
 
-	if !op.bhap.Author.Equal(op.userKey) {
-		http.Error(w, "Only authors may withdraw a BHAP",
-			http.StatusForbidden)
-		log.Warningf(ctx, "request from non-author denied")
+	if !op.user.Admin {
+		http.Error(w, "Only admins may re-tally a BHAP", http.StatusForbidden)
+		log.Warningf(ctx, "re-tally request from non-admin denied")
 		return
 	}
 
 	if op.bhap.Status != bhap.DiscussionStatus {
-		http.Error(w, "Only discussion BHAPs may be withdrawn",
+		http.Error(w, "Only discussion BHAPs can be re-tallied",
 			http.StatusBadRequest)
-		log.Warningf(ctx, "vote on non-discussion BHAP denied")
+		log.Warningf(ctx, "re-tally request for non-discussion BHAP denied")
 		return
 	}
 
-	op.bhap.Status = bhap.WithdrawnStatus
-	if _, err := datastore.Put(ctx, op.bhapKey, &op.bhap); err != nil {
-		http.Error(w, "Could not update BHAP", http.StatusInternalServerError)
-		log.Warningf(ctx, "updating BHAP: %v", err)
+	updated, err := bhap.Retally(ctx, Store, op.bhapKey, op.bhap, op.userKey)
+	if err == bhap.ErrVotersUnchanged {
+		http.Error(w, "Membership hasn't changed since voting started",
+			http.StatusBadRequest)
+		log.Warningf(ctx, "re-tally request with no voter changes denied")
+		return
+	} else if err != nil {
+		http.Error(w, "Could not re-tally BHAP", http.StatusInternalServerError)
+		log.Errorf(ctx, "re-tallying BHAP: %v", err)
 		return
 	}
 
-	http.Redirect(w, r, fmt.Sprintf("/bhap/%v", op.bhap.ID), http.StatusSeeOther)
+	log.Infof(ctx, "re-tallied BHAP %v", updated.ID)
+
+	http.Redirect(w, r, bhapURL(updated), http.StatusSeeOther)
+}
+
+// HandleReopen handles requests from admins to put a decided BHAP back into
+// discussion after membership has changed.
+func HandleReopen(op bhapOperator, w http.ResponseWriter, r *http.Request) {
+	ctx := bhap.RequestContext(r)
+
+	if op.bhap.Status != bhap.AcceptedStatus && op.bhap.Status != bhap.RejectedStatus {
+		http.Error(w, "Only accepted or rejected BHAPs can be reopened",
+			http.StatusBadRequest)
+		log.Warningf(ctx, "reopen request for undecided BHAP denied")
+		return
+	}
+
+	updated, err := bhap.Reopen(ctx, Store, op.bhapKey, op.bhap, op.actor())
+	switch err.(type) {
+	case nil:
+	case *bhap.ForbiddenTransitionError:
+		http.Error(w, "Only admins may reopen a BHAP", http.StatusForbidden)
+		log.Warningf(ctx, "reopen request from non-admin denied")
+		return
+	default:
+		if err == bhap.ErrVotersUnchanged {
+			http.Error(w, "Membership hasn't changed since this BHAP was voted on",
+				http.StatusBadRequest)
+			log.Warningf(ctx, "reopen request with no voter changes denied")
+		} else if err == bhap.ErrCannotReopen {
+			http.Error(w, "This BHAP was decided on a ballot or has replaced others, so it cannot be reopened",
+				http.StatusBadRequest)
+			log.Warningf(ctx, "reopen request for final BHAP denied")
+		} else {
+			http.Error(w, "Could not reopen BHAP", http.StatusInternalServerError)
+			log.Errorf(ctx, "reopening BHAP: %v", err)
+		}
+		return
+	}
+
+	log.Infof(ctx, "reopened BHAP %v", updated.ID)
+
+	http.Redirect(w, r, bhapURL(updated), http.StatusSeeOther)
+}
+
+// changeStatus moves the BHAP to a new status on behalf of the user. If the
+// change fails, an error is reported and false is returned.
+func changeStatus(op bhapOperator, w http.ResponseWriter, r *http.Request, to bhap.Status) (bhap.BHAP, bool) {
+	ctx := bhap.RequestContext(r)
+
+	updated, err := bhap.ChangeStatus(ctx, Store, op.bhapKey, op.bhap, to, op.actor())
+	switch err := err.(type) {
+	case nil:
+		return updated, true
+	case *bhap.IllegalTransitionError:
+		http.Error(w,
+			fmt.Sprintf("%v BHAPs cannot be moved to %v", err.From, err.To),
+			http.StatusBadRequest)
+		log.Warningf(ctx, "illegal status change denied: %v", err)
+	case *bhap.ForbiddenTransitionError:
+		http.Error(w,
+			fmt.Sprintf("You may not move a BHAP from %v to %v", err.From, err.To),
+			http.StatusForbidden)
+		log.Warningf(ctx, "forbidden status change denied: %v", err)
+	default:
+		if err == bhap.ErrIDsExhausted {
+			http.Error(w,
+				fmt.Sprintf("There are no IDs left for %v BHAPs", op.bhap.Type),
+				http.StatusConflict)
+			log.Errorf(ctx, "out of IDs for %v BHAPs", op.bhap.Type)
+		} else {
+			http.Error(w, "Could not update BHAP", http.StatusInternalServerError)
+			log.Errorf(ctx, "changing BHAP status: %v", err)
+		}
+	}
+
+	return bhap.BHAP{}, false
 }
@@ -5,16 +5,19 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/house-emoji/bhap"
-	"google.golang.org/appengine"
-	"google.golang.org/appengine/datastore"
-	"google.golang.org/appengine/log"
+	"github.com/house-emoji/bhap/log"
 )
 
 type bhapOperator struct {
 	bhap    bhap.BHAP
-	bhapKey *datastore.Key
+	bhapKey bhap.Key
 	user    bhap.User
-	userKey *datastore.Key
+	userKey bhap.Key
+}
+
+// actor returns the actor that the user acts as on this BHAP.
+func (op bhapOperator) actor() bhap.Actor {
+	return bhap.UserActor(op.bhap, op.userKey, op.user)
 }
 
 // bhapOperatorHandler is a handler that does some operation on a single BHAP.
@@ -25,7 +28,7 @@ type bhapOperatorHandler func(op bhapOperator, w http.ResponseWriter, r *http.Re
 // BHAP operations.
 func SetUpBHAPOperator(handler bhapOperatorHandler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx := appengine.NewContext(r)
+		ctx := bhap.RequestContext(r)
 
 		// Load the BHAP
 		loadedBHAP, bhapKey, err := bhapFromURLVars(ctx, mux.Vars(r))
@@ -34,19 +37,19 @@ func SetUpBHAPOperator(handler bhapOperatorHandler) http.HandlerFunc {
 			log.Errorf(ctx, "loading BHAP: %v", err)
 			return
 		}
-		if bhapKey == nil {
+		if bhapKey == "" {
 			http.Error(w, "No BHAP with that identifier", http.StatusNotFound)
 			log.Warningf(ctx, "request for non-existent BHAP")
 			return
 		}
 
-		user, userKey, err := bhap.UserFromSession(ctx, r)
+		user, userKey, err := bhap.UserFromSession(ctx, Store, r)
 		if err != nil {
 			http.Error(w, "Could not load user", http.StatusInternalServerError)
 			log.Errorf(ctx, "loading user: %v", err)
 			return
 		}
-		if userKey == nil {
+		if userKey == "" {
 			http.Error(w, "You are not logged in", http.StatusForbidden)
 			log.Warningf(ctx, "request from user that is not logged in")
 			return
@@ -1,15 +1,14 @@
 package pages
 
 import (
-	"html/template"
+	"context"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/house-emoji/bhap"
-	"google.golang.org/appengine"
-	"google.golang.org/appengine/datastore"
-	"google.golang.org/appengine/log"
-	blackfriday "gopkg.in/russross/blackfriday.v2"
+	"github.com/house-emoji/bhap/log"
 )
 
 var bhapTemplate = compileTempl("views/bhap.html")
@@ -21,12 +20,31 @@ const (
 	modeDraftNotAuthor               = "draftNotAuthor"
 	modeDraftAuthor                  = "draftAuthor"
 	modeDiscussionAuthor             = "discussionAuthor"
+	modeNotEligible                  = "notEligible"
 	modeDisucssionNoVote             = "discussionNoVote"
 	modeDiscussionVoted              = "discussionVoted"
 	modeAccepted                     = "accepted"
 	modeRejected                     = "rejected"
+	modeDeferred                     = "deferred"
+	modeReplaced                     = "replaced"
+	modeBallot                       = "ballot"
 )
 
+// proxyVote is a vote cast by a delegate on a member's behalf, as shown on
+// the BHAP page.
+type proxyVote struct {
+	Voter string
+	Proxy string
+}
+
+// voteRevision is the revision a vote was cast on, as shown on the BHAP page.
+type voteRevision struct {
+	Voter string
+	// Revision is zero if the vote was cast before revisions were recorded
+	Revision int
+	Outdated bool
+}
+
 // bhapPageFiller fills the BHAP viewer page template.
 type bhapPageFiller struct {
 	LoggedIn     bool
@@ -36,19 +54,70 @@ type bhapPageFiller struct {
 	SelectedVote string
 	OptionsMode  optionsMode
 	Editable     bool
-	HTMLContent  template.HTML
+	CanDefer     bool
+	CanResume    bool
+
+	AuthorName string
+	CoAuthors  []coAuthor
+	// IsOriginalAuthor is true if the user is the BHAP's original author,
+	// who alone may invite co-authors
+	IsOriginalAuthor bool
+	// InvitedToCoAuthor is true if the user has been invited to co-author
+	// the BHAP and hasn't answered yet
+	InvitedToCoAuthor bool
+
+	Replaces   []bhap.BHAP
+	ReplacedBy *bhap.BHAP
+	Ballot     *bhap.Ballot
+
+	ProxyVotes []proxyVote
+	// SelectedProxy is the name of the delegate who cast the user's vote,
+	// if they didn't cast it themselves
+	SelectedProxy string
 
-	VoteCount int
-	UserCount int
+	// URL is where the BHAP is found, for forms to post to
+	URL string
+	// Paragraphs holds the rendered content, with the inline comments
+	// attached to each paragraph. Revision is the revision it's rendered
+	// from
+	Paragraphs []contentParagraph
+	Revision   int
+	Comments   []commentThread
+	// OutdatedComments holds inline comments whose text has since been
+	// edited away
+	OutdatedComments []commentThread
+
+	VoteRevisions []voteRevision
+	// SelectedOutdated is true if the user's vote was cast on an older
+	// revision. ChangesURL then compares that revision to the current one,
+	// and ConfirmAction is where the vote can be cast again, if it can be
+	SelectedOutdated bool
+	SelectedRevision int
+	ChangesURL       string
+	ConfirmAction    string
+
+	// VotersJoined and VotersLeft count the members who have joined and
+	// left since the BHAP's voters were recorded. They are only filled in
+	// for admins
+	VotersJoined int
+	VotersLeft   int
+	CanRetally   bool
+	CanReopen    bool
+
+	VoteCount  int
+	UserCount  int
+	VotingDays int
+	VotingRule bhap.VotingRule
 
 	PercentAccepted  int
 	PercentRejected  int
+	PercentAbstained int
 	PercentUndecided int
 }
 
 // ServeBHAPPage serves up a page that displays info on a single BHAP.
 func ServeBHAPPage(w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
+	ctx := bhap.RequestContext(r)
 
 	// Load the requested BHAP
 	loadedBHAP, bhapKey, err := bhapFromURLVars(ctx, mux.Vars(r))
@@ -57,32 +126,26 @@ func ServeBHAPPage(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to load BHAP", http.StatusInternalServerError)
 		return
 	}
-	if bhapKey == nil {
+	if bhapKey == "" {
 		http.Error(w, "No BHAP with identifier", http.StatusNotFound)
 		log.Warningf(ctx, "unknown BHAP requested")
 		return
 	}
 
-	// Render the BHAP content
-	options := blackfriday.WithExtensions(blackfriday.HardLineBreak)
-	html := string(blackfriday.Run([]byte(loadedBHAP.Content), options))
-
-	var author bhap.User
-	if err := datastore.Get(ctx, loadedBHAP.Author, &author); err != nil {
-		log.Errorf(ctx, "loading user: %v", err)
-		http.Error(w, "Failed to load user", http.StatusInternalServerError)
-		return
-	}
+	// Render the BHAP content a block at a time, so that comments can be
+	// attached to each one
+	paragraphs := contentBlocksOf(loadedBHAP.Content)
+	rendered := contentParagraphsOf(paragraphs)
 
 	// Get the current logged in user
-	user, userKey, err := bhap.UserFromSession(ctx, r)
+	user, userKey, err := bhap.UserFromSession(ctx, Store, r)
 	if err != nil {
 		http.Error(w, "Could not read session", http.StatusInternalServerError)
 		log.Errorf(ctx, "getting session email: %v", err)
 		return
 	}
 
-	allVotes, err := bhap.AllVotesForBHAP(ctx, bhapKey)
+	allVotes, err := Store.AllVotesForBHAP(ctx, bhapKey)
 	if err != nil {
 		http.Error(w, "Could not get votes",
 			http.StatusInternalServerError)
@@ -90,15 +153,7 @@ func ServeBHAPPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userCount, err := datastore.NewQuery(bhap.UserEntityName).Count(ctx)
-	if err != nil {
-		http.Error(w, "Could not get user count",
-			http.StatusInternalServerError)
-		log.Errorf(ctx, "getting user count: %v", err)
-		return
-	}
-
-	usersVote, usersVoteKey, err := bhap.GetVoteForBHAP(ctx, bhapKey, userKey)
+	usersVote, usersVoteKey, err := Store.GetVoteForBHAP(ctx, bhapKey, userKey)
 	if err != nil {
 		http.Error(w, "Could not read user's vote",
 			http.StatusInternalServerError)
@@ -108,19 +163,23 @@ func ServeBHAPPage(w http.ResponseWriter, r *http.Request) {
 
 	// Decide what options the user should have
 	var mode optionsMode
-	if userKey == nil {
+	if userKey == "" {
 		mode = modeNotLoggedIn
 	} else if loadedBHAP.Status == bhap.DraftStatus {
-		if userKey.Equal(loadedBHAP.Author) {
+		if loadedBHAP.IsAuthor(userKey) {
 			mode = modeDraftAuthor
 		} else {
 			mode = modeDraftNotAuthor
 		}
 	} else if loadedBHAP.Status == bhap.DiscussionStatus {
-		if userKey.Equal(loadedBHAP.Author) {
+		if loadedBHAP.Ballot != "" {
+			mode = modeBallot
+		} else if loadedBHAP.IsAuthor(userKey) {
 			mode = modeDiscussionAuthor
+		} else if !loadedBHAP.CanVote(userKey) {
+			mode = modeNotEligible
 		} else {
-			if usersVoteKey == nil {
+			if usersVoteKey == "" {
 				mode = modeDisucssionNoVote
 			} else {
 				mode = modeDiscussionVoted
@@ -130,65 +189,291 @@ func ServeBHAPPage(w http.ResponseWriter, r *http.Request) {
 		mode = modeAccepted
 	} else if loadedBHAP.Status == bhap.RejectedStatus {
 		mode = modeRejected
+	} else if loadedBHAP.Status == bhap.DeferredStatus {
+		mode = modeDeferred
+	} else if loadedBHAP.Status == bhap.ReplacedStatus {
+		mode = modeReplaced
 	}
 
-	// Figure out the vote breakdown
-	acceptedCount := 0
-	rejectedCount := 0
-	for _, v := range allVotes {
-		if v.Value == bhap.AcceptedStatus {
-			acceptedCount++
-		} else if v.Value == bhap.RejectedStatus {
-			rejectedCount++
+	replaces, err := bhapsByKey(ctx, loadedBHAP.Replaces)
+	if err != nil {
+		http.Error(w, "Could not load replaced BHAPs",
+			http.StatusInternalServerError)
+		log.Errorf(ctx, "loading replaced BHAPs: %v", err)
+		return
+	}
+
+	var replacedBy *bhap.BHAP
+	if loadedBHAP.ReplacedBy != "" {
+		b, err := Store.ByKey(ctx, loadedBHAP.ReplacedBy)
+		if err != nil {
+			http.Error(w, "Could not load replacing BHAP",
+				http.StatusInternalServerError)
+			log.Errorf(ctx, "loading replacing BHAP: %v", err)
+			return
+		}
+		replacedBy = &b
+	}
+
+	var ballot *bhap.Ballot
+	if loadedBHAP.Ballot != "" {
+		b, err := Store.BallotByKey(ctx, loadedBHAP.Ballot)
+		if err != nil {
+			http.Error(w, "Could not load ballot",
+				http.StatusInternalServerError)
+			log.Errorf(ctx, "loading ballot: %v", err)
+			return
 		}
+		ballot = &b
+	}
+
+	actor := bhap.UserActor(loadedBHAP, userKey, user)
+	_, deferErr := bhap.FindTransition(loadedBHAP.Status, bhap.DeferredStatus, actor)
+	_, resumeErr := bhap.FindTransition(loadedBHAP.Status, bhap.DiscussionStatus, actor)
+
+	// Figure out the vote breakdown. On a secret ballot, only the totals are
+	// known
+	tally, err := bhap.CountVotes(ctx, Store, bhapKey, loadedBHAP)
+	if err != nil {
+		http.Error(w, "Could not count votes",
+			http.StatusInternalServerError)
+		log.Errorf(ctx, "counting votes: %v", err)
+		return
 	}
-	undecidedCount := userCount - (acceptedCount + rejectedCount) - 1
+	acceptedCount := tally.Accepted
+	rejectedCount := tally.Rejected
+	abstainedCount := tally.Abstained
+	undecidedCount := tally.Eligible - tally.Cast()
 
 	var fullName string
-	if userKey != nil {
+	if userKey != "" {
 		fullName = user.FirstName + " " + user.LastName
 	}
 
-	var selectedVote string
-	if usersVoteKey != nil {
+	proxyVotes, err := proxyVotesOf(ctx, allVotes)
+	if err != nil {
+		http.Error(w, "Could not load proxy votes",
+			http.StatusInternalServerError)
+		log.Errorf(ctx, "loading proxy votes: %v", err)
+		return
+	}
+
+	comments, commentKeys, err := Store.CommentsForBHAP(ctx, bhapKey)
+	if err != nil {
+		http.Error(w, "Could not get comments",
+			http.StatusInternalServerError)
+		log.Errorf(ctx, "getting comments: %v", err)
+		return
+	}
+	threads, err := commentThreadsOf(ctx, comments, commentKeys, loadedBHAP,
+		paragraphs, userKey)
+	if err != nil {
+		http.Error(w, "Could not load comments",
+			http.StatusInternalServerError)
+		log.Errorf(ctx, "loading comments: %v", err)
+		return
+	}
+	generalThreads, outdatedThreads := placeCommentThreads(threads, rendered)
+
+	// Secret ballots only show the totals, so who voted is left out too
+	var voteRevisions []voteRevision
+	if loadedBHAP.Ballot == "" && !loadedBHAP.SecretBallot {
+		voteRevisions, err = voteRevisionsOf(ctx, allVotes, loadedBHAP)
+		if err != nil {
+			http.Error(w, "Could not load voters",
+				http.StatusInternalServerError)
+			log.Errorf(ctx, "loading voters: %v", err)
+			return
+		}
+	}
+
+	// Ranks on a ballot are shown on the ballot page instead
+	var selectedVote, selectedProxy, confirmAction string
+	if usersVoteKey != "" && usersVote.Value != bhap.RankedVote {
 		if usersVote.Value == bhap.AcceptedStatus {
 			selectedVote = "ACCEPT"
+			confirmAction = "vote-accept"
 		} else if usersVote.Value == bhap.RejectedStatus {
 			selectedVote = "REJECTED"
+			confirmAction = "vote-reject"
+		} else if usersVote.Value == bhap.AbstainVote {
+			selectedVote = "ABSTAIN"
+			confirmAction = "vote-abstain"
+		} else if usersVote.Value == bhap.SecretVote {
+			selectedVote = "SECRET"
 		} else {
 			http.Error(w, "Unknown vote type",
 				http.StatusInternalServerError)
 			log.Errorf(ctx, "unknown vote type %v", usersVote.Value)
 			return
 		}
+
+		if usersVote.Proxy != "" {
+			proxy, err := Store.UserByKey(ctx, usersVote.Proxy)
+			if err != nil {
+				http.Error(w, "Failed to load user",
+					http.StatusInternalServerError)
+				log.Errorf(ctx, "loading proxy: %v", err)
+				return
+			}
+			selectedProxy = proxy.FirstName + " " + proxy.LastName
+		}
+	}
+
+	// Let the user see what changed since they voted, and cast their vote
+	// again on the current revision if they still agree
+	selectedOutdated := usersVoteKey != "" && usersVote.Outdated(loadedBHAP)
+	var changesURL string
+	if selectedOutdated {
+		from := usersVote.Revision
+		if from == 0 {
+			from = 1
+		}
+		changesURL = fmt.Sprintf("%v/history?from=%v&to=%v",
+			bhapURL(loadedBHAP), from, loadedBHAP.Revision)
+	}
+
+	var percentAccepted, percentRejected, percentAbstained, percentUndecided int
+	eligibleCount := float64(tally.Eligible)
+	if eligibleCount != 0 {
+		percentAccepted = int((float64(acceptedCount) / eligibleCount) * 100)
+		percentRejected = int((float64(rejectedCount) / eligibleCount) * 100)
+		percentAbstained = int((float64(abstainedCount) / eligibleCount) * 100)
+		percentUndecided = int((float64(undecidedCount) / eligibleCount) * 100)
 	}
 
-	var percentAccepted, percentRejected, percentUndecided int
-	countBesidesAuthor := float64(userCount - 1)
-	if countBesidesAuthor != 0 {
-		percentAccepted = int((float64(acceptedCount) / countBesidesAuthor) * 100)
-		percentRejected = int((float64(rejectedCount) / countBesidesAuthor) * 100)
-		percentUndecided = int((float64(undecidedCount) / countBesidesAuthor) * 100)
+	// Let admins bring the voters up to date if membership has changed
+	var joined, left []bhap.Key
+	if user.Admin && loadedBHAP.Ballot == "" {
+		joined, left, err = bhap.VoterChanges(ctx, Store, loadedBHAP)
+		if err != nil {
+			http.Error(w, "Could not check voters",
+				http.StatusInternalServerError)
+			log.Errorf(ctx, "checking voter changes: %v", err)
+			return
+		}
 	}
+	votersChanged := len(joined) > 0 || len(left) > 0
+	decided := loadedBHAP.Status == bhap.RejectedStatus ||
+		(loadedBHAP.Status == bhap.AcceptedStatus && len(loadedBHAP.Replaces) == 0)
 
-	editable := isEditableStatus(loadedBHAP.Status) && userKey.Equal(loadedBHAP.Author)
+	editable := isEditableStatus(loadedBHAP.Status) && loadedBHAP.IsAuthor(userKey)
+
+	authorName, coAuthors, err := authorsOf(ctx, loadedBHAP, userKey)
+	if err != nil {
+		http.Error(w, "Failed to load user", http.StatusInternalServerError)
+		log.Errorf(ctx, "loading authors: %v", err)
+		return
+	}
 
 	filler := bhapPageFiller{
-		LoggedIn:     userKey != nil,
+		LoggedIn:     userKey != "",
 		FullName:     fullName,
 		ID:           loadedBHAP.ID,
 		BHAP:         loadedBHAP,
 		OptionsMode:  mode,
 		SelectedVote: selectedVote,
 		Editable:     editable,
-		HTMLContent:  template.HTML(html),
+		CanDefer:     deferErr == nil,
+		CanResume:    loadedBHAP.Status == bhap.DeferredStatus && resumeErr == nil,
+
+		AuthorName:        authorName,
+		CoAuthors:         coAuthors,
+		IsOriginalAuthor:  userKey != "" && userKey == loadedBHAP.Author,
+		InvitedToCoAuthor: isEditableStatus(loadedBHAP.Status) && isInvitedCoAuthor(loadedBHAP, userKey),
 
-		VoteCount: len(allVotes),
-		UserCount: userCount - 1,
+		Replaces:   replaces,
+		ReplacedBy: replacedBy,
+		Ballot:     ballot,
+
+		ProxyVotes:    proxyVotes,
+		SelectedProxy: selectedProxy,
+
+		URL:              bhapURL(loadedBHAP),
+		Paragraphs:       rendered,
+		Revision:         loadedBHAP.Revision,
+		Comments:         generalThreads,
+		OutdatedComments: outdatedThreads,
+
+		VoteRevisions:    voteRevisions,
+		SelectedOutdated: selectedOutdated,
+		SelectedRevision: usersVote.Revision,
+		ChangesURL:       changesURL,
+		ConfirmAction:    confirmAction,
+
+		VotersJoined: len(joined),
+		VotersLeft:   len(left),
+		CanRetally:   votersChanged && loadedBHAP.Status == bhap.DiscussionStatus,
+		CanReopen:    votersChanged && decided,
+
+		VoteCount:  tally.Cast(),
+		UserCount:  tally.Eligible,
+		VotingDays: int(loadedBHAP.VotingPeriod() / (24 * time.Hour)),
+		VotingRule: bhap.RuleFor(loadedBHAP.Type),
 
 		PercentAccepted:  percentAccepted,
 		PercentRejected:  percentRejected,
+		PercentAbstained: percentAbstained,
 		PercentUndecided: percentUndecided,
 	}
 	showTemplate(ctx, w, bhapTemplate, filler)
 }
+
+// proxyVotesOf returns the votes that were cast by a delegate, with the names
+// of the members involved.
+func proxyVotesOf(ctx context.Context, votes []bhap.Vote) ([]proxyVote, error) {
+	names := make(map[bhap.Key]string)
+	nameOf := func(key bhap.Key) (string, error) {
+		if name, ok := names[key]; ok {
+			return name, nil
+		}
+		user, err := Store.UserByKey(ctx, key)
+		if err != nil {
+			return "", err
+		}
+		names[key] = user.FirstName + " " + user.LastName
+		return names[key], nil
+	}
+
+	var proxies []proxyVote
+	for _, v := range votes {
+		if v.Proxy == "" {
+			continue
+		}
+
+		voter, err := nameOf(v.ByUser)
+		if err != nil {
+			return nil, err
+		}
+		proxy, err := nameOf(v.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		proxies = append(proxies, proxyVote{Voter: voter, Proxy: proxy})
+	}
+
+	return proxies, nil
+}
+
+// voteRevisionsOf returns the revision each vote was cast on, with the names
+// of the voters. Ranked votes are left out.
+func voteRevisionsOf(ctx context.Context, votes []bhap.Vote, b bhap.BHAP) ([]voteRevision, error) {
+	var revisions []voteRevision
+	for _, v := range votes {
+		if v.Value == bhap.RankedVote {
+			continue
+		}
+
+		voter, err := Store.UserByKey(ctx, v.ByUser)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, voteRevision{
+			Voter:    voter.FirstName + " " + voter.LastName,
+			Revision: v.Revision,
+			Outdated: v.Outdated(b),
+		})
+	}
+
+	return revisions, nil
+}
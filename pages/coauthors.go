@@ -0,0 +1,217 @@
+package pages
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/house-emoji/bhap"
+	"github.com/house-emoji/bhap/email"
+	"github.com/house-emoji/bhap/log"
+)
+
+// coAuthor is a co-author of a BHAP as shown on the BHAP page.
+type coAuthor struct {
+	Name  string
+	Email string
+	// Invited is true if they haven't yet accepted the invitation
+	Invited bool
+	// Mine is true if the co-author is the user
+	Mine bool
+}
+
+// authorsOf returns the name of a BHAP's author, along with its co-authors
+// and the members invited to be one.
+func authorsOf(ctx context.Context, b bhap.BHAP, userKey bhap.Key) (string, []coAuthor, error) {
+	author, err := Store.UserByKey(ctx, b.Author)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var coAuthors []coAuthor
+	add := func(keys []bhap.Key, invited bool) error {
+		for _, key := range keys {
+			user, err := Store.UserByKey(ctx, key)
+			if err != nil {
+				return err
+			}
+			coAuthors = append(coAuthors, coAuthor{
+				Name:    user.FirstName + " " + user.LastName,
+				Email:   user.Email,
+				Invited: invited,
+				Mine:    userKey != "" && key == userKey,
+			})
+		}
+		return nil
+	}
+	if err := add(b.CoAuthors, false); err != nil {
+		return "", nil, err
+	}
+	if err := add(b.InvitedCoAuthors, true); err != nil {
+		return "", nil, err
+	}
+
+	return author.FirstName + " " + author.LastName, coAuthors, nil
+}
+
+// isInvitedCoAuthor returns true if the user has been invited to co-author
+// the BHAP.
+func isInvitedCoAuthor(b bhap.BHAP, userKey bhap.Key) bool {
+	if userKey == "" {
+		return false
+	}
+	for _, key := range b.InvitedCoAuthors {
+		if key == userKey {
+			return true
+		}
+	}
+	return false
+}
+
+// HandleInviteCoAuthor invites the member with the given email to co-author
+// the BHAP. Only the original author may invite co-authors.
+func HandleInviteCoAuthor(op bhapOperator, w http.ResponseWriter, r *http.Request) {
+	ctx := bhap.RequestContext(r)
+
+	if op.bhap.Author != op.userKey {
+		http.Error(w, "Only the original author may invite co-authors",
+			http.StatusForbidden)
+		log.Warningf(ctx, "co-author invitation from non-author denied")
+		return
+	}
+
+	if !isEditableStatus(op.bhap.Status) {
+		http.Error(w, "Only draft or discussion BHAPs may gain co-authors",
+			http.StatusBadRequest)
+		log.Warningf(ctx, "co-author invitation on decided BHAP denied")
+		return
+	}
+
+	inviteeKey, ok := memberFromForm(w, r)
+	if !ok {
+		return
+	}
+
+	updated, err := bhap.InviteCoAuthor(ctx, Store, op.bhapKey, op.bhap, inviteeKey)
+	if err == bhap.ErrAlreadyInvited {
+		http.Error(w, "That member is already an author or has been invited",
+			http.StatusBadRequest)
+		log.Warningf(ctx, "repeat co-author invitation denied")
+		return
+	} else if err != nil {
+		http.Error(w, "Could not invite co-author",
+			http.StatusInternalServerError)
+		log.Errorf(ctx, "inviting co-author: %v", err)
+		return
+	}
+
+	// The invitation is saved either way, and shows on the BHAP's page
+	if err := email.QueueCoAuthorInvitation(ctx, updated, inviteeKey); err != nil {
+		log.Errorf(ctx, "failed to queue co-author invitation: %v", err)
+	}
+
+	http.Redirect(w, r, bhapURL(updated), http.StatusSeeOther)
+}
+
+// HandleAcceptCoAuthorship accepts the user's invitation to co-author the
+// BHAP.
+func HandleAcceptCoAuthorship(op bhapOperator, w http.ResponseWriter, r *http.Request) {
+	ctx := bhap.RequestContext(r)
+
+	if !isEditableStatus(op.bhap.Status) {
+		http.Error(w, "Only draft or discussion BHAPs may gain co-authors",
+			http.StatusBadRequest)
+		log.Warningf(ctx, "co-authorship of decided BHAP denied")
+		return
+	}
+
+	updated, err := bhap.AcceptCoAuthorship(ctx, Store, op.bhapKey, op.bhap, op.userKey)
+	if err == bhap.ErrNotInvited {
+		http.Error(w, "You have not been invited to co-author this BHAP",
+			http.StatusBadRequest)
+		log.Warningf(ctx, "uninvited co-authorship denied")
+		return
+	} else if err != nil {
+		http.Error(w, "Could not accept invitation",
+			http.StatusInternalServerError)
+		log.Errorf(ctx, "accepting co-authorship: %v", err)
+		return
+	}
+
+	http.Redirect(w, r, bhapURL(updated), http.StatusSeeOther)
+}
+
+// HandleDeclineCoAuthorship turns down the user's invitation to co-author the
+// BHAP.
+func HandleDeclineCoAuthorship(op bhapOperator, w http.ResponseWriter, r *http.Request) {
+	ctx := bhap.RequestContext(r)
+
+	updated, err := bhap.DeclineCoAuthorship(ctx, Store, op.bhapKey, op.bhap, op.userKey)
+	if err == bhap.ErrNotInvited {
+		http.Error(w, "You have not been invited to co-author this BHAP",
+			http.StatusBadRequest)
+		log.Warningf(ctx, "declining missing invitation denied")
+		return
+	} else if err != nil {
+		http.Error(w, "Could not decline invitation",
+			http.StatusInternalServerError)
+		log.Errorf(ctx, "declining co-authorship: %v", err)
+		return
+	}
+
+	http.Redirect(w, r, bhapURL(updated), http.StatusSeeOther)
+}
+
+// HandleRemoveCoAuthor removes the member with the given email as a
+// co-author of the BHAP, or takes back their invitation. The original author
+// may remove anyone, and co-authors may remove themselves.
+func HandleRemoveCoAuthor(op bhapOperator, w http.ResponseWriter, r *http.Request) {
+	ctx := bhap.RequestContext(r)
+
+	memberKey, ok := memberFromForm(w, r)
+	if !ok {
+		return
+	}
+
+	if op.bhap.Author != op.userKey && memberKey != op.userKey {
+		http.Error(w, "Only the original author may remove other co-authors",
+			http.StatusForbidden)
+		log.Warningf(ctx, "co-author removal from non-author denied")
+		return
+	}
+
+	updated, err := bhap.RemoveCoAuthor(ctx, Store, op.bhapKey, op.bhap, memberKey)
+	if err == bhap.ErrNotCoAuthor {
+		http.Error(w, "That member is not a co-author", http.StatusBadRequest)
+		log.Warningf(ctx, "removal of non-co-author denied")
+		return
+	} else if err != nil {
+		http.Error(w, "Could not remove co-author",
+			http.StatusInternalServerError)
+		log.Errorf(ctx, "removing co-author: %v", err)
+		return
+	}
+
+	http.Redirect(w, r, bhapURL(updated), http.StatusSeeOther)
+}
+
+// memberFromForm loads the member given by the "email" form field. If there
+// is no such member, an error is reported and false is returned.
+func memberFromForm(w http.ResponseWriter, r *http.Request) (bhap.Key, bool) {
+	ctx := bhap.RequestContext(r)
+
+	_, key, err := Store.UserByEmail(ctx, strings.TrimSpace(r.FormValue("email")))
+	if err != nil {
+		http.Error(w, "Could not load user", http.StatusInternalServerError)
+		log.Errorf(ctx, "loading user by email: %v", err)
+		return "", false
+	}
+	if key == "" {
+		http.Error(w, "There is no member with that email",
+			http.StatusBadRequest)
+		log.Warningf(ctx, "unknown member given")
+		return "", false
+	}
+
+	return key, true
+}
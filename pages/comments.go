@@ -0,0 +1,418 @@
+package pages
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/house-emoji/bhap"
+	"github.com/house-emoji/bhap/email"
+	"github.com/house-emoji/bhap/log"
+	"github.com/rs/xid"
+	blackfriday "gopkg.in/russross/blackfriday.v2"
+)
+
+// commentThread is a comment as shown on the BHAP page, along with its
+// replies.
+type commentThread struct {
+	UID    string
+	Author string
+	Date   time.Time
+	Edited bool
+	// Deleted comments are only shown to hold their replies in place
+	Deleted bool
+	// Content is the comment's Markdown, for the edit form
+	Content     string
+	HTMLContent template.HTML
+	// URL is where actions on the comment are sent
+	URL      string
+	CanReply bool
+	// Mine is true if the user wrote the comment, and so can edit or
+	// delete it
+	Mine    bool
+	Replies []commentThread
+
+	// Quote is the text an inline comment is attached to, if it's only
+	// part of a paragraph or can no longer be found
+	Quote string
+	// Outdated is true if an inline comment's text can no longer be found.
+	// ChangesURL then compares the revision it was attached to with the
+	// current one
+	Outdated   bool
+	ChangesURL string
+
+	// inline is true if the comment is attached to the paragraph numbered
+	// paragraph
+	inline    bool
+	paragraph int
+}
+
+// contentParagraph is a paragraph of a BHAP's content as shown on the BHAP
+// page, along with the inline comments attached to it.
+type contentParagraph struct {
+	Number int
+	HTML   template.HTML
+	// Lines holds the lines that comments can be attached to on their own.
+	// It is empty if the paragraph only has the one line
+	Lines    []string
+	Comments []commentThread
+}
+
+// markdownOptions are the options BHAP content and comments are rendered with.
+// Fenced code is enabled to match how bhap.Paragraphs treats it.
+var markdownOptions = blackfriday.WithExtensions(
+	blackfriday.HardLineBreak | blackfriday.FencedCode)
+
+// contentBlocksOf splits a BHAP's content into the blocks that inline
+// comments can be attached to. The content is split at blank lines, but only
+// where Markdown itself starts a new block, so that things like loose lists
+// and list items with more than one paragraph are kept whole.
+func contentBlocksOf(content string) []string {
+	var blocks []string
+	for _, chunk := range bhap.Paragraphs(content) {
+		chunkCnt := len(topLevelNodes(chunk))
+		if len(blocks) > 0 {
+			last := blocks[len(blocks)-1]
+			joined := last + "\n\n" + chunk
+			// Chunks without blocks of their own, like link reference
+			// definitions, go with the block before them
+			if chunkCnt == 0 ||
+				len(topLevelNodes(joined)) != len(topLevelNodes(last))+chunkCnt {
+				blocks[len(blocks)-1] = joined
+				continue
+			}
+		}
+		blocks = append(blocks, chunk)
+	}
+
+	return blocks
+}
+
+// topLevelNodes parses Markdown and returns the blocks at the top of the
+// document.
+func topLevelNodes(content string) []*blackfriday.Node {
+	root := blackfriday.New(markdownOptions).Parse([]byte(content))
+
+	var nodes []*blackfriday.Node
+	for node := root.FirstChild; node != nil; node = node.Next {
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// contentParagraphsOf renders the blocks of a BHAP's content from
+// contentBlocksOf, ready for inline comments to be attached. The content is
+// parsed as a whole so that blocks can refer to each other, like with
+// reference links, and then each block's share of the top-level nodes is
+// rendered on its own.
+func contentParagraphsOf(paragraphs []string) []contentParagraph {
+	nodes := topLevelNodes(strings.Join(paragraphs, "\n\n"))
+	renderer := blackfriday.NewHTMLRenderer(blackfriday.HTMLRendererParameters{
+		Flags: blackfriday.CommonHTMLFlags,
+	})
+
+	counts := make([]int, len(paragraphs))
+	total := 0
+	for i, text := range paragraphs {
+		counts[i] = len(topLevelNodes(text))
+		total += counts[i]
+	}
+	// If the whole document parsed differently than its blocks did, each
+	// block is rendered by itself instead
+	together := total == len(nodes)
+
+	rendered := make([]contentParagraph, len(paragraphs))
+	for i, text := range paragraphs {
+		rendered[i].Number = i
+		if lines := strings.Split(text, "\n"); len(lines) > 1 {
+			rendered[i].Lines = lines
+		}
+
+		if !together {
+			rendered[i].HTML = template.HTML(blackfriday.Run([]byte(text), markdownOptions))
+			continue
+		}
+
+		var html bytes.Buffer
+		for _, node := range nodes[:counts[i]] {
+			node.Walk(func(n *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+				return renderer.RenderNode(&html, n, entering)
+			})
+		}
+		nodes = nodes[counts[i]:]
+		rendered[i].HTML = template.HTML(html.String())
+	}
+
+	return rendered
+}
+
+// placeCommentThreads attaches inline comment threads to the paragraphs they
+// were left on. The threads on the BHAP as a whole are returned, followed by
+// the inline threads whose text can no longer be found.
+func placeCommentThreads(threads []commentThread, paragraphs []contentParagraph) (general, outdated []commentThread) {
+	for _, thread := range threads {
+		if !thread.inline {
+			general = append(general, thread)
+		} else if thread.Outdated {
+			outdated = append(outdated, thread)
+		} else {
+			p := &paragraphs[thread.paragraph]
+			p.Comments = append(p.Comments, thread)
+		}
+	}
+
+	return general, outdated
+}
+
+// commentThreadsOf arranges the comments on a BHAP into threads, with the
+// names of their authors. Inline comments are matched up with the paragraphs
+// of the BHAP's content.
+func commentThreadsOf(ctx context.Context, comments []bhap.Comment, keys []bhap.Key, b bhap.BHAP, paragraphs []string, userKey bhap.Key) ([]commentThread, error) {
+	replies := make(map[bhap.Key][]int)
+	for i, c := range comments {
+		replies[c.Parent] = append(replies[c.Parent], i)
+	}
+
+	names := make(map[bhap.Key]string)
+	var threadsOf func(parent bhap.Key) ([]commentThread, error)
+	threadsOf = func(parent bhap.Key) ([]commentThread, error) {
+		var threads []commentThread
+		for _, i := range replies[parent] {
+			c := comments[i]
+
+			name, ok := names[c.Author]
+			if !ok {
+				author, err := Store.UserByKey(ctx, c.Author)
+				if err != nil {
+					return nil, err
+				}
+				name = author.FirstName + " " + author.LastName
+				names[c.Author] = name
+			}
+
+			thread := commentThread{
+				UID:      c.UID,
+				Date:     c.Date,
+				Edited:   !c.Edited.IsZero(),
+				Deleted:  c.Deleted,
+				URL:      bhapURL(b) + "/comments/" + c.UID,
+				CanReply: userKey != "",
+			}
+			if !c.Deleted {
+				thread.Author = name
+				thread.Content = c.Content
+				thread.HTMLContent = renderComment(c.Content)
+				thread.Mine = userKey != "" && c.Author == userKey
+			}
+
+			if c.Anchor != "" {
+				paragraph, found := c.FindAnchor(paragraphs)
+				thread.inline = true
+				thread.paragraph = paragraph
+				thread.Outdated = !found
+				if !found || !c.AnchorsWhole(paragraphs[paragraph]) {
+					thread.Quote = c.Anchor
+				}
+				if thread.Outdated && b.Revision > c.AnchorRevision {
+					from := c.AnchorRevision
+					if from == 0 {
+						from = 1
+					}
+					thread.ChangesURL = fmt.Sprintf("%v/history?from=%v&to=%v",
+						bhapURL(b), from, b.Revision)
+				}
+			}
+
+			var err error
+			if thread.Replies, err = threadsOf(keys[i]); err != nil {
+				return nil, err
+			}
+			threads = append(threads, thread)
+		}
+		return threads, nil
+	}
+
+	return threadsOf("")
+}
+
+// renderComment renders a comment's Markdown as HTML.
+func renderComment(content string) template.HTML {
+	return template.HTML(blackfriday.Run([]byte(content), markdownOptions))
+}
+
+// HandleNewComment posts a comment on a BHAP. If the URL gives the UID of
+// another comment on the BHAP, the new comment replies to it. Otherwise, if
+// the "paragraph" field is set, the comment is attached to that paragraph of
+// the BHAP, or to one of its lines if the "line" field is set too.
+func HandleNewComment(op bhapOperator, w http.ResponseWriter, r *http.Request) {
+	ctx := bhap.RequestContext(r)
+
+	content := strings.TrimSpace(r.FormValue("content"))
+	if content == "" {
+		http.Error(w, "Comments cannot be empty", http.StatusBadRequest)
+		log.Warningf(ctx, "empty comment denied")
+		return
+	}
+
+	var parentKey bhap.Key
+	if parentUID := mux.Vars(r)["commentID"]; parentUID != "" {
+		parent, key, err := Store.CommentByUID(ctx, parentUID)
+		if err != nil {
+			http.Error(w, "Could not load comment",
+				http.StatusInternalServerError)
+			log.Errorf(ctx, "loading parent comment: %v", err)
+			return
+		}
+		if key == "" || parent.OnBHAP != op.bhapKey {
+			http.Error(w, "No comment with that UID", http.StatusNotFound)
+			log.Warningf(ctx, "reply to unknown comment %v", parentUID)
+			return
+		}
+		parentKey = key
+	}
+
+	c := bhap.Comment{
+		OnBHAP:  op.bhapKey,
+		Parent:  parentKey,
+		Author:  op.userKey,
+		UID:     xid.New().String(),
+		Content: content,
+		Date:    time.Now(),
+	}
+
+	if paragraph := r.FormValue("paragraph"); paragraph != "" && parentKey == "" {
+		var err error
+		c.Anchor, c.AnchorParagraph, err = parseAnchor(op.bhap,
+			r.FormValue("revision"), paragraph, r.FormValue("line"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			log.Warningf(ctx, "invalid comment anchor: %v", err)
+			return
+		}
+		c.AnchorRevision = op.bhap.Revision
+	}
+	if _, err := Store.NewComment(ctx, c); err != nil {
+		http.Error(w, "Could not save comment", http.StatusInternalServerError)
+		log.Errorf(ctx, "saving comment: %v", err)
+		return
+	}
+
+	// The comment is saved either way, so the authors not hearing about it
+	// isn't worth failing the request over
+	if err := email.QueueCommentNotices(ctx, op.bhap, c); err != nil {
+		log.Errorf(ctx, "failed to queue comment notices: %v", err)
+	}
+
+	http.Redirect(w, r, bhapURL(op.bhap)+"#comment-"+c.UID, http.StatusSeeOther)
+}
+
+// parseAnchor reads which paragraph, and optionally which line of it, an
+// inline comment is being attached to, returning the text there and the
+// number of the paragraph. The revision is the one the commenter was reading,
+// which must still be current. Problems are reported as a formError.
+func parseAnchor(b bhap.BHAP, revision, paragraph, line string) (string, int, error) {
+	if revision != strconv.Itoa(b.Revision) {
+		return "", 0, formError("The BHAP has changed since you loaded it")
+	}
+
+	paragraphs := contentBlocksOf(b.Content)
+	number, err := strconv.Atoi(paragraph)
+	if err != nil || number < 0 || number >= len(paragraphs) {
+		return "", 0, formError(fmt.Sprintf("There is no paragraph %q", paragraph))
+	}
+	if line == "" {
+		return paragraphs[number], number, nil
+	}
+
+	lines := strings.Split(paragraphs[number], "\n")
+	lineNumber, err := strconv.Atoi(line)
+	if err != nil || lineNumber < 0 || lineNumber >= len(lines) {
+		return "", 0, formError(fmt.Sprintf("There is no line %q", line))
+	}
+
+	return lines[lineNumber], number, nil
+}
+
+// HandleEditComment saves changes to one of the user's comments.
+func HandleEditComment(op bhapOperator, w http.ResponseWriter, r *http.Request) {
+	ctx := bhap.RequestContext(r)
+
+	c, key, ok := usersComment(op, w, r)
+	if !ok {
+		return
+	}
+
+	content := strings.TrimSpace(r.FormValue("content"))
+	if content == "" {
+		http.Error(w, "Comments cannot be empty", http.StatusBadRequest)
+		log.Warningf(ctx, "emptying comment denied")
+		return
+	}
+
+	if content != c.Content {
+		c.Content = content
+		c.Edited = time.Now()
+		if err := Store.PutComment(ctx, key, c); err != nil {
+			http.Error(w, "Could not save comment",
+				http.StatusInternalServerError)
+			log.Errorf(ctx, "saving comment: %v", err)
+			return
+		}
+	}
+
+	http.Redirect(w, r, bhapURL(op.bhap)+"#comment-"+c.UID, http.StatusSeeOther)
+}
+
+// HandleDeleteComment deletes one of the user's comments.
+func HandleDeleteComment(op bhapOperator, w http.ResponseWriter, r *http.Request) {
+	ctx := bhap.RequestContext(r)
+
+	c, key, ok := usersComment(op, w, r)
+	if !ok {
+		return
+	}
+
+	if err := bhap.DeleteComment(ctx, Store, key, c); err != nil {
+		http.Error(w, "Could not delete comment",
+			http.StatusInternalServerError)
+		log.Errorf(ctx, "deleting comment: %v", err)
+		return
+	}
+
+	http.Redirect(w, r, bhapURL(op.bhap)+"#comments", http.StatusSeeOther)
+}
+
+// usersComment loads the comment given in the URL, making sure it's on the
+// BHAP and was written by the user. If it can't be loaded or the user isn't
+// its author, an error is reported and false is returned.
+func usersComment(op bhapOperator, w http.ResponseWriter, r *http.Request) (bhap.Comment, bhap.Key, bool) {
+	ctx := bhap.RequestContext(r)
+
+	uid := mux.Vars(r)["commentID"]
+	c, key, err := Store.CommentByUID(ctx, uid)
+	if err != nil {
+		http.Error(w, "Could not load comment", http.StatusInternalServerError)
+		log.Errorf(ctx, "loading comment: %v", err)
+		return bhap.Comment{}, "", false
+	}
+	if key == "" || c.OnBHAP != op.bhapKey || c.Deleted {
+		http.Error(w, "No comment with that UID", http.StatusNotFound)
+		log.Warningf(ctx, "request for unknown comment %v", uid)
+		return bhap.Comment{}, "", false
+	}
+
+	if c.Author != op.userKey {
+		http.Error(w, "Only the author of a comment may change it",
+			http.StatusForbidden)
+		log.Warningf(ctx, "comment change from non-author denied")
+		return bhap.Comment{}, "", false
+	}
+
+	return c, key, true
+}
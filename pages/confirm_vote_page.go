@@ -0,0 +1,65 @@
+package pages
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/house-emoji/bhap"
+	"github.com/house-emoji/bhap/log"
+)
+
+var confirmVoteTemplate = compileTempl("views/confirm-vote.html")
+
+// confirmVotePageFiller fills the page for confirming a vote.
+type confirmVotePageFiller struct {
+	FullName string
+	BHAP     bhap.BHAP
+	URL      string
+	// Value is the vote being confirmed
+	Value bhap.Status
+	// AlreadyVoted is set if the user has voted on the BHAP before
+	AlreadyVoted bool
+}
+
+// ServeConfirmVoteAccept serves a page for confirming a vote to accept the
+// BHAP.
+func ServeConfirmVoteAccept(op bhapOperator, w http.ResponseWriter, r *http.Request) {
+	serveConfirmVote(op, w, r, bhap.AcceptedStatus)
+}
+
+// ServeConfirmVoteReject serves a page for confirming a vote to reject the
+// BHAP.
+func ServeConfirmVoteReject(op bhapOperator, w http.ResponseWriter, r *http.Request) {
+	serveConfirmVote(op, w, r, bhap.RejectedStatus)
+}
+
+// serveConfirmVote serves a page with a single button that casts the vote.
+// Links in emails lead here instead of voting right away, since the links
+// may be followed without the member clicking them, like by mail scanners.
+func serveConfirmVote(op bhapOperator, w http.ResponseWriter, r *http.Request, value bhap.Status) {
+	ctx := bhap.RequestContext(r)
+
+	if op.bhap.Status != bhap.DiscussionStatus || bhap.VotingClosed(op.bhap, time.Now()) ||
+		!op.bhap.CanVote(op.userKey) {
+		// Whatever is going on is explained on the BHAP page
+		http.Redirect(w, r, bhapURL(op.bhap), http.StatusSeeOther)
+		return
+	}
+
+	_, voteKey, err := Store.GetVoteForBHAP(ctx, op.bhapKey, op.userKey)
+	if err != nil {
+		http.Error(w, "Could not get vote", http.StatusInternalServerError)
+		log.Errorf(ctx, "getting vote: %v", err)
+		return
+	}
+
+	filler := confirmVotePageFiller{
+		FullName:     op.user.FirstName + " " + op.user.LastName,
+		BHAP:         op.bhap,
+		URL:          bhapURL(op.bhap),
+		Value:        value,
+		AlreadyVoted: voteKey != "",
+	}
+
+	showTemplate(ctx, w, confirmVoteTemplate, filler)
+}
@@ -5,29 +5,129 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/house-emoji/bhap"
-	"google.golang.org/appengine/datastore"
 )
 
 // bhapFromURLVars looks in the provided URL variables for a BHAP identifier
 // and loads the BHAP from it.
 //
 // In this case, URL variables refer to what mux.Vars(r) returns.
-func bhapFromURLVars(ctx context.Context, vars map[string]string) (bhap.BHAP, *datastore.Key, error) {
+func bhapFromURLVars(ctx context.Context, vars map[string]string) (bhap.BHAP, bhap.Key, error) {
 	if idStr, ok := vars["id"]; ok {
 		// The BHAP is being identified by its regular ID
 		id, err := strconv.Atoi(idStr)
 		if err != nil {
-			return bhap.BHAP{}, nil, fmt.Errorf("converting BHAP ID to int: %v", err)
+			return bhap.BHAP{}, "", fmt.Errorf("converting BHAP ID to int: %v", err)
 		}
 
-		return bhap.ByID(ctx, id)
+		return Store.ByID(ctx, id)
 	} else if draftID, ok := vars["draftID"]; ok {
 		// The BHAP is being identified by its draft ID
-		return bhap.ByDraftID(ctx, draftID)
+		return Store.ByDraftID(ctx, draftID)
 	} else {
 		// Invalid request
-		return bhap.BHAP{}, nil, errors.New("no provided BHAP identifier")
+		return bhap.BHAP{}, "", errors.New("no provided BHAP identifier")
 	}
 }
+
+// bhapURL returns the URL of the page for the given BHAP. Drafts are
+// identified by their draft ID, since they have not been given an ID yet.
+func bhapURL(b bhap.BHAP) string {
+	if b.Status == bhap.DraftStatus {
+		return fmt.Sprintf("/draft/%v", b.DraftID)
+	}
+	return fmt.Sprintf("/bhap/%v", b.ID)
+}
+
+// formError is a problem with a submitted form that the user can fix. Its
+// message is shown to them as-is.
+type formError string
+
+func (e formError) Error() string {
+	return string(e)
+}
+
+// parseReplaces reads a list of BHAP IDs separated by commas or spaces, as
+// entered in the "replaces" form field, and returns the keys of those BHAPs.
+// Only accepted BHAPs may be listed, unless they were already in the
+// existing list. Problems with the list are reported as a formError.
+func parseReplaces(ctx context.Context, value string, self bhap.Key, existing []bhap.Key) ([]bhap.Key, error) {
+	alreadyListed := make(map[bhap.Key]bool)
+	for _, key := range existing {
+		alreadyListed[key] = true
+	}
+
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+
+	keys := make([]bhap.Key, 0, len(fields))
+	seen := make(map[bhap.Key]bool)
+	for _, field := range fields {
+		id, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, formError(fmt.Sprintf("%q is not a BHAP ID", field))
+		}
+
+		b, key, err := Store.ByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if key == "" {
+			return nil, formError(fmt.Sprintf("There is no BHAP %v", id))
+		}
+		if key == self {
+			return nil, formError("A BHAP cannot replace itself")
+		}
+		if b.Status != bhap.AcceptedStatus && !alreadyListed[key] {
+			return nil, formError(fmt.Sprintf(
+				"BHAP %v is %v, but only accepted BHAPs can be replaced",
+				id, b.Status))
+		}
+
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	return keys, nil
+}
+
+// bhapsByKey loads the BHAPs with the given keys, in order.
+func bhapsByKey(ctx context.Context, keys []bhap.Key) ([]bhap.BHAP, error) {
+	bhaps := make([]bhap.BHAP, len(keys))
+	for i, key := range keys {
+		b, err := Store.ByKey(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		bhaps[i] = b
+	}
+
+	return bhaps, nil
+}
+
+// maxVotingPeriodDays is the longest voting period an author may choose.
+const maxVotingPeriodDays = 365
+
+// parseVotingPeriod reads the number of days entered in the
+// "votingPeriodDays" form field. An empty field means the default period,
+// which is returned as zero. Problems are reported as a formError.
+func parseVotingPeriod(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 1 || days > maxVotingPeriodDays {
+		return 0, formError(fmt.Sprintf(
+			"The voting period must be between 1 and %v days",
+			maxVotingPeriodDays))
+	}
+
+	return days, nil
+}
@@ -0,0 +1,348 @@
+package pages
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/house-emoji/bhap"
+	"github.com/house-emoji/bhap/log"
+	"github.com/rs/xid"
+)
+
+var delegationsTemplate = compileTempl("views/delegations.html")
+
+// delegationRow is a delegation as shown on the delegations page.
+type delegationRow struct {
+	UID string
+	// Name is the name of the other member in the delegation
+	Name string
+	// BHAP is the BHAP the delegation is limited to, if any
+	BHAP    *bhap.BHAP
+	Start   time.Time
+	LastDay time.Time
+	// Revocable is true if the delegation can still be taken back
+	Revocable bool
+}
+
+// delegationsPageFiller fills the delegations page template.
+type delegationsPageFiller struct {
+	LoggedIn bool
+	FullName string
+	Outgoing []delegationRow
+	Incoming []delegationRow
+	Today    string
+}
+
+// ServeDelegationsPage serves a page where members can delegate their vote
+// to another member and see who has delegated to them.
+func ServeDelegationsPage(w http.ResponseWriter, r *http.Request) {
+	ctx := bhap.RequestContext(r)
+
+	currUser, userKey, err := bhap.UserFromSession(ctx, Store, r)
+	if err != nil {
+		http.Error(w, "Could not read session", http.StatusInternalServerError)
+		log.Errorf(ctx, "could not get session email: %v", err)
+		return
+	}
+	if userKey == "" {
+		http.Error(w, "You are not logged in", http.StatusForbidden)
+		log.Warningf(ctx, "request from user that is not logged in")
+		return
+	}
+
+	outgoing, _, err := Store.DelegationsFrom(ctx, userKey)
+	if err != nil {
+		http.Error(w, "Could not get delegations",
+			http.StatusInternalServerError)
+		log.Errorf(ctx, "getting delegations from user: %v", err)
+		return
+	}
+	incoming, _, err := Store.DelegationsTo(ctx, userKey)
+	if err != nil {
+		http.Error(w, "Could not get delegations",
+			http.StatusInternalServerError)
+		log.Errorf(ctx, "getting delegations to user: %v", err)
+		return
+	}
+
+	outgoingRows := make([]delegationRow, len(outgoing))
+	for i, d := range outgoing {
+		if outgoingRows[i], err = newDelegationRow(ctx, d, d.To); err != nil {
+			http.Error(w, "Could not load delegation",
+				http.StatusInternalServerError)
+			log.Errorf(ctx, "loading delegation: %v", err)
+			return
+		}
+	}
+	incomingRows := make([]delegationRow, len(incoming))
+	for i, d := range incoming {
+		if incomingRows[i], err = newDelegationRow(ctx, d, d.From); err != nil {
+			http.Error(w, "Could not load delegation",
+				http.StatusInternalServerError)
+			log.Errorf(ctx, "loading delegation: %v", err)
+			return
+		}
+	}
+
+	filler := delegationsPageFiller{
+		LoggedIn: true,
+		FullName: currUser.FirstName + " " + currUser.LastName,
+		Outgoing: outgoingRows,
+		Incoming: incomingRows,
+		Today:    time.Now().UTC().Format(dateInputFormat),
+	}
+
+	showTemplate(ctx, w, delegationsTemplate, filler)
+}
+
+// newDelegationRow loads what's needed to show a delegation. The other user
+// is the member on the other end of the delegation from the viewer.
+func newDelegationRow(ctx context.Context, d bhap.Delegation, other bhap.Key) (delegationRow, error) {
+	user, err := Store.UserByKey(ctx, other)
+	if err != nil {
+		return delegationRow{}, err
+	}
+
+	row := delegationRow{
+		UID:  d.UID,
+		Name: user.FirstName + " " + user.LastName,
+	}
+
+	if d.BHAP != "" {
+		b, err := Store.ByKey(ctx, d.BHAP)
+		if err != nil {
+			return delegationRow{}, err
+		}
+		row.BHAP = &b
+	} else {
+		row.Start = d.Start
+		row.LastDay = d.End.Add(-24 * time.Hour)
+	}
+
+	row.Revocable, err = delegationRevocable(ctx, d)
+	if err != nil {
+		return delegationRow{}, err
+	}
+
+	return row, nil
+}
+
+// delegationRevocable returns true if the delegation can still be taken
+// back. A delegation for a single BHAP can be revoked until the vote on that
+// BHAP closes. One for a date range can be revoked until the range is over.
+func delegationRevocable(ctx context.Context, d bhap.Delegation) (bool, error) {
+	now := time.Now()
+	if d.BHAP == "" {
+		return now.Before(d.End), nil
+	}
+
+	b, err := Store.ByKey(ctx, d.BHAP)
+	if err != nil {
+		return false, err
+	}
+
+	return (b.Status == bhap.DiscussionStatus || b.Status == bhap.DeferredStatus) &&
+		!bhap.VotingClosed(b, now), nil
+}
+
+// HandleDelegationForm creates a new delegation from the user to another
+// member based on a POST form.
+func HandleDelegationForm(w http.ResponseWriter, r *http.Request) {
+	ctx := bhap.RequestContext(r)
+
+	_, userKey, err := bhap.UserFromSession(ctx, Store, r)
+	if err != nil {
+		http.Error(w, "Could not read session", http.StatusInternalServerError)
+		log.Errorf(ctx, "could not get session email: %v", err)
+		return
+	}
+	if userKey == "" {
+		http.Error(w, "You are not logged in", http.StatusForbidden)
+		log.Warningf(ctx, "request from user that is not logged in")
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Could not parse form", http.StatusBadRequest)
+		log.Warningf(ctx, "parsing delegation form: %v", err)
+		return
+	}
+
+	d, err := parseDelegation(ctx, r.Form.Get("email"), r.Form.Get("bhapID"),
+		r.Form.Get("startDate"), r.Form.Get("endDate"))
+	if _, ok := err.(formError); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		log.Warningf(ctx, "invalid delegation: %v", err)
+		return
+	} else if err != nil {
+		http.Error(w, "Could not read delegation",
+			http.StatusInternalServerError)
+		log.Errorf(ctx, "parsing delegation: %v", err)
+		return
+	}
+	d.UID = xid.New().String()
+	d.From = userKey
+	d.CreatedDate = time.Now()
+
+	if d.To == userKey {
+		http.Error(w, "You cannot delegate your vote to yourself",
+			http.StatusBadRequest)
+		log.Warningf(ctx, "delegation to self denied")
+		return
+	}
+
+	existing, _, err := Store.DelegationsFrom(ctx, userKey)
+	if err != nil {
+		http.Error(w, "Could not get delegations",
+			http.StatusInternalServerError)
+		log.Errorf(ctx, "getting delegations from user: %v", err)
+		return
+	}
+	for _, other := range existing {
+		if d.Overlaps(other) {
+			http.Error(w, "You have already delegated your vote for some of that time",
+				http.StatusBadRequest)
+			log.Warningf(ctx, "overlapping delegation denied")
+			return
+		}
+	}
+
+	if _, err := bhap.Delegate(ctx, Store, d); err != nil {
+		http.Error(w, "Could not save delegation",
+			http.StatusInternalServerError)
+		log.Errorf(ctx, "delegating vote: %v", err)
+		return
+	}
+
+	log.Infof(ctx, "saved delegation %v", d.UID)
+
+	http.Redirect(w, r, "/delegations", http.StatusSeeOther)
+}
+
+// parseDelegation reads the fields of the delegation form. The delegate is
+// given by email. If a BHAP ID is given, the delegation is limited to that
+// BHAP. Otherwise, it covers the dates from start to end, inclusive. Problems
+// are reported as a formError.
+func parseDelegation(ctx context.Context, email, bhapID, startDate, endDate string) (bhap.Delegation, error) {
+	var d bhap.Delegation
+
+	_, delegateKey, err := Store.UserByEmail(ctx, strings.TrimSpace(email))
+	if err != nil {
+		return bhap.Delegation{}, err
+	}
+	if delegateKey == "" {
+		return bhap.Delegation{}, formError("There is no member with that email")
+	}
+	d.To = delegateKey
+
+	if bhapID = strings.TrimSpace(bhapID); bhapID != "" {
+		id, err := strconv.Atoi(bhapID)
+		if err != nil {
+			return bhap.Delegation{}, formError(fmt.Sprintf(
+				"%q is not a BHAP ID", bhapID))
+		}
+
+		b, key, err := Store.ByID(ctx, id)
+		if err != nil {
+			return bhap.Delegation{}, err
+		}
+		if key == "" {
+			return bhap.Delegation{}, formError(fmt.Sprintf(
+				"There is no BHAP %v", id))
+		}
+		if (b.Status != bhap.DiscussionStatus && b.Status != bhap.DeferredStatus) ||
+			bhap.VotingClosed(b, time.Now()) {
+			return bhap.Delegation{}, formError(fmt.Sprintf(
+				"BHAP %v is not being voted on", id))
+		}
+
+		d.BHAP = key
+		return d, nil
+	}
+
+	start, err := time.Parse(dateInputFormat, startDate)
+	if err != nil {
+		return bhap.Delegation{}, formError("A BHAP ID or a start date is required")
+	}
+	end, err := time.Parse(dateInputFormat, endDate)
+	if err != nil {
+		return bhap.Delegation{}, formError("An end date is required")
+	}
+	if end.Before(start) {
+		return bhap.Delegation{}, formError("The end date cannot be before the start date")
+	}
+
+	d.Start = start
+	d.End = end.Add(24 * time.Hour)
+	if !time.Now().Before(d.End) {
+		return bhap.Delegation{}, formError("The end date has already passed")
+	}
+
+	return d, nil
+}
+
+// HandleRevokeDelegation takes back a delegation made by the user.
+func HandleRevokeDelegation(w http.ResponseWriter, r *http.Request) {
+	ctx := bhap.RequestContext(r)
+
+	_, userKey, err := bhap.UserFromSession(ctx, Store, r)
+	if err != nil {
+		http.Error(w, "Could not read session", http.StatusInternalServerError)
+		log.Errorf(ctx, "could not get session email: %v", err)
+		return
+	}
+	if userKey == "" {
+		http.Error(w, "You are not logged in", http.StatusForbidden)
+		log.Warningf(ctx, "request from user that is not logged in")
+		return
+	}
+
+	d, key, err := Store.DelegationByUID(ctx, mux.Vars(r)["uid"])
+	if err != nil {
+		http.Error(w, "Could not load delegation",
+			http.StatusInternalServerError)
+		log.Errorf(ctx, "loading delegation: %v", err)
+		return
+	}
+	if key == "" {
+		http.Error(w, "No delegation with that identifier", http.StatusNotFound)
+		log.Warningf(ctx, "revoke request for non-existent delegation")
+		return
+	}
+	if d.From != userKey {
+		http.Error(w, "Only the member who delegated their vote may revoke it",
+			http.StatusForbidden)
+		log.Warningf(ctx, "revoke request from another user denied")
+		return
+	}
+
+	revocable, err := delegationRevocable(ctx, d)
+	if err != nil {
+		http.Error(w, "Could not load delegation",
+			http.StatusInternalServerError)
+		log.Errorf(ctx, "checking delegation: %v", err)
+		return
+	}
+	if !revocable {
+		http.Error(w, "The vote this delegation covers has closed",
+			http.StatusBadRequest)
+		log.Warningf(ctx, "revoke request after vote closed denied")
+		return
+	}
+
+	if err := bhap.RevokeDelegation(ctx, Store, key, d); err != nil {
+		http.Error(w, "Could not revoke delegation",
+			http.StatusInternalServerError)
+		log.Errorf(ctx, "revoking delegation: %v", err)
+		return
+	}
+
+	log.Infof(ctx, "revoked delegation %v", d.UID)
+
+	http.Redirect(w, r, "/delegations", http.StatusSeeOther)
+}
@@ -0,0 +1,118 @@
+package pages
+
+import "strings"
+
+// diffKind describes how a row of a diff differs between the two texts.
+type diffKind string
+
+const (
+	diffSame    diffKind = "same"
+	diffRemoved diffKind = "removed"
+	diffAdded   diffKind = "added"
+	diffChanged diffKind = "changed"
+)
+
+// diffRow is one row of a side-by-side diff. Line numbers start from 1. A
+// side is left empty, with a line number of 0, if the row only has a line
+// on the other side.
+type diffRow struct {
+	Kind      diffKind
+	LeftLine  int
+	Left      string
+	RightLine int
+	Right     string
+}
+
+// sideBySideDiff compares two texts line by line, lining up the lines they
+// have in common. Lines removed from one spot and added in the same spot are
+// paired up as changed.
+func sideBySideDiff(before, after string) []diffRow {
+	a := splitLines(before)
+	b := splitLines(after)
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var rows []diffRow
+	var removed, added []int
+	flush := func() {
+		for k := 0; k < len(removed) || k < len(added); k++ {
+			var row diffRow
+			if k < len(removed) {
+				row.LeftLine = removed[k] + 1
+				row.Left = a[removed[k]]
+				row.Kind = diffRemoved
+			}
+			if k < len(added) {
+				row.RightLine = added[k] + 1
+				row.Right = b[added[k]]
+				row.Kind = diffAdded
+			}
+			if row.LeftLine != 0 && row.RightLine != 0 {
+				row.Kind = diffChanged
+			}
+			rows = append(rows, row)
+		}
+		removed, added = nil, nil
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		if i < len(a) && j < len(b) && a[i] == b[j] {
+			flush()
+			rows = append(rows, diffRow{
+				Kind:      diffSame,
+				LeftLine:  i + 1,
+				Left:      a[i],
+				RightLine: j + 1,
+				Right:     b[j],
+			})
+			i++
+			j++
+		} else if j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]) {
+			added = append(added, j)
+			j++
+		} else {
+			removed = append(removed, i)
+			i++
+		}
+	}
+	flush()
+
+	return rows
+}
+
+// splitLines splits text into lines, treating Windows line endings like
+// Unix ones. Empty text has no lines.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	text = strings.Replace(text, "\r\n", "\n", -1)
+	return strings.Split(text, "\n")
+}
+
+// diffHasChanges returns true if any row of the diff has a difference.
+func diffHasChanges(rows []diffRow) bool {
+	for _, row := range rows {
+		if row.Kind != diffSame {
+			return true
+		}
+	}
+	return false
+}
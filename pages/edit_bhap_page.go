@@ -1,14 +1,14 @@
 package pages
 
 import (
-	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 	"github.com/house-emoji/bhap"
-	"google.golang.org/appengine"
-	"google.golang.org/appengine/datastore"
-	"google.golang.org/appengine/log"
+	"github.com/house-emoji/bhap/email"
+	"github.com/house-emoji/bhap/log"
 )
 
 var bhapEditTemplate = compileTempl("views/edit.html")
@@ -17,11 +17,17 @@ type editPageFiller struct {
 	LoggedIn bool
 	FullName string
 	BHAP     bhap.BHAP
+	Replaces string
+	// DefaultVotingPeriodDays is used when the BHAP doesn't set a period
+	DefaultVotingPeriodDays int
+	// ResetVotesOnEdit is true if changing the wording of a BHAP in
+	// discussion throws out the votes already cast
+	ResetVotesOnEdit bool
 }
 
 // ServeEditPage serves up a page that allows the user to edit a proposal.
 func ServeEditPage(w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
+	ctx := bhap.RequestContext(r)
 
 	// Load the BHAP
 	loadedBHAP, bhapKey, err := bhapFromURLVars(ctx, mux.Vars(r))
@@ -30,7 +36,7 @@ func ServeEditPage(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to load BHAP", http.StatusInternalServerError)
 		return
 	}
-	if bhapKey == nil {
+	if bhapKey == "" {
 		http.Error(w, "No BHAP with that identifier", 404)
 		log.Warningf(ctx, "unknown BHAP requested")
 		return
@@ -43,44 +49,59 @@ func ServeEditPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var author bhap.User
-	if err := datastore.Get(ctx, loadedBHAP.Author, &author); err != nil {
+	if _, err := Store.UserByKey(ctx, loadedBHAP.Author); err != nil {
 		log.Errorf(ctx, "Error loading user: %v", err)
 		http.Error(w, "Failed to load user", http.StatusInternalServerError)
 		return
 	}
 
 	// Get the current logged in user
-	currUser, userKey, err := bhap.UserFromSession(ctx, r)
+	currUser, userKey, err := bhap.UserFromSession(ctx, Store, r)
 	if err != nil {
 		http.Error(w, "Could not read session", http.StatusInternalServerError)
 		log.Errorf(ctx, "could not get session email: %v", err)
 		return
 	}
 
-	if !loadedBHAP.Author.Equal(userKey) {
+	if !loadedBHAP.IsAuthor(userKey) {
 		http.Error(w, "Only authors may edit a BHAP", http.StatusForbidden)
 		log.Warningf(ctx, "request to edit by a non-author")
 		return
 	}
 
+	replaced, err := bhapsByKey(ctx, loadedBHAP.Replaces)
+	if err != nil {
+		http.Error(w, "Could not load replaced BHAPs",
+			http.StatusInternalServerError)
+		log.Errorf(ctx, "loading replaced BHAPs: %v", err)
+		return
+	}
+	replacedIDs := make([]string, len(replaced))
+	for i, b := range replaced {
+		replacedIDs[i] = strconv.Itoa(b.ID)
+	}
+
 	filler := editPageFiller{
-		LoggedIn: userKey != nil,
+		LoggedIn: userKey != "",
 		FullName: currUser.FirstName + " " + currUser.LastName,
 		BHAP:     loadedBHAP,
+		Replaces: strings.Join(replacedIDs, ", "),
+
+		DefaultVotingPeriodDays: bhap.CurrentSettings().VotingPeriodDays,
+		ResetVotesOnEdit:        bhap.CurrentSettings().ResetVotesOnEdit,
 	}
 	showTemplate(ctx, w, bhapEditTemplate, filler)
 }
 
 // HandleEdit handles a request to edit a BHAP.
 func HandleEdit(op bhapOperator, w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
+	ctx := bhap.RequestContext(r)
 
 	title := r.FormValue("title")
 	shortDescription := r.FormValue("shortDescription")
 	content := r.FormValue("content")
 
-	if !op.bhap.Author.Equal(op.userKey) {
+	if !op.bhap.IsAuthor(op.userKey) {
 		http.Error(w, "Only authors may edit a BHAP",
 			http.StatusForbidden)
 		log.Warningf(ctx, "request from non-author denied")
@@ -94,21 +115,51 @@ func HandleEdit(op bhapOperator, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	replaces, err := parseReplaces(ctx, r.FormValue("replaces"), op.bhapKey, op.bhap.Replaces)
+	if _, ok := err.(formError); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		log.Warningf(ctx, "invalid replaced BHAPs: %v", err)
+		return
+	} else if err != nil {
+		http.Error(w, "Could not load replaced BHAPs",
+			http.StatusInternalServerError)
+		log.Errorf(ctx, "loading replaced BHAPs: %v", err)
+		return
+	}
+
+	original := op.bhap
 	op.bhap.Title = title
 	op.bhap.ShortDescription = shortDescription
 	op.bhap.Content = content
+	op.bhap.Replaces = replaces
+
+	// The voting period and ballot can't change once voting has started
+	if op.bhap.Status == bhap.DraftStatus {
+		votingPeriodDays, err := parseVotingPeriod(r.FormValue("votingPeriodDays"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			log.Warningf(ctx, "invalid voting period: %v", err)
+			return
+		}
+		op.bhap.VotingPeriodDays = votingPeriodDays
+		op.bhap.SecretBallot = r.FormValue("secret-ballot") == "on"
+	}
 
-	if _, err := datastore.Put(ctx, op.bhapKey, &op.bhap); err != nil {
+	summary := strings.TrimSpace(r.FormValue("summary"))
+	updated, outdated, err := bhap.SaveRevision(ctx, Store, op.bhapKey, original, op.bhap, op.userKey, summary)
+	if err != nil {
 		log.Errorf(ctx, "failed to update BHAP: %v", err)
 		http.Error(w, "Could not update BHAP", http.StatusInternalServerError)
 		return
 	}
 
-	if op.bhap.Status == bhap.DraftStatus {
-		http.Redirect(w, r, fmt.Sprintf("/draft/%v", op.bhap.DraftID), http.StatusSeeOther)
-	} else {
-		http.Redirect(w, r, fmt.Sprintf("/bhap/%v", op.bhap.ID), http.StatusSeeOther)
+	// The edit is saved either way, so voters not hearing about it isn't
+	// worth failing the request over
+	if err := email.QueueRevisionNotices(ctx, updated, op.userKey, outdated); err != nil {
+		log.Errorf(ctx, "failed to queue revision notices: %v", err)
 	}
+
+	http.Redirect(w, r, bhapURL(updated), http.StatusSeeOther)
 }
 
 func isEditableStatus(status bhap.Status) bool {
@@ -0,0 +1,165 @@
+package pages
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/house-emoji/bhap"
+	"github.com/house-emoji/bhap/log"
+)
+
+var historyTemplate = compileTempl("views/history.html")
+
+// revisionRow is a revision as listed on the history page.
+type revisionRow struct {
+	Number  int
+	Date    time.Time
+	Author  string
+	Summary string
+}
+
+// historyPageFiller fills the revision history page template.
+type historyPageFiller struct {
+	LoggedIn  bool
+	FullName  string
+	BHAP      bhap.BHAP
+	URL       string
+	Revisions []revisionRow
+	// From and To are the numbers of the revisions being compared
+	From int
+	To   int
+
+	TitleDiff       []diffRow
+	DescriptionDiff []diffRow
+	ContentDiff     []diffRow
+}
+
+// ServeHistoryPage serves a page listing the revisions of a BHAP, where any
+// two of them can be compared side by side. The revisions to compare are
+// given by the "from" and "to" query parameters. By default, the latest
+// revision is compared against the one before it.
+func ServeHistoryPage(w http.ResponseWriter, r *http.Request) {
+	ctx := bhap.RequestContext(r)
+
+	loadedBHAP, bhapKey, err := bhapFromURLVars(ctx, mux.Vars(r))
+	if err != nil {
+		log.Errorf(ctx, "could not load BHAP: %v", err)
+		http.Error(w, "Failed to load BHAP", http.StatusInternalServerError)
+		return
+	}
+	if bhapKey == "" {
+		http.Error(w, "No BHAP with identifier", http.StatusNotFound)
+		log.Warningf(ctx, "unknown BHAP requested")
+		return
+	}
+
+	user, userKey, err := bhap.UserFromSession(ctx, Store, r)
+	if err != nil {
+		http.Error(w, "Could not read session", http.StatusInternalServerError)
+		log.Errorf(ctx, "getting session email: %v", err)
+		return
+	}
+
+	revisions, err := Store.RevisionsForBHAP(ctx, bhapKey)
+	if err != nil {
+		http.Error(w, "Could not get revisions",
+			http.StatusInternalServerError)
+		log.Errorf(ctx, "getting revisions: %v", err)
+		return
+	}
+
+	rows, err := revisionRowsOf(ctx, revisions)
+	if err != nil {
+		http.Error(w, "Failed to load user", http.StatusInternalServerError)
+		log.Errorf(ctx, "loading revision authors: %v", err)
+		return
+	}
+
+	filler := historyPageFiller{
+		LoggedIn:  userKey != "",
+		FullName:  user.FirstName + " " + user.LastName,
+		BHAP:      loadedBHAP,
+		URL:       bhapURL(loadedBHAP),
+		Revisions: rows,
+	}
+
+	if len(revisions) > 0 {
+		filler.To = len(revisions)
+		filler.From = filler.To - 1
+		if filler.From < 1 {
+			filler.From = 1
+		}
+
+		if filler.From, err = revisionNumber(r.FormValue("from"), filler.From, len(revisions)); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			log.Warningf(ctx, "invalid revision to compare from: %v", err)
+			return
+		}
+		if filler.To, err = revisionNumber(r.FormValue("to"), filler.To, len(revisions)); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			log.Warningf(ctx, "invalid revision to compare to: %v", err)
+			return
+		}
+
+		// Only the parts that changed are shown
+		from := revisions[filler.From-1]
+		to := revisions[filler.To-1]
+		if diff := sideBySideDiff(from.Title, to.Title); diffHasChanges(diff) {
+			filler.TitleDiff = diff
+		}
+		if diff := sideBySideDiff(from.ShortDescription, to.ShortDescription); diffHasChanges(diff) {
+			filler.DescriptionDiff = diff
+		}
+		if diff := sideBySideDiff(from.Content, to.Content); diffHasChanges(diff) {
+			filler.ContentDiff = diff
+		}
+	}
+
+	showTemplate(ctx, w, historyTemplate, filler)
+}
+
+// revisionRowsOf returns the revisions as they are listed on the history
+// page, newest first.
+func revisionRowsOf(ctx context.Context, revisions []bhap.Revision) ([]revisionRow, error) {
+	names := make(map[bhap.Key]string)
+	rows := make([]revisionRow, len(revisions))
+	for i, rev := range revisions {
+		name, ok := names[rev.Author]
+		if !ok && rev.Author != "" {
+			author, err := Store.UserByKey(ctx, rev.Author)
+			if err != nil {
+				return nil, err
+			}
+			name = author.FirstName + " " + author.LastName
+			names[rev.Author] = name
+		}
+
+		rows[len(revisions)-1-i] = revisionRow{
+			Number:  rev.Number,
+			Date:    rev.Date,
+			Author:  name,
+			Summary: rev.Summary,
+		}
+	}
+
+	return rows, nil
+}
+
+// revisionNumber parses the number of a revision to compare. If none is
+// given, the default is used. Problems are reported as a formError.
+func revisionNumber(value string, def, count int) (int, error) {
+	if value == "" {
+		return def, nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 1 || number > count {
+		return 0, formError(fmt.Sprintf("There is no revision %q", value))
+	}
+
+	return number, nil
+}
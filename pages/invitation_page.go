@@ -4,10 +4,8 @@ import (
 	"net/http"
 
 	"github.com/house-emoji/bhap"
+	"github.com/house-emoji/bhap/log"
 	"github.com/rs/xid"
-	"google.golang.org/appengine"
-	"google.golang.org/appengine/datastore"
-	"google.golang.org/appengine/log"
 )
 
 // ServeInvitePage serves the page that is used to create new invitations to
@@ -19,13 +17,11 @@ func ServeInvitePage(w http.ResponseWriter, r *http.Request) {
 // HandleInvitationForm creates a new invitation based on form input from a
 // POST request.
 func HandleInvitationForm(w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
+	ctx := bhap.RequestContext(r)
 
 	email := r.FormValue("email")
 
-	duplicateCount, err := datastore.NewQuery(bhap.UserEntityName).
-		Filter("Email =", email).
-		Count(ctx)
+	_, duplicateKey, err := Store.UserByEmail(ctx, email)
 	if err != nil {
 		log.Errorf(ctx, "looking for duplicate errors: %v", err)
 		http.Error(w, "Could not look for duplicate emails",
@@ -33,7 +29,7 @@ func HandleInvitationForm(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if duplicateCount > 0 {
+	if duplicateKey != "" {
 		log.Warningf(ctx, "attempt to add a duplicate email invitation")
 		http.Error(w, "A user with that email already exists",
 			http.StatusBadRequest)
@@ -46,8 +42,7 @@ func HandleInvitationForm(w http.ResponseWriter, r *http.Request) {
 		EmailSent: false,
 	}
 
-	key := datastore.NewKey(ctx, bhap.InvitationEntityName, "", 0, nil)
-	if _, err := datastore.Put(ctx, key, &newInvitation); err != nil {
+	if _, err := Store.NewInvitation(ctx, newInvitation); err != nil {
 		log.Errorf(ctx, "could not create invitation: %v", err)
 		http.Error(w, "Could not create invitation",
 			http.StatusInternalServerError)
@@ -4,8 +4,7 @@ import (
 	"net/http"
 
 	"github.com/house-emoji/bhap"
-	"google.golang.org/appengine"
-	"google.golang.org/appengine/log"
+	"github.com/house-emoji/bhap/log"
 )
 
 var listTemplate = compileTempl("views/list.html")
@@ -16,17 +15,21 @@ type listPageFiller struct {
 	FullName        string
 	NewBHAP         *bhap.BHAP
 	DiscussionBHAPs []bhap.BHAP
+	OpenBallots     []bhap.Ballot
+	CanCreateBallot bool
 	ActiveBHAPs     []bhap.BHAP
+	DeferredBHAPs   []bhap.BHAP
 	RejectedBHAPs   []bhap.BHAP
+	ReplacedBHAPs   []bhap.BHAP
 	DraftBHAPs      []bhap.BHAP
 }
 
 // ServeListPage serves a page with a list of all BHAPs.
 func ServeListPage(w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
+	ctx := bhap.RequestContext(r)
 
 	// Get the current logged in user
-	currUser, userKey, err := bhap.UserFromSession(ctx, r)
+	currUser, userKey, err := bhap.UserFromSession(ctx, Store, r)
 	if err != nil {
 		http.Error(w, "Could not read session", http.StatusInternalServerError)
 		log.Errorf(ctx, "could not get session email: %v", err)
@@ -34,7 +37,7 @@ func ServeListPage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get all discussion BHAPs
-	discussionBHAPs, err := bhap.ByStatus(ctx, bhap.DiscussionStatus)
+	discussionBHAPs, err := Store.ByStatus(ctx, bhap.DiscussionStatus)
 	if err != nil {
 		log.Errorf(ctx, "getting discussion BHAPs: %v", err)
 		http.Error(w, "Could not get discussion BHAPs",
@@ -48,8 +51,17 @@ func ServeListPage(w http.ResponseWriter, r *http.Request) {
 		discussionBHAPs = discussionBHAPs[1:]
 	}
 
+	// Get all open ballots
+	openBallots, _, err := Store.OpenBallots(ctx)
+	if err != nil {
+		log.Errorf(ctx, "getting open ballots: %v", err)
+		http.Error(w, "Could not get open ballots",
+			http.StatusInternalServerError)
+		return
+	}
+
 	// Get all active BHAPs
-	activeBHAPs, err := bhap.ByStatus(ctx, bhap.AcceptedStatus)
+	activeBHAPs, err := Store.ByStatus(ctx, bhap.AcceptedStatus)
 	if err != nil {
 		log.Errorf(ctx, "getting active BHAPs: %v", err)
 		http.Error(w, "Could not get active BHAPs",
@@ -57,8 +69,17 @@ func ServeListPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Get all deferred BHAPs
+	deferredBHAPs, err := Store.ByStatus(ctx, bhap.DeferredStatus)
+	if err != nil {
+		log.Errorf(ctx, "getting deferred BHAPs: %v", err)
+		http.Error(w, "Could not get deferred BHAPs",
+			http.StatusInternalServerError)
+		return
+	}
+
 	// Get all rejected BHAPs
-	rejectedBHAPs, err := bhap.ByStatus(ctx, bhap.RejectedStatus)
+	rejectedBHAPs, err := Store.ByStatus(ctx, bhap.RejectedStatus)
 	if err != nil {
 		log.Errorf(ctx, "getting rejected BHAPs: %v", err)
 		http.Error(w, "Could not get rejected BHAPs",
@@ -66,8 +87,17 @@ func ServeListPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Get all replaced BHAPs
+	replacedBHAPs, err := Store.ByStatus(ctx, bhap.ReplacedStatus)
+	if err != nil {
+		log.Errorf(ctx, "getting replaced BHAPs: %v", err)
+		http.Error(w, "Could not get replaced BHAPs",
+			http.StatusInternalServerError)
+		return
+	}
+
 	// Get all draft BHAPs
-	draftBHAPs, err := bhap.ByStatus(ctx, bhap.DraftStatus)
+	draftBHAPs, err := Store.ByStatus(ctx, bhap.DraftStatus)
 	if err != nil {
 		log.Errorf(ctx, "getting draft BHAPs: %v", err)
 		http.Error(w, "Could not get draft BHAPs",
@@ -76,12 +106,16 @@ func ServeListPage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	filler := listPageFiller{
-		LoggedIn:        userKey != nil,
+		LoggedIn:        userKey != "",
 		FullName:        currUser.FirstName + " " + currUser.LastName,
 		NewBHAP:         newBHAP,
 		DiscussionBHAPs: discussionBHAPs,
+		OpenBallots:     openBallots,
+		CanCreateBallot: userKey != "" && currUser.Admin,
 		ActiveBHAPs:     activeBHAPs,
+		DeferredBHAPs:   deferredBHAPs,
 		RejectedBHAPs:   rejectedBHAPs,
+		ReplacedBHAPs:   replacedBHAPs,
 		DraftBHAPs:      draftBHAPs,
 	}
 
@@ -5,21 +5,23 @@ import (
 	"math/rand"
 	"net/http"
 	"path"
+	"strings"
 
 	"github.com/house-emoji/bhap"
-	"google.golang.org/appengine"
-	"google.golang.org/appengine/log"
+	"github.com/house-emoji/bhap/log"
 )
 
 var loginTemplate = compileTempl("views/login.html")
 
 type loginPageFiller struct {
 	BackgroundURL string
+	// Next is where to go after logging in
+	Next string
 }
 
 // ServeLoginPage serves the page for logging in.
 func ServeLoginPage(w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
+	ctx := bhap.RequestContext(r)
 
 	backgroundURL, err := randomBackgroundURL()
 	if err != nil {
@@ -31,20 +33,22 @@ func ServeLoginPage(w http.ResponseWriter, r *http.Request) {
 
 	filler := loginPageFiller{
 		BackgroundURL: backgroundURL,
+		Next:          localPath(r.FormValue("next")),
 	}
 
 	showTemplate(ctx, w, loginTemplate, filler)
 }
 
 // HandleLoginForm attempts to log the user in using credentials from a POST
-// form.
+// form. Once logged in, the user is sent to the page given by the "next" form
+// value, or the home page by default.
 func HandleLoginForm(w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
+	ctx := bhap.RequestContext(r)
 
 	email := r.FormValue("email")
 	password := r.FormValue("password")
 
-	loggedIn, err := bhap.CheckLogin(ctx, email, password)
+	loggedIn, err := bhap.CheckLogin(ctx, Store, email, password)
 	if err != nil {
 		log.Errorf(ctx, "Error authenticating: %v", err)
 		http.Error(w, "Error authenticating", http.StatusInternalServerError)
@@ -55,6 +59,13 @@ func HandleLoginForm(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Not being made an admin shouldn't keep anyone from logging in
+	if granted, err := bhap.GrantListedAdmin(ctx, Store, email); err != nil {
+		log.Errorf(ctx, "could not make %v an admin: %v", email, err)
+	} else if granted {
+		log.Infof(ctx, "made %v an admin, as listed in the settings", email)
+	}
+
 	loginSession, err := bhap.GetSession(r)
 	if err != nil {
 		http.Error(w, "Could not decode session", http.StatusInternalServerError)
@@ -70,12 +81,23 @@ func HandleLoginForm(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	http.Redirect(w, r, localPath(r.FormValue("next")), http.StatusSeeOther)
+}
+
+// localPath returns the path if it leads to a page on this site, so that
+// links to the login page can't send users elsewhere. Otherwise, the path to
+// the home page is returned.
+func localPath(p string) string {
+	if !strings.HasPrefix(p, "/") || strings.HasPrefix(p, "//") ||
+		strings.HasPrefix(p, "/\\") {
+		return "/"
+	}
+	return p
 }
 
 // HandleLogoutForm logs the user out.
 func HandleLogoutForm(w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
+	ctx := bhap.RequestContext(r)
 
 	if err := bhap.DeleteSession(w, r); err != nil {
 		http.Error(w, "Could not log out", http.StatusInternalServerError)
@@ -2,16 +2,17 @@ package pages
 
 import (
 	"net/http"
+	"net/url"
 
 	"github.com/house-emoji/bhap"
-	"google.golang.org/appengine"
-	"google.golang.org/appengine/log"
+	"github.com/house-emoji/bhap/log"
 )
 
-// RequireLogin is middleware that requires the user be logged in.
+// RequireLogin is middleware that requires the user be logged in. Users who
+// aren't are sent to the login page, and come back once they've logged in.
 func RequireLogin(next func(w http.ResponseWriter, r *http.Request)) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ctx := appengine.NewContext(r)
+		ctx := bhap.RequestContext(r)
 
 		loginSession, err := bhap.GetSession(r)
 		if err != nil {
@@ -22,7 +23,8 @@ func RequireLogin(next func(w http.ResponseWriter, r *http.Request)) http.Handle
 
 		if loginSession.IsNew {
 			log.Infof(ctx, "No session exists, redirecting to login")
-			http.Redirect(w, r, "/login", http.StatusFound)
+			http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()),
+				http.StatusFound)
 			return
 		}
 
@@ -6,10 +6,8 @@ import (
 	"time"
 
 	"github.com/house-emoji/bhap"
+	"github.com/house-emoji/bhap/log"
 	"github.com/rs/xid"
-	"google.golang.org/appengine"
-	"google.golang.org/appengine/datastore"
-	"google.golang.org/appengine/log"
 )
 
 const dateFormat = "2006-01-02"
@@ -20,16 +18,17 @@ var (
 )
 
 type proposePageFiller struct {
-	LoggedIn bool
-	FullName string
+	LoggedIn         bool
+	FullName         string
+	VotingPeriodDays int
 }
 
 // ServeNewBHAPPage serves a page for creating new BHAPs.
 func ServeNewBHAPPage(w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
+	ctx := bhap.RequestContext(r)
 
 	// Get the current logged in user
-	currUser, userKey, err := bhap.UserFromSession(ctx, r)
+	currUser, userKey, err := bhap.UserFromSession(ctx, Store, r)
 	if err != nil {
 		http.Error(w, "Could not read session", http.StatusInternalServerError)
 		log.Errorf(ctx, "could not get session email: %v", err)
@@ -37,8 +36,9 @@ func ServeNewBHAPPage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	filler := proposePageFiller{
-		LoggedIn: userKey != nil,
-		FullName: currUser.FirstName + " " + currUser.LastName,
+		LoggedIn:         userKey != "",
+		FullName:         currUser.FirstName + " " + currUser.LastName,
+		VotingPeriodDays: bhap.CurrentSettings().VotingPeriodDays,
 	}
 
 	showTemplate(ctx, w, proposeTemplate, filler)
@@ -46,21 +46,41 @@ func ServeNewBHAPPage(w http.ResponseWriter, r *http.Request) {
 
 // HandleNewBHAPForm creates a new BHAP based on information passed from a POST form.
 func HandleNewBHAPForm(w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
+	ctx := bhap.RequestContext(r)
 
 	title := r.FormValue("title")
 	shortDescription := r.FormValue("shortDescription")
 	isMeta := r.FormValue("is-meta")
+	secretBallot := r.FormValue("secret-ballot")
 	content := r.FormValue("content")
 
 	// Get the current logged in user
-	_, userKey, err := bhap.UserFromSession(ctx, r)
+	_, userKey, err := bhap.UserFromSession(ctx, Store, r)
 	if err != nil {
 		http.Error(w, "Could not read session", http.StatusInternalServerError)
 		log.Errorf(ctx, "could not get session email: %v", err)
 		return
 	}
 
+	votingPeriodDays, err := parseVotingPeriod(r.FormValue("votingPeriodDays"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		log.Warningf(ctx, "invalid voting period: %v", err)
+		return
+	}
+
+	replaces, err := parseReplaces(ctx, r.FormValue("replaces"), "", nil)
+	if _, ok := err.(formError); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		log.Warningf(ctx, "invalid replaced BHAPs: %v", err)
+		return
+	} else if err != nil {
+		http.Error(w, "Could not load replaced BHAPs",
+			http.StatusInternalServerError)
+		log.Errorf(ctx, "loading replaced BHAPs: %v", err)
+		return
+	}
+
 	draftID := xid.New().String()
 	typ := bhap.HouseRuleBHAPType
 	if isMeta == "on" {
@@ -78,15 +98,26 @@ func HandleNewBHAPForm(w http.ResponseWriter, r *http.Request) {
 		Status:           bhap.DraftStatus,
 		CreatedDate:      time.Now(),
 		Content:          content,
+		Replaces:         replaces,
+		VotingPeriodDays: votingPeriodDays,
+		SecretBallot:     secretBallot == "on",
+
+		Revision:            1,
+		SubstantiveRevision: 1,
 	}
 
 	// Save the new BHAP
-	key := datastore.NewKey(ctx, bhap.BHAPEntityName, "", 0, nil)
-	if _, err := datastore.Put(ctx, key, &newBHAP); err != nil {
+	key, err := Store.NewBHAP(ctx, newBHAP)
+	if err != nil {
 		log.Errorf(ctx, "failed to save BHAP: %v", err)
 		http.Error(w, "Could not save BHAP", http.StatusInternalServerError)
 		return
 	}
+	if err := bhap.RecordRevision(ctx, Store, key, newBHAP, userKey, ""); err != nil {
+		log.Errorf(ctx, "failed to record revision: %v", err)
+		http.Error(w, "Could not save BHAP", http.StatusInternalServerError)
+		return
+	}
 
 	log.Infof(ctx, "saved draft BHAP %v: %v", draftID, title)
 
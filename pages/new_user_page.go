@@ -7,10 +7,8 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/house-emoji/bhap"
+	"github.com/house-emoji/bhap/log"
 	"golang.org/x/crypto/bcrypt"
-	"google.golang.org/appengine"
-	"google.golang.org/appengine/datastore"
-	"google.golang.org/appengine/log"
 )
 
 const minPasswordLength = 5
@@ -25,12 +23,12 @@ type newUserFiller struct {
 // ServeNewUserPage serves the page that can be used to create a new user from
 // an invitation.
 func ServeNewUserPage(w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
+	ctx := bhap.RequestContext(r)
 
 	// Get the invitation UID
 	uid := mux.Vars(r)["uid"]
 
-	invite, key, err := bhap.InvitationByUID(ctx, uid)
+	invite, key, err := Store.InvitationByUID(ctx, uid)
 	if err != nil {
 		http.Error(w,
 			"Error while getting invitation information",
@@ -39,7 +37,7 @@ func ServeNewUserPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if key == nil {
+	if key == "" {
 		http.Error(w,
 			"Invalid invitation ID. Are you trying to pull a fast one?",
 			http.StatusBadRequest)
@@ -65,7 +63,7 @@ func ServeNewUserPage(w http.ResponseWriter, r *http.Request) {
 
 // HandleNewUserForm creates a new user based on form data from a POST request.
 func HandleNewUserForm(w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
+	ctx := bhap.RequestContext(r)
 
 	// Get the invitation UID
 	uid := mux.Vars(r)["uid"]
@@ -90,7 +88,7 @@ func HandleNewUserForm(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	invite, inviteKey, err := bhap.InvitationByUID(ctx, uid)
+	invite, inviteKey, err := Store.InvitationByUID(ctx, uid)
 	if err != nil {
 		http.Error(w,
 			"Error while getting invitation information",
@@ -99,7 +97,7 @@ func HandleNewUserForm(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if inviteKey == nil {
+	if inviteKey == "" {
 		http.Error(w,
 			"Invalid invitation ID. Are you trying to pull a fast one?",
 			http.StatusBadRequest)
@@ -118,24 +116,37 @@ func HandleNewUserForm(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Nobody else can make the first member an admin, so they start as one
+	userCnt, err := Store.UserCount(ctx)
+	if err != nil {
+		http.Error(w,
+			"Error counting users",
+			http.StatusInternalServerError)
+		log.Errorf(ctx, "could not count users: %v", err)
+		return
+	}
+
 	newUser := bhap.User{
 		FirstName:    firstName,
 		LastName:     lastName,
 		Email:        invite.Email,
 		PasswordHash: passwordHash,
+		Admin:        userCnt == 0,
 	}
 
-	userKey := datastore.NewKey(ctx, bhap.UserEntityName, "", 0, nil)
-	if _, err := datastore.Put(ctx, userKey, &newUser); err != nil {
+	if _, err := Store.NewUser(ctx, newUser); err != nil {
 		http.Error(w,
 			"Error saving new user",
 			http.StatusInternalServerError)
 		log.Errorf(ctx, "could not save new user: %v", err)
 		return
 	}
+	if newUser.Admin {
+		log.Infof(ctx, "made first user %v an admin", newUser.Email)
+	}
 
 	// Delete the invitation so it can't be reused
-	if err := datastore.Delete(ctx, inviteKey); err != nil {
+	if err := Store.DeleteInvitation(ctx, inviteKey); err != nil {
 		log.Errorf(ctx, "could not delete used invitation: %v", err)
 	}
 
@@ -0,0 +1,227 @@
+package pages
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/house-emoji/bhap"
+	"github.com/house-emoji/bhap/log"
+)
+
+var (
+	settingsTemplate    = compileTempl("views/settings.html")
+	unsubscribeTemplate = compileTempl("views/unsubscribe.html")
+)
+
+// notificationSetting is a kind of email as listed on the settings page.
+type notificationSetting struct {
+	Name        bhap.Notification
+	Description string
+	Wants       bool
+}
+
+// settingsPageFiller fills the settings page template.
+type settingsPageFiller struct {
+	LoggedIn      bool
+	FullName      string
+	Notifications []notificationSetting
+}
+
+// unsubscribePageFiller fills the unsubscribe page template.
+type unsubscribePageFiller struct {
+	LoggedIn bool
+	FullName string
+	// Confirming is set while the member is being asked whether to
+	// unsubscribe
+	Confirming bool
+	// Notification is the kind of email unsubscribed from
+	Notification bhap.Notification
+	Description  string
+	// User and Token are from the unsubscribe link, so that the member can
+	// change their mind without logging in
+	User  bhap.Key
+	Token string
+	// Resubscribed is set once the member changed their mind
+	Resubscribed bool
+}
+
+// ServeSettingsPage serves a page where members choose which kinds of email
+// they get.
+func ServeSettingsPage(w http.ResponseWriter, r *http.Request) {
+	ctx := bhap.RequestContext(r)
+
+	user, userKey, err := bhap.UserFromSession(ctx, Store, r)
+	if err != nil {
+		http.Error(w, "Could not read session", http.StatusInternalServerError)
+		log.Errorf(ctx, "could not get session email: %v", err)
+		return
+	}
+	if userKey == "" {
+		http.Error(w, "You are not logged in", http.StatusForbidden)
+		log.Warningf(ctx, "request from user that is not logged in")
+		return
+	}
+
+	filler := settingsPageFiller{
+		LoggedIn: true,
+		FullName: user.FirstName + " " + user.LastName,
+	}
+	for _, n := range bhap.Notifications {
+		filler.Notifications = append(filler.Notifications, notificationSetting{
+			Name:        n,
+			Description: n.Description(),
+			Wants:       user.Wants(n),
+		})
+	}
+
+	showTemplate(ctx, w, settingsTemplate, filler)
+}
+
+// HandleSettingsForm saves the user's settings from a POST form. Each kind of
+// email has a checkbox named after it.
+func HandleSettingsForm(w http.ResponseWriter, r *http.Request) {
+	ctx := bhap.RequestContext(r)
+
+	user, userKey, err := bhap.UserFromSession(ctx, Store, r)
+	if err != nil {
+		http.Error(w, "Could not read session", http.StatusInternalServerError)
+		log.Errorf(ctx, "could not get session email: %v", err)
+		return
+	}
+	if userKey == "" {
+		http.Error(w, "You are not logged in", http.StatusForbidden)
+		log.Warningf(ctx, "request from user that is not logged in")
+		return
+	}
+
+	for _, n := range bhap.Notifications {
+		user.SetWants(n, r.FormValue(string(n)) != "")
+	}
+
+	if err := Store.PutUser(ctx, userKey, user); err != nil {
+		http.Error(w, "Could not save settings", http.StatusInternalServerError)
+		log.Errorf(ctx, "saving user: %v", err)
+		return
+	}
+
+	http.Redirect(w, r, "/settings", http.StatusSeeOther)
+}
+
+// ServeUnsubscribePage serves a page with a single button that unsubscribes
+// a member from the kind of email given in the URL, which is where
+// unsubscribe links in emails lead. Following the link doesn't unsubscribe
+// right away, since links may be followed without the member clicking them,
+// like by mail scanners.
+func ServeUnsubscribePage(w http.ResponseWriter, r *http.Request) {
+	n, userKey, token, ok := checkLink(w, r)
+	if !ok {
+		return
+	}
+
+	showUnsubscribePage(w, r, unsubscribePageFiller{
+		Confirming:   true,
+		Notification: n,
+		Description:  n.Description(),
+		User:         userKey,
+		Token:        token,
+	})
+}
+
+// HandleUnsubscribe unsubscribes a member from the kind of email given in the
+// URL. The link is signed for the member, so they don't need to be logged in.
+// Mail clients that unsubscribe in one click send this request themselves.
+func HandleUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	setWantsFromLink(w, r, false)
+}
+
+// HandleResubscribe undoes unsubscribing through a link in an email.
+func HandleResubscribe(w http.ResponseWriter, r *http.Request) {
+	setWantsFromLink(w, r, true)
+}
+
+// checkLink reads the kind of email, member and token from an unsubscribe
+// link. If the link doesn't check out, an error is reported and false is
+// returned.
+func checkLink(w http.ResponseWriter, r *http.Request) (bhap.Notification, bhap.Key, string, bool) {
+	ctx := bhap.RequestContext(r)
+
+	n := bhap.Notification(mux.Vars(r)["category"])
+	if !n.Valid() {
+		http.Error(w, "No such kind of email", http.StatusNotFound)
+		log.Warningf(ctx, "unsubscribe request for unknown kind of email %q", n)
+		return "", "", "", false
+	}
+
+	userKey := bhap.Key(r.FormValue("user"))
+	token := r.FormValue("token")
+	valid, err := bhap.CheckUnsubscribeToken(ctx, userKey, n, token)
+	if err != nil {
+		http.Error(w, "Could not check link", http.StatusInternalServerError)
+		log.Errorf(ctx, "checking unsubscribe token: %v", err)
+		return "", "", "", false
+	}
+	if userKey == "" || !valid {
+		http.Error(w, "This link is not valid. You can choose which emails you get from the settings page.",
+			http.StatusForbidden)
+		log.Warningf(ctx, "unsubscribe request with invalid token denied")
+		return "", "", "", false
+	}
+
+	return n, userKey, token, true
+}
+
+// setWantsFromLink sets whether the member given in an unsubscribe link gets
+// the kind of email in the URL, as long as the link's token checks out.
+func setWantsFromLink(w http.ResponseWriter, r *http.Request, wants bool) {
+	ctx := bhap.RequestContext(r)
+
+	n, userKey, token, ok := checkLink(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := Store.UserByKey(ctx, userKey)
+	if err != nil {
+		http.Error(w, "Could not load user", http.StatusInternalServerError)
+		log.Errorf(ctx, "loading user: %v", err)
+		return
+	}
+
+	user.SetWants(n, wants)
+	if err := Store.PutUser(ctx, userKey, user); err != nil {
+		http.Error(w, "Could not save settings", http.StatusInternalServerError)
+		log.Errorf(ctx, "saving user: %v", err)
+		return
+	}
+
+	if wants {
+		log.Infof(ctx, "resubscribed %v to %v", user.Email, n)
+	} else {
+		log.Infof(ctx, "unsubscribed %v from %v", user.Email, n)
+	}
+
+	showUnsubscribePage(w, r, unsubscribePageFiller{
+		Notification: n,
+		Description:  n.Description(),
+		User:         userKey,
+		Token:        token,
+		Resubscribed: wants,
+	})
+}
+
+// showUnsubscribePage fills in who is logged in and shows the unsubscribe
+// page.
+func showUnsubscribePage(w http.ResponseWriter, r *http.Request, filler unsubscribePageFiller) {
+	ctx := bhap.RequestContext(r)
+
+	currUser, currUserKey, err := bhap.UserFromSession(ctx, Store, r)
+	if err != nil {
+		http.Error(w, "Could not read session", http.StatusInternalServerError)
+		log.Errorf(ctx, "could not get session email: %v", err)
+		return
+	}
+
+	filler.LoggedIn = currUserKey != ""
+	filler.FullName = currUser.FirstName + " " + currUser.LastName
+	showTemplate(ctx, w, unsubscribeTemplate, filler)
+}
@@ -0,0 +1,187 @@
+package pages
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/house-emoji/bhap"
+	"github.com/house-emoji/bhap/email"
+	"github.com/house-emoji/bhap/log"
+)
+
+// HandleResumeDeferred is a task that brings deferred BHAPs back into
+// discussion once their resume date has passed.
+func HandleResumeDeferred(w http.ResponseWriter, r *http.Request) {
+	ctx := bhap.RequestContext(r)
+
+	deferred, err := Store.ByStatus(ctx, bhap.DeferredStatus)
+	if err != nil {
+		http.Error(w, "Could not get deferred BHAPs",
+			http.StatusInternalServerError)
+		log.Errorf(ctx, "getting deferred BHAPs: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for _, b := range deferred {
+		if b.ResumeDate.IsZero() || b.ResumeDate.After(now) {
+			continue
+		}
+
+		_, key, err := Store.ByID(ctx, b.ID)
+		if err != nil {
+			log.Errorf(ctx, "loading BHAP %v: %v", b.ID, err)
+			continue
+		}
+
+		updated, err := bhap.ChangeStatus(ctx, Store, key, b, bhap.DiscussionStatus, bhap.SystemActor)
+		if err != nil {
+			log.Errorf(ctx, "resuming BHAP %v: %v", b.ID, err)
+			continue
+		}
+
+		if err := bhap.CheckVotes(ctx, Store, key, updated); err != nil {
+			log.Errorf(ctx, "checking votes on BHAP %v: %v", b.ID, err)
+		}
+	}
+}
+
+// HandleCloseExpiredVotes is a task that finalizes BHAPs in discussion whose
+// voting deadline has passed, along with ballots whose deadline has passed.
+func HandleCloseExpiredVotes(w http.ResponseWriter, r *http.Request) {
+	ctx := bhap.RequestContext(r)
+
+	discussion, err := Store.ByStatus(ctx, bhap.DiscussionStatus)
+	if err != nil {
+		http.Error(w, "Could not get discussion BHAPs",
+			http.StatusInternalServerError)
+		log.Errorf(ctx, "getting discussion BHAPs: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for _, b := range discussion {
+		// BHAPs on a ballot are decided with the ballot
+		if b.Ballot != "" || !bhap.VotingClosed(b, now) {
+			continue
+		}
+
+		_, key, err := Store.ByID(ctx, b.ID)
+		if err != nil {
+			log.Errorf(ctx, "loading BHAP %v: %v", b.ID, err)
+			continue
+		}
+
+		if err := bhap.CloseVote(ctx, Store, key, b); err != nil {
+			log.Errorf(ctx, "closing vote on BHAP %v: %v", b.ID, err)
+		}
+	}
+
+	ballots, ballotKeys, err := Store.OpenBallots(ctx)
+	if err != nil {
+		http.Error(w, "Could not get open ballots",
+			http.StatusInternalServerError)
+		log.Errorf(ctx, "getting open ballots: %v", err)
+		return
+	}
+
+	for i, ballot := range ballots {
+		if now.Before(ballot.Deadline) {
+			continue
+		}
+
+		if err := bhap.CloseBallot(ctx, Store, ballotKeys[i], ballot); err != nil {
+			log.Errorf(ctx, "closing ballot %v: %v", ballot.UID, err)
+		}
+	}
+}
+
+// HandleSendVoteReminders is a task that reminds members who haven't voted on
+// BHAPs in discussion to vote, following the schedule in the settings. Each
+// reminder on the schedule is only sent once per BHAP.
+func HandleSendVoteReminders(w http.ResponseWriter, r *http.Request) {
+	ctx := bhap.RequestContext(r)
+
+	discussion, err := Store.ByStatus(ctx, bhap.DiscussionStatus)
+	if err != nil {
+		http.Error(w, "Could not get discussion BHAPs",
+			http.StatusInternalServerError)
+		log.Errorf(ctx, "getting discussion BHAPs: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for _, b := range discussion {
+		due := bhap.RemindersDue(b, now)
+		if due <= b.RemindersSent {
+			continue
+		}
+
+		_, key, err := Store.ByID(ctx, b.ID)
+		if err != nil {
+			log.Errorf(ctx, "loading BHAP %v: %v", b.ID, err)
+			continue
+		}
+
+		missing, err := bhap.MissingVoters(ctx, Store, key, b)
+		if err != nil {
+			log.Errorf(ctx, "getting missing voters on BHAP %v: %v", b.ID, err)
+			continue
+		}
+		if err := email.QueueVoteReminders(ctx, b, missing); err != nil {
+			log.Errorf(ctx, "queueing vote reminders for BHAP %v: %v", b.ID, err)
+			continue
+		}
+
+		// Reminders that were missed, like while the task wasn't running,
+		// are rolled into this one
+		b.RemindersSent = due
+		if err := Store.PutBHAP(ctx, key, b); err != nil {
+			log.Errorf(ctx, "saving BHAP %v: %v", b.ID, err)
+		}
+	}
+}
+
+// HandleSendDigests is a task that sends the weekly digest to members who
+// chose to get it. The digest covers what happened since the last one was
+// sent, or the week leading up to when the task runs if none has been. The
+// task does nothing if a digest already went out within the last day, so
+// that running it again doesn't send members the same digest twice.
+func HandleSendDigests(w http.ResponseWriter, r *http.Request) {
+	ctx := bhap.RequestContext(r)
+
+	now := time.Now()
+	since, err := Store.LastDigestDate(ctx)
+	if err != nil {
+		http.Error(w, "Could not get last digest", http.StatusInternalServerError)
+		log.Errorf(ctx, "getting last digest: %v", err)
+		return
+	}
+	if since.IsZero() {
+		since = now.AddDate(0, 0, -7)
+	} else if now.Sub(since) < 24*time.Hour {
+		log.Infof(ctx, "the digest already went out at %v", since)
+		return
+	}
+
+	digest, err := bhap.CollectDigest(ctx, Store, since)
+	if err != nil {
+		http.Error(w, "Could not collect digest", http.StatusInternalServerError)
+		log.Errorf(ctx, "collecting digest: %v", err)
+		return
+	}
+	if digest.Empty() {
+		log.Infof(ctx, "nothing to put in the digest this week")
+	} else if err := email.QueueDigests(ctx, digest); err != nil {
+		http.Error(w, "Could not queue digests", http.StatusInternalServerError)
+		log.Errorf(ctx, "queueing digests: %v", err)
+		return
+	}
+
+	// The next digest picks up from here, even if there was nothing to send
+	if err := Store.RecordDigest(ctx, now); err != nil {
+		http.Error(w, "Could not record digest", http.StatusInternalServerError)
+		log.Errorf(ctx, "recording digest: %v", err)
+		return
+	}
+}
@@ -5,7 +5,7 @@ import (
 	"html/template"
 	"net/http"
 
-	"google.golang.org/appengine/log"
+	"github.com/house-emoji/bhap/log"
 )
 
 // compileTempl wraps the common template compiling pattern. Panics in case of
@@ -0,0 +1,72 @@
+package bhap
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RemindersDue returns how many of the reminders on the schedule in the
+// settings should have gone out by now for a BHAP in discussion. Reminders
+// are counted from when voting started, and none are due for BHAPs on a
+// ballot or whose vote has closed.
+func RemindersDue(b BHAP, now time.Time) int {
+	if b.Status != DiscussionStatus || b.Ballot != "" ||
+		b.VotingDeadline.IsZero() || VotingClosed(b, now) {
+		return 0
+	}
+
+	started := b.VotingDeadline.Add(-b.VotingPeriod())
+	elapsed := now.Sub(started)
+
+	due := 0
+	for _, days := range CurrentSettings().ReminderDays {
+		if elapsed < time.Duration(days)*24*time.Hour {
+			break
+		}
+		due++
+	}
+	return due
+}
+
+// MissingVoters returns the keys of members who are eligible to vote on a
+// BHAP but haven't. Members whose vote is delegated to someone else aren't
+// included, since their vote will be cast for them.
+func MissingVoters(ctx context.Context, s Store, key Key, b BHAP) ([]Key, error) {
+	voters := b.Voters
+	if b.VotersDate.IsZero() {
+		var err error
+		if voters, err = currentVoters(ctx, s, b); err != nil {
+			return nil, err
+		}
+	}
+
+	votes, err := s.AllVotesForBHAP(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("getting votes: %v", err)
+	}
+	voted := make(map[Key]bool)
+	for _, vote := range votes {
+		voted[vote.ByUser] = true
+	}
+
+	now := time.Now()
+	var missing []Key
+	for _, voter := range voters {
+		if voted[voter] {
+			continue
+		}
+
+		delegate, err := DelegateFor(ctx, s, voter, key, now)
+		if err != nil {
+			return nil, err
+		}
+		if delegate != "" {
+			continue
+		}
+
+		missing = append(missing, voter)
+	}
+
+	return missing, nil
+}
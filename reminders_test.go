@@ -0,0 +1,51 @@
+package bhap_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/house-emoji/bhap"
+)
+
+func TestRemindersDue(t *testing.T) {
+	defer bhap.SetSettings(bhap.CurrentSettings())
+	bhap.SetSettings(bhap.DefaultSettings())
+
+	// Voting runs for 14 days, with reminders on days 2, 5 and 9
+	started := time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	discussion := bhap.BHAP{
+		Status:         bhap.DiscussionStatus,
+		VotingDeadline: started.AddDate(0, 0, 14),
+	}
+	onBallot := discussion
+	onBallot.Ballot = "ballot"
+	deferred := discussion
+	deferred.Status = bhap.DeferredStatus
+	longer := discussion
+	longer.VotingPeriodDays = 28
+	longer.VotingDeadline = started.AddDate(0, 0, 28)
+
+	tests := []struct {
+		name string
+		b    bhap.BHAP
+		now  time.Time
+		want int
+	}{
+		{"just started", discussion, started, 0},
+		{"before the first", discussion, started.Add(2*day - time.Second), 0},
+		{"on the first", discussion, started.Add(2 * day), 1},
+		{"between reminders", discussion, started.Add(6 * day), 2},
+		{"after the last", discussion, started.Add(13 * day), 3},
+		{"vote closed", discussion, started.Add(14 * day), 0},
+		{"longer voting period", longer, started.Add(9 * day), 3},
+		{"on a ballot", onBallot, started.Add(6 * day), 0},
+		{"not in discussion", deferred, started.Add(6 * day), 0},
+		{"no deadline", bhap.BHAP{Status: bhap.DiscussionStatus}, started, 0},
+	}
+	for _, test := range tests {
+		if got := bhap.RemindersDue(test.b, test.now); got != test.want {
+			t.Errorf("%v: got %v reminders due, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package bhap
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Revision is the text of a BHAP as of one save. Revisions are never changed
+// or deleted once recorded, so earlier versions of a BHAP can always be
+// compared against later ones.
+type Revision struct {
+	OnBHAP Key
+	// Number orders the revisions of a BHAP, starting from 1
+	Number int
+	// Author is the key of the user who saved the revision
+	Author Key
+	Date   time.Time
+	// Summary is an optional note from the author about what changed
+	Summary          string `datastore:"Summary,noindex"`
+	Title            string `datastore:"Title,noindex"`
+	ShortDescription string `datastore:"ShortDescription,noindex"`
+	// Stored in Markdown
+	Content string `datastore:"Content,noindex"`
+}
+
+// RecordRevision records the current text of a BHAP as the revision given by
+// its Revision field.
+func RecordRevision(ctx context.Context, s Store, key Key, b BHAP, authorKey Key, summary string) error {
+	r := revisionOf(key, b, authorKey, b.LastModified, summary)
+	r.Number = b.Revision
+	if err := s.NewRevision(ctx, r); err != nil {
+		return fmt.Errorf("recording revision: %v", err)
+	}
+	return nil
+}
+
+// SaveRevision saves edits to a BHAP and records them as a new revision. The
+// original is the BHAP as it was before the edits. If the BHAP predates
+// revisions, the original is recorded first so that the edits can be
+// compared against it.
+//
+// If the edits change what is being voted on, the votes already cast are
+// dealt with by outdateVotes. The saved BHAP is returned, along with the
+// votes whose voters should be told about the edits.
+func SaveRevision(ctx context.Context, s Store, key Key, original, edited BHAP, authorKey Key, summary string) (BHAP, []Vote, error) {
+	revisions, err := s.RevisionsForBHAP(ctx, key)
+	if err != nil {
+		return BHAP{}, nil, fmt.Errorf("getting revisions: %v", err)
+	}
+
+	var toRecord []Revision
+	if len(revisions) == 0 {
+		toRecord = append(toRecord, revisionOf(key, original, original.Author,
+			original.LastModified, ""))
+	}
+
+	substantive := changesVote(original, edited)
+	edited.LastModified = time.Now()
+	toRecord = append(toRecord, revisionOf(key, edited, authorKey,
+		edited.LastModified, summary))
+
+	// The store numbers the revision, so that edits saved at the same time
+	// can't both claim the same number
+	edited, err = s.SaveRevision(ctx, key, edited, substantive, toRecord...)
+	if err != nil {
+		return BHAP{}, nil, fmt.Errorf("saving BHAP: %v", err)
+	}
+
+	if !substantive {
+		return edited, nil, nil
+	}
+	outdated, err := outdateVotes(ctx, s, key, edited, original.SubstantiveRevision)
+	if err != nil {
+		return BHAP{}, nil, err
+	}
+
+	return edited, outdated, nil
+}
+
+// Outdated returns true if the vote was cast on a revision of the BHAP from
+// before its author last changed what is being voted on.
+func (v Vote) Outdated(b BHAP) bool {
+	return v.Value != RankedVote && v.Revision < b.SubstantiveRevision
+}
+
+// outdateVotes deals with the votes on a BHAP in discussion after its author
+// changed what is being voted on. Depending on the settings, the votes are
+// either thrown out or kept and marked as cast on an older revision. The
+// votes that were current up until the edit are returned. Ranked votes are
+// left alone, since they rank the whole ballot.
+func outdateVotes(ctx context.Context, s Store, key Key, b BHAP, previous int) ([]Vote, error) {
+	if b.Status != DiscussionStatus || b.Ballot != "" {
+		return nil, nil
+	}
+
+	votes, err := s.AllVotesForBHAP(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("getting votes: %v", err)
+	}
+
+	var current []Vote
+	for _, vote := range votes {
+		if vote.Revision >= previous {
+			current = append(current, vote)
+		}
+	}
+
+	if CurrentSettings().ResetVotesOnEdit && len(votes) > 0 {
+		if err := ClearVotes(ctx, s, key); err != nil {
+			return nil, err
+		}
+	}
+
+	return current, nil
+}
+
+// changesVote returns true if edits to a BHAP change what is being voted on.
+// Changes to spacing alone don't count.
+func changesVote(original, edited BHAP) bool {
+	if !sameWords(original.Title, edited.Title) ||
+		!sameWords(original.ShortDescription, edited.ShortDescription) ||
+		!sameWords(original.Content, edited.Content) {
+		return true
+	}
+
+	if len(original.Replaces) != len(edited.Replaces) {
+		return true
+	}
+	for i := range original.Replaces {
+		if original.Replaces[i] != edited.Replaces[i] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sameWords returns true if two texts are the same apart from spacing.
+func sameWords(a, b string) bool {
+	return strings.Join(strings.Fields(a), " ") == strings.Join(strings.Fields(b), " ")
+}
+
+// revisionOf returns a revision holding the text of a BHAP. It is left for
+// the caller to number.
+func revisionOf(key Key, b BHAP, authorKey Key, date time.Time, summary string) Revision {
+	return Revision{
+		OnBHAP:           key,
+		Author:           authorKey,
+		Date:             date,
+		Summary:          summary,
+		Title:            b.Title,
+		ShortDescription: b.ShortDescription,
+		Content:          b.Content,
+	}
+}
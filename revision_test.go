@@ -0,0 +1,63 @@
+package bhap
+
+import "testing"
+
+func TestChangesVote(t *testing.T) {
+	original := BHAP{
+		Title:            "Quiet hours",
+		ShortDescription: "No noise after ten",
+		Content:          "Be quiet after ten.\n\nThanks.",
+		Replaces:         []Key{"r1"},
+	}
+
+	tests := []struct {
+		name string
+		edit func(b *BHAP)
+		want bool
+	}{
+		{"no change", func(b *BHAP) {}, false},
+		{"spacing", func(b *BHAP) {
+			b.Content = "Be  quiet after ten.\n\n\nThanks.\n"
+		}, false},
+		{"title", func(b *BHAP) { b.Title = "Quieter hours" }, true},
+		{"short description", func(b *BHAP) {
+			b.ShortDescription = "No noise after eleven"
+		}, true},
+		{"content", func(b *BHAP) { b.Content = "Be quiet after ten." }, true},
+		{"replaces another", func(b *BHAP) { b.Replaces = []Key{"r1", "r2"} }, true},
+		{"replaces a different one", func(b *BHAP) { b.Replaces = []Key{"r2"} }, true},
+		{"replaces nothing", func(b *BHAP) { b.Replaces = nil }, true},
+	}
+
+	for _, test := range tests {
+		edited := original
+		edited.Replaces = append([]Key(nil), original.Replaces...)
+		test.edit(&edited)
+
+		if got := changesVote(original, edited); got != test.want {
+			t.Errorf("%v: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestVoteOutdated(t *testing.T) {
+	b := BHAP{Revision: 4, SubstantiveRevision: 3}
+
+	tests := []struct {
+		vote Vote
+		want bool
+	}{
+		{Vote{Value: AcceptedStatus, Revision: 2}, true},
+		{Vote{Value: AcceptedStatus, Revision: 3}, false},
+		{Vote{Value: RejectedStatus, Revision: 4}, false},
+		// Ranked votes rank the whole ballot, not one revision of an option
+		{Vote{Value: RankedVote, Revision: 1}, false},
+	}
+
+	for _, test := range tests {
+		if got := test.vote.Outdated(b); got != test.want {
+			t.Errorf("%v vote on revision %v: got %v, want %v",
+				test.vote.Value, test.vote.Revision, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package bhap
+
+import "fmt"
+
+// Threshold is the share of cast votes that must accept a BHAP for it to
+// pass.
+type Threshold string
+
+const (
+	// SimpleMajority needs more accept votes than reject votes.
+	SimpleMajority Threshold = "simple majority"
+	// TwoThirds needs at least two thirds of the votes to accept.
+	TwoThirds Threshold = "two-thirds"
+	// Unanimous needs every vote to accept.
+	Unanimous Threshold = "unanimous"
+)
+
+// VotingRule decides whether a BHAP passes based on its votes.
+type VotingRule struct {
+	Threshold Threshold `json:"threshold"`
+	// Quorum is the fraction of eligible members who must have voted when
+	// the vote closes for the result to stand. BHAPs that miss it are
+	// rejected.
+	Quorum float64 `json:"quorum"`
+}
+
+// defaultVotingRule applies to BHAP types without a rule of their own.
+var defaultVotingRule = VotingRule{Threshold: SimpleMajority, Quorum: 0.5}
+
+// RuleFor returns the voting rule in effect for BHAPs of the given type.
+func RuleFor(typ BHAPType) VotingRule {
+	if rule, ok := CurrentSettings().VotingRules[typ]; ok {
+		return rule
+	}
+	return defaultVotingRule
+}
+
+// QuorumMet returns true if enough members voted for the result to stand.
+func (r VotingRule) QuorumMet(t Tally) bool {
+	if t.Cast() == 0 {
+		return false
+	}
+	return float64(t.Cast()) >= r.Quorum*float64(t.Eligible)
+}
+
+// Passes returns true if the votes cast are enough to accept the BHAP.
+// Abstentions and the quorum are not considered.
+func (r VotingRule) Passes(t Tally) bool {
+	switch r.Threshold {
+	case TwoThirds:
+		return t.Accepted > 0 && 3*t.Accepted >= 2*t.Decisive()
+	case Unanimous:
+		return t.Accepted > 0 && t.Rejected == 0
+	default:
+		return t.Accepted > t.Rejected
+	}
+}
+
+func (r VotingRule) String() string {
+	var share string
+	switch r.Threshold {
+	case TwoThirds:
+		share = "at least two thirds of the votes cast"
+	case Unanimous:
+		share = "every vote cast"
+	default:
+		share = "a majority of the votes cast"
+	}
+
+	if r.Quorum <= 0 {
+		return fmt.Sprintf("Accepted by %v, not counting abstentions", share)
+	}
+	return fmt.Sprintf("Accepted by %v, not counting abstentions, with at "+
+		"least %.0f%% of members voting", share, r.Quorum*100)
+}
+
+// validate checks that the rule makes sense.
+func (r VotingRule) validate() error {
+	switch r.Threshold {
+	case SimpleMajority, TwoThirds, Unanimous:
+	default:
+		return fmt.Errorf("unknown threshold %q", r.Threshold)
+	}
+
+	if r.Quorum < 0 || r.Quorum > 1 {
+		return fmt.Errorf("quorum %v is not between 0 and 1", r.Quorum)
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package bhap
+
+import "testing"
+
+func TestVotingRulePasses(t *testing.T) {
+	tests := []struct {
+		threshold Threshold
+		tally     Tally
+		want      bool
+	}{
+		{SimpleMajority, Tally{Accepted: 2, Rejected: 1}, true},
+		{SimpleMajority, Tally{Accepted: 1, Rejected: 1}, false},
+		{SimpleMajority, Tally{Accepted: 1, Rejected: 2}, false},
+		{SimpleMajority, Tally{Accepted: 1, Abstained: 5}, true},
+		{SimpleMajority, Tally{Abstained: 3}, false},
+		{SimpleMajority, Tally{}, false},
+
+		{TwoThirds, Tally{Accepted: 2, Rejected: 1}, true},
+		{TwoThirds, Tally{Accepted: 3, Rejected: 2}, false},
+		{TwoThirds, Tally{Accepted: 2, Rejected: 1, Abstained: 4}, true},
+		{TwoThirds, Tally{Accepted: 1, Rejected: 1, Abstained: 4}, false},
+		{TwoThirds, Tally{Abstained: 3}, false},
+		{TwoThirds, Tally{}, false},
+
+		{Unanimous, Tally{Accepted: 3}, true},
+		{Unanimous, Tally{Accepted: 3, Rejected: 1}, false},
+		{Unanimous, Tally{Accepted: 1, Abstained: 2}, true},
+		{Unanimous, Tally{Abstained: 3}, false},
+		{Unanimous, Tally{}, false},
+	}
+
+	for _, test := range tests {
+		rule := VotingRule{Threshold: test.threshold}
+		if got := rule.Passes(test.tally); got != test.want {
+			t.Errorf("%v with %+v: got %v, want %v",
+				test.threshold, test.tally, got, test.want)
+		}
+	}
+}
+
+func TestVotingRuleQuorumMet(t *testing.T) {
+	tests := []struct {
+		quorum float64
+		tally  Tally
+		want   bool
+	}{
+		{0.5, Tally{Accepted: 1, Rejected: 1, Eligible: 4}, true},
+		{0.5, Tally{Accepted: 1, Eligible: 4}, false},
+		{0.5, Tally{Accepted: 1, Abstained: 1, Eligible: 4}, true},
+		{0.5, Tally{Abstained: 2, Eligible: 4}, true},
+		{0.5, Tally{Accepted: 2, Eligible: 5}, false},
+		{1, Tally{Accepted: 2, Rejected: 1, Eligible: 3}, true},
+		{1, Tally{Accepted: 2, Eligible: 3}, false},
+		{0, Tally{Accepted: 1, Eligible: 10}, true},
+		// A vote nobody took part in never stands
+		{0, Tally{Eligible: 10}, false},
+		{0.5, Tally{}, false},
+	}
+
+	for _, test := range tests {
+		rule := VotingRule{Threshold: SimpleMajority, Quorum: test.quorum}
+		if got := rule.QuorumMet(test.tally); got != test.want {
+			t.Errorf("quorum %v with %+v: got %v, want %v",
+				test.quorum, test.tally, got, test.want)
+		}
+	}
+}
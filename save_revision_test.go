@@ -0,0 +1,108 @@
+package bhap_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/house-emoji/bhap"
+	"github.com/house-emoji/bhap/memstore"
+)
+
+func TestSaveRevisionPredatingRevisions(t *testing.T) {
+	ctx := context.Background()
+	s := memstore.New()
+	author := newTestUser(t, s, "author@example.com")
+	original := bhap.BHAP{ID: 100, Author: author, Content: "Be quiet."}
+	bhapKey := newTestBHAP(t, s, original)
+
+	edited := original
+	edited.Content = "Be very quiet."
+	saved, _, err := bhap.SaveRevision(ctx, s, bhapKey, original, edited, author, "Stronger")
+	if err != nil {
+		t.Fatalf("saving revision: %v", err)
+	}
+	if saved.Revision != 2 || saved.SubstantiveRevision != 2 {
+		t.Errorf("saved BHAP at revision %v, substantive revision %v, want 2 and 2",
+			saved.Revision, saved.SubstantiveRevision)
+	}
+
+	revisions, err := s.RevisionsForBHAP(ctx, bhapKey)
+	if err != nil {
+		t.Fatalf("getting revisions: %v", err)
+	}
+	if len(revisions) != 2 {
+		t.Fatalf("got %v revisions, want the original and the edits", len(revisions))
+	}
+	if r := revisions[0]; r.Number != 1 || r.Content != "Be quiet." {
+		t.Errorf("got first revision %+v, want the original numbered 1", r)
+	}
+	if r := revisions[1]; r.Number != 2 || r.Content != "Be very quiet." || r.Summary != "Stronger" {
+		t.Errorf("got second revision %+v, want the edits numbered 2", r)
+	}
+}
+
+func TestSaveRevisionConcurrent(t *testing.T) {
+	ctx := context.Background()
+	s := memstore.New()
+	author := newTestUser(t, s, "author@example.com")
+	coAuthor := newTestUser(t, s, "coauthor@example.com")
+	original := bhap.BHAP{
+		ID:        100,
+		Author:    author,
+		CoAuthors: []bhap.Key{coAuthor},
+		Content:   "Be quiet.",
+		Revision:  1,
+	}
+	bhapKey := newTestBHAP(t, s, original)
+	if err := bhap.RecordRevision(ctx, s, bhapKey, original, author, ""); err != nil {
+		t.Fatalf("recording revision: %v", err)
+	}
+
+	// Both authors start editing from the same revision and save at once
+	editors := []bhap.Key{author, coAuthor}
+	saved := make([]bhap.BHAP, len(editors))
+	errs := make([]error, len(editors))
+	var wg sync.WaitGroup
+	for i, editor := range editors {
+		wg.Add(1)
+		go func(i int, editor bhap.Key) {
+			defer wg.Done()
+			edited := original
+			edited.Content = "Be quiet, says " + string(editor)
+			saved[i], _, errs[i] = bhap.SaveRevision(ctx, s, bhapKey, original,
+				edited, editor, "")
+		}(i, editor)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("saving revision: %v", err)
+		}
+	}
+	if saved[0].Revision == saved[1].Revision {
+		t.Errorf("both edits were saved as revision %v", saved[0].Revision)
+	}
+
+	revisions, err := s.RevisionsForBHAP(ctx, bhapKey)
+	if err != nil {
+		t.Fatalf("getting revisions: %v", err)
+	}
+	if len(revisions) != 3 {
+		t.Fatalf("got %v revisions, want the original and both edits", len(revisions))
+	}
+	latest := revisions[2]
+	if latest.Number != 3 {
+		t.Errorf("got latest revision numbered %v, want 3", latest.Number)
+	}
+
+	got, err := s.ByKey(ctx, bhapKey)
+	if err != nil {
+		t.Fatalf("getting BHAP: %v", err)
+	}
+	if got.Revision != 3 || got.Content != latest.Content {
+		t.Errorf("got BHAP at revision %v with content %q, want it to match the latest revision",
+			got.Revision, got.Content)
+	}
+}
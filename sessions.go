@@ -7,35 +7,40 @@ import (
 
 	cascadestore "github.com/dsoprea/goappenginesessioncascade"
 	"github.com/gorilla/sessions"
-	"google.golang.org/appengine/datastore"
 )
 
-var sessionStore *cascadestore.CascadeStore
+var sessionStore sessions.Store
 
 func init() {
 	sessionStore = cascadestore.NewCascadeStore(
 		cascadestore.DistributedBackends, []byte("23c124b173d"))
 }
 
+// SetSessionStore replaces the store that login sessions are kept in. By
+// default, sessions are kept in App Engine's memcache and Datastore.
+func SetSessionStore(store sessions.Store) {
+	sessionStore = store
+}
+
 func GetSession(r *http.Request) (*sessions.Session, error) {
 	return sessionStore.Get(r, "login")
 }
 
 // UserFromSession gets the currently logged in User based on session
-// information. If no user is logged in, the returned key will be nil.
-func UserFromSession(ctx context.Context, r *http.Request) (User, *datastore.Key, error) {
+// information. If no user is logged in, the returned key will be empty.
+func UserFromSession(ctx context.Context, s UserStore, r *http.Request) (User, Key, error) {
 	loginSession, err := sessionStore.Get(r, "login")
 	if err != nil {
-		return User{}, nil, fmt.Errorf("could not decode session: %v", err)
+		return User{}, "", fmt.Errorf("could not decode session: %v", err)
 	}
 
 	if loginSession.IsNew {
-		return User{}, nil, nil
+		return User{}, "", nil
 	}
 
 	email := loginSession.Values["email"].(string)
 
-	return UserByEmail(ctx, email)
+	return s.UserByEmail(ctx, email)
 }
 
 // DeleteSession deletes the current session information.
@@ -0,0 +1,116 @@
+package bhap
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Settings are house-wide options that change how BHAPs move through the
+// process.
+type Settings struct {
+	// KeepVotesOnDefer keeps the votes cast on a BHAP when it is deferred,
+	// so that they still count once it's resumed. Otherwise, members vote
+	// again from scratch.
+	KeepVotesOnDefer bool `json:"keepVotesOnDefer"`
+	// VotingPeriodDays is how long BHAPs stay in discussion before their
+	// vote closes, unless the author chooses otherwise.
+	VotingPeriodDays int `json:"votingPeriodDays"`
+	// VotingRules decide whether BHAPs of each type pass. Types that
+	// aren't listed need a simple majority with half of members voting.
+	VotingRules map[BHAPType]VotingRule `json:"votingRules"`
+	// ResetVotesOnEdit throws out the votes cast on a BHAP in discussion
+	// when its author changes what is being voted on. Otherwise, the votes
+	// still count, but are marked as cast on an older revision.
+	ResetVotesOnEdit bool `json:"resetVotesOnEdit"`
+	// ReminderDays is how many days into discussion members who haven't
+	// voted on a BHAP are reminded to, in increasing order. No reminders
+	// are sent if it is empty.
+	ReminderDays []int `json:"reminderDays"`
+	// Admins lists the emails of members who are made admins the next time
+	// they log in. Otherwise, only the first member to register is made one,
+	// so houses started before there were admins need this to get theirs.
+	Admins []string `json:"admins"`
+}
+
+// DefaultSettings returns the settings used when none are configured.
+func DefaultSettings() Settings {
+	return Settings{
+		KeepVotesOnDefer: true,
+		VotingPeriodDays: 14,
+		ReminderDays:     []int{2, 5, 9},
+		VotingRules: map[BHAPType]VotingRule{
+			// Changes to the process itself need broader support
+			MetaBHAPType:      {Threshold: TwoThirds, Quorum: 0.5},
+			HouseRuleBHAPType: {Threshold: SimpleMajority, Quorum: 0.5},
+		},
+	}
+}
+
+var (
+	settingsMu      sync.RWMutex
+	currentSettings = DefaultSettings()
+)
+
+// CurrentSettings returns the settings in effect.
+func CurrentSettings() Settings {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+
+	return currentSettings
+}
+
+// SetSettings replaces the settings in effect.
+func SetSettings(s Settings) {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+
+	currentSettings = s
+}
+
+// LoadSettingsFile reads settings from a JSON file. Any setting missing from
+// the file keeps its default value.
+func LoadSettingsFile(filename string) (Settings, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return Settings{}, err
+	}
+	defer f.Close()
+
+	s := DefaultSettings()
+	if err := json.NewDecoder(f).Decode(&s); err != nil {
+		return Settings{}, fmt.Errorf("decoding settings: %v", err)
+	}
+
+	if err := s.validate(); err != nil {
+		return Settings{}, err
+	}
+
+	return s, nil
+}
+
+// validate returns an error if any setting is out of range.
+func (s Settings) validate() error {
+	if s.VotingPeriodDays <= 0 {
+		return fmt.Errorf("voting period must be at least a day, not %v days",
+			s.VotingPeriodDays)
+	}
+
+	for i, days := range s.ReminderDays {
+		if days < 0 || (i > 0 && days <= s.ReminderDays[i-1]) {
+			return fmt.Errorf("reminder days must be increasing and not negative")
+		}
+	}
+
+	for typ, rule := range s.VotingRules {
+		if _, err := FirstID(typ); err != nil {
+			return fmt.Errorf("voting rule for unknown BHAP type %q", typ)
+		}
+		if err := rule.validate(); err != nil {
+			return fmt.Errorf("voting rule for %v BHAPs: %v", typ, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package bhap
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadSettingsFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "settings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name    string
+		json    string
+		wantErr bool
+	}{
+		{"empty", `{}`, false},
+		{"voting period", `{"votingPeriodDays": 7}`, false},
+		{"zero voting period", `{"votingPeriodDays": 0}`, true},
+		{"negative voting period", `{"votingPeriodDays": -3}`, true},
+		{"reminders", `{"reminderDays": [0, 1, 6]}`, false},
+		{"no reminders", `{"reminderDays": []}`, false},
+		{"negative reminder", `{"reminderDays": [-1, 2]}`, true},
+		{"repeated reminder", `{"reminderDays": [2, 2]}`, true},
+		{"decreasing reminders", `{"reminderDays": [5, 2]}`, true},
+		{"voting rule", `{"votingRules": {"Meta": {"threshold": "unanimous", "quorum": 1}}}`, false},
+		{"unknown threshold", `{"votingRules": {"Meta": {"threshold": "most", "quorum": 0.5}}}`, true},
+		{"negative quorum", `{"votingRules": {"Meta": {"threshold": "simple majority", "quorum": -0.1}}}`, true},
+		{"quorum over 1", `{"votingRules": {"Meta": {"threshold": "simple majority", "quorum": 1.5}}}`, true},
+		{"unknown type", `{"votingRules": {"Chores": {"threshold": "simple majority", "quorum": 0.5}}}`, true},
+		{"bad JSON", `{"votingPeriodDays": }`, true},
+	}
+
+	for _, test := range tests {
+		filename := filepath.Join(dir, "settings.json")
+		if err := ioutil.WriteFile(filename, []byte(test.json), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := LoadSettingsFile(filename)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("%v: got error %v, want error: %v", test.name, err, test.wantErr)
+		}
+	}
+}
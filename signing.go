@@ -0,0 +1,58 @@
+package bhap
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+)
+
+// Signer signs data so that the site can later tell that it made the
+// signature, like for links in emails that act on a member's behalf without
+// them logging in.
+type Signer interface {
+	// Sign returns a signature of the data.
+	Sign(ctx context.Context, data []byte) ([]byte, error)
+	// Verify returns true if the signature is one the signer made of the
+	// data.
+	Verify(ctx context.Context, data, signature []byte) (bool, error)
+}
+
+// HMACSigner signs data with a secret key.
+type HMACSigner struct {
+	Key []byte
+}
+
+// Sign returns a signature of the data.
+func (s HMACSigner) Sign(ctx context.Context, data []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, s.Key)
+	mac.Write(data)
+	return mac.Sum(nil), nil
+}
+
+// Verify returns true if the signature is one the signer made of the data.
+func (s HMACSigner) Verify(ctx context.Context, data, signature []byte) (bool, error) {
+	expected, err := s.Sign(ctx, data)
+	if err != nil {
+		return false, err
+	}
+	return hmac.Equal(signature, expected), nil
+}
+
+var signer Signer
+
+// By default, data is signed with a key that only lasts until the app
+// restarts
+func init() {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	signer = HMACSigner{Key: key}
+}
+
+// SetSigner replaces what signs links in emails, so that links already sent
+// keep working across restarts and instances.
+func SetSigner(s Signer) {
+	signer = s
+}
@@ -0,0 +1,252 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/house-emoji/bhap"
+)
+
+// ballotColumns lists the columns scanned by scanBallot, in order.
+const ballotColumns = `id, uid, title, created_by_id, created_date, deadline,
+	closed, winner_id, quorum`
+
+// scanBallot scans a row made up of ballotColumns. Options are loaded
+// separately.
+func scanBallot(row scanner) (bhap.Ballot, bhap.Key, error) {
+	var b bhap.Ballot
+	var id int64
+	var createdByID, winnerID sql.NullInt64
+
+	err := row.Scan(&id, &b.UID, &b.Title, &createdByID, &b.CreatedDate,
+		&b.Deadline, &b.Closed, &winnerID, &b.Quorum)
+	if err != nil {
+		return bhap.Ballot{}, "", err
+	}
+	b.CreatedBy = nullKeyOf(createdByID)
+	b.Winner = nullKeyOf(winnerID)
+
+	return b, keyOf(id), nil
+}
+
+// queryBallots runs a query that selects ballotColumns and returns every
+// result along with its key.
+func (s *Store) queryBallots(ctx context.Context, query string, args ...interface{}) ([]bhap.Ballot, []bhap.Key, error) {
+	rows, err := s.query(ctx, s.db, query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var results []bhap.Ballot
+	var keys []bhap.Key
+	for rows.Next() {
+		b, key, err := scanBallot(rows)
+		if err != nil {
+			return nil, nil, err
+		}
+		results = append(results, b)
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	// Finish with the rows before making more queries, since SQLite only
+	// has the one connection
+	rows.Close()
+
+	for i := range results {
+		if err := s.loadBallotOptions(ctx, keys[i], &results[i]); err != nil {
+			return nil, nil, fmt.Errorf("loading ballot options: %v", err)
+		}
+	}
+
+	return results, keys, nil
+}
+
+// loadBallotOptions fills in the options of a ballot.
+func (s *Store) loadBallotOptions(ctx context.Context, key bhap.Key, b *bhap.Ballot) error {
+	id, err := idOf(key)
+	if err != nil {
+		return err
+	}
+
+	rows, err := s.query(ctx, s.db,
+		`SELECT bhap_id, counted FROM ballot_options
+		WHERE ballot_id = ? ORDER BY position`,
+		id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	b.Options = nil
+	b.Counted = nil
+	for rows.Next() {
+		var bhapID int64
+		var counted bool
+		if err := rows.Scan(&bhapID, &counted); err != nil {
+			return err
+		}
+		b.Options = append(b.Options, keyOf(bhapID))
+		if counted {
+			b.Counted = append(b.Counted, keyOf(bhapID))
+		}
+	}
+
+	return rows.Err()
+}
+
+// saveBallotOptions records the options of a ballot, overwriting what was
+// recorded before.
+func (s *Store) saveBallotOptions(ctx context.Context, tx *sql.Tx, ballotID int64, b bhap.Ballot) error {
+	_, err := s.exec(ctx, tx,
+		`DELETE FROM ballot_options WHERE ballot_id = ?`, ballotID)
+	if err != nil {
+		return err
+	}
+
+	counted := make(map[bhap.Key]bool)
+	for _, key := range b.Counted {
+		counted[key] = true
+	}
+
+	for i, key := range b.Options {
+		bhapID, err := idOf(key)
+		if err != nil {
+			return err
+		}
+
+		_, err = s.exec(ctx, tx,
+			`INSERT INTO ballot_options (ballot_id, bhap_id, position, counted)
+			VALUES (?, ?, ?, ?)`,
+			ballotID, bhapID, i, counted[key])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// BallotByKey returns the ballot with the given key.
+func (s *Store) BallotByKey(ctx context.Context, key bhap.Key) (bhap.Ballot, error) {
+	id, err := idOf(key)
+	if err != nil {
+		return bhap.Ballot{}, err
+	}
+
+	results, _, err := s.queryBallots(ctx,
+		`SELECT `+ballotColumns+` FROM ballots WHERE id = ?`,
+		id)
+	if err != nil {
+		return bhap.Ballot{}, fmt.Errorf("getting ballot: %v", err)
+	}
+	if len(results) == 0 {
+		return bhap.Ballot{}, fmt.Errorf("no ballot with key %v", key)
+	}
+
+	return results[0], nil
+}
+
+// BallotByUID returns the ballot with the given UID.
+func (s *Store) BallotByUID(ctx context.Context, uid string) (bhap.Ballot, bhap.Key, error) {
+	results, keys, err := s.queryBallots(ctx,
+		`SELECT `+ballotColumns+` FROM ballots WHERE uid = ?`,
+		uid)
+	if err != nil {
+		return bhap.Ballot{}, "", fmt.Errorf("by UID %v: %v", uid, err)
+	}
+	if len(results) == 0 {
+		return bhap.Ballot{}, "", nil
+	}
+
+	return results[0], keys[0], nil
+}
+
+// OpenBallots returns all ballots that have yet to be decided.
+func (s *Store) OpenBallots(ctx context.Context) ([]bhap.Ballot, []bhap.Key, error) {
+	results, keys, err := s.queryBallots(ctx,
+		`SELECT `+ballotColumns+` FROM ballots WHERE closed = ? ORDER BY id`,
+		false)
+	if err != nil {
+		return nil, nil, fmt.Errorf("finding open ballots: %v", err)
+	}
+
+	return results, keys, nil
+}
+
+// NewBallot saves a new ballot and returns its key.
+func (s *Store) NewBallot(ctx context.Context, b bhap.Ballot) (bhap.Key, error) {
+	createdByID, err := nullIDOf(b.CreatedBy)
+	if err != nil {
+		return "", err
+	}
+	winnerID, err := nullIDOf(b.Winner)
+	if err != nil {
+		return "", err
+	}
+
+	var key bhap.Key
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		key, err = s.insert(ctx, tx,
+			`INSERT INTO ballots (uid, title, created_by_id, created_date,
+				deadline, closed, winner_id, quorum)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			RETURNING id`,
+			b.UID, b.Title, createdByID, b.CreatedDate.UTC(),
+			b.Deadline.UTC(), b.Closed, winnerID, b.Quorum)
+		if err != nil {
+			return err
+		}
+
+		id, err := idOf(key)
+		if err != nil {
+			return err
+		}
+		return s.saveBallotOptions(ctx, tx, id, b)
+	})
+	if err != nil {
+		return "", fmt.Errorf("saving new ballot: %v", err)
+	}
+
+	return key, nil
+}
+
+// PutBallot saves changes to an existing ballot.
+func (s *Store) PutBallot(ctx context.Context, key bhap.Key, b bhap.Ballot) error {
+	id, err := idOf(key)
+	if err != nil {
+		return err
+	}
+	createdByID, err := nullIDOf(b.CreatedBy)
+	if err != nil {
+		return err
+	}
+	winnerID, err := nullIDOf(b.Winner)
+	if err != nil {
+		return err
+	}
+
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		_, err := s.exec(ctx, tx,
+			`UPDATE ballots SET uid = ?, title = ?, created_by_id = ?,
+				created_date = ?, deadline = ?, closed = ?, winner_id = ?,
+				quorum = ?
+			WHERE id = ?`,
+			b.UID, b.Title, createdByID, b.CreatedDate.UTC(),
+			b.Deadline.UTC(), b.Closed, winnerID, b.Quorum, id)
+		if err != nil {
+			return err
+		}
+
+		return s.saveBallotOptions(ctx, tx, id, b)
+	})
+	if err != nil {
+		return fmt.Errorf("saving ballot: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,586 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/house-emoji/bhap"
+)
+
+// bhapColumns lists the columns scanned by scanBHAP, in order.
+const bhapColumns = `id, draft_id, number, title, short_description,
+	last_modified, author_id, status, created_date, type, content,
+	defer_reason, resume_date, replaced_by_id, voting_period_days,
+	voting_deadline, ballot_id, secret_ballot, voters_date, revision,
+	substantive_revision, reminders_sent, decided_date`
+
+// scanner is something a row can be scanned from.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanBHAP scans a row made up of bhapColumns.
+func scanBHAP(row scanner) (bhap.BHAP, bhap.Key, error) {
+	var b bhap.BHAP
+	var id int64
+	var authorID, replacedByID, ballotID sql.NullInt64
+	var resumeDate, votingDeadline, votersDate, decidedDate *time.Time
+
+	err := row.Scan(&id, &b.DraftID, &b.ID, &b.Title, &b.ShortDescription,
+		&b.LastModified, &authorID, &b.Status, &b.CreatedDate, &b.Type,
+		&b.Content, &b.DeferReason, &resumeDate, &replacedByID,
+		&b.VotingPeriodDays, &votingDeadline, &ballotID, &b.SecretBallot,
+		&votersDate, &b.Revision, &b.SubstantiveRevision, &b.RemindersSent,
+		&decidedDate)
+	if err != nil {
+		return bhap.BHAP{}, "", err
+	}
+	b.Author = nullKeyOf(authorID)
+	b.ResumeDate = timeOrZero(resumeDate)
+	b.ReplacedBy = nullKeyOf(replacedByID)
+	b.VotingDeadline = timeOrZero(votingDeadline)
+	b.Ballot = nullKeyOf(ballotID)
+	b.VotersDate = timeOrZero(votersDate)
+	b.DecidedDate = timeOrZero(decidedDate)
+
+	return b, keyOf(id), nil
+}
+
+// queryBHAPs runs a query that selects bhapColumns and returns every result.
+func (s *Store) queryBHAPs(ctx context.Context, query string, args ...interface{}) ([]bhap.BHAP, error) {
+	results, _, err := s.queryKeyedBHAPs(ctx, query, args...)
+	return results, err
+}
+
+// queryKeyedBHAPs runs a query that selects bhapColumns and returns every
+// result along with its key.
+func (s *Store) queryKeyedBHAPs(ctx context.Context, query string, args ...interface{}) ([]bhap.BHAP, []bhap.Key, error) {
+	rows, err := s.query(ctx, s.db, query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	results := make([]bhap.BHAP, 0)
+	keys := make([]bhap.Key, 0)
+	for rows.Next() {
+		b, key, err := scanBHAP(rows)
+		if err != nil {
+			return nil, nil, err
+		}
+		results = append(results, b)
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	// Finish with the rows before making more queries, since SQLite only
+	// has the one connection
+	rows.Close()
+
+	if err := s.loadReplaces(ctx, results, keys); err != nil {
+		return nil, nil, fmt.Errorf("loading replaced BHAPs: %v", err)
+	}
+	if err := s.loadVoters(ctx, results, keys); err != nil {
+		return nil, nil, fmt.Errorf("loading voters: %v", err)
+	}
+	if err := s.loadCoAuthors(ctx, results, keys); err != nil {
+		return nil, nil, fmt.Errorf("loading co-authors: %v", err)
+	}
+
+	return results, keys, nil
+}
+
+// queryBHAP runs a query that selects bhapColumns and returns the first
+// result. If there are no results, the key will be empty.
+func (s *Store) queryBHAP(ctx context.Context, query string, args ...interface{}) (bhap.BHAP, bhap.Key, error) {
+	results, keys, err := s.queryKeyedBHAPs(ctx, query, args...)
+	if err != nil {
+		return bhap.BHAP{}, "", err
+	}
+	if len(results) == 0 {
+		return bhap.BHAP{}, "", nil
+	}
+
+	return results[0], keys[0], nil
+}
+
+// loadReplaces fills in the Replaces field of each of the given BHAPs.
+func (s *Store) loadReplaces(ctx context.Context, bhaps []bhap.BHAP, keys []bhap.Key) error {
+	if len(bhaps) == 0 {
+		return nil
+	}
+
+	indexes := make(map[bhap.Key]int)
+	params := make([]string, len(keys))
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		id, err := idOf(key)
+		if err != nil {
+			return err
+		}
+		indexes[key] = i
+		params[i] = "?"
+		args[i] = id
+	}
+
+	rows, err := s.query(ctx, s.db,
+		`SELECT bhap_id, replaced_id FROM bhap_replacements
+		WHERE bhap_id IN (`+strings.Join(params, ", ")+`)
+		ORDER BY bhap_id, position`,
+		args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bhapID, replacedID int64
+		if err := rows.Scan(&bhapID, &replacedID); err != nil {
+			return err
+		}
+		i := indexes[keyOf(bhapID)]
+		bhaps[i].Replaces = append(bhaps[i].Replaces, keyOf(replacedID))
+	}
+
+	return rows.Err()
+}
+
+// saveReplaces records which BHAPs a BHAP replaces, overwriting what was
+// recorded before.
+func (s *Store) saveReplaces(ctx context.Context, tx *sql.Tx, bhapID int64, replaces []bhap.Key) error {
+	_, err := s.exec(ctx, tx,
+		`DELETE FROM bhap_replacements WHERE bhap_id = ?`, bhapID)
+	if err != nil {
+		return err
+	}
+
+	for i, key := range replaces {
+		replacedID, err := idOf(key)
+		if err != nil {
+			return err
+		}
+
+		_, err = s.exec(ctx, tx,
+			`INSERT INTO bhap_replacements (bhap_id, replaced_id, position)
+			VALUES (?, ?, ?)`,
+			bhapID, replacedID, i)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadVoters fills in the Voters field of each of the given BHAPs.
+func (s *Store) loadVoters(ctx context.Context, bhaps []bhap.BHAP, keys []bhap.Key) error {
+	if len(bhaps) == 0 {
+		return nil
+	}
+
+	indexes := make(map[bhap.Key]int)
+	params := make([]string, len(keys))
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		id, err := idOf(key)
+		if err != nil {
+			return err
+		}
+		indexes[key] = i
+		params[i] = "?"
+		args[i] = id
+	}
+
+	rows, err := s.query(ctx, s.db,
+		`SELECT bhap_id, user_id FROM bhap_voters
+		WHERE bhap_id IN (`+strings.Join(params, ", ")+`)
+		ORDER BY bhap_id, user_id`,
+		args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bhapID, userID int64
+		if err := rows.Scan(&bhapID, &userID); err != nil {
+			return err
+		}
+		i := indexes[keyOf(bhapID)]
+		bhaps[i].Voters = append(bhaps[i].Voters, keyOf(userID))
+	}
+
+	return rows.Err()
+}
+
+// saveVoters records who may vote on a BHAP, overwriting what was recorded
+// before.
+func (s *Store) saveVoters(ctx context.Context, tx *sql.Tx, bhapID int64, voters []bhap.Key) error {
+	_, err := s.exec(ctx, tx,
+		`DELETE FROM bhap_voters WHERE bhap_id = ?`, bhapID)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range voters {
+		userID, err := idOf(key)
+		if err != nil {
+			return err
+		}
+
+		_, err = s.exec(ctx, tx,
+			`INSERT INTO bhap_voters (bhap_id, user_id) VALUES (?, ?)`,
+			bhapID, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadCoAuthors fills in the CoAuthors and InvitedCoAuthors fields of each
+// of the given BHAPs.
+func (s *Store) loadCoAuthors(ctx context.Context, bhaps []bhap.BHAP, keys []bhap.Key) error {
+	if len(bhaps) == 0 {
+		return nil
+	}
+
+	indexes := make(map[bhap.Key]int)
+	params := make([]string, len(keys))
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		id, err := idOf(key)
+		if err != nil {
+			return err
+		}
+		indexes[key] = i
+		params[i] = "?"
+		args[i] = id
+	}
+
+	rows, err := s.query(ctx, s.db,
+		`SELECT bhap_id, user_id, accepted FROM bhap_coauthors
+		WHERE bhap_id IN (`+strings.Join(params, ", ")+`)
+		ORDER BY bhap_id, position`,
+		args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bhapID, userID int64
+		var accepted bool
+		if err := rows.Scan(&bhapID, &userID, &accepted); err != nil {
+			return err
+		}
+		b := &bhaps[indexes[keyOf(bhapID)]]
+		if accepted {
+			b.CoAuthors = append(b.CoAuthors, keyOf(userID))
+		} else {
+			b.InvitedCoAuthors = append(b.InvitedCoAuthors, keyOf(userID))
+		}
+	}
+
+	return rows.Err()
+}
+
+// saveCoAuthors records the co-authors of a BHAP and who has been invited to
+// be one, overwriting what was recorded before.
+func (s *Store) saveCoAuthors(ctx context.Context, tx *sql.Tx, bhapID int64, b bhap.BHAP) error {
+	_, err := s.exec(ctx, tx,
+		`DELETE FROM bhap_coauthors WHERE bhap_id = ?`, bhapID)
+	if err != nil {
+		return err
+	}
+
+	position := 0
+	save := func(keys []bhap.Key, accepted bool) error {
+		for _, key := range keys {
+			userID, err := idOf(key)
+			if err != nil {
+				return err
+			}
+
+			_, err = s.exec(ctx, tx,
+				`INSERT INTO bhap_coauthors (bhap_id, user_id, accepted, position)
+				VALUES (?, ?, ?, ?)`,
+				bhapID, userID, accepted, position)
+			if err != nil {
+				return err
+			}
+			position++
+		}
+		return nil
+	}
+
+	if err := save(b.CoAuthors, true); err != nil {
+		return err
+	}
+	return save(b.InvitedCoAuthors, false)
+}
+
+// ByKey gets the BHAP with the given key.
+func (s *Store) ByKey(ctx context.Context, key bhap.Key) (bhap.BHAP, error) {
+	id, err := idOf(key)
+	if err != nil {
+		return bhap.BHAP{}, err
+	}
+
+	b, found, err := s.queryBHAP(ctx,
+		`SELECT `+bhapColumns+` FROM bhaps WHERE id = ?`,
+		id)
+	if err != nil {
+		return bhap.BHAP{}, fmt.Errorf("by key %v: %v", key, err)
+	}
+	if found == "" {
+		return bhap.BHAP{}, fmt.Errorf("no BHAP with key %v", key)
+	}
+
+	return b, nil
+}
+
+// ByDraftID gets a BHAP by the given draft ID. If none exists, the key will
+// be empty.
+func (s *Store) ByDraftID(ctx context.Context, draftID string) (bhap.BHAP, bhap.Key, error) {
+	b, key, err := s.queryBHAP(ctx,
+		`SELECT `+bhapColumns+` FROM bhaps WHERE draft_id = ? LIMIT 1`,
+		draftID)
+	if err != nil {
+		return bhap.BHAP{}, "", fmt.Errorf("by draft ID %v: %v", draftID, err)
+	}
+
+	return b, key, nil
+}
+
+// ByID gets a BHAP by the given ID. If none exists, the key will be empty.
+func (s *Store) ByID(ctx context.Context, id int) (bhap.BHAP, bhap.Key, error) {
+	b, key, err := s.queryBHAP(ctx,
+		`SELECT `+bhapColumns+` FROM bhaps WHERE number = ? LIMIT 1`,
+		id)
+	if err != nil {
+		return bhap.BHAP{}, "", fmt.Errorf("by ID %v: %v", id, err)
+	}
+
+	return b, key, nil
+}
+
+// NextID reserves and returns the next unused ID for a new BHAP, using the
+// numbering convention that fits for the given type. IDs are handed out from
+// a counter row that is locked while it is incremented, so concurrent callers
+// never receive the same ID.
+func (s *Store) NextID(ctx context.Context, typ bhap.BHAPType) (int, error) {
+	indexStart, err := bhap.FirstID(typ)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		// Counters for types that predate them start after the highest ID
+		// in use
+		var max sql.NullInt64
+		var err error
+		if typ == bhap.MetaBHAPType {
+			err = s.queryRow(ctx, tx,
+				`SELECT MAX(number) FROM bhaps WHERE number >= ? AND number < ?`,
+				indexStart, bhap.FirstHouseRuleID).
+				Scan(&max)
+		} else {
+			err = s.queryRow(ctx, tx,
+				`SELECT MAX(number) FROM bhaps WHERE number >= ?`,
+				indexStart).
+				Scan(&max)
+		}
+		if err != nil {
+			return err
+		}
+		initial := indexStart
+		if max.Valid {
+			initial = int(max.Int64) + 1
+		}
+
+		_, err = s.exec(ctx, tx,
+			`INSERT INTO bhap_id_counters (type, next_id) VALUES (?, ?)
+			ON CONFLICT (type) DO NOTHING`,
+			typ, initial)
+		if err != nil {
+			return err
+		}
+
+		err = s.queryRow(ctx, tx,
+			`UPDATE bhap_id_counters SET next_id = next_id + 1
+			WHERE type = ?
+			RETURNING next_id - 1`,
+			typ).
+			Scan(&id)
+		if err != nil {
+			return err
+		}
+
+		if !bhap.InIDRange(typ, id) {
+			return bhap.ErrIDsExhausted
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// GetAll returns all recorded BHAPs.
+func (s *Store) GetAll(ctx context.Context) ([]bhap.BHAP, error) {
+	return s.queryBHAPs(ctx,
+		`SELECT `+bhapColumns+` FROM bhaps ORDER BY number, id`)
+}
+
+// ByStatus returns all BHAPs with the given status(es).
+func (s *Store) ByStatus(ctx context.Context, statuses ...bhap.Status) ([]bhap.BHAP, error) {
+	if len(statuses) == 0 {
+		return []bhap.BHAP{}, nil
+	}
+
+	params := make([]string, len(statuses))
+	args := make([]interface{}, len(statuses))
+	for i, status := range statuses {
+		params[i] = "?"
+		args[i] = status
+	}
+
+	results, err := s.queryBHAPs(ctx,
+		`SELECT `+bhapColumns+` FROM bhaps
+		WHERE status IN (`+strings.Join(params, ", ")+`)
+		ORDER BY number, id`,
+		args...)
+	if err != nil {
+		return nil, fmt.Errorf("finding %v BHAPs: %v", statuses, err)
+	}
+
+	return results, nil
+}
+
+// NewBHAP saves a new BHAP and returns its key.
+func (s *Store) NewBHAP(ctx context.Context, b bhap.BHAP) (bhap.Key, error) {
+	authorID, err := nullIDOf(b.Author)
+	if err != nil {
+		return "", err
+	}
+	replacedByID, err := nullIDOf(b.ReplacedBy)
+	if err != nil {
+		return "", err
+	}
+	ballotID, err := nullIDOf(b.Ballot)
+	if err != nil {
+		return "", err
+	}
+
+	var key bhap.Key
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		key, err = s.insert(ctx, tx,
+			`INSERT INTO bhaps (draft_id, number, title, short_description,
+				last_modified, author_id, status, created_date, type, content,
+				defer_reason, resume_date, replaced_by_id, voting_period_days,
+				voting_deadline, ballot_id, secret_ballot, voters_date,
+				revision, substantive_revision, reminders_sent, decided_date)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?,
+				?, ?)
+			RETURNING id`,
+			b.DraftID, b.ID, b.Title, b.ShortDescription, b.LastModified.UTC(),
+			authorID, b.Status, b.CreatedDate.UTC(), b.Type, b.Content,
+			b.DeferReason, nullTimeOf(b.ResumeDate), replacedByID,
+			b.VotingPeriodDays, nullTimeOf(b.VotingDeadline), ballotID,
+			b.SecretBallot, nullTimeOf(b.VotersDate), b.Revision,
+			b.SubstantiveRevision, b.RemindersSent, nullTimeOf(b.DecidedDate))
+		if err != nil {
+			return err
+		}
+
+		id, err := idOf(key)
+		if err != nil {
+			return err
+		}
+		if err := s.saveReplaces(ctx, tx, id, b.Replaces); err != nil {
+			return err
+		}
+		if err := s.saveVoters(ctx, tx, id, b.Voters); err != nil {
+			return err
+		}
+		return s.saveCoAuthors(ctx, tx, id, b)
+	})
+	if err != nil {
+		return "", fmt.Errorf("saving new BHAP: %v", err)
+	}
+
+	return key, nil
+}
+
+// PutBHAP saves changes to an existing BHAP.
+func (s *Store) PutBHAP(ctx context.Context, key bhap.Key, b bhap.BHAP) error {
+	id, err := idOf(key)
+	if err != nil {
+		return err
+	}
+
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		return s.updateBHAP(ctx, tx, id, b)
+	})
+	if err != nil {
+		return fmt.Errorf("saving BHAP: %v", err)
+	}
+
+	return nil
+}
+
+// updateBHAP saves changes to the BHAP with the given ID as part of a
+// transaction.
+func (s *Store) updateBHAP(ctx context.Context, tx *sql.Tx, id int64, b bhap.BHAP) error {
+	authorID, err := nullIDOf(b.Author)
+	if err != nil {
+		return err
+	}
+	replacedByID, err := nullIDOf(b.ReplacedBy)
+	if err != nil {
+		return err
+	}
+	ballotID, err := nullIDOf(b.Ballot)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.exec(ctx, tx,
+		`UPDATE bhaps SET draft_id = ?, number = ?, title = ?,
+			short_description = ?, last_modified = ?, author_id = ?,
+			status = ?, created_date = ?, type = ?, content = ?,
+			defer_reason = ?, resume_date = ?, replaced_by_id = ?,
+			voting_period_days = ?, voting_deadline = ?, ballot_id = ?,
+			secret_ballot = ?, voters_date = ?, revision = ?,
+			substantive_revision = ?, reminders_sent = ?,
+			decided_date = ?
+		WHERE id = ?`,
+		b.DraftID, b.ID, b.Title, b.ShortDescription, b.LastModified.UTC(),
+		authorID, b.Status, b.CreatedDate.UTC(), b.Type, b.Content,
+		b.DeferReason, nullTimeOf(b.ResumeDate), replacedByID,
+		b.VotingPeriodDays, nullTimeOf(b.VotingDeadline), ballotID,
+		b.SecretBallot, nullTimeOf(b.VotersDate), b.Revision,
+		b.SubstantiveRevision, b.RemindersSent,
+		nullTimeOf(b.DecidedDate), id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.saveReplaces(ctx, tx, id, b.Replaces); err != nil {
+		return err
+	}
+	if err := s.saveVoters(ctx, tx, id, b.Voters); err != nil {
+		return err
+	}
+	return s.saveCoAuthors(ctx, tx, id, b)
+}
@@ -0,0 +1,154 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/house-emoji/bhap"
+)
+
+// commentColumns lists the columns scanned by scanComment, in order.
+const commentColumns = `id, uid, bhap_id, parent_id, author_id, content, date,
+	edited, deleted, anchor, anchor_paragraph, anchor_revision`
+
+// scanComment scans a row made up of commentColumns.
+func scanComment(row scanner) (bhap.Comment, bhap.Key, error) {
+	var c bhap.Comment
+	var id, bhapID, authorID int64
+	var parentID sql.NullInt64
+	var edited *time.Time
+
+	err := row.Scan(&id, &c.UID, &bhapID, &parentID, &authorID, &c.Content,
+		&c.Date, &edited, &c.Deleted, &c.Anchor, &c.AnchorParagraph,
+		&c.AnchorRevision)
+	if err != nil {
+		return bhap.Comment{}, "", err
+	}
+	c.OnBHAP = keyOf(bhapID)
+	c.Parent = nullKeyOf(parentID)
+	c.Author = keyOf(authorID)
+	c.Edited = timeOrZero(edited)
+
+	return c, keyOf(id), nil
+}
+
+// queryComments runs a query that selects commentColumns and returns every
+// result along with its key.
+func (s *Store) queryComments(ctx context.Context, query string, args ...interface{}) ([]bhap.Comment, []bhap.Key, error) {
+	rows, err := s.query(ctx, s.db, query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	results := make([]bhap.Comment, 0)
+	var keys []bhap.Key
+	for rows.Next() {
+		c, key, err := scanComment(rows)
+		if err != nil {
+			return nil, nil, err
+		}
+		results = append(results, c)
+		keys = append(keys, key)
+	}
+
+	return results, keys, rows.Err()
+}
+
+// CommentsForBHAP returns every comment on a BHAP, oldest first.
+func (s *Store) CommentsForBHAP(ctx context.Context, bhapKey bhap.Key) ([]bhap.Comment, []bhap.Key, error) {
+	bhapID, err := idOf(bhapKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	results, keys, err := s.queryComments(ctx,
+		`SELECT `+commentColumns+` FROM comments
+		WHERE bhap_id = ? ORDER BY date, id`,
+		bhapID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting comments: %v", err)
+	}
+
+	return results, keys, nil
+}
+
+// CommentByUID returns the comment with the given UID.
+func (s *Store) CommentByUID(ctx context.Context, uid string) (bhap.Comment, bhap.Key, error) {
+	results, keys, err := s.queryComments(ctx,
+		`SELECT `+commentColumns+` FROM comments WHERE uid = ?`,
+		uid)
+	if err != nil {
+		return bhap.Comment{}, "", fmt.Errorf("by UID %v: %v", uid, err)
+	}
+	if len(results) == 0 {
+		return bhap.Comment{}, "", nil
+	}
+
+	return results[0], keys[0], nil
+}
+
+// NewComment saves a new comment and returns its key.
+func (s *Store) NewComment(ctx context.Context, c bhap.Comment) (bhap.Key, error) {
+	bhapID, err := idOf(c.OnBHAP)
+	if err != nil {
+		return "", err
+	}
+	parentID, err := nullIDOf(c.Parent)
+	if err != nil {
+		return "", err
+	}
+	authorID, err := idOf(c.Author)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := s.insert(ctx, s.db,
+		`INSERT INTO comments (uid, bhap_id, parent_id, author_id, content,
+			date, edited, deleted, anchor, anchor_paragraph, anchor_revision)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`,
+		c.UID, bhapID, parentID, authorID, c.Content, c.Date.UTC(),
+		nullTimeOf(c.Edited), c.Deleted, c.Anchor, c.AnchorParagraph,
+		c.AnchorRevision)
+	if err != nil {
+		return "", fmt.Errorf("saving new comment: %v", err)
+	}
+
+	return key, nil
+}
+
+// PutComment saves changes to an existing comment. Only the content and
+// whether it was edited or deleted can change. Anchors stay where they were
+// first attached.
+func (s *Store) PutComment(ctx context.Context, key bhap.Key, c bhap.Comment) error {
+	id, err := idOf(key)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.exec(ctx, s.db,
+		`UPDATE comments SET content = ?, edited = ?, deleted = ? WHERE id = ?`,
+		c.Content, nullTimeOf(c.Edited), c.Deleted, id)
+	if err != nil {
+		return fmt.Errorf("saving comment: %v", err)
+	}
+
+	return nil
+}
+
+// DeleteComment deletes a comment.
+func (s *Store) DeleteComment(ctx context.Context, key bhap.Key) error {
+	id, err := idOf(key)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.exec(ctx, s.db, `DELETE FROM comments WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("deleting comment: %v", err)
+	}
+
+	return nil
+}
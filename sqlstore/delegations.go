@@ -0,0 +1,152 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/house-emoji/bhap"
+)
+
+// delegationColumns lists the columns scanned by scanDelegation, in order.
+const delegationColumns = `id, uid, from_user_id, to_user_id, bhap_id,
+	start_date, end_date, created_date`
+
+// scanDelegation scans a row made up of delegationColumns.
+func scanDelegation(row scanner) (bhap.Delegation, bhap.Key, error) {
+	var d bhap.Delegation
+	var id, fromID, toID int64
+	var bhapID sql.NullInt64
+	var start, end *time.Time
+
+	err := row.Scan(&id, &d.UID, &fromID, &toID, &bhapID, &start, &end,
+		&d.CreatedDate)
+	if err != nil {
+		return bhap.Delegation{}, "", err
+	}
+	d.From = keyOf(fromID)
+	d.To = keyOf(toID)
+	d.BHAP = nullKeyOf(bhapID)
+	d.Start = timeOrZero(start)
+	d.End = timeOrZero(end)
+
+	return d, keyOf(id), nil
+}
+
+// queryDelegations runs a query that selects delegationColumns and returns
+// every result along with its key.
+func (s *Store) queryDelegations(ctx context.Context, query string, args ...interface{}) ([]bhap.Delegation, []bhap.Key, error) {
+	rows, err := s.query(ctx, s.db, query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var results []bhap.Delegation
+	var keys []bhap.Key
+	for rows.Next() {
+		d, key, err := scanDelegation(rows)
+		if err != nil {
+			return nil, nil, err
+		}
+		results = append(results, d)
+		keys = append(keys, key)
+	}
+
+	return results, keys, rows.Err()
+}
+
+// DelegationsFrom returns every delegation made by the given user.
+func (s *Store) DelegationsFrom(ctx context.Context, userKey bhap.Key) ([]bhap.Delegation, []bhap.Key, error) {
+	userID, err := idOf(userKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	results, keys, err := s.queryDelegations(ctx,
+		`SELECT `+delegationColumns+` FROM delegations
+		WHERE from_user_id = ? ORDER BY id`,
+		userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting delegations: %v", err)
+	}
+
+	return results, keys, nil
+}
+
+// DelegationsTo returns every delegation made to the given user.
+func (s *Store) DelegationsTo(ctx context.Context, userKey bhap.Key) ([]bhap.Delegation, []bhap.Key, error) {
+	userID, err := idOf(userKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	results, keys, err := s.queryDelegations(ctx,
+		`SELECT `+delegationColumns+` FROM delegations
+		WHERE to_user_id = ? ORDER BY id`,
+		userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting delegations: %v", err)
+	}
+
+	return results, keys, nil
+}
+
+// DelegationByUID returns the delegation with the given UID.
+func (s *Store) DelegationByUID(ctx context.Context, uid string) (bhap.Delegation, bhap.Key, error) {
+	results, keys, err := s.queryDelegations(ctx,
+		`SELECT `+delegationColumns+` FROM delegations WHERE uid = ?`,
+		uid)
+	if err != nil {
+		return bhap.Delegation{}, "", fmt.Errorf("by UID %v: %v", uid, err)
+	}
+	if len(results) == 0 {
+		return bhap.Delegation{}, "", nil
+	}
+
+	return results[0], keys[0], nil
+}
+
+// NewDelegation saves a new delegation and returns its key.
+func (s *Store) NewDelegation(ctx context.Context, d bhap.Delegation) (bhap.Key, error) {
+	fromID, err := idOf(d.From)
+	if err != nil {
+		return "", err
+	}
+	toID, err := idOf(d.To)
+	if err != nil {
+		return "", err
+	}
+	bhapID, err := nullIDOf(d.BHAP)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := s.insert(ctx, s.db,
+		`INSERT INTO delegations (uid, from_user_id, to_user_id, bhap_id,
+			start_date, end_date, created_date)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`,
+		d.UID, fromID, toID, bhapID, nullTimeOf(d.Start), nullTimeOf(d.End),
+		d.CreatedDate.UTC())
+	if err != nil {
+		return "", fmt.Errorf("saving new delegation: %v", err)
+	}
+
+	return key, nil
+}
+
+// DeleteDelegation deletes a delegation.
+func (s *Store) DeleteDelegation(ctx context.Context, key bhap.Key) error {
+	id, err := idOf(key)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.exec(ctx, s.db, `DELETE FROM delegations WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("deleting delegation: %v", err)
+	}
+
+	return nil
+}
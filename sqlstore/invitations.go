@@ -0,0 +1,96 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/house-emoji/bhap"
+)
+
+// UnsentInvitations returns all invitations that have yet to be emailed.
+func (s *Store) UnsentInvitations(ctx context.Context) ([]bhap.Invitation, []bhap.Key, error) {
+	rows, err := s.query(ctx, s.db,
+		`SELECT id, email, uid, email_sent FROM invitations
+		WHERE email_sent = ? ORDER BY id`,
+		false)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var results []bhap.Invitation
+	var keys []bhap.Key
+	for rows.Next() {
+		var id int64
+		var inv bhap.Invitation
+		if err := rows.Scan(&id, &inv.Email, &inv.UID, &inv.EmailSent); err != nil {
+			return nil, nil, err
+		}
+		results = append(results, inv)
+		keys = append(keys, keyOf(id))
+	}
+
+	return results, keys, rows.Err()
+}
+
+// InvitationByUID returns the invitation with the corresponding UID.
+func (s *Store) InvitationByUID(ctx context.Context, uid string) (bhap.Invitation, bhap.Key, error) {
+	var id int64
+	var inv bhap.Invitation
+	err := s.queryRow(ctx, s.db,
+		`SELECT id, email, uid, email_sent FROM invitations WHERE uid = ?`,
+		uid).
+		Scan(&id, &inv.Email, &inv.UID, &inv.EmailSent)
+	if err == sql.ErrNoRows {
+		return bhap.Invitation{}, "", nil
+	} else if err != nil {
+		return bhap.Invitation{}, "", err
+	}
+
+	return inv, keyOf(id), nil
+}
+
+// NewInvitation saves a new invitation and returns its key.
+func (s *Store) NewInvitation(ctx context.Context, inv bhap.Invitation) (bhap.Key, error) {
+	key, err := s.insert(ctx, s.db,
+		`INSERT INTO invitations (email, uid, email_sent) VALUES (?, ?, ?)
+		RETURNING id`,
+		inv.Email, inv.UID, inv.EmailSent)
+	if err != nil {
+		return "", fmt.Errorf("saving new invitation: %v", err)
+	}
+
+	return key, nil
+}
+
+// PutInvitation saves changes to an existing invitation.
+func (s *Store) PutInvitation(ctx context.Context, key bhap.Key, inv bhap.Invitation) error {
+	id, err := idOf(key)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.exec(ctx, s.db,
+		`UPDATE invitations SET email = ?, uid = ?, email_sent = ? WHERE id = ?`,
+		inv.Email, inv.UID, inv.EmailSent, id)
+	if err != nil {
+		return fmt.Errorf("saving invitation: %v", err)
+	}
+
+	return nil
+}
+
+// DeleteInvitation deletes an invitation.
+func (s *Store) DeleteInvitation(ctx context.Context, key bhap.Key) error {
+	id, err := idOf(key)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.exec(ctx, s.db, `DELETE FROM invitations WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("deleting invitation: %v", err)
+	}
+
+	return nil
+}
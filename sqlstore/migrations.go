@@ -0,0 +1,391 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// migration is one versioned change to the database schema. Statements may
+// use {{primaryKey}} and {{blob}} in place of dialect-specific column types.
+type migration struct {
+	version    int
+	statements []string
+}
+
+// migrations is the full history of the schema, oldest first. Once a
+// migration has been released it must never be changed; add a new one
+// instead.
+var migrations = []migration{
+	{
+		version: 1,
+		statements: []string{
+			`CREATE TABLE users (
+				id {{primaryKey}},
+				first_name TEXT NOT NULL,
+				last_name TEXT NOT NULL,
+				email TEXT NOT NULL UNIQUE,
+				password_hash {{blob}}
+			)`,
+			`CREATE TABLE bhaps (
+				id {{primaryKey}},
+				draft_id TEXT NOT NULL,
+				number INTEGER NOT NULL,
+				title TEXT NOT NULL,
+				short_description TEXT NOT NULL,
+				last_modified TIMESTAMP NOT NULL,
+				author_id INTEGER REFERENCES users (id),
+				status TEXT NOT NULL,
+				created_date TIMESTAMP NOT NULL,
+				type TEXT NOT NULL,
+				content TEXT NOT NULL
+			)`,
+			`CREATE INDEX bhaps_draft_id ON bhaps (draft_id)`,
+			`CREATE INDEX bhaps_status_number ON bhaps (status, number)`,
+			`CREATE TABLE votes (
+				id {{primaryKey}},
+				bhap_id INTEGER NOT NULL REFERENCES bhaps (id),
+				user_id INTEGER NOT NULL REFERENCES users (id),
+				value TEXT NOT NULL,
+				UNIQUE (bhap_id, user_id)
+			)`,
+			`CREATE TABLE invitations (
+				id {{primaryKey}},
+				email TEXT NOT NULL,
+				uid TEXT NOT NULL UNIQUE,
+				email_sent BOOLEAN NOT NULL
+			)`,
+			`CREATE TABLE sessions (
+				id TEXT PRIMARY KEY,
+				data TEXT NOT NULL,
+				expires_at TIMESTAMP NOT NULL
+			)`,
+		},
+	},
+	{
+		version: 2,
+		statements: []string{
+			`CREATE TABLE bhap_id_counters (
+				type TEXT PRIMARY KEY,
+				next_id INTEGER NOT NULL
+			)`,
+			`CREATE UNIQUE INDEX bhaps_unique_number ON bhaps (number)
+			WHERE number >= 0`,
+		},
+	},
+	{
+		version: 3,
+		statements: []string{
+			`ALTER TABLE users ADD COLUMN admin BOOLEAN NOT NULL DEFAULT FALSE`,
+		},
+	},
+	{
+		version: 4,
+		statements: []string{
+			`ALTER TABLE bhaps ADD COLUMN defer_reason TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE bhaps ADD COLUMN resume_date TIMESTAMP`,
+		},
+	},
+	{
+		version: 5,
+		statements: []string{
+			`ALTER TABLE bhaps ADD COLUMN replaced_by_id INTEGER
+			REFERENCES bhaps (id)`,
+			`CREATE TABLE bhap_replacements (
+				bhap_id INTEGER NOT NULL REFERENCES bhaps (id),
+				replaced_id INTEGER NOT NULL REFERENCES bhaps (id),
+				position INTEGER NOT NULL,
+				PRIMARY KEY (bhap_id, replaced_id)
+			)`,
+		},
+	},
+	{
+		version: 6,
+		statements: []string{
+			`ALTER TABLE bhaps ADD COLUMN voting_period_days INTEGER NOT NULL
+			DEFAULT 0`,
+			`ALTER TABLE bhaps ADD COLUMN voting_deadline TIMESTAMP`,
+		},
+	},
+	{
+		version: 7,
+		statements: []string{
+			`CREATE TABLE ballots (
+				id {{primaryKey}},
+				uid TEXT NOT NULL UNIQUE,
+				title TEXT NOT NULL,
+				created_by_id INTEGER REFERENCES users (id),
+				created_date TIMESTAMP NOT NULL,
+				deadline TIMESTAMP NOT NULL,
+				closed BOOLEAN NOT NULL,
+				winner_id INTEGER REFERENCES bhaps (id)
+			)`,
+			`CREATE TABLE ballot_options (
+				ballot_id INTEGER NOT NULL REFERENCES ballots (id),
+				bhap_id INTEGER NOT NULL REFERENCES bhaps (id),
+				position INTEGER NOT NULL,
+				counted BOOLEAN NOT NULL,
+				PRIMARY KEY (ballot_id, bhap_id)
+			)`,
+			`ALTER TABLE bhaps ADD COLUMN ballot_id INTEGER
+			REFERENCES ballots (id)`,
+			`ALTER TABLE votes ADD COLUMN ballot_rank INTEGER NOT NULL
+			DEFAULT 0`,
+		},
+	},
+	{
+		version: 8,
+		statements: []string{
+			`CREATE TABLE vote_events (
+				id {{primaryKey}},
+				bhap_id INTEGER NOT NULL REFERENCES bhaps (id),
+				user_id INTEGER NOT NULL REFERENCES users (id),
+				action TEXT NOT NULL,
+				value TEXT NOT NULL,
+				ballot_rank INTEGER NOT NULL,
+				previous TEXT NOT NULL,
+				date TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX vote_events_bhap_id ON vote_events (bhap_id)`,
+		},
+	},
+	{
+		version: 9,
+		statements: []string{
+			`CREATE TABLE delegations (
+				id {{primaryKey}},
+				uid TEXT NOT NULL UNIQUE,
+				from_user_id INTEGER NOT NULL REFERENCES users (id),
+				to_user_id INTEGER NOT NULL REFERENCES users (id),
+				bhap_id INTEGER REFERENCES bhaps (id),
+				start_date TIMESTAMP,
+				end_date TIMESTAMP,
+				created_date TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX delegations_from_user_id ON delegations (from_user_id)`,
+			`CREATE INDEX delegations_to_user_id ON delegations (to_user_id)`,
+			`ALTER TABLE votes ADD COLUMN proxy_id INTEGER
+			REFERENCES users (id)`,
+			`ALTER TABLE vote_events ADD COLUMN proxy_id INTEGER
+			REFERENCES users (id)`,
+		},
+	},
+	{
+		version: 10,
+		statements: []string{
+			`ALTER TABLE bhaps ADD COLUMN secret_ballot BOOLEAN NOT NULL
+			DEFAULT FALSE`,
+			`CREATE TABLE secret_choices (
+				bhap_id INTEGER NOT NULL REFERENCES bhaps (id),
+				value TEXT NOT NULL,
+				count INTEGER NOT NULL,
+				PRIMARY KEY (bhap_id, value)
+			)`,
+		},
+	},
+	{
+		version: 11,
+		statements: []string{
+			`ALTER TABLE bhaps ADD COLUMN voters_date TIMESTAMP`,
+			`CREATE TABLE bhap_voters (
+				bhap_id INTEGER NOT NULL REFERENCES bhaps (id),
+				user_id INTEGER NOT NULL REFERENCES users (id),
+				PRIMARY KEY (bhap_id, user_id)
+			)`,
+			`CREATE TABLE voter_changes (
+				id {{primaryKey}},
+				bhap_id INTEGER NOT NULL REFERENCES bhaps (id),
+				user_id INTEGER NOT NULL REFERENCES users (id),
+				reopened_from TEXT NOT NULL,
+				date TIMESTAMP NOT NULL
+			)`,
+			`CREATE INDEX voter_changes_bhap_id ON voter_changes (bhap_id)`,
+			`CREATE TABLE voter_change_members (
+				voter_change_id INTEGER NOT NULL
+					REFERENCES voter_changes (id),
+				user_id INTEGER NOT NULL REFERENCES users (id),
+				added BOOLEAN NOT NULL,
+				PRIMARY KEY (voter_change_id, user_id)
+			)`,
+		},
+	},
+	{
+		version: 12,
+		statements: []string{
+			`CREATE TABLE revisions (
+				id {{primaryKey}},
+				bhap_id INTEGER NOT NULL REFERENCES bhaps (id),
+				number INTEGER NOT NULL,
+				author_id INTEGER REFERENCES users (id),
+				date TIMESTAMP NOT NULL,
+				summary TEXT NOT NULL,
+				title TEXT NOT NULL,
+				short_description TEXT NOT NULL,
+				content TEXT NOT NULL,
+				UNIQUE (bhap_id, number)
+			)`,
+		},
+	},
+	{
+		version: 13,
+		statements: []string{
+			`ALTER TABLE bhaps ADD COLUMN revision INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE bhaps ADD COLUMN substantive_revision INTEGER NOT NULL
+			DEFAULT 0`,
+			`ALTER TABLE votes ADD COLUMN revision INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE vote_events ADD COLUMN revision INTEGER NOT NULL
+			DEFAULT 0`,
+			`CREATE TABLE outgoing_emails (
+				id {{primaryKey}},
+				recipient TEXT NOT NULL,
+				subject TEXT NOT NULL,
+				body TEXT NOT NULL,
+				queued TIMESTAMP NOT NULL,
+				sent BOOLEAN NOT NULL
+			)`,
+			`CREATE INDEX outgoing_emails_sent ON outgoing_emails (sent)`,
+		},
+	},
+	{
+		version: 14,
+		statements: []string{
+			`CREATE TABLE comments (
+				id {{primaryKey}},
+				uid TEXT NOT NULL UNIQUE,
+				bhap_id INTEGER NOT NULL REFERENCES bhaps (id),
+				parent_id INTEGER REFERENCES comments (id),
+				author_id INTEGER NOT NULL REFERENCES users (id),
+				content TEXT NOT NULL,
+				date TIMESTAMP NOT NULL,
+				edited TIMESTAMP,
+				deleted BOOLEAN NOT NULL
+			)`,
+			`CREATE INDEX comments_bhap_id ON comments (bhap_id)`,
+		},
+	},
+	{
+		version: 15,
+		statements: []string{
+			`ALTER TABLE comments ADD COLUMN anchor TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE comments ADD COLUMN anchor_paragraph INTEGER NOT NULL
+			DEFAULT 0`,
+			`ALTER TABLE comments ADD COLUMN anchor_revision INTEGER NOT NULL
+			DEFAULT 0`,
+		},
+	},
+	{
+		version: 16,
+		statements: []string{
+			`CREATE TABLE bhap_coauthors (
+				bhap_id INTEGER NOT NULL REFERENCES bhaps (id),
+				user_id INTEGER NOT NULL REFERENCES users (id),
+				accepted BOOLEAN NOT NULL,
+				position INTEGER NOT NULL,
+				PRIMARY KEY (bhap_id, user_id)
+			)`,
+		},
+	},
+	{
+		version: 17,
+		statements: []string{
+			`ALTER TABLE bhaps ADD COLUMN reminders_sent INTEGER NOT NULL
+			DEFAULT 0`,
+		},
+	},
+	{
+		version: 18,
+		statements: []string{
+			`ALTER TABLE bhaps ADD COLUMN decided_date TIMESTAMP`,
+			`ALTER TABLE users ADD COLUMN weekly_digest BOOLEAN NOT NULL
+			DEFAULT FALSE`,
+		},
+	},
+	{
+		version: 19,
+		statements: []string{
+			`ALTER TABLE users ADD COLUMN no_discussion_emails BOOLEAN NOT NULL
+			DEFAULT FALSE`,
+			`ALTER TABLE users ADD COLUMN no_decision_emails BOOLEAN NOT NULL
+			DEFAULT FALSE`,
+			`ALTER TABLE users ADD COLUMN no_comment_emails BOOLEAN NOT NULL
+			DEFAULT FALSE`,
+			`ALTER TABLE users ADD COLUMN no_reminder_emails BOOLEAN NOT NULL
+			DEFAULT FALSE`,
+			// Members who chose the digest used to get it instead of
+			// emails about BHAPs entering discussion and being decided
+			`UPDATE users SET no_discussion_emails = weekly_digest,
+			no_decision_emails = weekly_digest`,
+			`ALTER TABLE outgoing_emails ADD COLUMN unsubscribe_url TEXT NOT NULL
+			DEFAULT ''`,
+		},
+	},
+	{
+		version: 20,
+		statements: []string{
+			`CREATE TABLE digests (
+				sent TIMESTAMP NOT NULL
+			)`,
+		},
+	},
+	{
+		version: 21,
+		statements: []string{
+			`ALTER TABLE ballots ADD COLUMN quorum DOUBLE PRECISION NOT NULL
+			DEFAULT 0`,
+		},
+	},
+}
+
+// migrate applies every migration that has not yet been applied to the
+// database, each in its own transaction.
+func (s *Store) migrate(ctx context.Context) error {
+	_, err := s.exec(ctx, s.db, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("creating migrations table: %v", err)
+	}
+
+	var current int
+	err = s.queryRow(ctx, s.db,
+		`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).
+		Scan(&current)
+	if err != nil {
+		return fmt.Errorf("getting schema version: %v", err)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+
+		if err := s.applyMigration(ctx, m); err != nil {
+			return fmt.Errorf("applying migration %v: %v", m.version, err)
+		}
+	}
+
+	return nil
+}
+
+// applyMigration runs a single migration and records it as applied.
+func (s *Store) applyMigration(ctx context.Context, m migration) error {
+	replacer := strings.NewReplacer(
+		"{{primaryKey}}", s.dialect.primaryKey,
+		"{{blob}}", s.dialect.blob)
+
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		for _, statement := range m.statements {
+			if _, err := s.exec(ctx, tx, replacer.Replace(statement)); err != nil {
+				return err
+			}
+		}
+
+		_, err := s.exec(ctx, tx,
+			`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`,
+			m.version, time.Now().UTC())
+		return err
+	})
+}
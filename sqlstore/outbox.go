@@ -0,0 +1,98 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/house-emoji/bhap"
+)
+
+// UnsentEmails returns every queued email that has yet to be sent, oldest
+// first.
+func (s *Store) UnsentEmails(ctx context.Context) ([]bhap.OutgoingEmail, []bhap.Key, error) {
+	rows, err := s.query(ctx, s.db,
+		`SELECT id, recipient, subject, body, queued, sent, unsubscribe_url
+		FROM outgoing_emails WHERE sent = ? ORDER BY queued, id`,
+		false)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var results []bhap.OutgoingEmail
+	var keys []bhap.Key
+	for rows.Next() {
+		var id int64
+		var e bhap.OutgoingEmail
+		err := rows.Scan(&id, &e.To, &e.Subject, &e.Body, &e.Queued, &e.Sent,
+			&e.UnsubscribeURL)
+		if err != nil {
+			return nil, nil, err
+		}
+		results = append(results, e)
+		keys = append(keys, keyOf(id))
+	}
+
+	return results, keys, rows.Err()
+}
+
+// QueueEmail adds an email to the outbox.
+func (s *Store) QueueEmail(ctx context.Context, e bhap.OutgoingEmail) error {
+	_, err := s.exec(ctx, s.db,
+		`INSERT INTO outgoing_emails (recipient, subject, body, queued, sent,
+			unsubscribe_url)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		e.To, e.Subject, e.Body, e.Queued.UTC(), e.Sent, e.UnsubscribeURL)
+	if err != nil {
+		return fmt.Errorf("queueing email: %v", err)
+	}
+
+	return nil
+}
+
+// PutEmail saves changes to a queued email.
+func (s *Store) PutEmail(ctx context.Context, key bhap.Key, e bhap.OutgoingEmail) error {
+	id, err := idOf(key)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.exec(ctx, s.db,
+		`UPDATE outgoing_emails SET recipient = ?, subject = ?, body = ?,
+			queued = ?, sent = ?, unsubscribe_url = ?
+		WHERE id = ?`,
+		e.To, e.Subject, e.Body, e.Queued.UTC(), e.Sent, e.UnsubscribeURL, id)
+	if err != nil {
+		return fmt.Errorf("saving email: %v", err)
+	}
+
+	return nil
+}
+
+// LastDigestDate returns when the weekly digest was last sent, or the zero
+// time if it never was.
+func (s *Store) LastDigestDate(ctx context.Context) (time.Time, error) {
+	var date time.Time
+	err := s.queryRow(ctx, s.db,
+		`SELECT sent FROM digests ORDER BY sent DESC LIMIT 1`).Scan(&date)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	} else if err != nil {
+		return time.Time{}, fmt.Errorf("getting last digest: %v", err)
+	}
+
+	return date, nil
+}
+
+// RecordDigest records that the weekly digest was sent at the given time.
+func (s *Store) RecordDigest(ctx context.Context, date time.Time) error {
+	_, err := s.exec(ctx, s.db,
+		`INSERT INTO digests (sent) VALUES (?)`, date.UTC())
+	if err != nil {
+		return fmt.Errorf("recording digest: %v", err)
+	}
+
+	return nil
+}
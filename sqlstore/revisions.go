@@ -0,0 +1,112 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/house-emoji/bhap"
+)
+
+// RevisionsForBHAP returns every revision of a BHAP, oldest first.
+func (s *Store) RevisionsForBHAP(ctx context.Context, bhapKey bhap.Key) ([]bhap.Revision, error) {
+	bhapID, err := idOf(bhapKey)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.query(ctx, s.db,
+		`SELECT number, author_id, date, summary, title, short_description,
+			content
+		FROM revisions WHERE bhap_id = ? ORDER BY number`,
+		bhapID)
+	if err != nil {
+		return nil, fmt.Errorf("getting revisions: %v", err)
+	}
+	defer rows.Close()
+
+	revisions := make([]bhap.Revision, 0)
+	for rows.Next() {
+		var authorID sql.NullInt64
+		r := bhap.Revision{OnBHAP: bhapKey}
+		err := rows.Scan(&r.Number, &authorID, &r.Date, &r.Summary, &r.Title,
+			&r.ShortDescription, &r.Content)
+		if err != nil {
+			return nil, fmt.Errorf("getting revisions: %v", err)
+		}
+		r.Author = nullKeyOf(authorID)
+		revisions = append(revisions, r)
+	}
+
+	return revisions, rows.Err()
+}
+
+// NewRevision records a revision.
+func (s *Store) NewRevision(ctx context.Context, r bhap.Revision) error {
+	bhapID, err := idOf(r.OnBHAP)
+	if err != nil {
+		return err
+	}
+
+	if err := s.insertRevision(ctx, s.db, bhapID, r); err != nil {
+		return fmt.Errorf("saving revision: %v", err)
+	}
+
+	return nil
+}
+
+// SaveRevision saves changes to an existing BHAP and records the given
+// revisions of it, all in one transaction.
+func (s *Store) SaveRevision(ctx context.Context, key bhap.Key, b bhap.BHAP, substantive bool, revisions ...bhap.Revision) (bhap.BHAP, error) {
+	id, err := idOf(key)
+	if err != nil {
+		return bhap.BHAP{}, err
+	}
+
+	// If another revision is saved at the same time, the revision numbers
+	// being unique makes one of the transactions fail as a whole
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		var number int
+		err := s.queryRow(ctx, tx,
+			`SELECT COALESCE(MAX(number), 0) FROM revisions WHERE bhap_id = ?`,
+			id).Scan(&number)
+		if err != nil {
+			return err
+		}
+
+		for _, r := range revisions {
+			number++
+			r.Number = number
+			if err := s.insertRevision(ctx, tx, id, r); err != nil {
+				return err
+			}
+		}
+
+		b.Revision = number
+		if substantive {
+			b.SubstantiveRevision = number
+		}
+		return s.updateBHAP(ctx, tx, id, b)
+	})
+	if err != nil {
+		return bhap.BHAP{}, fmt.Errorf("saving revision: %v", err)
+	}
+
+	return b, nil
+}
+
+// insertRevision adds a revision of the BHAP with the given ID.
+func (s *Store) insertRevision(ctx context.Context, q querier, bhapID int64, r bhap.Revision) error {
+	authorID, err := nullIDOf(r.Author)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.exec(ctx, q,
+		`INSERT INTO revisions (bhap_id, number, author_id, date, summary,
+			title, short_description, content)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		bhapID, r.Number, authorID, r.Date.UTC(), r.Summary, r.Title,
+		r.ShortDescription, r.Content)
+	return err
+}
@@ -0,0 +1,138 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"encoding/base32"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// SessionStore is a sessions.Store that keeps session data in the database,
+// leaving only a signed session ID in the cookie.
+type SessionStore struct {
+	Codecs  []securecookie.Codec
+	Options *sessions.Options // default configuration
+
+	store *Store
+}
+
+// SessionStore creates a session store that saves sessions to this
+// database. See sessions.NewCookieStore for a description of keyPairs.
+func (s *Store) SessionStore(keyPairs ...[]byte) *SessionStore {
+	ss := &SessionStore{
+		Codecs: securecookie.CodecsFromPairs(keyPairs...),
+		Options: &sessions.Options{
+			Path:   "/",
+			MaxAge: 86400 * 30,
+		},
+		store: s,
+	}
+
+	ss.MaxAge(ss.Options.MaxAge)
+	return ss
+}
+
+// Get returns a session for the given name after adding it to the registry.
+func (ss *SessionStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(ss, name)
+}
+
+// New returns a session for the given name without adding it to the
+// registry. If the session has expired or does not exist, a new session is
+// returned.
+func (ss *SessionStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(ss, name)
+	opts := *ss.Options
+	session.Options = &opts
+	session.IsNew = true
+
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	if err := securecookie.DecodeMulti(name, cookie.Value, &session.ID, ss.Codecs...); err != nil {
+		return session, err
+	}
+
+	var data string
+	err = ss.store.queryRow(r.Context(), ss.store.db,
+		`SELECT data FROM sessions WHERE id = ? AND expires_at > ?`,
+		session.ID, time.Now().UTC()).
+		Scan(&data)
+	if err == sql.ErrNoRows {
+		session.ID = ""
+		return session, nil
+	} else if err != nil {
+		return session, err
+	}
+
+	if err := securecookie.DecodeMulti(name, data, &session.Values, ss.Codecs...); err != nil {
+		return session, err
+	}
+	session.IsNew = false
+
+	return session, nil
+}
+
+// Save writes the session to the database and sets its cookie. If the
+// session's MaxAge is <= 0, the session is deleted instead.
+func (ss *SessionStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	ctx := r.Context()
+
+	if session.Options.MaxAge <= 0 {
+		_, err := ss.store.exec(ctx, ss.store.db,
+			`DELETE FROM sessions WHERE id = ?`,
+			session.ID)
+		if err != nil {
+			return err
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	if session.ID == "" {
+		session.ID = strings.TrimRight(
+			base32.StdEncoding.EncodeToString(
+				securecookie.GenerateRandomKey(32)), "=")
+	}
+
+	data, err := securecookie.EncodeMulti(session.Name(), session.Values, ss.Codecs...)
+	if err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().UTC().Add(time.Duration(session.Options.MaxAge) * time.Second)
+	_, err = ss.store.exec(ctx, ss.store.db,
+		`INSERT INTO sessions (id, data, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT (id) DO UPDATE
+		SET data = excluded.data, expires_at = excluded.expires_at`,
+		session.ID, data, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	encodedID, err := securecookie.EncodeMulti(session.Name(), session.ID, ss.Codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encodedID, session.Options))
+
+	return nil
+}
+
+// MaxAge sets the maximum age for sessions and the underlying cookies.
+// Individual sessions can be deleted by setting Options.MaxAge = -1 for that
+// session.
+func (ss *SessionStore) MaxAge(age int) {
+	ss.Options.MaxAge = age
+
+	for _, codec := range ss.Codecs {
+		if sc, ok := codec.(*securecookie.SecureCookie); ok {
+			sc.MaxAge(age)
+		}
+	}
+}
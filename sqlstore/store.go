@@ -0,0 +1,208 @@
+// Package sqlstore implements bhap.Store on top of a SQL database. SQLite and
+// PostgreSQL are supported. The schema is created and upgraded automatically
+// when the store is opened.
+package sqlstore
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/house-emoji/bhap"
+)
+
+// dialect describes the differences between supported databases.
+type dialect struct {
+	// primaryKey is the column definition for an auto-incrementing integer
+	// primary key.
+	primaryKey string
+	// blob is the column type for binary data.
+	blob string
+	// numberedParams is true if query parameters are written as $1, $2, ...
+	// instead of ?.
+	numberedParams bool
+	// singleConn is true if the database can only handle one writer at a
+	// time.
+	singleConn bool
+}
+
+var (
+	sqliteDialect = dialect{
+		primaryKey: "INTEGER PRIMARY KEY AUTOINCREMENT",
+		blob:       "BLOB",
+		singleConn: true,
+	}
+	postgresDialect = dialect{
+		primaryKey:     "SERIAL PRIMARY KEY",
+		blob:           "BYTEA",
+		numberedParams: true,
+	}
+)
+
+// dialects maps database/sql driver names to their dialect.
+var dialects = map[string]dialect{
+	"sqlite3":  sqliteDialect,
+	"postgres": postgresDialect,
+	"pgx":      postgresDialect,
+}
+
+// Store is a bhap.Store backed by a SQL database.
+type Store struct {
+	db      *sql.DB
+	dialect dialect
+}
+
+var _ bhap.Store = (*Store)(nil)
+
+// Open connects to the database and brings its schema up to date. The driver
+// for the database must already be registered with database/sql. Supported
+// drivers are "sqlite3", "postgres" and "pgx".
+func Open(driverName, dataSourceName string) (*Store, error) {
+	d, ok := dialects[driverName]
+	if !ok {
+		return nil, fmt.Errorf("unsupported database driver %v", driverName)
+	}
+
+	db, err := sql.Open(driverName, dataSourceName)
+	if err != nil {
+		return nil, fmt.Errorf("opening database: %v", err)
+	}
+	if d.singleConn {
+		db.SetMaxOpenConns(1)
+	}
+
+	s := &Store{db: db, dialect: d}
+	if err := s.migrate(context.Background()); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrating database: %v", err)
+	}
+
+	return s, nil
+}
+
+// Close closes the underlying database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// querier is something that queries can be run against, either the database
+// itself or a transaction.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// rebind rewrites the ? parameters in a query to whatever the dialect
+// expects.
+func (s *Store) rebind(query string) string {
+	if !s.dialect.numberedParams {
+		return query
+	}
+
+	var rebound bytes.Buffer
+	param := 0
+	for _, c := range query {
+		if c == '?' {
+			param++
+			rebound.WriteString("$" + strconv.Itoa(param))
+		} else {
+			rebound.WriteRune(c)
+		}
+	}
+	return rebound.String()
+}
+
+// inTx runs the given function in a transaction, committing if it succeeds.
+func (s *Store) inTx(ctx context.Context, f func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := f(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) exec(ctx context.Context, q querier, query string, args ...interface{}) (sql.Result, error) {
+	return q.ExecContext(ctx, s.rebind(query), args...)
+}
+
+func (s *Store) query(ctx context.Context, q querier, query string, args ...interface{}) (*sql.Rows, error) {
+	return q.QueryContext(ctx, s.rebind(query), args...)
+}
+
+func (s *Store) queryRow(ctx context.Context, q querier, query string, args ...interface{}) *sql.Row {
+	return q.QueryRowContext(ctx, s.rebind(query), args...)
+}
+
+// insert runs an INSERT statement and returns the key of the new row. The
+// statement must end with "RETURNING id".
+func (s *Store) insert(ctx context.Context, q querier, query string, args ...interface{}) (bhap.Key, error) {
+	var id int64
+	if err := s.queryRow(ctx, q, query, args...).Scan(&id); err != nil {
+		return "", err
+	}
+	return keyOf(id), nil
+}
+
+// keyOf converts a row ID into a bhap.Key.
+func keyOf(id int64) bhap.Key {
+	return bhap.Key(strconv.FormatInt(id, 10))
+}
+
+// nullKeyOf converts a nullable row ID into a bhap.Key, which is empty if the
+// ID is null.
+func nullKeyOf(id sql.NullInt64) bhap.Key {
+	if !id.Valid {
+		return ""
+	}
+	return keyOf(id.Int64)
+}
+
+// idOf converts a bhap.Key created by this store back into a row ID.
+func idOf(key bhap.Key) (int64, error) {
+	id, err := strconv.ParseInt(string(key), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid key %q: %v", key, err)
+	}
+	return id, nil
+}
+
+// nullIDOf converts a bhap.Key into a nullable row ID, which is null if the
+// key is empty.
+func nullIDOf(key bhap.Key) (sql.NullInt64, error) {
+	if key == "" {
+		return sql.NullInt64{}, nil
+	}
+	id, err := idOf(key)
+	if err != nil {
+		return sql.NullInt64{}, err
+	}
+	return sql.NullInt64{Int64: id, Valid: true}, nil
+}
+
+// nullTimeOf converts a time into a value that is stored as NULL if the time
+// is zero.
+func nullTimeOf(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC()
+}
+
+// timeOrZero returns the time pointed to, or the zero time if there is none.
+// It's used to scan nullable time columns.
+func timeOrZero(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
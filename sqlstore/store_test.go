@@ -0,0 +1,19 @@
+package sqlstore
+
+import (
+	"testing"
+
+	"github.com/house-emoji/bhap"
+	"github.com/house-emoji/bhap/storetest"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestSQLiteStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) bhap.Store {
+		s, err := Open("sqlite3", ":memory:")
+		if err != nil {
+			t.Fatalf("opening store: %v", err)
+		}
+		return s
+	})
+}
@@ -0,0 +1,134 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/house-emoji/bhap"
+)
+
+// userColumns lists the columns scanned by scanUser, in order.
+const userColumns = `id, first_name, last_name, email, password_hash, admin,
+	weekly_digest, no_discussion_emails, no_decision_emails, no_comment_emails,
+	no_reminder_emails`
+
+// scanUser scans a row made up of userColumns.
+func scanUser(row scanner) (bhap.User, bhap.Key, error) {
+	var id int64
+	var u bhap.User
+	err := row.Scan(&id, &u.FirstName, &u.LastName, &u.Email, &u.PasswordHash,
+		&u.Admin, &u.WeeklyDigest, &u.NoDiscussionEmails, &u.NoDecisionEmails,
+		&u.NoCommentEmails, &u.NoReminderEmails)
+	if err != nil {
+		return bhap.User{}, "", err
+	}
+
+	return u, keyOf(id), nil
+}
+
+// UserByKey returns the user with the given key.
+func (s *Store) UserByKey(ctx context.Context, key bhap.Key) (bhap.User, error) {
+	id, err := idOf(key)
+	if err != nil {
+		return bhap.User{}, err
+	}
+
+	u, _, err := scanUser(s.queryRow(ctx, s.db,
+		`SELECT `+userColumns+` FROM users WHERE id = ?`,
+		id))
+	if err != nil {
+		return bhap.User{}, fmt.Errorf("getting user: %v", err)
+	}
+
+	return u, nil
+}
+
+// UserByEmail returns the user with the given email. If no user with that
+// email exists, the key will be empty.
+func (s *Store) UserByEmail(ctx context.Context, email string) (bhap.User, bhap.Key, error) {
+	u, key, err := scanUser(s.queryRow(ctx, s.db,
+		`SELECT `+userColumns+` FROM users WHERE email = ?`,
+		email))
+	if err == sql.ErrNoRows {
+		return bhap.User{}, "", nil
+	} else if err != nil {
+		return bhap.User{}, "", err
+	}
+
+	return u, key, nil
+}
+
+// UserCount returns the number of users.
+func (s *Store) UserCount(ctx context.Context) (int, error) {
+	var count int
+	if err := s.queryRow(ctx, s.db, `SELECT COUNT(*) FROM users`).Scan(&count); err != nil {
+		return 0, fmt.Errorf("counting users: %v", err)
+	}
+
+	return count, nil
+}
+
+// AllUsers returns every user.
+func (s *Store) AllUsers(ctx context.Context) ([]bhap.User, []bhap.Key, error) {
+	rows, err := s.query(ctx, s.db,
+		`SELECT `+userColumns+` FROM users ORDER BY id`)
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting users: %v", err)
+	}
+	defer rows.Close()
+
+	users := make([]bhap.User, 0)
+	keys := make([]bhap.Key, 0)
+	for rows.Next() {
+		u, key, err := scanUser(rows)
+		if err != nil {
+			return nil, nil, fmt.Errorf("getting users: %v", err)
+		}
+		users = append(users, u)
+		keys = append(keys, key)
+	}
+
+	return users, keys, rows.Err()
+}
+
+// NewUser saves a new user and returns its key.
+func (s *Store) NewUser(ctx context.Context, u bhap.User) (bhap.Key, error) {
+	key, err := s.insert(ctx, s.db,
+		`INSERT INTO users (first_name, last_name, email, password_hash, admin,
+			weekly_digest, no_discussion_emails, no_decision_emails,
+			no_comment_emails, no_reminder_emails)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`,
+		u.FirstName, u.LastName, u.Email, u.PasswordHash, u.Admin,
+		u.WeeklyDigest, u.NoDiscussionEmails, u.NoDecisionEmails,
+		u.NoCommentEmails, u.NoReminderEmails)
+	if err != nil {
+		return "", fmt.Errorf("saving new user: %v", err)
+	}
+
+	return key, nil
+}
+
+// PutUser saves changes to an existing user.
+func (s *Store) PutUser(ctx context.Context, key bhap.Key, u bhap.User) error {
+	id, err := idOf(key)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.exec(ctx, s.db,
+		`UPDATE users SET first_name = ?, last_name = ?, email = ?,
+			password_hash = ?, admin = ?, weekly_digest = ?,
+			no_discussion_emails = ?, no_decision_emails = ?,
+			no_comment_emails = ?, no_reminder_emails = ?
+		WHERE id = ?`,
+		u.FirstName, u.LastName, u.Email, u.PasswordHash, u.Admin,
+		u.WeeklyDigest, u.NoDiscussionEmails, u.NoDecisionEmails,
+		u.NoCommentEmails, u.NoReminderEmails, id)
+	if err != nil {
+		return fmt.Errorf("saving user: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/house-emoji/bhap"
+)
+
+// VoteEventsForBHAP returns every vote event recorded for a BHAP, oldest
+// first.
+func (s *Store) VoteEventsForBHAP(ctx context.Context, bhapKey bhap.Key) ([]bhap.VoteEvent, error) {
+	bhapID, err := idOf(bhapKey)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.query(ctx, s.db,
+		`SELECT user_id, action, value, ballot_rank, previous, proxy_id,
+			revision, date
+		FROM vote_events WHERE bhap_id = ? ORDER BY date, id`,
+		bhapID)
+	if err != nil {
+		return nil, fmt.Errorf("getting vote events: %v", err)
+	}
+	defer rows.Close()
+
+	events := make([]bhap.VoteEvent, 0)
+	for rows.Next() {
+		var userID int64
+		var proxyID sql.NullInt64
+		e := bhap.VoteEvent{OnBHAP: bhapKey}
+		err := rows.Scan(&userID, &e.Action, &e.Value, &e.Rank, &e.Previous,
+			&proxyID, &e.Revision, &e.Date)
+		if err != nil {
+			return nil, fmt.Errorf("getting vote events: %v", err)
+		}
+		e.ByUser = keyOf(userID)
+		e.Proxy = nullKeyOf(proxyID)
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+// NewVoteEvent records a vote event.
+func (s *Store) NewVoteEvent(ctx context.Context, e bhap.VoteEvent) error {
+	bhapID, err := idOf(e.OnBHAP)
+	if err != nil {
+		return err
+	}
+	userID, err := idOf(e.ByUser)
+	if err != nil {
+		return err
+	}
+	proxyID, err := nullIDOf(e.Proxy)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.exec(ctx, s.db,
+		`INSERT INTO vote_events (bhap_id, user_id, action, value,
+			ballot_rank, previous, proxy_id, revision, date)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		bhapID, userID, e.Action, e.Value, e.Rank, e.Previous, proxyID,
+		e.Revision, e.Date.UTC())
+	if err != nil {
+		return fmt.Errorf("saving vote event: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/house-emoji/bhap"
+)
+
+// VoterChangesForBHAP returns every voter change recorded for a BHAP, oldest
+// first.
+func (s *Store) VoterChangesForBHAP(ctx context.Context, bhapKey bhap.Key) ([]bhap.VoterChange, error) {
+	bhapID, err := idOf(bhapKey)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.query(ctx, s.db,
+		`SELECT id, user_id, reopened_from, date FROM voter_changes
+		WHERE bhap_id = ? ORDER BY date, id`,
+		bhapID)
+	if err != nil {
+		return nil, fmt.Errorf("getting voter changes: %v", err)
+	}
+	defer rows.Close()
+
+	changes := make([]bhap.VoterChange, 0)
+	var ids []int64
+	for rows.Next() {
+		var id, userID int64
+		c := bhap.VoterChange{OnBHAP: bhapKey}
+		if err := rows.Scan(&id, &userID, &c.ReopenedFrom, &c.Date); err != nil {
+			return nil, fmt.Errorf("getting voter changes: %v", err)
+		}
+		c.ByUser = keyOf(userID)
+		changes = append(changes, c)
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("getting voter changes: %v", err)
+	}
+	// Finish with the rows before making more queries, since SQLite only
+	// has the one connection
+	rows.Close()
+
+	for i, id := range ids {
+		if err := s.loadVoterChangeMembers(ctx, id, &changes[i]); err != nil {
+			return nil, fmt.Errorf("getting voter change members: %v", err)
+		}
+	}
+
+	return changes, nil
+}
+
+// loadVoterChangeMembers fills in who was added and removed in a voter
+// change.
+func (s *Store) loadVoterChangeMembers(ctx context.Context, id int64, c *bhap.VoterChange) error {
+	rows, err := s.query(ctx, s.db,
+		`SELECT user_id, added FROM voter_change_members
+		WHERE voter_change_id = ? ORDER BY user_id`,
+		id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID int64
+		var added bool
+		if err := rows.Scan(&userID, &added); err != nil {
+			return err
+		}
+		if added {
+			c.Added = append(c.Added, keyOf(userID))
+		} else {
+			c.Removed = append(c.Removed, keyOf(userID))
+		}
+	}
+
+	return rows.Err()
+}
+
+// NewVoterChange records a voter change.
+func (s *Store) NewVoterChange(ctx context.Context, c bhap.VoterChange) error {
+	bhapID, err := idOf(c.OnBHAP)
+	if err != nil {
+		return err
+	}
+	userID, err := idOf(c.ByUser)
+	if err != nil {
+		return err
+	}
+
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		key, err := s.insert(ctx, tx,
+			`INSERT INTO voter_changes (bhap_id, user_id, reopened_from, date)
+			VALUES (?, ?, ?, ?)
+			RETURNING id`,
+			bhapID, userID, c.ReopenedFrom, c.Date.UTC())
+		if err != nil {
+			return err
+		}
+		id, err := idOf(key)
+		if err != nil {
+			return err
+		}
+
+		if err := s.saveVoterChangeMembers(ctx, tx, id, c.Added, true); err != nil {
+			return err
+		}
+		return s.saveVoterChangeMembers(ctx, tx, id, c.Removed, false)
+	})
+	if err != nil {
+		return fmt.Errorf("saving voter change: %v", err)
+	}
+
+	return nil
+}
+
+// saveVoterChangeMembers records members who were either added or removed in
+// a voter change.
+func (s *Store) saveVoterChangeMembers(ctx context.Context, tx *sql.Tx, id int64, members []bhap.Key, added bool) error {
+	for _, key := range members {
+		userID, err := idOf(key)
+		if err != nil {
+			return err
+		}
+
+		_, err = s.exec(ctx, tx,
+			`INSERT INTO voter_change_members (voter_change_id, user_id, added)
+			VALUES (?, ?, ?)`,
+			id, userID, added)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
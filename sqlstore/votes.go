@@ -0,0 +1,273 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/house-emoji/bhap"
+)
+
+// AllVotesForBHAP returns all the votes that have been cast for a given BHAP.
+func (s *Store) AllVotesForBHAP(ctx context.Context, bhapKey bhap.Key) ([]bhap.Vote, error) {
+	bhapID, err := idOf(bhapKey)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.query(ctx, s.db,
+		`SELECT user_id, value, ballot_rank, proxy_id, revision FROM votes
+		WHERE bhap_id = ? ORDER BY id`,
+		bhapID)
+	if err != nil {
+		return []bhap.Vote{}, fmt.Errorf("getting BHAP votes: %v", err)
+	}
+	defer rows.Close()
+
+	votes := make([]bhap.Vote, 0)
+	for rows.Next() {
+		var userID int64
+		var proxyID sql.NullInt64
+		vote := bhap.Vote{OnBHAP: bhapKey}
+		err := rows.Scan(&userID, &vote.Value, &vote.Rank, &proxyID,
+			&vote.Revision)
+		if err != nil {
+			return nil, fmt.Errorf("getting BHAP votes: %v", err)
+		}
+		vote.ByUser = keyOf(userID)
+		vote.Proxy = nullKeyOf(proxyID)
+		votes = append(votes, vote)
+	}
+
+	return votes, rows.Err()
+}
+
+// GetVoteForBHAP returns the user's current vote on a BHAP.
+func (s *Store) GetVoteForBHAP(ctx context.Context, bhapKey, userKey bhap.Key) (bhap.Vote, bhap.Key, error) {
+	if userKey == "" {
+		return bhap.Vote{}, "", nil
+	}
+
+	bhapID, err := idOf(bhapKey)
+	if err != nil {
+		return bhap.Vote{}, "", err
+	}
+	userID, err := idOf(userKey)
+	if err != nil {
+		return bhap.Vote{}, "", err
+	}
+
+	var id int64
+	var proxyID sql.NullInt64
+	vote := bhap.Vote{OnBHAP: bhapKey, ByUser: userKey}
+	err = s.queryRow(ctx, s.db,
+		`SELECT id, value, ballot_rank, proxy_id, revision FROM votes
+		WHERE bhap_id = ? AND user_id = ?`,
+		bhapID, userID).
+		Scan(&id, &vote.Value, &vote.Rank, &proxyID, &vote.Revision)
+	if err == sql.ErrNoRows {
+		return bhap.Vote{}, "", nil
+	} else if err != nil {
+		return bhap.Vote{}, "", fmt.Errorf("getting user's vote: %v", err)
+	}
+	vote.Proxy = nullKeyOf(proxyID)
+
+	return vote, keyOf(id), nil
+}
+
+// SetVoteForBHAP sets the vote of the user for the given BHAP to a value cast
+// on the given revision, creating a new vote if necessary.
+func (s *Store) SetVoteForBHAP(ctx context.Context, bhapKey, userKey bhap.Key, value bhap.Status, revision int) error {
+	bhapID, err := idOf(bhapKey)
+	if err != nil {
+		return err
+	}
+	userID, err := idOf(userKey)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.exec(ctx, s.db,
+		`INSERT INTO votes (bhap_id, user_id, value, ballot_rank, proxy_id,
+			revision)
+		VALUES (?, ?, ?, 0, NULL, ?)
+		ON CONFLICT (bhap_id, user_id) DO UPDATE
+		SET value = excluded.value, ballot_rank = excluded.ballot_rank,
+			proxy_id = excluded.proxy_id, revision = excluded.revision`,
+		bhapID, userID, value, revision)
+	if err != nil {
+		return fmt.Errorf("creating vote: %v", err)
+	}
+
+	return nil
+}
+
+// SetProxyVoteForBHAP sets the vote of the user for the given BHAP to a value
+// cast on their behalf by a delegate on the given revision, creating a new
+// vote if necessary.
+func (s *Store) SetProxyVoteForBHAP(ctx context.Context, bhapKey, userKey, proxyKey bhap.Key, value bhap.Status, revision int) error {
+	bhapID, err := idOf(bhapKey)
+	if err != nil {
+		return err
+	}
+	userID, err := idOf(userKey)
+	if err != nil {
+		return err
+	}
+	proxyID, err := idOf(proxyKey)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.exec(ctx, s.db,
+		`INSERT INTO votes (bhap_id, user_id, value, ballot_rank, proxy_id,
+			revision)
+		VALUES (?, ?, ?, 0, ?, ?)
+		ON CONFLICT (bhap_id, user_id) DO UPDATE
+		SET value = excluded.value, ballot_rank = excluded.ballot_rank,
+			proxy_id = excluded.proxy_id, revision = excluded.revision`,
+		bhapID, userID, value, proxyID, revision)
+	if err != nil {
+		return fmt.Errorf("saving proxy vote: %v", err)
+	}
+
+	return nil
+}
+
+// SetRankForBHAP sets the user's ranked vote for a BHAP on a ballot, creating
+// a new vote if necessary.
+func (s *Store) SetRankForBHAP(ctx context.Context, bhapKey, userKey bhap.Key, rank int) error {
+	bhapID, err := idOf(bhapKey)
+	if err != nil {
+		return err
+	}
+	userID, err := idOf(userKey)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.exec(ctx, s.db,
+		`INSERT INTO votes (bhap_id, user_id, value, ballot_rank, proxy_id,
+			revision)
+		VALUES (?, ?, ?, ?, NULL, 0)
+		ON CONFLICT (bhap_id, user_id) DO UPDATE
+		SET value = excluded.value, ballot_rank = excluded.ballot_rank,
+			proxy_id = excluded.proxy_id, revision = excluded.revision`,
+		bhapID, userID, bhap.RankedVote, rank)
+	if err != nil {
+		return fmt.Errorf("saving ranked vote: %v", err)
+	}
+
+	return nil
+}
+
+// DeleteVote deletes a cast vote.
+func (s *Store) DeleteVote(ctx context.Context, voteKey bhap.Key) error {
+	id, err := idOf(voteKey)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.exec(ctx, s.db, `DELETE FROM votes WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("deleting vote: %v", err)
+	}
+
+	return nil
+}
+
+// DeleteVotesForBHAP deletes every vote cast on a BHAP, along with any
+// secret choices made on it.
+func (s *Store) DeleteVotesForBHAP(ctx context.Context, bhapKey bhap.Key) error {
+	bhapID, err := idOf(bhapKey)
+	if err != nil {
+		return err
+	}
+
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := s.exec(ctx, tx, `DELETE FROM votes WHERE bhap_id = ?`, bhapID); err != nil {
+			return err
+		}
+		_, err := s.exec(ctx, tx, `DELETE FROM secret_choices WHERE bhap_id = ?`, bhapID)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("deleting BHAP votes: %v", err)
+	}
+
+	return nil
+}
+
+// CastSecretVote records that the user voted on a BHAP with a secret ballot
+// and counts their choice, as long as they haven't already voted. The vote's
+// unique constraint decides between concurrent votes by the same user.
+func (s *Store) CastSecretVote(ctx context.Context, bhapKey, userKey bhap.Key, value bhap.Status, revision int) error {
+	bhapID, err := idOf(bhapKey)
+	if err != nil {
+		return err
+	}
+	userID, err := idOf(userKey)
+	if err != nil {
+		return err
+	}
+
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		res, err := s.exec(ctx, tx,
+			`INSERT INTO votes (bhap_id, user_id, value, ballot_rank, proxy_id,
+				revision)
+			VALUES (?, ?, ?, 0, NULL, ?)
+			ON CONFLICT (bhap_id, user_id) DO NOTHING`,
+			bhapID, userID, bhap.SecretVote, revision)
+		if err != nil {
+			return err
+		}
+		if inserted, err := res.RowsAffected(); err != nil {
+			return err
+		} else if inserted == 0 {
+			return bhap.ErrVoteFinal
+		}
+
+		_, err = s.exec(ctx, tx,
+			`INSERT INTO secret_choices (bhap_id, value, count)
+			VALUES (?, ?, 1)
+			ON CONFLICT (bhap_id, value) DO UPDATE
+			SET count = secret_choices.count + 1`,
+			bhapID, value)
+		return err
+	})
+	if err == bhap.ErrVoteFinal {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("casting secret vote: %v", err)
+	}
+
+	return nil
+}
+
+// SecretChoices returns how many times each choice was made on a BHAP's
+// secret ballot.
+func (s *Store) SecretChoices(ctx context.Context, bhapKey bhap.Key) (map[bhap.Status]int, error) {
+	bhapID, err := idOf(bhapKey)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.query(ctx, s.db,
+		`SELECT value, count FROM secret_choices WHERE bhap_id = ?`,
+		bhapID)
+	if err != nil {
+		return nil, fmt.Errorf("getting secret choices: %v", err)
+	}
+	defer rows.Close()
+
+	choices := make(map[bhap.Status]int)
+	for rows.Next() {
+		var value bhap.Status
+		var count int
+		if err := rows.Scan(&value, &count); err != nil {
+			return nil, fmt.Errorf("getting secret choices: %v", err)
+		}
+		choices[value] = count
+	}
+
+	return choices, rows.Err()
+}
@@ -0,0 +1,215 @@
+package bhap
+
+import (
+	"context"
+	"time"
+)
+
+// Key uniquely identifies a persisted entity. Its contents are only
+// meaningful to the Store that created it. The zero value refers to no entity.
+type Key string
+
+// Store persists BHAPs, revisions, comments, votes, vote events, voter
+// changes, users, invitations, ballots, delegations and outgoing emails. Each
+// storage backend provides its own implementation.
+type Store interface {
+	BHAPStore
+	RevisionStore
+	CommentStore
+	VoteStore
+	VoteEventStore
+	VoterChangeStore
+	UserStore
+	InvitationStore
+	BallotStore
+	DelegationStore
+	OutboxStore
+}
+
+// BHAPStore persists BHAPs.
+type BHAPStore interface {
+	// ByID gets a BHAP by the given ID. If none exists, the key will be
+	// empty.
+	ByID(ctx context.Context, id int) (BHAP, Key, error)
+	// ByKey gets the BHAP with the given key.
+	ByKey(ctx context.Context, key Key) (BHAP, error)
+	// ByDraftID gets a BHAP by the given draft ID. If none exists, the key
+	// will be empty.
+	ByDraftID(ctx context.Context, draftID string) (BHAP, Key, error)
+	// NextID reserves and returns the next unused ID for a new BHAP, using
+	// the numbering convention that fits for the given type. No two calls
+	// return the same ID, even when made concurrently. If the type has run
+	// out of IDs, ErrIDsExhausted is returned.
+	NextID(ctx context.Context, typ BHAPType) (int, error)
+	// GetAll returns all recorded BHAPs, sorted by ID.
+	GetAll(ctx context.Context) ([]BHAP, error)
+	// ByStatus returns all BHAPs with the given status(es), sorted by ID.
+	ByStatus(ctx context.Context, statuses ...Status) ([]BHAP, error)
+	// NewBHAP saves a new BHAP and returns its key.
+	NewBHAP(ctx context.Context, b BHAP) (Key, error)
+	// PutBHAP saves changes to an existing BHAP.
+	PutBHAP(ctx context.Context, key Key, b BHAP) error
+}
+
+// RevisionStore persists the revisions of BHAPs. Revisions can only be added,
+// never changed or removed.
+type RevisionStore interface {
+	// RevisionsForBHAP returns every revision of a BHAP, oldest first.
+	RevisionsForBHAP(ctx context.Context, bhapKey Key) ([]Revision, error)
+	// NewRevision records a revision.
+	NewRevision(ctx context.Context, r Revision) error
+	// SaveRevision saves changes to an existing BHAP and records the given
+	// revisions of it, all in one transaction. The revisions are numbered in
+	// order, starting one after the BHAP's latest, and the BHAP's Revision is
+	// set to the last one's number. So is its SubstantiveRevision, if
+	// substantive is true. The saved BHAP is returned.
+	SaveRevision(ctx context.Context, key Key, b BHAP, substantive bool, revisions ...Revision) (BHAP, error)
+}
+
+// CommentStore persists comments on BHAPs.
+type CommentStore interface {
+	// CommentsForBHAP returns every comment on a BHAP, oldest first.
+	CommentsForBHAP(ctx context.Context, bhapKey Key) ([]Comment, []Key, error)
+	// CommentByUID returns the comment with the given UID. If none exists,
+	// the key will be empty.
+	CommentByUID(ctx context.Context, uid string) (Comment, Key, error)
+	// NewComment saves a new comment and returns its key.
+	NewComment(ctx context.Context, c Comment) (Key, error)
+	// PutComment saves changes to an existing comment.
+	PutComment(ctx context.Context, key Key, c Comment) error
+	// DeleteComment deletes a comment.
+	DeleteComment(ctx context.Context, key Key) error
+}
+
+// VoteStore persists votes on BHAPs.
+type VoteStore interface {
+	// AllVotesForBHAP returns all the votes that have been cast for a given
+	// BHAP.
+	AllVotesForBHAP(ctx context.Context, bhapKey Key) ([]Vote, error)
+	// GetVoteForBHAP returns the user's current vote on a BHAP. If the user
+	// has not voted, the key will be empty.
+	GetVoteForBHAP(ctx context.Context, bhapKey, userKey Key) (Vote, Key, error)
+	// SetVoteForBHAP sets the vote of the user for the given BHAP to a value
+	// cast on the given revision, creating a new vote if necessary.
+	SetVoteForBHAP(ctx context.Context, bhapKey, userKey Key, value Status, revision int) error
+	// SetProxyVoteForBHAP sets the vote of the user for the given BHAP to a
+	// value cast on their behalf by a delegate on the given revision,
+	// creating a new vote if necessary.
+	SetProxyVoteForBHAP(ctx context.Context, bhapKey, userKey, proxyKey Key, value Status, revision int) error
+	// SetRankForBHAP sets the user's ranked vote for a BHAP on a ballot,
+	// creating a new vote if necessary.
+	SetRankForBHAP(ctx context.Context, bhapKey, userKey Key, rank int) error
+	// DeleteVote deletes a cast vote.
+	DeleteVote(ctx context.Context, voteKey Key) error
+	// DeleteVotesForBHAP deletes every vote cast on a BHAP, along with any
+	// secret choices made on it.
+	DeleteVotesForBHAP(ctx context.Context, bhapKey Key) error
+	// CastSecretVote records that the user voted on a BHAP with a secret
+	// ballot on the given revision, and counts their choice. Only the number
+	// of times each choice was made is kept, not who made it. Both are saved
+	// together or not at all. If the user has already voted, ErrVoteFinal
+	// is returned and nothing is saved.
+	CastSecretVote(ctx context.Context, bhapKey, userKey Key, value Status, revision int) error
+	// SecretChoices returns how many times each choice was made on a BHAP's
+	// secret ballot.
+	SecretChoices(ctx context.Context, bhapKey Key) (map[Status]int, error)
+}
+
+// VoteEventStore persists the audit trail of changes to votes. Events can
+// only be added, never changed or removed.
+type VoteEventStore interface {
+	// VoteEventsForBHAP returns every vote event recorded for a BHAP, oldest
+	// first.
+	VoteEventsForBHAP(ctx context.Context, bhapKey Key) ([]VoteEvent, error)
+	// NewVoteEvent records a vote event.
+	NewVoteEvent(ctx context.Context, e VoteEvent) error
+}
+
+// VoterChangeStore persists changes to who may vote on BHAPs. Changes can only
+// be added, never changed or removed.
+type VoterChangeStore interface {
+	// VoterChangesForBHAP returns every voter change recorded for a BHAP,
+	// oldest first.
+	VoterChangesForBHAP(ctx context.Context, bhapKey Key) ([]VoterChange, error)
+	// NewVoterChange records a voter change.
+	NewVoterChange(ctx context.Context, c VoterChange) error
+}
+
+// UserStore persists members of the BHAP consortium.
+type UserStore interface {
+	// UserByKey returns the user with the given key.
+	UserByKey(ctx context.Context, key Key) (User, error)
+	// UserByEmail returns the user with the given email. If no user with
+	// that email exists, the key will be empty.
+	UserByEmail(ctx context.Context, email string) (User, Key, error)
+	// UserCount returns the number of users.
+	UserCount(ctx context.Context) (int, error)
+	// AllUsers returns every user.
+	AllUsers(ctx context.Context) ([]User, []Key, error)
+	// NewUser saves a new user and returns its key.
+	NewUser(ctx context.Context, u User) (Key, error)
+	// PutUser saves changes to an existing user.
+	PutUser(ctx context.Context, key Key, u User) error
+}
+
+// InvitationStore persists invitations to join the BHAP consortium.
+type InvitationStore interface {
+	// UnsentInvitations returns all invitations that have yet to be emailed.
+	UnsentInvitations(ctx context.Context) ([]Invitation, []Key, error)
+	// InvitationByUID returns the invitation with the corresponding UID. If
+	// none exists, the key will be empty.
+	InvitationByUID(ctx context.Context, uid string) (Invitation, Key, error)
+	// NewInvitation saves a new invitation and returns its key.
+	NewInvitation(ctx context.Context, inv Invitation) (Key, error)
+	// PutInvitation saves changes to an existing invitation.
+	PutInvitation(ctx context.Context, key Key, inv Invitation) error
+	// DeleteInvitation deletes an invitation.
+	DeleteInvitation(ctx context.Context, key Key) error
+}
+
+// BallotStore persists ballots between rival BHAPs.
+type BallotStore interface {
+	// BallotByKey returns the ballot with the given key.
+	BallotByKey(ctx context.Context, key Key) (Ballot, error)
+	// BallotByUID returns the ballot with the given UID. If none exists, the
+	// key will be empty.
+	BallotByUID(ctx context.Context, uid string) (Ballot, Key, error)
+	// OpenBallots returns all ballots that have yet to be decided.
+	OpenBallots(ctx context.Context) ([]Ballot, []Key, error)
+	// NewBallot saves a new ballot and returns its key.
+	NewBallot(ctx context.Context, b Ballot) (Key, error)
+	// PutBallot saves changes to an existing ballot.
+	PutBallot(ctx context.Context, key Key, b Ballot) error
+}
+
+// DelegationStore persists delegations of members' votes.
+type DelegationStore interface {
+	// DelegationsFrom returns every delegation made by the given user.
+	DelegationsFrom(ctx context.Context, userKey Key) ([]Delegation, []Key, error)
+	// DelegationsTo returns every delegation made to the given user.
+	DelegationsTo(ctx context.Context, userKey Key) ([]Delegation, []Key, error)
+	// DelegationByUID returns the delegation with the given UID. If none
+	// exists, the key will be empty.
+	DelegationByUID(ctx context.Context, uid string) (Delegation, Key, error)
+	// NewDelegation saves a new delegation and returns its key.
+	NewDelegation(ctx context.Context, d Delegation) (Key, error)
+	// DeleteDelegation deletes a delegation.
+	DeleteDelegation(ctx context.Context, key Key) error
+}
+
+// OutboxStore persists emails waiting to be sent.
+type OutboxStore interface {
+	// UnsentEmails returns every queued email that has yet to be sent,
+	// oldest first.
+	UnsentEmails(ctx context.Context) ([]OutgoingEmail, []Key, error)
+	// QueueEmail adds an email to the outbox.
+	QueueEmail(ctx context.Context, e OutgoingEmail) error
+	// PutEmail saves changes to a queued email.
+	PutEmail(ctx context.Context, key Key, e OutgoingEmail) error
+	// LastDigestDate returns when the weekly digest was last sent, or the
+	// zero time if it never was.
+	LastDigestDate(ctx context.Context) (time.Time, error)
+	// RecordDigest records that the weekly digest was sent at the given
+	// time.
+	RecordDigest(ctx context.Context, date time.Time) error
+}
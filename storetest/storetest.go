@@ -0,0 +1,975 @@
+// Package storetest checks that an implementation of bhap.Store behaves the
+// way the rest of the site expects. Each storage backend runs the same suite
+// from its own tests.
+package storetest
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/house-emoji/bhap"
+)
+
+// Run runs the whole suite. The factory must return a new, empty store each
+// time it's called.
+func Run(t *testing.T, newStore func(t *testing.T) bhap.Store) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s bhap.Store)
+	}{
+		{"BHAPs", testBHAPs},
+		{"NextIDConcurrent", testNextIDConcurrent},
+		{"NextIDExhausted", testNextIDExhausted},
+		{"NextIDAfterExisting", testNextIDAfterExisting},
+		{"Drafts", testDrafts},
+		{"Users", testUsers},
+		{"Votes", testVotes},
+		{"SecretChoices", testSecretChoices},
+		{"SecretVoteConcurrent", testSecretVoteConcurrent},
+		{"Revisions", testRevisions},
+		{"SaveRevisionConcurrent", testSaveRevisionConcurrent},
+		{"Ballots", testBallots},
+		{"Delegations", testDelegations},
+		{"Outbox", testOutbox},
+		{"Digests", testDigests},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			test.test(t, newStore(t))
+		})
+	}
+}
+
+// date returns midnight UTC on the given day. Stores may drop anything finer
+// than a second or the time zone, so tests stick to times like these.
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// newUser saves a user with the given email and returns its key.
+func newUser(t *testing.T, s bhap.Store, email string) bhap.Key {
+	key, err := s.NewUser(context.Background(), bhap.User{
+		FirstName:    "Test",
+		LastName:     email,
+		Email:        email,
+		PasswordHash: []byte("hash"),
+	})
+	if err != nil {
+		t.Fatalf("saving user: %v", err)
+	}
+	return key
+}
+
+// newBHAP saves a House Rule BHAP in discussion with the given ID and
+// returns its key.
+func newBHAP(t *testing.T, s bhap.Store, id int, author bhap.Key) bhap.Key {
+	key, err := s.NewBHAP(context.Background(), bhap.BHAP{
+		DraftID:      "draft" + strconv.Itoa(id),
+		ID:           id,
+		Title:        "Quiet hours",
+		Author:       author,
+		Status:       bhap.DiscussionStatus,
+		Type:         bhap.HouseRuleBHAPType,
+		CreatedDate:  date(2018, 1, 1),
+		LastModified: date(2018, 1, 1),
+	})
+	if err != nil {
+		t.Fatalf("saving BHAP: %v", err)
+	}
+	return key
+}
+
+func testBHAPs(t *testing.T, s bhap.Store) {
+	ctx := context.Background()
+	author := newUser(t, s, "author@example.com")
+
+	b := bhap.BHAP{
+		DraftID:          "abc",
+		ID:               101,
+		Title:            "Quiet hours",
+		ShortDescription: "No noise after ten",
+		Author:           author,
+		Status:           bhap.DiscussionStatus,
+		Type:             bhap.HouseRuleBHAPType,
+		Content:          "Be quiet.",
+		CreatedDate:      date(2018, 1, 1),
+		LastModified:     date(2018, 1, 2),
+		VotingDeadline:   date(2018, 1, 16),
+		Voters:           []bhap.Key{author},
+		VotersDate:       date(2018, 1, 2),
+		Revision:         1,
+	}
+	key, err := s.NewBHAP(ctx, b)
+	if err != nil {
+		t.Fatalf("saving BHAP: %v", err)
+	}
+	if key == "" {
+		t.Fatalf("saving BHAP returned an empty key")
+	}
+
+	got, err := s.ByKey(ctx, key)
+	if err != nil {
+		t.Fatalf("by key: %v", err)
+	}
+	checkBHAP(t, got, b)
+
+	got, gotKey, err := s.ByID(ctx, 101)
+	if err != nil {
+		t.Fatalf("by ID: %v", err)
+	}
+	if gotKey != key {
+		t.Errorf("by ID: got key %v, want %v", gotKey, key)
+	}
+	checkBHAP(t, got, b)
+
+	got, gotKey, err = s.ByDraftID(ctx, "abc")
+	if err != nil {
+		t.Fatalf("by draft ID: %v", err)
+	}
+	if gotKey != key {
+		t.Errorf("by draft ID: got key %v, want %v", gotKey, key)
+	}
+
+	if _, gotKey, err := s.ByID(ctx, 404); err != nil {
+		t.Errorf("by unknown ID: %v", err)
+	} else if gotKey != "" {
+		t.Errorf("by unknown ID: got key %v, want none", gotKey)
+	}
+	if _, gotKey, err := s.ByDraftID(ctx, "missing"); err != nil {
+		t.Errorf("by unknown draft ID: %v", err)
+	} else if gotKey != "" {
+		t.Errorf("by unknown draft ID: got key %v, want none", gotKey)
+	}
+
+	b.Status = bhap.AcceptedStatus
+	b.Title = "Quieter hours"
+	b.DecidedDate = date(2018, 1, 10)
+	if err := s.PutBHAP(ctx, key, b); err != nil {
+		t.Fatalf("putting BHAP: %v", err)
+	}
+	got, err = s.ByKey(ctx, key)
+	if err != nil {
+		t.Fatalf("by key after put: %v", err)
+	}
+	checkBHAP(t, got, b)
+
+	newBHAP(t, s, 100, author)
+	all, err := s.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("getting all: %v", err)
+	}
+	if ids := bhapIDs(all); !equalInts(ids, []int{100, 101}) {
+		t.Errorf("got all IDs %v, want [100 101]", ids)
+	}
+
+	accepted, err := s.ByStatus(ctx, bhap.AcceptedStatus)
+	if err != nil {
+		t.Fatalf("by status: %v", err)
+	}
+	if ids := bhapIDs(accepted); !equalInts(ids, []int{101}) {
+		t.Errorf("got accepted IDs %v, want [101]", ids)
+	}
+	both, err := s.ByStatus(ctx, bhap.AcceptedStatus, bhap.DiscussionStatus)
+	if err != nil {
+		t.Fatalf("by statuses: %v", err)
+	}
+	if ids := bhapIDs(both); !equalInts(ids, []int{100, 101}) {
+		t.Errorf("got accepted and discussion IDs %v, want [100 101]", ids)
+	}
+}
+
+// checkBHAP compares the fields of a BHAP that every store must keep.
+func checkBHAP(t *testing.T, got, want bhap.BHAP) {
+	t.Helper()
+
+	if got.DraftID != want.DraftID || got.ID != want.ID ||
+		got.Title != want.Title ||
+		got.ShortDescription != want.ShortDescription ||
+		got.Author != want.Author || got.Status != want.Status ||
+		got.Type != want.Type || got.Content != want.Content ||
+		got.Revision != want.Revision {
+		t.Errorf("got BHAP %+v, want %+v", got, want)
+	}
+	if !got.CreatedDate.Equal(want.CreatedDate) ||
+		!got.LastModified.Equal(want.LastModified) ||
+		!got.VotingDeadline.Equal(want.VotingDeadline) ||
+		!got.VotersDate.Equal(want.VotersDate) ||
+		!got.DecidedDate.Equal(want.DecidedDate) {
+		t.Errorf("got BHAP dates %v, %v, %v, %v, %v, want %v, %v, %v, %v, %v",
+			got.CreatedDate, got.LastModified, got.VotingDeadline,
+			got.VotersDate, got.DecidedDate,
+			want.CreatedDate, want.LastModified, want.VotingDeadline,
+			want.VotersDate, want.DecidedDate)
+	}
+	if !equalKeys(got.Voters, want.Voters) {
+		t.Errorf("got voters %v, want %v", got.Voters, want.Voters)
+	}
+}
+
+func testNextIDConcurrent(t *testing.T, s bhap.Store) {
+	ctx := context.Background()
+	const callers = 20
+
+	ids := make([]int, callers)
+	errs := make([]error, callers)
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ids[i], errs[i] = s.NextID(ctx, bhap.HouseRuleBHAPType)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("next ID: %v", err)
+		}
+	}
+
+	sort.Ints(ids)
+	for i, id := range ids {
+		if want := bhap.FirstHouseRuleID + i; id != want {
+			t.Fatalf("got IDs %v, want each of %v to %v once",
+				ids, bhap.FirstHouseRuleID, bhap.FirstHouseRuleID+callers-1)
+		}
+	}
+}
+
+func testNextIDExhausted(t *testing.T, s bhap.Store) {
+	ctx := context.Background()
+
+	for want := bhap.FirstMetaID; want < bhap.FirstHouseRuleID; want++ {
+		id, err := s.NextID(ctx, bhap.MetaBHAPType)
+		if err != nil {
+			t.Fatalf("next Meta ID: %v", err)
+		}
+		if id != want {
+			t.Fatalf("got Meta ID %v, want %v", id, want)
+		}
+	}
+
+	if _, err := s.NextID(ctx, bhap.MetaBHAPType); err != bhap.ErrIDsExhausted {
+		t.Errorf("next Meta ID once all are taken: got error %v, want %v",
+			err, bhap.ErrIDsExhausted)
+	}
+
+	// House Rule IDs are unaffected
+	id, err := s.NextID(ctx, bhap.HouseRuleBHAPType)
+	if err != nil {
+		t.Fatalf("next House Rule ID: %v", err)
+	}
+	if id != bhap.FirstHouseRuleID {
+		t.Errorf("got House Rule ID %v, want %v", id, bhap.FirstHouseRuleID)
+	}
+}
+
+func testNextIDAfterExisting(t *testing.T, s bhap.Store) {
+	ctx := context.Background()
+	author := newUser(t, s, "author@example.com")
+	newBHAP(t, s, 104, author)
+
+	id, err := s.NextID(ctx, bhap.HouseRuleBHAPType)
+	if err != nil {
+		t.Fatalf("next ID: %v", err)
+	}
+	if id != 105 {
+		t.Errorf("got ID %v after BHAP 104, want 105", id)
+	}
+}
+
+func testDrafts(t *testing.T, s bhap.Store) {
+	ctx := context.Background()
+	author := newUser(t, s, "author@example.com")
+
+	// Drafts all share the ID -1 until they are given one of their own
+	var keys []bhap.Key
+	for _, draftID := range []string{"first", "second", "third"} {
+		key, err := s.NewBHAP(ctx, bhap.BHAP{
+			DraftID:      draftID,
+			ID:           -1,
+			Title:        draftID,
+			Author:       author,
+			Status:       bhap.DraftStatus,
+			Type:         bhap.HouseRuleBHAPType,
+			CreatedDate:  date(2018, 1, 1),
+			LastModified: date(2018, 1, 1),
+		})
+		if err != nil {
+			t.Fatalf("saving draft %v: %v", draftID, err)
+		}
+		keys = append(keys, key)
+	}
+	if keys[0] == keys[1] || keys[1] == keys[2] || keys[0] == keys[2] {
+		t.Fatalf("got keys %v for different drafts", keys)
+	}
+
+	drafts, err := s.ByStatus(ctx, bhap.DraftStatus)
+	if err != nil {
+		t.Fatalf("by status: %v", err)
+	}
+	if len(drafts) != 3 {
+		t.Errorf("got %v drafts, want 3", len(drafts))
+	}
+
+	second, key, err := s.ByDraftID(ctx, "second")
+	if err != nil {
+		t.Fatalf("by draft ID: %v", err)
+	}
+	if key != keys[1] {
+		t.Errorf("by draft ID: got key %v, want %v", key, keys[1])
+	}
+
+	second.ID = 100
+	second.Status = bhap.DiscussionStatus
+	if err := s.PutBHAP(ctx, key, second); err != nil {
+		t.Fatalf("giving draft an ID: %v", err)
+	}
+	if _, gotKey, err := s.ByID(ctx, 100); err != nil {
+		t.Fatalf("by ID: %v", err)
+	} else if gotKey != key {
+		t.Errorf("by ID: got key %v, want %v", gotKey, key)
+	}
+
+	if _, gotKey, err := s.ByDraftID(ctx, "first"); err != nil {
+		t.Fatalf("by draft ID: %v", err)
+	} else if gotKey != keys[0] {
+		t.Errorf("other draft: got key %v, want %v", gotKey, keys[0])
+	}
+}
+
+func testUsers(t *testing.T, s bhap.Store) {
+	ctx := context.Background()
+
+	if count, err := s.UserCount(ctx); err != nil {
+		t.Fatalf("counting users: %v", err)
+	} else if count != 0 {
+		t.Errorf("got %v users in an empty store", count)
+	}
+
+	alice := newUser(t, s, "alice@example.com")
+	bob := newUser(t, s, "bob@example.com")
+
+	u, err := s.UserByKey(ctx, alice)
+	if err != nil {
+		t.Fatalf("by key: %v", err)
+	}
+	if u.Email != "alice@example.com" || string(u.PasswordHash) != "hash" {
+		t.Errorf("got user %+v, want alice", u)
+	}
+
+	u, key, err := s.UserByEmail(ctx, "bob@example.com")
+	if err != nil {
+		t.Fatalf("by email: %v", err)
+	}
+	if key != bob || u.Email != "bob@example.com" {
+		t.Errorf("by email: got %v %+v, want bob", key, u)
+	}
+	if _, key, err := s.UserByEmail(ctx, "nobody@example.com"); err != nil {
+		t.Errorf("by unknown email: %v", err)
+	} else if key != "" {
+		t.Errorf("by unknown email: got key %v, want none", key)
+	}
+
+	if count, err := s.UserCount(ctx); err != nil {
+		t.Fatalf("counting users: %v", err)
+	} else if count != 2 {
+		t.Errorf("got %v users, want 2", count)
+	}
+
+	_, keys, err := s.AllUsers(ctx)
+	if err != nil {
+		t.Fatalf("all users: %v", err)
+	}
+	if !equalKeys(keys, []bhap.Key{alice, bob}) {
+		t.Errorf("got user keys %v, want %v", keys, []bhap.Key{alice, bob})
+	}
+
+	u, err = s.UserByKey(ctx, alice)
+	if err != nil {
+		t.Fatalf("by key: %v", err)
+	}
+	u.Admin = true
+	u.WeeklyDigest = true
+	u.NoCommentEmails = true
+	if err := s.PutUser(ctx, alice, u); err != nil {
+		t.Fatalf("putting user: %v", err)
+	}
+	got, err := s.UserByKey(ctx, alice)
+	if err != nil {
+		t.Fatalf("by key after put: %v", err)
+	}
+	if !got.Admin || !got.WeeklyDigest || !got.NoCommentEmails || got.NoReminderEmails {
+		t.Errorf("got user %+v after put, want %+v", got, u)
+	}
+}
+
+func testVotes(t *testing.T, s bhap.Store) {
+	ctx := context.Background()
+	author := newUser(t, s, "author@example.com")
+	alice := newUser(t, s, "alice@example.com")
+	bob := newUser(t, s, "bob@example.com")
+	bhapKey := newBHAP(t, s, 100, author)
+	otherKey := newBHAP(t, s, 101, author)
+
+	if _, key, err := s.GetVoteForBHAP(ctx, bhapKey, alice); err != nil {
+		t.Fatalf("getting missing vote: %v", err)
+	} else if key != "" {
+		t.Errorf("got vote key %v before voting, want none", key)
+	}
+
+	if err := s.SetVoteForBHAP(ctx, bhapKey, alice, bhap.AcceptedStatus, 1); err != nil {
+		t.Fatalf("voting: %v", err)
+	}
+	if err := s.SetVoteForBHAP(ctx, bhapKey, alice, bhap.RejectedStatus, 2); err != nil {
+		t.Fatalf("changing vote: %v", err)
+	}
+	if err := s.SetProxyVoteForBHAP(ctx, bhapKey, bob, alice, bhap.AbstainVote, 2); err != nil {
+		t.Fatalf("voting by proxy: %v", err)
+	}
+	if err := s.SetRankForBHAP(ctx, otherKey, alice, 2); err != nil {
+		t.Fatalf("ranking: %v", err)
+	}
+
+	vote, aliceVote, err := s.GetVoteForBHAP(ctx, bhapKey, alice)
+	if err != nil {
+		t.Fatalf("getting vote: %v", err)
+	}
+	want := bhap.Vote{OnBHAP: bhapKey, ByUser: alice, Value: bhap.RejectedStatus, Revision: 2}
+	if vote != want {
+		t.Errorf("got vote %+v, want %+v", vote, want)
+	}
+
+	vote, _, err = s.GetVoteForBHAP(ctx, bhapKey, bob)
+	if err != nil {
+		t.Fatalf("getting proxy vote: %v", err)
+	}
+	want = bhap.Vote{OnBHAP: bhapKey, ByUser: bob, Value: bhap.AbstainVote, Proxy: alice, Revision: 2}
+	if vote != want {
+		t.Errorf("got proxy vote %+v, want %+v", vote, want)
+	}
+
+	vote, _, err = s.GetVoteForBHAP(ctx, otherKey, alice)
+	if err != nil {
+		t.Fatalf("getting ranked vote: %v", err)
+	}
+	want = bhap.Vote{OnBHAP: otherKey, ByUser: alice, Value: bhap.RankedVote, Rank: 2}
+	if vote != want {
+		t.Errorf("got ranked vote %+v, want %+v", vote, want)
+	}
+
+	votes, err := s.AllVotesForBHAP(ctx, bhapKey)
+	if err != nil {
+		t.Fatalf("all votes: %v", err)
+	}
+	if len(votes) != 2 {
+		t.Errorf("got %v votes, want one each from alice and bob", len(votes))
+	}
+
+	if err := s.DeleteVote(ctx, aliceVote); err != nil {
+		t.Fatalf("deleting vote: %v", err)
+	}
+	if _, key, err := s.GetVoteForBHAP(ctx, bhapKey, alice); err != nil {
+		t.Fatalf("getting deleted vote: %v", err)
+	} else if key != "" {
+		t.Errorf("got vote key %v after deleting it, want none", key)
+	}
+
+	if err := s.DeleteVotesForBHAP(ctx, bhapKey); err != nil {
+		t.Fatalf("deleting votes: %v", err)
+	}
+	if votes, err := s.AllVotesForBHAP(ctx, bhapKey); err != nil {
+		t.Fatalf("all votes: %v", err)
+	} else if len(votes) != 0 {
+		t.Errorf("got %v votes after deleting them all", len(votes))
+	}
+	if votes, err := s.AllVotesForBHAP(ctx, otherKey); err != nil {
+		t.Fatalf("all votes: %v", err)
+	} else if len(votes) != 1 {
+		t.Errorf("got %v votes on another BHAP, want 1 to be left", len(votes))
+	}
+}
+
+func testSecretChoices(t *testing.T, s bhap.Store) {
+	ctx := context.Background()
+	author := newUser(t, s, "author@example.com")
+	bhapKey := newBHAP(t, s, 100, author)
+
+	alice := newUser(t, s, "alice@example.com")
+	bob := newUser(t, s, "bob@example.com")
+	carol := newUser(t, s, "carol@example.com")
+
+	votes := []struct {
+		user  bhap.Key
+		value bhap.Status
+	}{
+		{alice, bhap.AcceptedStatus},
+		{bob, bhap.AcceptedStatus},
+		{carol, bhap.AbstainVote},
+	}
+	for _, v := range votes {
+		if err := s.CastSecretVote(ctx, bhapKey, v.user, v.value, 1); err != nil {
+			t.Fatalf("casting secret vote: %v", err)
+		}
+	}
+
+	// Votes on a secret ballot can't be changed
+	if err := s.CastSecretVote(ctx, bhapKey, alice, bhap.RejectedStatus, 1); err != bhap.ErrVoteFinal {
+		t.Errorf("voting again: got error %v, want %v", err, bhap.ErrVoteFinal)
+	}
+
+	vote, _, err := s.GetVoteForBHAP(ctx, bhapKey, alice)
+	if err != nil {
+		t.Fatalf("getting vote: %v", err)
+	}
+	want := bhap.Vote{OnBHAP: bhapKey, ByUser: alice, Value: bhap.SecretVote, Revision: 1}
+	if vote != want {
+		t.Errorf("got vote %+v, want %+v", vote, want)
+	}
+
+	choices, err := s.SecretChoices(ctx, bhapKey)
+	if err != nil {
+		t.Fatalf("secret choices: %v", err)
+	}
+	if choices[bhap.AcceptedStatus] != 2 || choices[bhap.RejectedStatus] != 0 ||
+		choices[bhap.AbstainVote] != 1 {
+		t.Errorf("got secret choices %v, want 2 accepted and 1 abstained", choices)
+	}
+
+	if err := s.DeleteVotesForBHAP(ctx, bhapKey); err != nil {
+		t.Fatalf("deleting votes: %v", err)
+	}
+	choices, err = s.SecretChoices(ctx, bhapKey)
+	if err != nil {
+		t.Fatalf("secret choices: %v", err)
+	}
+	if len(choices) != 0 {
+		t.Errorf("got secret choices %v after deleting votes, want none", choices)
+	}
+}
+
+func testSecretVoteConcurrent(t *testing.T, s bhap.Store) {
+	ctx := context.Background()
+	author := newUser(t, s, "author@example.com")
+	alice := newUser(t, s, "alice@example.com")
+	bhapKey := newBHAP(t, s, 100, author)
+	const attempts = 10
+
+	errs := make([]error, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = s.CastSecretVote(ctx, bhapKey, alice, bhap.AcceptedStatus, 1)
+		}(i)
+	}
+	wg.Wait()
+
+	cast := 0
+	for _, err := range errs {
+		if err == nil {
+			cast++
+		} else if err != bhap.ErrVoteFinal {
+			t.Fatalf("casting secret vote: %v", err)
+		}
+	}
+	if cast != 1 {
+		t.Errorf("%v of %v concurrent votes by one member were cast, want 1",
+			cast, attempts)
+	}
+
+	choices, err := s.SecretChoices(ctx, bhapKey)
+	if err != nil {
+		t.Fatalf("secret choices: %v", err)
+	}
+	if choices[bhap.AcceptedStatus] != 1 {
+		t.Errorf("got secret choices %v, want 1 accepted", choices)
+	}
+}
+
+func testRevisions(t *testing.T, s bhap.Store) {
+	ctx := context.Background()
+	author := newUser(t, s, "author@example.com")
+	bhapKey := newBHAP(t, s, 100, author)
+
+	first := bhap.Revision{
+		OnBHAP:  bhapKey,
+		Number:  1,
+		Author:  author,
+		Date:    date(2018, 1, 1),
+		Title:   "Quiet hours",
+		Content: "Be quiet.",
+	}
+	if err := s.NewRevision(ctx, first); err != nil {
+		t.Fatalf("saving revision: %v", err)
+	}
+
+	b, err := s.ByKey(ctx, bhapKey)
+	if err != nil {
+		t.Fatalf("getting BHAP: %v", err)
+	}
+	b.Content = "Be very quiet."
+	b.SubstantiveRevision = 1
+	second := bhap.Revision{
+		OnBHAP:  bhapKey,
+		Author:  author,
+		Date:    date(2018, 1, 2),
+		Summary: "Stronger wording",
+		Title:   "Quiet hours",
+		Content: "Be very quiet.",
+	}
+	saved, err := s.SaveRevision(ctx, bhapKey, b, false, second)
+	if err != nil {
+		t.Fatalf("saving revision: %v", err)
+	}
+	if saved.Revision != 2 || saved.SubstantiveRevision != 1 {
+		t.Errorf("saved BHAP at revision %v, substantive revision %v, want 2 and 1",
+			saved.Revision, saved.SubstantiveRevision)
+	}
+
+	got, err := s.ByKey(ctx, bhapKey)
+	if err != nil {
+		t.Fatalf("getting BHAP: %v", err)
+	}
+	if got.Content != "Be very quiet." || got.Revision != 2 {
+		t.Errorf("got BHAP at revision %v with content %q, want revision 2 with the edits",
+			got.Revision, got.Content)
+	}
+
+	revisions, err := s.RevisionsForBHAP(ctx, bhapKey)
+	if err != nil {
+		t.Fatalf("getting revisions: %v", err)
+	}
+	second.Number = 2
+	want := []bhap.Revision{first, second}
+	if len(revisions) != len(want) {
+		t.Fatalf("got %v revisions, want %v", len(revisions), len(want))
+	}
+	for i := range want {
+		if got := revisions[i]; got.Number != want[i].Number ||
+			got.Author != want[i].Author || !got.Date.Equal(want[i].Date) ||
+			got.Summary != want[i].Summary || got.Content != want[i].Content {
+			t.Errorf("got revision %+v, want %+v", got, want[i])
+		}
+	}
+}
+
+func testSaveRevisionConcurrent(t *testing.T, s bhap.Store) {
+	ctx := context.Background()
+	author := newUser(t, s, "author@example.com")
+	bhapKey := newBHAP(t, s, 100, author)
+	b, err := s.ByKey(ctx, bhapKey)
+	if err != nil {
+		t.Fatalf("getting BHAP: %v", err)
+	}
+	const editors = 10
+
+	saved := make([]bhap.BHAP, editors)
+	errs := make([]error, editors)
+	var wg sync.WaitGroup
+	for i := 0; i < editors; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			edited := b
+			edited.Content = "Edit " + strconv.Itoa(i)
+			saved[i], errs[i] = s.SaveRevision(ctx, bhapKey, edited, true,
+				bhap.Revision{
+					OnBHAP:  bhapKey,
+					Author:  author,
+					Date:    date(2018, 1, 2),
+					Content: edited.Content,
+				})
+		}(i)
+	}
+	wg.Wait()
+
+	// Stores may refuse some of the edits, but never save two under the
+	// same number
+	numbers := make(map[int]bool)
+	for i, err := range errs {
+		if err != nil {
+			continue
+		}
+		if numbers[saved[i].Revision] {
+			t.Errorf("more than one edit was saved as revision %v", saved[i].Revision)
+		}
+		numbers[saved[i].Revision] = true
+	}
+	if len(numbers) == 0 {
+		t.Fatalf("no edits were saved: %v", errs[0])
+	}
+
+	revisions, err := s.RevisionsForBHAP(ctx, bhapKey)
+	if err != nil {
+		t.Fatalf("getting revisions: %v", err)
+	}
+	if len(revisions) != len(numbers) {
+		t.Fatalf("got %v revisions, want %v", len(revisions), len(numbers))
+	}
+	for i, r := range revisions {
+		if r.Number != i+1 {
+			t.Errorf("got revision numbered %v, want %v", r.Number, i+1)
+		}
+	}
+
+	latest := revisions[len(revisions)-1]
+	got, err := s.ByKey(ctx, bhapKey)
+	if err != nil {
+		t.Fatalf("getting BHAP: %v", err)
+	}
+	if got.Revision != latest.Number || got.Content != latest.Content {
+		t.Errorf("got BHAP at revision %v with content %q, want revision %v with content %q",
+			got.Revision, got.Content, latest.Number, latest.Content)
+	}
+}
+
+func testBallots(t *testing.T, s bhap.Store) {
+	ctx := context.Background()
+	author := newUser(t, s, "author@example.com")
+	first := newBHAP(t, s, 100, author)
+	second := newBHAP(t, s, 101, author)
+
+	b := bhap.Ballot{
+		UID:         "ballot",
+		Title:       "Pick one",
+		Options:     []bhap.Key{first, second},
+		CreatedBy:   author,
+		CreatedDate: date(2018, 1, 1),
+		Deadline:    date(2018, 1, 15),
+	}
+	key, err := s.NewBallot(ctx, b)
+	if err != nil {
+		t.Fatalf("saving ballot: %v", err)
+	}
+
+	got, err := s.BallotByKey(ctx, key)
+	if err != nil {
+		t.Fatalf("by key: %v", err)
+	}
+	if got.UID != b.UID || got.Title != b.Title || got.CreatedBy != b.CreatedBy ||
+		!equalKeys(got.Options, b.Options) || !got.Deadline.Equal(b.Deadline) {
+		t.Errorf("got ballot %+v, want %+v", got, b)
+	}
+
+	if _, gotKey, err := s.BallotByUID(ctx, "ballot"); err != nil {
+		t.Fatalf("by UID: %v", err)
+	} else if gotKey != key {
+		t.Errorf("by UID: got key %v, want %v", gotKey, key)
+	}
+	if _, gotKey, err := s.BallotByUID(ctx, "missing"); err != nil {
+		t.Errorf("by unknown UID: %v", err)
+	} else if gotKey != "" {
+		t.Errorf("by unknown UID: got key %v, want none", gotKey)
+	}
+
+	if _, keys, err := s.OpenBallots(ctx); err != nil {
+		t.Fatalf("open ballots: %v", err)
+	} else if !equalKeys(keys, []bhap.Key{key}) {
+		t.Errorf("got open ballots %v, want %v", keys, []bhap.Key{key})
+	}
+
+	b.Closed = true
+	b.Counted = []bhap.Key{first, second}
+	b.Winner = second
+	b.Quorum = 0.5
+	if err := s.PutBallot(ctx, key, b); err != nil {
+		t.Fatalf("putting ballot: %v", err)
+	}
+	got, err = s.BallotByKey(ctx, key)
+	if err != nil {
+		t.Fatalf("by key after put: %v", err)
+	}
+	if !got.Closed || got.Winner != second || !equalKeys(got.Counted, b.Counted) ||
+		got.Quorum != b.Quorum {
+		t.Errorf("got ballot %+v after closing, want %+v", got, b)
+	}
+
+	if _, keys, err := s.OpenBallots(ctx); err != nil {
+		t.Fatalf("open ballots: %v", err)
+	} else if len(keys) != 0 {
+		t.Errorf("got open ballots %v after closing, want none", keys)
+	}
+}
+
+func testDelegations(t *testing.T, s bhap.Store) {
+	ctx := context.Background()
+	alice := newUser(t, s, "alice@example.com")
+	bob := newUser(t, s, "bob@example.com")
+	carol := newUser(t, s, "carol@example.com")
+	bhapKey := newBHAP(t, s, 100, carol)
+
+	ranged := bhap.Delegation{
+		UID:         "ranged",
+		From:        alice,
+		To:          bob,
+		Start:       date(2018, 1, 1),
+		End:         date(2018, 2, 1),
+		CreatedDate: date(2018, 1, 1),
+	}
+	rangedKey, err := s.NewDelegation(ctx, ranged)
+	if err != nil {
+		t.Fatalf("saving delegation: %v", err)
+	}
+	single := bhap.Delegation{
+		UID:         "single",
+		From:        alice,
+		To:          carol,
+		BHAP:        bhapKey,
+		CreatedDate: date(2018, 1, 2),
+	}
+	if _, err := s.NewDelegation(ctx, single); err != nil {
+		t.Fatalf("saving delegation: %v", err)
+	}
+
+	from, _, err := s.DelegationsFrom(ctx, alice)
+	if err != nil {
+		t.Fatalf("delegations from: %v", err)
+	}
+	if len(from) != 2 {
+		t.Errorf("got %v delegations from alice, want 2", len(from))
+	}
+
+	to, _, err := s.DelegationsTo(ctx, bob)
+	if err != nil {
+		t.Fatalf("delegations to: %v", err)
+	}
+	if len(to) != 1 || to[0].UID != "ranged" || to[0].From != alice ||
+		!to[0].Start.Equal(ranged.Start) || !to[0].End.Equal(ranged.End) {
+		t.Errorf("got delegations to bob %+v, want %+v", to, ranged)
+	}
+
+	got, key, err := s.DelegationByUID(ctx, "single")
+	if err != nil {
+		t.Fatalf("by UID: %v", err)
+	}
+	if key == "" || got.BHAP != bhapKey || got.To != carol {
+		t.Errorf("by UID: got %v %+v, want %+v", key, got, single)
+	}
+	if _, key, err := s.DelegationByUID(ctx, "missing"); err != nil {
+		t.Errorf("by unknown UID: %v", err)
+	} else if key != "" {
+		t.Errorf("by unknown UID: got key %v, want none", key)
+	}
+
+	if err := s.DeleteDelegation(ctx, rangedKey); err != nil {
+		t.Fatalf("deleting delegation: %v", err)
+	}
+	if to, _, err := s.DelegationsTo(ctx, bob); err != nil {
+		t.Fatalf("delegations to: %v", err)
+	} else if len(to) != 0 {
+		t.Errorf("got %v delegations to bob after deleting, want none", len(to))
+	}
+}
+
+func testOutbox(t *testing.T, s bhap.Store) {
+	ctx := context.Background()
+
+	emails := []bhap.OutgoingEmail{
+		{To: "alice@example.com", Subject: "First", Body: "one",
+			Queued: date(2018, 1, 1), UnsubscribeURL: "https://example.com/u"},
+		{To: "bob@example.com", Subject: "Second", Body: "two",
+			Queued: date(2018, 1, 2)},
+	}
+	for _, e := range emails {
+		if err := s.QueueEmail(ctx, e); err != nil {
+			t.Fatalf("queueing email: %v", err)
+		}
+	}
+
+	unsent, keys, err := s.UnsentEmails(ctx)
+	if err != nil {
+		t.Fatalf("unsent emails: %v", err)
+	}
+	if len(unsent) != 2 {
+		t.Fatalf("got %v unsent emails, want 2", len(unsent))
+	}
+	for i, e := range unsent {
+		if e.To != emails[i].To || e.Subject != emails[i].Subject ||
+			e.Body != emails[i].Body || e.UnsubscribeURL != emails[i].UnsubscribeURL ||
+			!e.Queued.Equal(emails[i].Queued) {
+			t.Errorf("got unsent email %v as %+v, want %+v", i, e, emails[i])
+		}
+	}
+
+	sent := unsent[0]
+	sent.Sent = true
+	if err := s.PutEmail(ctx, keys[0], sent); err != nil {
+		t.Fatalf("putting email: %v", err)
+	}
+
+	unsent, _, err = s.UnsentEmails(ctx)
+	if err != nil {
+		t.Fatalf("unsent emails: %v", err)
+	}
+	if len(unsent) != 1 || unsent[0].Subject != "Second" {
+		t.Errorf("got unsent emails %+v after sending the first, want the second", unsent)
+	}
+}
+
+func testDigests(t *testing.T, s bhap.Store) {
+	ctx := context.Background()
+
+	last, err := s.LastDigestDate(ctx)
+	if err != nil {
+		t.Fatalf("getting last digest: %v", err)
+	}
+	if !last.IsZero() {
+		t.Errorf("got last digest at %v before any were sent", last)
+	}
+
+	// Recording an older digest doesn't go back in time
+	for _, d := range []time.Time{date(2018, 1, 1), date(2018, 1, 8), date(2018, 1, 3)} {
+		if err := s.RecordDigest(ctx, d); err != nil {
+			t.Fatalf("recording digest: %v", err)
+		}
+	}
+
+	last, err = s.LastDigestDate(ctx)
+	if err != nil {
+		t.Fatalf("getting last digest: %v", err)
+	}
+	if !last.Equal(date(2018, 1, 8)) {
+		t.Errorf("got last digest at %v, want %v", last, date(2018, 1, 8))
+	}
+}
+
+func bhapIDs(bhaps []bhap.BHAP) []int {
+	ids := make([]int, len(bhaps))
+	for i, b := range bhaps {
+		ids[i] = b.ID
+	}
+	return ids
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func equalKeys(a, b []bhap.Key) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -3,19 +3,28 @@ package bhap
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"golang.org/x/crypto/bcrypt"
-	"google.golang.org/appengine/datastore"
 )
 
-const UserEntityName = "User"
-
 // User contains data on a member of the BHAP consortium.
 type User struct {
 	FirstName    string
 	LastName     string
 	Email        string
 	PasswordHash []byte
+	// Admin is true if the user may manage other users' BHAPs.
+	Admin bool
+	// WeeklyDigest is true if the user chose to get a weekly summary of
+	// what happened to BHAPs.
+	WeeklyDigest bool
+	// The rest are set if the user chose not to get each other kind of
+	// email, so that those are on by default
+	NoDiscussionEmails bool
+	NoDecisionEmails   bool
+	NoCommentEmails    bool
+	NoReminderEmails   bool
 }
 
 func (u User) String() string {
@@ -24,39 +33,47 @@ func (u User) String() string {
 
 // CheckLogin checks the given login credentials and returns true if they are
 // correct.
-func CheckLogin(ctx context.Context, email, password string) (bool, error) {
-	var results []User
-	query := datastore.NewQuery(UserEntityName).
-		Filter("Email =", email).
-		Limit(1)
-	if _, err := query.GetAll(ctx, &results); err != nil {
+func CheckLogin(ctx context.Context, s UserStore, email, password string) (bool, error) {
+	user, key, err := s.UserByEmail(ctx, email)
+	if err != nil {
 		return false, err
 	}
 
-	if len(results) == 0 {
+	if key == "" {
 		return false, nil
 	}
 
-	err := bcrypt.CompareHashAndPassword(results[0].PasswordHash, []byte(password))
+	err = bcrypt.CompareHashAndPassword(user.PasswordHash, []byte(password))
 
 	return err == nil, nil
 }
 
-// UserByEmail returns the user with the given email. If no user with that
-// email exists, the key will be nil.
-func UserByEmail(ctx context.Context, email string) (User, *datastore.Key, error) {
-	var results []User
-	query := datastore.NewQuery(UserEntityName).
-		Filter("Email =", email).
-		Limit(1)
-	keys, err := query.GetAll(ctx, &results)
-	if err != nil {
-		return User{}, nil, err
+// GrantListedAdmin makes the user with the given email an admin if the
+// settings list them as one. It returns true if the user was made an admin
+// just now.
+func GrantListedAdmin(ctx context.Context, s UserStore, email string) (bool, error) {
+	listed := false
+	for _, admin := range CurrentSettings().Admins {
+		if strings.EqualFold(admin, email) {
+			listed = true
+			break
+		}
+	}
+	if !listed {
+		return false, nil
 	}
 
-	if len(results) == 0 {
-		return User{}, nil, nil
+	user, key, err := s.UserByEmail(ctx, email)
+	if err != nil {
+		return false, err
+	}
+	if key == "" || user.Admin {
+		return false, nil
 	}
 
-	return results[0], keys[0], nil
+	user.Admin = true
+	if err := s.PutUser(ctx, key, user); err != nil {
+		return false, fmt.Errorf("saving user: %v", err)
+	}
+	return true, nil
 }
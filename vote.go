@@ -3,124 +3,163 @@ package bhap
 import (
 	"context"
 	"fmt"
+	"time"
 
-	"google.golang.org/appengine/datastore"
-	"google.golang.org/appengine/log"
+	"github.com/house-emoji/bhap/log"
 )
 
-const voteEntityName = "Vote"
-
 // Vote represents a user's vote for a BHAP.
 type Vote struct {
-	OnBHAP *datastore.Key
-	ByUser *datastore.Key
+	OnBHAP Key
+	ByUser Key
 	Value  Status
+	// Rank is where the user placed the BHAP on a ballot, starting from 1.
+	// It is only set for ranked votes
+	Rank int
+	// Proxy is the key of the delegate who cast the vote on the user's
+	// behalf. It is empty if the user voted themselves.
+	Proxy Key
+	// Revision is the number of the BHAP's revision the vote was cast on. It
+	// is zero for ranked votes and votes cast before revisions were recorded
+	Revision int
 }
 
-// AllVotesForBHAP returns all the votes that have been cast for a given BHAP.
-func AllVotesForBHAP(ctx context.Context, bhapKey *datastore.Key) ([]Vote, error) {
-	var votes []Vote
-	_, err := datastore.NewQuery(voteEntityName).
-		Ancestor(bhapKey).
-		GetAll(ctx, &votes)
-	if err != nil {
-		return []Vote{}, fmt.Errorf("getting BHAP votes: %v", err)
-	}
+// AbstainVote is the value of a vote that neither accepts nor rejects a
+// BHAP. It counts toward quorum, but not toward the threshold.
+const AbstainVote Status = "Abstain"
+
+// SecretVote is the value of a vote on a BHAP with a secret ballot. It only
+// records that the member voted. Their choice is counted separately, without
+// being linked to them.
+const SecretVote Status = "Secret"
+
+// Tally is a count of the votes cast on a BHAP.
+type Tally struct {
+	Accepted  int
+	Rejected  int
+	Abstained int
+	// Eligible is the number of members who may vote on the BHAP.
+	Eligible int
+}
 
-	return votes, nil
+// Cast returns the number of votes that have been cast, including
+// abstentions.
+func (t Tally) Cast() int {
+	return t.Accepted + t.Rejected + t.Abstained
 }
 
-// GetVoteForBHAP returns the user's current vote on a BHAP.
-func GetVoteForBHAP(ctx context.Context, bhapKey, userKey *datastore.Key) (Vote, *datastore.Key, error) {
-	var votes []Vote
-	keys, err := datastore.NewQuery(voteEntityName).
-		Ancestor(bhapKey).
-		Filter("ByUser =", userKey).
-		GetAll(ctx, &votes)
-	if err != nil {
-		return Vote{}, nil, fmt.Errorf("getting user's vote: %v", err)
+// Decisive returns the number of votes that either accept or reject.
+func (t Tally) Decisive() int {
+	return t.Accepted + t.Rejected
+}
+
+// CountVotes tallies the votes cast on a BHAP by its eligible voters. For
+// BHAPs with a secret ballot, the secret choices are counted instead of the
+// votes themselves.
+func CountVotes(ctx context.Context, s Store, bhapKey Key, forBHAP BHAP) (Tally, error) {
+	var t Tally
+	if forBHAP.SecretBallot {
+		choices, err := s.SecretChoices(ctx, bhapKey)
+		if err != nil {
+			return Tally{}, err
+		}
+		t.Accepted = choices[AcceptedStatus]
+		t.Rejected = choices[RejectedStatus]
+		t.Abstained = choices[AbstainVote]
+	} else {
+		votes, err := s.AllVotesForBHAP(ctx, bhapKey)
+		if err != nil {
+			return Tally{}, err
+		}
+
+		for _, vote := range votes {
+			if !forBHAP.CanVote(vote.ByUser) {
+				continue
+			}
+
+			if vote.Value == AcceptedStatus {
+				t.Accepted++
+			} else if vote.Value == RejectedStatus {
+				t.Rejected++
+			} else if vote.Value == AbstainVote {
+				t.Abstained++
+			}
+		}
 	}
 
-	if len(votes) == 0 {
-		return Vote{}, nil, nil
+	if !forBHAP.VotersDate.IsZero() {
+		t.Eligible = len(forBHAP.Voters)
 	} else {
-		return votes[0], keys[0], nil
+		userCnt, err := s.UserCount(ctx)
+		if err != nil {
+			return Tally{}, fmt.Errorf("counting users: %v", err)
+		}
+		// Everyone but the author and co-authors may vote
+		t.Eligible = userCnt - 1 - len(forBHAP.CoAuthors)
 	}
+
+	return t, nil
 }
 
-// SetVoteForBHAP sets the vote of the user for the given BHAP to a value,
-// creating a new vote object if necessary.
-func SetVoteForBHAP(ctx context.Context, bhapKey, userKey *datastore.Key, value Status) error {
-	var existingVotes []Vote
-	existingKeys, err := datastore.NewQuery(voteEntityName).
-		Ancestor(bhapKey).
-		Filter("ByUser =", userKey).
-		GetAll(ctx, &existingVotes)
+// CheckVotes counts up all votes for a BHAP and changes its status if
+// necessary. Before the voting deadline, all users must vote for the BHAP to
+// be finalized. The BHAP is then decided by the voting rule for its type.
+// BHAPs on a ballot are decided along with the rest of the ballot.
+func CheckVotes(ctx context.Context, s Store, bhapKey Key, forBHAP BHAP) error {
+	if forBHAP.Ballot != "" {
+		ballot, err := s.BallotByKey(ctx, forBHAP.Ballot)
+		if err != nil {
+			return fmt.Errorf("loading ballot: %v", err)
+		}
+		return CheckBallot(ctx, s, forBHAP.Ballot, ballot)
+	}
+
+	t, err := CountVotes(ctx, s, bhapKey, forBHAP)
 	if err != nil {
-		return fmt.Errorf("looking for existing votes: %v", err)
+		return err
 	}
 
-	var voteToSave Vote
-	var voteKey *datastore.Key
+	if t.Eligible == 0 || t.Cast() != t.Eligible {
+		return nil
+	}
 
-	if len(existingVotes) == 0 {
-		// Make a new vote if one doesn't exist
-		voteKey = datastore.NewIncompleteKey(ctx, voteEntityName, bhapKey)
-		voteToSave = Vote{
-			OnBHAP: bhapKey,
-			ByUser: userKey,
-			Value:  value}
-	} else {
-		// Edit the existing vote
-		voteKey = existingKeys[0]
-		voteToSave = existingVotes[0]
-		voteToSave.Value = value
+	decision := RejectedStatus
+	if RuleFor(forBHAP.Type).Passes(t) {
+		decision = AcceptedStatus
 	}
 
-	if _, err := datastore.Put(ctx, voteKey, &voteToSave); err != nil {
-		return fmt.Errorf("creating vote: %v", err)
+	if _, err := ChangeStatus(ctx, s, bhapKey, forBHAP, decision, SystemActor); err != nil {
+		return fmt.Errorf("finalizing BHAP: %v", err)
 	}
 
 	return nil
 }
 
-// CheckVotes counts up all votes for a BHAP and changes its status if
-// necessary. All users must vote for the BHAP to be finalized.
-func CheckVotes(ctx context.Context, bhapKey *datastore.Key, forBHAP BHAP) error {
-	votes, err := AllVotesForBHAP(ctx, bhapKey)
-	if err != nil {
-		return err
-	}
-
-	accepted := 0
-	rejected := 0
-	for _, vote := range votes {
-		if vote.Value == AcceptedStatus {
-			accepted++
-		} else if vote.Value == RejectedStatus {
-			rejected++
-		}
-	}
+// VotingClosed returns true if the BHAP's voting deadline has passed.
+func VotingClosed(b BHAP, now time.Time) bool {
+	return !b.VotingDeadline.IsZero() && !now.Before(b.VotingDeadline)
+}
 
-	userCnt, err := datastore.NewQuery(UserEntityName).Count(ctx)
+// CloseVote finalizes a BHAP whose voting deadline has passed. If enough
+// members voted to meet the quorum, the BHAP is decided by the voting rule
+// for its type. Otherwise, the BHAP is rejected.
+func CloseVote(ctx context.Context, s Store, bhapKey Key, forBHAP BHAP) error {
+	t, err := CountVotes(ctx, s, bhapKey, forBHAP)
 	if err != nil {
-		return fmt.Errorf("counting users: %v", err)
+		return err
 	}
 
-	nonAuthorCnt := userCnt - 1
-
-	if accepted+rejected == nonAuthorCnt {
-		if accepted > nonAuthorCnt/2 {
-			forBHAP.Status = AcceptedStatus
-			log.Infof(ctx, "marked BHAP %v as accepted", forBHAP.ID)
-		} else if rejected > nonAuthorCnt/2 {
-			forBHAP.Status = RejectedStatus
-			log.Infof(ctx, "marked BHAP %v as rejected", forBHAP.ID)
-		}
+	rule := RuleFor(forBHAP.Type)
+	decision := RejectedStatus
+	if !rule.QuorumMet(t) {
+		log.Infof(ctx, "BHAP %v did not reach quorum with %v of %v votes",
+			forBHAP.ID, t.Cast(), t.Eligible)
+	} else if rule.Passes(t) {
+		decision = AcceptedStatus
 	}
 
-	if _, err := datastore.Put(ctx, bhapKey, &forBHAP); err != nil {
-		return fmt.Errorf("saving BHAP: %v", err)
+	if _, err := ChangeStatus(ctx, s, bhapKey, forBHAP, decision, SystemActor); err != nil {
+		return fmt.Errorf("closing vote: %v", err)
 	}
 
 	return nil
@@ -0,0 +1,170 @@
+package bhap_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/house-emoji/bhap"
+	"github.com/house-emoji/bhap/memstore"
+)
+
+// votingBHAP saves a House Rule in discussion that the given members may
+// vote on, casts the given votes on it, and returns its key.
+func votingBHAP(t *testing.T, s bhap.Store, author bhap.Key, voters []bhap.Key, votes map[bhap.Key]bhap.Status) (bhap.BHAP, bhap.Key) {
+	t.Helper()
+	ctx := context.Background()
+
+	b := bhap.BHAP{
+		ID:             100,
+		Author:         author,
+		Voters:         voters,
+		VotersDate:     time.Now(),
+		VotingDeadline: time.Now().AddDate(0, 0, 7),
+		Revision:       1,
+	}
+	key := newTestBHAP(t, s, b)
+	for voter, value := range votes {
+		if err := s.SetVoteForBHAP(ctx, key, voter, value, 1); err != nil {
+			t.Fatalf("voting: %v", err)
+		}
+	}
+
+	b, err := s.ByKey(ctx, key)
+	if err != nil {
+		t.Fatalf("loading BHAP: %v", err)
+	}
+	return b, key
+}
+
+func TestCheckVotes(t *testing.T) {
+	defer bhap.SetSettings(bhap.CurrentSettings())
+	bhap.SetSettings(bhap.DefaultSettings())
+
+	accept, reject, abstain := bhap.AcceptedStatus, bhap.RejectedStatus, bhap.AbstainVote
+	tests := []struct {
+		name  string
+		votes []bhap.Status
+		// outsider is a vote from a member who isn't eligible
+		outsider bhap.Status
+		want     bhap.Status
+	}{
+		{"waiting for votes", []bhap.Status{accept}, "", bhap.DiscussionStatus},
+		{"waiting for the last vote", []bhap.Status{accept, accept}, "", bhap.DiscussionStatus},
+		{"everyone accepts", []bhap.Status{accept, accept, accept}, "", bhap.AcceptedStatus},
+		{"majority rejects", []bhap.Status{accept, reject, reject}, "", bhap.RejectedStatus},
+		{"abstentions count as voting", []bhap.Status{accept, abstain, abstain}, "", bhap.AcceptedStatus},
+		{"everyone abstains", []bhap.Status{abstain, abstain, abstain}, "", bhap.RejectedStatus},
+		{"ineligible votes don't count", []bhap.Status{accept, accept}, accept, bhap.DiscussionStatus},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := memstore.New()
+			author := newTestUser(t, s, "author@example.com")
+			voters := []bhap.Key{
+				newTestUser(t, s, "alice@example.com"),
+				newTestUser(t, s, "bob@example.com"),
+				newTestUser(t, s, "carol@example.com"),
+			}
+			outsider := newTestUser(t, s, "dave@example.com")
+
+			votes := make(map[bhap.Key]bhap.Status)
+			for i, value := range test.votes {
+				votes[voters[i]] = value
+			}
+			if test.outsider != "" {
+				votes[outsider] = test.outsider
+			}
+			b, key := votingBHAP(t, s, author, voters, votes)
+
+			if err := bhap.CheckVotes(ctx, s, key, b); err != nil {
+				t.Fatalf("checking votes: %v", err)
+			}
+
+			got, err := s.ByKey(ctx, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != test.want {
+				t.Errorf("got status %v, want %v", got.Status, test.want)
+			}
+		})
+	}
+}
+
+func TestCloseVote(t *testing.T) {
+	defer bhap.SetSettings(bhap.CurrentSettings())
+	bhap.SetSettings(bhap.DefaultSettings())
+
+	accept, reject, abstain := bhap.AcceptedStatus, bhap.RejectedStatus, bhap.AbstainVote
+	// Four members may vote, and House Rules need half of them to
+	tests := []struct {
+		name  string
+		votes []bhap.Status
+		want  bhap.Status
+	}{
+		{"no votes", nil, bhap.RejectedStatus},
+		{"below quorum", []bhap.Status{accept}, bhap.RejectedStatus},
+		{"quorum reached by abstaining", []bhap.Status{accept, abstain}, bhap.AcceptedStatus},
+		{"quorum of abstentions", []bhap.Status{abstain, abstain}, bhap.RejectedStatus},
+		{"quorum, tied", []bhap.Status{accept, reject}, bhap.RejectedStatus},
+		{"quorum, majority accepts", []bhap.Status{accept, accept, reject}, bhap.AcceptedStatus},
+		{"quorum, majority rejects", []bhap.Status{accept, reject, reject}, bhap.RejectedStatus},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := memstore.New()
+			author := newTestUser(t, s, "author@example.com")
+			var voters []bhap.Key
+			for _, email := range []string{"alice@example.com", "bob@example.com",
+				"carol@example.com", "dave@example.com"} {
+				voters = append(voters, newTestUser(t, s, email))
+			}
+
+			votes := make(map[bhap.Key]bhap.Status)
+			for i, value := range test.votes {
+				votes[voters[i]] = value
+			}
+			b, key := votingBHAP(t, s, author, voters, votes)
+
+			if err := bhap.CloseVote(ctx, s, key, b); err != nil {
+				t.Fatalf("closing vote: %v", err)
+			}
+
+			got, err := s.ByKey(ctx, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != test.want {
+				t.Errorf("got status %v, want %v", got.Status, test.want)
+			}
+			if got.DecidedDate.IsZero() {
+				t.Errorf("closed vote wasn't dated")
+			}
+		})
+	}
+}
+
+func TestVotingClosed(t *testing.T) {
+	deadline := time.Date(2018, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		deadline time.Time
+		now      time.Time
+		want     bool
+	}{
+		{"no deadline", time.Time{}, deadline, false},
+		{"before the deadline", deadline, deadline.Add(-time.Second), false},
+		{"at the deadline", deadline, deadline, true},
+		{"after the deadline", deadline, deadline.AddDate(0, 0, 1), true},
+	}
+	for _, test := range tests {
+		b := bhap.BHAP{VotingDeadline: test.deadline}
+		if got := bhap.VotingClosed(b, test.now); got != test.want {
+			t.Errorf("%v: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}